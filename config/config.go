@@ -0,0 +1,347 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/util"
+)
+
+// Config holds the service configuration, loaded from environment variables
+type Config struct {
+	DatabaseURL string
+	// DatabaseReplicaURLs are the read replicas serving the lookups of users,
+	// a user written lately is read from the primary for DatabaseReplicaStickyWindow
+	DatabaseReplicaURLs         []string
+	DatabaseReplicaStickyWindow time.Duration
+	// UserRepository is the implementation of the user repository, pgx or the
+	// gorm one it replaces
+	UserRepository string
+	// UserCacheSize is how many users the lookups of users cache, 0 disables
+	// the cache, and UserCacheTTL how long each is cached
+	UserCacheSize int
+	UserCacheTTL  time.Duration
+	// DatabaseMaxConns bounds the connections of each pool, DatabaseMinConns
+	// are kept open by the pgx pool and DatabaseMaxIdleConns by the gorm one
+	DatabaseMaxConns     int
+	DatabaseMinConns     int
+	DatabaseMaxIdleConns int
+	// DatabaseConnMaxLifetime is how long a connection is used before it is replaced
+	DatabaseConnMaxLifetime time.Duration
+	// DatabaseConnectTimeout is how long startup retries connecting to the database
+	DatabaseConnectTimeout time.Duration
+	DeletionGracePeriod    time.Duration
+	PurgeInterval          time.Duration
+	// TOTPIssuer names the service in authenticator apps
+	TOTPIssuer string
+	// WebAuthn relying party, the id is the domain passkeys are bound to and
+	// the origins are the web origins allowed to use them
+	WebAuthnRPID      string
+	WebAuthnRPName    string
+	WebAuthnRPOrigins []string
+	// OIDCIssuer is the public base url of the OpenID Connect provider
+	OIDCIssuer string
+	// OIDCSigningKeyFile is the PEM file of the RSA key signing OpenID Connect
+	// tokens, it is required unless OIDCDevSigningKey allows a temporary key
+	OIDCSigningKeyFile string
+	// OIDCDevSigningKey generates a temporary signing key when there is no key
+	// file, tokens then stop verifying after a restart so it is only for development
+	OIDCDevSigningKey bool
+	// OIDCLoginURL is the first party login page of browsers reaching the
+	// authorization endpoint without a session
+	OIDCLoginURL string
+	// IdentityProviders are the external identity providers users sign in with
+	IdentityProviders []IdentityProviderConfig
+	// TrustedProxies are the proxies whose X-Forwarded-For names the client ip
+	// address, without them the address of the peer is the client one
+	TrustedProxies []*net.IPNet
+	// RateLimitStore keeps the rate limit buckets, memory limits every replica
+	// on its own while postgres shares the limits between replicas
+	RateLimitStore string
+	// PasswordHashConcurrency is how many password hashes run at once, each
+	// takes about 32MB of memory, and PasswordHashQueueTimeout is how long a
+	// request waits for a free slot before it is rejected with 503
+	PasswordHashConcurrency  int
+	PasswordHashQueueTimeout time.Duration
+	// PasswordPolicy are the character rules of new passwords
+	PasswordPolicy util.PasswordPolicy
+	// PasswordMaxAge is how long a password is valid before the user is asked
+	// to change it at login, 0 never expires passwords
+	PasswordMaxAge time.Duration
+	// PasswordMinStrength is the lowest strength score new passwords need, from
+	// 0 too guessable to 4 very unguessable
+	PasswordMinStrength int
+	// PasswordBreachFile is the sorted SHA-1 hash file of breached passwords,
+	// the breach check is skipped when it is empty
+	PasswordBreachFile string
+	// DefaultLanguage is the language of messages for clients whose
+	// Accept-Language names none of the supported en and id
+	DefaultLanguage string
+}
+
+// IdentityProviderConfig is an external OpenID Connect provider, it is
+// configured with IDENTITY_PROVIDER_<NAME>_* variables
+type IdentityProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the client page the provider redirects back to
+	RedirectURL string
+}
+
+// Load reads the configuration from environment variables, missing values fall back to the defaults
+func Load() (*Config, error) {
+	cfg := &Config{
+		DatabaseURL:         os.Getenv("DATABASE_URL"),
+		DatabaseReplicaURLs: getList("DATABASE_REPLICA_URLS"),
+		UserRepository:      getString("USER_REPOSITORY", "pgx"),
+		TOTPIssuer:          getString("TOTP_ISSUER", "SawitPro"),
+		WebAuthnRPID:        getString("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:      getString("WEBAUTHN_RP_NAME", "SawitPro"),
+		WebAuthnRPOrigins:   strings.Split(getString("WEBAUTHN_RP_ORIGINS", "http://localhost:1323"), ","),
+		OIDCIssuer:          getString("OIDC_ISSUER", "http://localhost:1323"),
+		OIDCSigningKeyFile:  os.Getenv("OIDC_SIGNING_KEY_FILE"),
+		OIDCLoginURL:        os.Getenv("OIDC_LOGIN_URL"),
+		RateLimitStore:      getString("RATE_LIMIT_STORE", "memory"),
+		PasswordBreachFile:  os.Getenv("PASSWORD_BREACH_FILE"),
+		DefaultLanguage:     getString("DEFAULT_LANGUAGE", "en"),
+	}
+	if cfg.RateLimitStore != "memory" && cfg.RateLimitStore != "postgres" {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_STORE: %s, it must be memory or postgres", cfg.RateLimitStore)
+	}
+	if cfg.UserRepository != "pgx" && cfg.UserRepository != "gorm" {
+		return nil, fmt.Errorf("invalid USER_REPOSITORY: %s, it must be pgx or gorm", cfg.UserRepository)
+	}
+	if cfg.DefaultLanguage != "en" && cfg.DefaultLanguage != "id" {
+		return nil, fmt.Errorf("invalid DEFAULT_LANGUAGE: %s, it must be en or id", cfg.DefaultLanguage)
+	}
+
+	var err error
+	if cfg.DatabaseMaxConns, err = getInt("DATABASE_MAX_CONNS", 10); err != nil {
+		return nil, err
+	}
+	if cfg.DatabaseMinConns, err = getInt("DATABASE_MIN_CONNS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DatabaseMaxIdleConns, err = getInt("DATABASE_MAX_IDLE_CONNS", 2); err != nil {
+		return nil, err
+	}
+	if cfg.DatabaseMaxConns < 1 || cfg.DatabaseMinConns < 0 || cfg.DatabaseMinConns > cfg.DatabaseMaxConns {
+		return nil, fmt.Errorf("invalid DATABASE_MIN_CONNS %d and DATABASE_MAX_CONNS %d, the pool needs between 0 and at least 1 connections", cfg.DatabaseMinConns, cfg.DatabaseMaxConns)
+	}
+	if cfg.DatabaseMaxIdleConns < 0 || cfg.DatabaseMaxIdleConns > cfg.DatabaseMaxConns {
+		return nil, fmt.Errorf("invalid DATABASE_MAX_IDLE_CONNS: %d, it must be between 0 and DATABASE_MAX_CONNS", cfg.DatabaseMaxIdleConns)
+	}
+	if cfg.DatabaseConnMaxLifetime, err = getDuration("DATABASE_CONN_MAX_LIFETIME", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.DatabaseReplicaStickyWindow, err = getDuration("DATABASE_REPLICA_STICKY_WINDOW", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.UserCacheSize, err = getInt("USER_CACHE_SIZE", 10000); err != nil {
+		return nil, err
+	}
+	if cfg.UserCacheSize < 0 {
+		return nil, fmt.Errorf("invalid USER_CACHE_SIZE: %d, it can not be negative", cfg.UserCacheSize)
+	}
+	if cfg.UserCacheTTL, err = getDuration("USER_CACHE_TTL", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.UserCacheTTL <= 0 {
+		return nil, fmt.Errorf("invalid USER_CACHE_TTL: %s, it must be positive", cfg.UserCacheTTL)
+	}
+	if cfg.DatabaseConnectTimeout, err = getDuration("DATABASE_CONNECT_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.DeletionGracePeriod, err = getDuration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.PurgeInterval, err = getDuration("ACCOUNT_PURGE_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordHashConcurrency, err = getInt("PASSWORD_HASH_CONCURRENCY", 4); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordHashConcurrency < 1 {
+		return nil, fmt.Errorf("invalid PASSWORD_HASH_CONCURRENCY: %d, it must be at least 1", cfg.PasswordHashConcurrency)
+	}
+	if cfg.PasswordHashQueueTimeout, err = getDuration("PASSWORD_HASH_QUEUE_TIMEOUT", 2*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordPolicy, err = getPasswordPolicy(); err != nil {
+		return nil, err
+	}
+	if cfg.OIDCDevSigningKey, err = getBool("OIDC_DEV_SIGNING_KEY", false); err != nil {
+		return nil, err
+	}
+	if cfg.OIDCLoginURL != "" {
+		if loginURL, err := url.Parse(cfg.OIDCLoginURL); err != nil || !loginURL.IsAbs() {
+			return nil, fmt.Errorf("invalid OIDC_LOGIN_URL: %s, it must be an absolute url", cfg.OIDCLoginURL)
+		}
+	}
+	if cfg.PasswordMaxAge, err = getDuration("PASSWORD_MAX_AGE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordMinStrength, err = getInt("PASSWORD_MIN_STRENGTH", 2); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordMinStrength < 0 || cfg.PasswordMinStrength > 4 {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_STRENGTH: %d, it must be between 0 and 4", cfg.PasswordMinStrength)
+	}
+	if cfg.IdentityProviders, err = getIdentityProviders(); err != nil {
+		return nil, err
+	}
+	if cfg.TrustedProxies, err = getNetworks("TRUSTED_PROXIES"); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// getIdentityProviders reads the providers named in the comma separated IDENTITY_PROVIDERS
+func getIdentityProviders() ([]IdentityProviderConfig, error) {
+	var providers []IdentityProviderConfig
+	for _, name := range strings.Split(os.Getenv("IDENTITY_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "IDENTITY_PROVIDER_" + strings.ToUpper(name) + "_"
+		provider := IdentityProviderConfig{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return nil, fmt.Errorf("identity provider %s needs %sISSUER, %sCLIENT_ID and %sREDIRECT_URL", name, prefix, prefix, prefix)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// getPasswordPolicy reads the PASSWORD_* policy variables on top of the default policy
+func getPasswordPolicy() (util.PasswordPolicy, error) {
+	policy := util.DefaultPasswordPolicy()
+	var err error
+	if policy.MinLength, err = getInt("PASSWORD_MIN_LENGTH", policy.MinLength); err != nil {
+		return policy, err
+	}
+	if policy.MaxLength, err = getInt("PASSWORD_MAX_LENGTH", policy.MaxLength); err != nil {
+		return policy, err
+	}
+	if policy.MaxRepeated, err = getInt("PASSWORD_MAX_REPEATED", policy.MaxRepeated); err != nil {
+		return policy, err
+	}
+	if policy.History, err = getInt("PASSWORD_HISTORY", policy.History); err != nil {
+		return policy, err
+	}
+	policy.Symbols = getString("PASSWORD_SYMBOLS", policy.Symbols)
+	if require, ok := os.LookupEnv("PASSWORD_REQUIRE"); ok && require != "" {
+		policy.Require = nil
+		for _, class := range strings.Split(require, ",") {
+			switch class = strings.TrimSpace(class); class {
+			case "none":
+			case util.PasswordUpper, util.PasswordLower, util.PasswordNumber, util.PasswordSymbol:
+				policy.Require = append(policy.Require, class)
+			default:
+				return policy, fmt.Errorf("invalid PASSWORD_REQUIRE: %s, it must be a list of upper, lower, number and symbol or none", require)
+			}
+		}
+	}
+
+	if policy.MinLength < 1 || policy.MaxLength < policy.MinLength {
+		return policy, fmt.Errorf("invalid PASSWORD_MIN_LENGTH %d and PASSWORD_MAX_LENGTH %d", policy.MinLength, policy.MaxLength)
+	}
+	if policy.MaxRepeated < 0 || policy.History < 0 {
+		return policy, fmt.Errorf("invalid PASSWORD_MAX_REPEATED %d and PASSWORD_HISTORY %d, they can not be negative", policy.MaxRepeated, policy.History)
+	}
+	return policy, nil
+}
+
+// getString reads the environment variable key, falling back to the default when it is empty
+func getString(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getList splits the comma separated environment variable key, leaving out empty items
+func getList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getNetworks parses the comma separated ip addresses and CIDR ranges of the
+// environment variable key, an address is a range of its own
+func getNetworks(key string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range getList(key) {
+		if ip := net.ParseIP(item); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// getDuration parses a duration such as "720h" from the environment variable key
+func getDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return duration, nil
+}
+
+// getInt parses an integer from the environment variable key
+func getInt(key string, defaultValue int) (int, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return number, nil
+}
+
+// getBool parses a boolean such as "true" or "1" from the environment variable key
+func getBool(key string, defaultValue bool) (bool, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,323 @@
+package config
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name: "Default Config",
+			env:  map[string]string{},
+			want: &Config{
+				UserRepository:              "pgx",
+				UserCacheSize:               10000,
+				UserCacheTTL:                10 * time.Second,
+				DatabaseMaxConns:            10,
+				DatabaseMaxIdleConns:        2,
+				DatabaseConnMaxLifetime:     time.Hour,
+				DatabaseConnectTimeout:      30 * time.Second,
+				DatabaseReplicaStickyWindow: 5 * time.Second,
+				DeletionGracePeriod:         30 * 24 * time.Hour,
+				PurgeInterval:               time.Hour,
+				TOTPIssuer:                  "SawitPro",
+				WebAuthnRPID:                "localhost",
+				WebAuthnRPName:              "SawitPro",
+				WebAuthnRPOrigins:           []string{"http://localhost:1323"},
+				OIDCIssuer:                  "http://localhost:1323",
+				RateLimitStore:              "memory",
+				PasswordHashConcurrency:     4,
+				PasswordHashQueueTimeout:    2 * time.Second,
+				PasswordPolicy:              util.DefaultPasswordPolicy(),
+				PasswordMinStrength:         2,
+				DefaultLanguage:             "en",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Config From Environment",
+			env: map[string]string{
+				"DATABASE_URL":                           "postgres://localhost:5432/database",
+				"DATABASE_REPLICA_URLS":                  "postgres://replica-1:5432/database, postgres://replica-2:5432/database",
+				"DATABASE_REPLICA_STICKY_WINDOW":         "2s",
+				"USER_REPOSITORY":                        "gorm",
+				"USER_CACHE_SIZE":                        "0",
+				"USER_CACHE_TTL":                         "1m",
+				"DATABASE_MAX_CONNS":                     "20",
+				"DATABASE_MIN_CONNS":                     "2",
+				"DATABASE_MAX_IDLE_CONNS":                "5",
+				"DATABASE_CONN_MAX_LIFETIME":             "30m",
+				"DATABASE_CONNECT_TIMEOUT":               "1m",
+				"ACCOUNT_DELETION_GRACE_PERIOD":          "168h",
+				"ACCOUNT_PURGE_INTERVAL":                 "15m",
+				"TOTP_ISSUER":                            "SawitPro Staging",
+				"WEBAUTHN_RP_ID":                         "staging.sawitpro.com",
+				"WEBAUTHN_RP_NAME":                       "SawitPro Staging",
+				"WEBAUTHN_RP_ORIGINS":                    "https://staging.sawitpro.com,android:apk-key-hash:abc",
+				"OIDC_ISSUER":                            "https://staging.sawitpro.com",
+				"OIDC_SIGNING_KEY_FILE":                  "/run/secrets/oidc.pem",
+				"OIDC_DEV_SIGNING_KEY":                   "true",
+				"OIDC_LOGIN_URL":                         "https://app.sawitpro.com/login",
+				"IDENTITY_PROVIDERS":                     "google",
+				"IDENTITY_PROVIDER_GOOGLE_ISSUER":        "https://accounts.google.com",
+				"IDENTITY_PROVIDER_GOOGLE_CLIENT_ID":     "client",
+				"IDENTITY_PROVIDER_GOOGLE_CLIENT_SECRET": "secret",
+				"IDENTITY_PROVIDER_GOOGLE_REDIRECT_URL":  "https://staging.sawitpro.com/callback",
+				"TRUSTED_PROXIES":                        "10.0.0.0/8, 192.168.1.10",
+				"RATE_LIMIT_STORE":                       "postgres",
+				"PASSWORD_HASH_CONCURRENCY":              "8",
+				"PASSWORD_HASH_QUEUE_TIMEOUT":            "500ms",
+				"PASSWORD_MIN_LENGTH":                    "12",
+				"PASSWORD_MAX_LENGTH":                    "128",
+				"PASSWORD_REQUIRE":                       "upper, lower",
+				"PASSWORD_SYMBOLS":                       "#-_",
+				"PASSWORD_MAX_REPEATED":                  "2",
+				"PASSWORD_HISTORY":                       "5",
+				"PASSWORD_MAX_AGE":                       "2160h",
+				"PASSWORD_MIN_STRENGTH":                  "3",
+				"PASSWORD_BREACH_FILE":                   "/data/pwned-passwords.txt",
+				"DEFAULT_LANGUAGE":                       "id",
+			},
+			want: &Config{
+				DatabaseURL:                 "postgres://localhost:5432/database",
+				DatabaseReplicaURLs:         []string{"postgres://replica-1:5432/database", "postgres://replica-2:5432/database"},
+				DatabaseReplicaStickyWindow: 2 * time.Second,
+				UserRepository:              "gorm",
+				UserCacheTTL:                time.Minute,
+				DatabaseMaxConns:            20,
+				DatabaseMinConns:            2,
+				DatabaseMaxIdleConns:        5,
+				DatabaseConnMaxLifetime:     30 * time.Minute,
+				DatabaseConnectTimeout:      time.Minute,
+				DeletionGracePeriod:         7 * 24 * time.Hour,
+				PurgeInterval:               15 * time.Minute,
+				TOTPIssuer:                  "SawitPro Staging",
+				WebAuthnRPID:                "staging.sawitpro.com",
+				WebAuthnRPName:              "SawitPro Staging",
+				WebAuthnRPOrigins:           []string{"https://staging.sawitpro.com", "android:apk-key-hash:abc"},
+				OIDCIssuer:                  "https://staging.sawitpro.com",
+				OIDCSigningKeyFile:          "/run/secrets/oidc.pem",
+				OIDCDevSigningKey:           true,
+				OIDCLoginURL:                "https://app.sawitpro.com/login",
+				IdentityProviders: []IdentityProviderConfig{{
+					Name:         "google",
+					Issuer:       "https://accounts.google.com",
+					ClientID:     "client",
+					ClientSecret: "secret",
+					RedirectURL:  "https://staging.sawitpro.com/callback",
+				}},
+				TrustedProxies: []*net.IPNet{
+					{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+					{IP: net.IPv4(192, 168, 1, 10).To4(), Mask: net.CIDRMask(32, 32)},
+				},
+				RateLimitStore:           "postgres",
+				PasswordHashConcurrency:  8,
+				PasswordHashQueueTimeout: 500 * time.Millisecond,
+				PasswordPolicy: util.PasswordPolicy{
+					MinLength:   12,
+					MaxLength:   128,
+					Require:     []string{util.PasswordUpper, util.PasswordLower},
+					Symbols:     "#-_",
+					MaxRepeated: 2,
+					History:     5,
+				},
+				PasswordMaxAge:      90 * 24 * time.Hour,
+				PasswordMinStrength: 3,
+				PasswordBreachFile:  "/data/pwned-passwords.txt",
+				DefaultLanguage:     "id",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Not Valid Duration",
+			env: map[string]string{
+				"ACCOUNT_DELETION_GRACE_PERIOD": "30 days",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid Dev Signing Key",
+			env: map[string]string{
+				"OIDC_DEV_SIGNING_KEY": "sometimes",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid Trusted Proxies",
+			env: map[string]string{
+				"TRUSTED_PROXIES": "10.0.0.0/33",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid Rate Limit Store",
+			env: map[string]string{
+				"RATE_LIMIT_STORE": "redis",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid User Repository",
+			env: map[string]string{
+				"USER_REPOSITORY": "mongo",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid User Cache Size",
+			env: map[string]string{
+				"USER_CACHE_SIZE": "-1",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid OIDC Login URL",
+			env: map[string]string{
+				"OIDC_LOGIN_URL": "/login",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid User Cache TTL",
+			env: map[string]string{
+				"USER_CACHE_TTL": "0s",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid Database Pool Size",
+			env: map[string]string{
+				"DATABASE_MAX_CONNS": "2",
+				"DATABASE_MIN_CONNS": "4",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid Database Max Idle Conns",
+			env: map[string]string{
+				"DATABASE_MAX_IDLE_CONNS": "20",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid Password Hash Concurrency",
+			env: map[string]string{
+				"PASSWORD_HASH_CONCURRENCY": "0",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid Password Length",
+			env: map[string]string{
+				"PASSWORD_MIN_LENGTH": "80",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid Password Character Class",
+			env: map[string]string{
+				"PASSWORD_REQUIRE": "upper,emoji",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid Default Language",
+			env: map[string]string{
+				"DEFAULT_LANGUAGE": "fr",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Not Valid Password Min Strength",
+			env: map[string]string{
+				"PASSWORD_MIN_STRENGTH": "5",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Identity Provider Without Issuer",
+			env: map[string]string{
+				"IDENTITY_PROVIDERS":                   "apple",
+				"IDENTITY_PROVIDER_APPLE_CLIENT_ID":    "client",
+				"IDENTITY_PROVIDER_APPLE_REDIRECT_URL": "https://staging.sawitpro.com/callback",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DATABASE_URL", "")
+			t.Setenv("DATABASE_REPLICA_URLS", "")
+			t.Setenv("DATABASE_REPLICA_STICKY_WINDOW", "")
+			t.Setenv("USER_REPOSITORY", "")
+			t.Setenv("USER_CACHE_SIZE", "")
+			t.Setenv("USER_CACHE_TTL", "")
+			t.Setenv("DATABASE_MAX_CONNS", "")
+			t.Setenv("DATABASE_MIN_CONNS", "")
+			t.Setenv("DATABASE_MAX_IDLE_CONNS", "")
+			t.Setenv("DATABASE_CONN_MAX_LIFETIME", "")
+			t.Setenv("DATABASE_CONNECT_TIMEOUT", "")
+			t.Setenv("ACCOUNT_DELETION_GRACE_PERIOD", "")
+			t.Setenv("ACCOUNT_PURGE_INTERVAL", "")
+			t.Setenv("TOTP_ISSUER", "")
+			t.Setenv("WEBAUTHN_RP_ID", "")
+			t.Setenv("WEBAUTHN_RP_NAME", "")
+			t.Setenv("WEBAUTHN_RP_ORIGINS", "")
+			t.Setenv("OIDC_ISSUER", "")
+			t.Setenv("OIDC_SIGNING_KEY_FILE", "")
+			t.Setenv("OIDC_DEV_SIGNING_KEY", "")
+			t.Setenv("OIDC_LOGIN_URL", "")
+			t.Setenv("IDENTITY_PROVIDERS", "")
+			t.Setenv("TRUSTED_PROXIES", "")
+			t.Setenv("RATE_LIMIT_STORE", "")
+			t.Setenv("PASSWORD_HASH_CONCURRENCY", "")
+			t.Setenv("PASSWORD_HASH_QUEUE_TIMEOUT", "")
+			t.Setenv("PASSWORD_MIN_LENGTH", "")
+			t.Setenv("PASSWORD_MAX_LENGTH", "")
+			t.Setenv("PASSWORD_REQUIRE", "")
+			t.Setenv("PASSWORD_SYMBOLS", "")
+			t.Setenv("PASSWORD_MAX_REPEATED", "")
+			t.Setenv("PASSWORD_HISTORY", "")
+			t.Setenv("PASSWORD_MAX_AGE", "")
+			t.Setenv("PASSWORD_MIN_STRENGTH", "")
+			t.Setenv("PASSWORD_BREACH_FILE", "")
+			t.Setenv("DEFAULT_LANGUAGE", "")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			got, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -10,9 +10,176 @@
 /** This is test table. Remove this table and replace with your own tables. */
 CREATE TABLE users (
   id serial PRIMARY KEY,
-  phone_number VARCHAR ( 15 ) UNIQUE NOT NULL,
+  phone_number VARCHAR ( 15 ) NOT NULL,
   fullname VARCHAR ( 60 ) NOT NULL,
   password VARCHAR ( 64 ) NOT NULL,
   salt_token VARCHAR ( 100 ) NOT NULL,
+  role VARCHAR ( 16 ) NOT NULL DEFAULT 'user',
+  status VARCHAR ( 16 ) NOT NULL DEFAULT 'active',
+  password_reset_required BOOLEAN NOT NULL DEFAULT false,
+  password_changed_at timestamp NULL,
+  created_at timestamp default current_timestamp NOT NULL,
+  updated_at timestamp default current_timestamp NOT NULL,
+  deleted_at timestamp NULL,
+  purge_at timestamp NULL,
+  tokens_revoked_at timestamp NULL,
+  totp_secret VARCHAR ( 32 ) NOT NULL DEFAULT '',
+  totp_enabled BOOLEAN NOT NULL DEFAULT false,
+  totp_last_step BIGINT NOT NULL DEFAULT 0
+);
+
+/** phone number only has to be unique among active users, so a deleted phone number can be registered again */
+CREATE UNIQUE INDEX users_phone_number_active_key ON users ( phone_number ) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_deleted_at ON users ( deleted_at );
+/** keyset pagination of the user listing, ordered by created_at then id */
+CREATE INDEX users_created_at_id_idx ON users ( created_at, id ) WHERE deleted_at IS NULL;
+/** prefix filters of the user listing, pattern ops let LIKE 'prefix%' use the index under any collation */
+CREATE INDEX users_phone_number_pattern_idx ON users ( phone_number varchar_pattern_ops ) WHERE deleted_at IS NULL;
+CREATE INDEX users_fullname_lower_pattern_idx ON users ( lower(fullname) text_pattern_ops ) WHERE deleted_at IS NULL;
+/** lets the purge worker find accounts whose deletion grace period is over */
+CREATE INDEX users_purge_at_idx ON users ( purge_at ) WHERE purge_at IS NOT NULL;
+
+CREATE TABLE audit_events (
+  id serial PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users ( id ),
+  event_type VARCHAR ( 32 ) NOT NULL,
+  ip_address VARCHAR ( 45 ) NOT NULL,
+  user_agent VARCHAR ( 255 ) NOT NULL,
+  created_at timestamp default current_timestamp NOT NULL
+);
+
+/** serves both the per user listing and the keyset paging of the data export */
+CREATE INDEX audit_events_user_id_id_idx ON audit_events ( user_id, id );
+
+CREATE TABLE sessions (
+  id VARCHAR ( 32 ) PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users ( id ),
+  user_agent VARCHAR ( 255 ) NOT NULL,
+  ip_address VARCHAR ( 45 ) NOT NULL,
+  device_label VARCHAR ( 60 ) NOT NULL,
+  created_at timestamp default current_timestamp NOT NULL,
+  last_seen_at timestamp NOT NULL,
+  expires_at timestamp NOT NULL,
+  revoked_at timestamp NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions ( user_id );
+
+/** one-time codes replacing the TOTP code when the authenticator app is lost, only the sha256 of the code is stored */
+CREATE TABLE recovery_codes (
+  id serial PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users ( id ),
+  code_hash VARCHAR ( 64 ) NOT NULL,
+  created_at timestamp default current_timestamp NOT NULL,
+  used_at timestamp NULL
+);
+
+CREATE INDEX recovery_codes_user_id_idx ON recovery_codes ( user_id );
+
+/** passkeys, a user can register one per authenticator */
+CREATE TABLE webauthn_credentials (
+  id serial PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users ( id ),
+  credential_id BYTEA NOT NULL,
+  public_key BYTEA NOT NULL,
+  attestation_type VARCHAR ( 32 ) NOT NULL,
+  aaguid BYTEA NULL,
+  sign_count BIGINT NOT NULL DEFAULT 0,
+  transports VARCHAR ( 100 ) NOT NULL DEFAULT '',
+  backup_eligible BOOLEAN NOT NULL DEFAULT false,
+  backup_state BOOLEAN NOT NULL DEFAULT false,
+  name VARCHAR ( 60 ) NOT NULL,
+  created_at timestamp default current_timestamp NOT NULL,
+  last_used_at timestamp NULL
+);
+
+CREATE UNIQUE INDEX webauthn_credentials_credential_id_key ON webauthn_credentials ( credential_id );
+CREATE INDEX webauthn_credentials_user_id_idx ON webauthn_credentials ( user_id );
+
+/** state of passkey ceremonies between their begin and finish requests, a row is deleted when the ceremony finishes */
+CREATE TABLE webauthn_ceremonies (
+  id VARCHAR ( 32 ) PRIMARY KEY,
+  user_id INTEGER NOT NULL,
+  kind VARCHAR ( 16 ) NOT NULL,
+  session_data TEXT NOT NULL,
+  expires_at timestamp NOT NULL
+);
+
+CREATE INDEX webauthn_ceremonies_expires_at_idx ON webauthn_ceremonies ( expires_at );
+
+/** applications signing users in through the OpenID Connect provider */
+CREATE TABLE oauth_clients (
+  id VARCHAR ( 32 ) PRIMARY KEY,
+  name VARCHAR ( 100 ) NOT NULL,
+  secret_hash VARCHAR ( 64 ) NOT NULL DEFAULT '',
+  redirect_uris TEXT NOT NULL,
+  first_party BOOLEAN NOT NULL DEFAULT false,
   created_at timestamp default current_timestamp NOT NULL
 );
+
+/** one-time authorization codes, a row is deleted when the code is exchanged */
+CREATE TABLE oauth_authorization_codes (
+  code_hash VARCHAR ( 64 ) PRIMARY KEY,
+  client_id VARCHAR ( 32 ) NOT NULL REFERENCES oauth_clients ( id ),
+  user_id INTEGER NOT NULL REFERENCES users ( id ),
+  redirect_uri TEXT NOT NULL,
+  scope VARCHAR ( 100 ) NOT NULL,
+  nonce VARCHAR ( 255 ) NOT NULL DEFAULT '',
+  code_challenge VARCHAR ( 128 ) NOT NULL,
+  auth_time timestamp NOT NULL,
+  expires_at timestamp NOT NULL
+);
+
+CREATE INDEX oauth_authorization_codes_expires_at_idx ON oauth_authorization_codes ( expires_at );
+
+/** scopes a user allowed a third party client to access */
+CREATE TABLE oauth_consents (
+  user_id INTEGER NOT NULL REFERENCES users ( id ),
+  client_id VARCHAR ( 32 ) NOT NULL REFERENCES oauth_clients ( id ),
+  scope VARCHAR ( 100 ) NOT NULL,
+  created_at timestamp default current_timestamp NOT NULL,
+  updated_at timestamp default current_timestamp NOT NULL,
+  PRIMARY KEY ( user_id, client_id )
+);
+
+/** accounts at external identity providers linked to users */
+CREATE TABLE user_identities (
+  id serial PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users ( id ),
+  provider VARCHAR ( 50 ) NOT NULL,
+  subject VARCHAR ( 255 ) NOT NULL,
+  created_at timestamp default current_timestamp NOT NULL
+);
+
+CREATE UNIQUE INDEX user_identities_provider_subject_idx ON user_identities ( provider, subject );
+CREATE INDEX user_identities_user_id_idx ON user_identities ( user_id );
+
+/** sign ins at external identity providers between their begin and finish requests */
+CREATE TABLE identity_login_states (
+  id VARCHAR ( 64 ) PRIMARY KEY,
+  provider VARCHAR ( 50 ) NOT NULL,
+  user_id INTEGER NOT NULL DEFAULT 0,
+  nonce VARCHAR ( 64 ) NOT NULL,
+  code_verifier VARCHAR ( 64 ) NOT NULL,
+  expires_at timestamp NOT NULL
+);
+
+CREATE INDEX identity_login_states_expires_at_idx ON identity_login_states ( expires_at );
+
+/** token buckets of the rate limiter, shared by every replica */
+CREATE TABLE rate_limit_buckets (
+  id VARCHAR ( 255 ) PRIMARY KEY,
+  tokens DOUBLE PRECISION NOT NULL,
+  refilled_at timestamp NOT NULL
+);
+
+/** the last passwords of every user, a new password must not be one of them */
+CREATE TABLE password_history (
+  id serial PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users ( id ),
+  password VARCHAR ( 64 ) NOT NULL,
+  salt_token VARCHAR ( 100 ) NOT NULL,
+  created_at timestamp default current_timestamp NOT NULL
+);
+
+CREATE INDEX password_history_user_id_id_idx ON password_history ( user_id, id );
@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/hashing"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// defaultPageSize is the page size when the limit is not given
+const defaultPageSize = 20
+
+// AdminHandler struct
+type AdminHandler struct {
+	UserRepo    repository.UserRepository
+	AuditRepo   repository.AuditRepository
+	SessionRepo repository.SessionRepository
+	// Hasher is the password hashing pool reported by PasswordHashingMetrics
+	Hasher *hashing.Pool
+	// UserCache is the cache of UserRepo reported by UserCacheMetrics
+	UserCache *repository.CachedUserRepository
+	// DatabasePools are the connection pools reported by DatabaseMetrics
+	DatabasePools []repository.ConnPool
+	// DeletionGracePeriod is how long a deleted account can still be restored before it is purged
+	DeletionGracePeriod time.Duration
+}
+
+// NewAdminHandler create new admin handler
+func NewAdminHandler(userRepo repository.UserRepository, sessionRepo repository.SessionRepository) *AdminHandler {
+	return &AdminHandler{UserRepo: userRepo, SessionRepo: sessionRepo}
+}
+
+// ListUsers handler for listing users page by page, optionally filtered by
+// name or phone prefix, status and creation time, the parameters are
+// validated against the spec before
+func (h *AdminHandler) ListUsers(c echo.Context, params generated.ListUsersParams) error {
+	listParams := models.UserListParams{
+		NamePrefix:  stringValue(params.Name),
+		PhonePrefix: stringValue(params.Phone),
+		CreatedFrom: params.CreatedFrom,
+		CreatedTo:   params.CreatedTo,
+		Cursor:      stringValue(params.Cursor),
+		Limit:       defaultPageSize,
+	}
+	if params.Status != nil {
+		listParams.Status = string(*params.Status)
+	}
+	if params.Limit != nil {
+		listParams.Limit = *params.Limit
+	}
+
+	page, err := h.UserRepo.List(listParams)
+	if err == repository.ErrInvalidCursor {
+		return problem(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	response := generated.AdminUserListResponse{
+		Users:           make([]generated.AdminUser, 0, len(page.Users)),
+		Total:           page.Total,
+		TotalIsEstimate: page.TotalIsEstimate,
+	}
+	for i := range page.Users {
+		response.Users = append(response.Users, toAdminUser(&page.Users[i]))
+	}
+	if page.NextCursor != "" {
+		response.NextCursor = &page.NextCursor
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetUser handler for getting a user by id
+func (h *AdminHandler) GetUser(c echo.Context, id int) error {
+	user, err := h.findUser(id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, toAdminUser(user))
+}
+
+// DisableUser handler for disabling a user, the user can no longer login and every token is revoked
+func (h *AdminHandler) DisableUser(c echo.Context, id int) error {
+	columns := []string{repository.ColumnStatus, repository.ColumnTokensRevokedAt}
+	return h.updateUser(c, id, models.EventAccountDisabled, true, columns, func(user *models.User) {
+		now := time.Now()
+		user.Status = models.StatusDisabled
+		user.TokensRevokedAt = &now
+	})
+}
+
+// EnableUser handler for enabling a disabled user
+func (h *AdminHandler) EnableUser(c echo.Context, id int) error {
+	columns := []string{repository.ColumnStatus}
+	return h.updateUser(c, id, models.EventAccountEnabled, false, columns, func(user *models.User) {
+		user.Status = models.StatusActive
+	})
+}
+
+// ForcePasswordReset handler for forcing a user to change the password, every
+// token is revoked and the next login asks for a password change
+func (h *AdminHandler) ForcePasswordReset(c echo.Context, id int) error {
+	columns := []string{repository.ColumnPasswordResetRequired, repository.ColumnTokensRevokedAt}
+	return h.updateUser(c, id, models.EventPasswordResetForced, true, columns, func(user *models.User) {
+		now := time.Now()
+		user.PasswordResetRequired = true
+		user.TokensRevokedAt = &now
+	})
+}
+
+// DeleteUser handler for deleting a user, the same way users delete their own account
+func (h *AdminHandler) DeleteUser(c echo.Context, id int) error {
+	user, err := h.findUserForUpdate(id)
+	if err != nil {
+		return err
+	}
+
+	purgeAt := time.Now().Add(h.DeletionGracePeriod)
+	err = h.UserRepo.ScheduleDeletion(user.ID, purgeAt)
+	if err != nil {
+		return err
+	}
+	err = h.SessionRepo.RevokeAllByUser(user.ID)
+	if err != nil {
+		return err
+	}
+	recordEvent(h.AuditRepo, c, user.ID, models.EventAccountDeleted)
+
+	return c.JSON(http.StatusAccepted, generated.DeleteProfileResponse{
+		PurgeAt: purgeAt,
+	})
+}
+
+// updateUser applies change to the user and saves the columns it changed,
+// revokeSessions logs the user out of every device
+func (h *AdminHandler) updateUser(c echo.Context, id int, eventType string, revokeSessions bool, columns []string, change func(user *models.User)) error {
+	user, err := h.findUserForUpdate(id)
+	if err != nil {
+		return err
+	}
+
+	change(user)
+	err = h.UserRepo.Update(user, columns...)
+	if err != nil {
+		return err
+	}
+	if revokeSessions {
+		err = h.SessionRepo.RevokeAllByUser(user.ID)
+		if err != nil {
+			return err
+		}
+	}
+	recordEvent(h.AuditRepo, c, user.ID, eventType)
+
+	return c.JSON(http.StatusOK, toAdminUser(user))
+}
+
+// findUser finds the user by id
+func (h *AdminHandler) findUser(id int) (*models.User, error) {
+	return userFound(h.UserRepo.FindByID(id))
+}
+
+// findUserForUpdate finds the user by id on the primary, for the users an admin changes
+func (h *AdminHandler) findUserForUpdate(id int) (*models.User, error) {
+	return userFound(h.UserRepo.FindByIDForUpdate(id))
+}
+
+// userFound maps a missing user to 404
+func userFound(user *models.User, err error) (*models.User, error) {
+	if err != nil {
+		if err.Error() == "record not found" {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "user not found")
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// PasswordHashingMetrics handler for the counters of the password hashing pool
+func (h *AdminHandler) PasswordHashingMetrics(c echo.Context) error {
+	if h.Hasher == nil {
+		return problem(c, http.StatusNotFound, CodeNotFound, "password hashing pool is not configured")
+	}
+	stats := h.Hasher.Stats()
+	return c.JSON(http.StatusOK, generated.PasswordHashingMetrics{
+		Concurrency: stats.Concurrency,
+		InFlight:    stats.InFlight,
+		QueueDepth:  stats.QueueDepth,
+		Acquired:    int64(stats.Acquired),
+		Rejected:    int64(stats.Rejected),
+		WaitTotalMs: milliseconds(stats.WaitTotal),
+		WaitMaxMs:   milliseconds(stats.WaitMax),
+	})
+}
+
+// UserCacheMetrics handler for the counters of the user cache
+func (h *AdminHandler) UserCacheMetrics(c echo.Context) error {
+	if h.UserCache == nil {
+		return problem(c, http.StatusNotFound, CodeNotFound, "user cache is disabled")
+	}
+	stats := h.UserCache.Stats()
+	return c.JSON(http.StatusOK, generated.UserCacheMetrics{
+		Capacity:   stats.Capacity,
+		Size:       stats.Size,
+		Hits:       int64(stats.Hits),
+		SharedHits: int64(stats.SharedHits),
+		Misses:     int64(stats.Misses),
+		Evictions:  int64(stats.Evictions),
+	})
+}
+
+// DatabaseMetrics handler for the stats of the database connection pools
+func (h *AdminHandler) DatabaseMetrics(c echo.Context) error {
+	response := generated.DatabaseMetrics{Pools: []generated.DatabasePool{}}
+	for _, pool := range h.DatabasePools {
+		response.Pools = append(response.Pools, generated.DatabasePool{Name: pool.Name(), Stats: toDatabasePoolStats(pool.Stats())})
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func toDatabasePoolStats(stats repository.PoolStats) generated.DatabasePoolStats {
+	return generated.DatabasePoolStats{
+		MaxOpen:        stats.MaxOpen,
+		Open:           stats.Open,
+		InUse:          stats.InUse,
+		Idle:           stats.Idle,
+		WaitCount:      stats.WaitCount,
+		WaitDurationMs: milliseconds(stats.WaitDuration),
+		ClosedIdle:     stats.ClosedIdle,
+		ClosedLifetime: stats.ClosedLifetime,
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// toAdminUser maps a user to its admin representation, credentials are never exposed
+func toAdminUser(user *models.User) generated.AdminUser {
+	return generated.AdminUser{
+		Id:                    user.ID,
+		Phone:                 user.PhoneNumber,
+		Fullname:              user.Fullname,
+		Role:                  generated.AdminUserRole(user.Role),
+		Status:                generated.AdminUserStatus(user.Status),
+		PasswordResetRequired: user.PasswordResetRequired,
+		CreatedAt:             user.CreatedAt,
+		UpdatedAt:             user.UpdatedAt,
+	}
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/hashing"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func adminEchoCtx(method, target string) (*httptest.ResponseRecorder, echo.Context) {
+	req := httptest.NewRequest(method, target, nil)
+	rec := httptest.NewRecorder()
+	return rec, echo.New().NewContext(req, rec)
+}
+
+func TestListUsers(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	handler := NewAdminHandler(mockRepo, mocks.NewSessionRepository(t))
+
+	rec, c := adminEchoCtx(http.MethodGet, "/admin/users?name=smi&phone=%2B62812&status=active&created_from=2024-01-01T00:00:00Z&cursor=abc&limit=10")
+
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	status := generated.ListUsersParamsStatusActive
+	limit := 10
+	params := generated.ListUsersParams{
+		Name:        stringPtr("smi"),
+		Phone:       stringPtr("+62812"),
+		Status:      &status,
+		CreatedFrom: &createdAt,
+		Cursor:      stringPtr("abc"),
+		Limit:       &limit,
+	}
+	mockRepo.On("List", models.UserListParams{
+		NamePrefix:  "smi",
+		PhonePrefix: "+62812",
+		Status:      models.StatusActive,
+		CreatedFrom: &createdAt,
+		Cursor:      "abc",
+		Limit:       10,
+	}).Return(&models.UserPage{
+		Users: []models.User{
+			{
+				ID:          11,
+				PhoneNumber: "+62812345678912",
+				Fullname:    "mr smith",
+				Password:    "hashedPassword",
+				SaltToken:   "salt",
+				Role:        models.RoleUser,
+				Status:      models.StatusActive,
+				CreatedAt:   createdAt,
+				UpdatedAt:   createdAt,
+			},
+		},
+		NextCursor:      "def",
+		Total:           25000,
+		TotalIsEstimate: true,
+	}, nil)
+
+	err := handler.ListUsers(c, params)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	expectedJSON := `{"users":[{"id":11,"phone":"+62812345678912","fullname":"mr smith","role":"user","status":"active",
+		"password_reset_required":false,"created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-01T00:00:00Z"}],
+		"next_cursor":"def","total":25000,"total_is_estimate":true}`
+	assert.JSONEq(t, expectedJSON, rec.Body.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListUsersInvalidCursor(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	handler := NewAdminHandler(mockRepo, mocks.NewSessionRepository(t))
+
+	rec, c := adminEchoCtx(http.MethodGet, "/admin/users?cursor=bogus")
+
+	var emptyPage *models.UserPage
+	mockRepo.On("List", models.UserListParams{Cursor: "bogus", Limit: defaultPageSize}).Return(emptyPage, repository.ErrInvalidCursor)
+
+	err := handler.ListUsers(c, generated.ListUsersParams{Cursor: stringPtr("bogus")})
+	assert.NoError(t, err)
+
+	expectedJSON := `{"type":"urn:sawitpro:problem:bad_request","title":"Bad Request","status":400,"code":"bad_request","instance":"/admin/users","detail":"invalid cursor"}`
+	assert.JSONEq(t, expectedJSON, rec.Body.String())
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetUserNotFound(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	handler := NewAdminHandler(mockRepo, mocks.NewSessionRepository(t))
+
+	_, c := adminEchoCtx(http.MethodGet, "/admin/users/100")
+
+	var emptyUser *models.User
+	mockRepo.On("FindByID", 100).Return(emptyUser, errors.New("record not found"))
+
+	err := handler.GetUser(c, 100)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDisableUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	sessionRepo := mocks.NewSessionRepository(t)
+	handler := NewAdminHandler(mockRepo, sessionRepo)
+
+	rec, c := adminEchoCtx(http.MethodPost, "/admin/users/123/disable")
+
+	mockRepo.On("FindByIDForUpdate", 123).Return(&models.User{
+		ID:     123,
+		Role:   models.RoleUser,
+		Status: models.StatusActive,
+	}, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(user *models.User) bool {
+		return user.Status == models.StatusDisabled && user.TokensRevokedAt != nil
+	}), []string{repository.ColumnStatus, repository.ColumnTokensRevokedAt}).Return(nil)
+
+	sessionRepo.On("RevokeAllByUser", 123).Return(nil)
+
+	err := handler.DisableUser(c, 123)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"disabled"`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestForcePasswordReset(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	sessionRepo := mocks.NewSessionRepository(t)
+	handler := NewAdminHandler(mockRepo, sessionRepo)
+
+	rec, c := adminEchoCtx(http.MethodPost, "/admin/users/123/password-reset")
+
+	mockRepo.On("FindByIDForUpdate", 123).Return(&models.User{
+		ID:     123,
+		Role:   models.RoleUser,
+		Status: models.StatusActive,
+	}, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(user *models.User) bool {
+		return user.PasswordResetRequired && user.TokensRevokedAt != nil
+	}), []string{repository.ColumnPasswordResetRequired, repository.ColumnTokensRevokedAt}).Return(nil)
+
+	sessionRepo.On("RevokeAllByUser", 123).Return(nil)
+
+	err := handler.ForcePasswordReset(c, 123)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"password_reset_required":true`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	sessionRepo := mocks.NewSessionRepository(t)
+	handler := NewAdminHandler(mockRepo, sessionRepo)
+	handler.DeletionGracePeriod = 24 * time.Hour
+
+	rec, c := adminEchoCtx(http.MethodDelete, "/admin/users/123")
+
+	mockRepo.On("FindByIDForUpdate", 123).Return(&models.User{ID: 123}, nil)
+	mockRepo.On("ScheduleDeletion", 123, mock.AnythingOfType("time.Time")).Return(nil)
+
+	sessionRepo.On("RevokeAllByUser", 123).Return(nil)
+
+	err := handler.DeleteUser(c, 123)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPasswordHashingMetrics(t *testing.T) {
+	handler := NewAdminHandler(new(MockUserRepository), mocks.NewSessionRepository(t))
+	handler.Hasher = hashing.NewPool(2, time.Second)
+	release, err := handler.Hasher.Acquire(context.Background())
+	assert.NoError(t, err)
+	defer release()
+
+	rec, c := adminEchoCtx(http.MethodGet, "/admin/metrics/password-hashing")
+
+	err = handler.PasswordHashingMetrics(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"acquired":1,"concurrency":2,"in_flight":1,"queue_depth":0,"rejected":0`)
+}
+
+func TestDatabaseMetrics(t *testing.T) {
+	handler := NewAdminHandler(new(MockUserRepository), mocks.NewSessionRepository(t))
+	handler.DatabasePools = []repository.ConnPool{&stubConnPool{name: "pgx", stats: repository.PoolStats{MaxOpen: 10, Open: 2, ClosedLifetime: 1}}}
+
+	rec, c := adminEchoCtx(http.MethodGet, "/admin/metrics/database")
+
+	err := handler.DatabaseMetrics(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `{"pools":[{"name":"pgx","stats":{"closed_idle":0,"closed_lifetime":1,"idle":0,"in_use":0,"max_open":10,"open":2`)
+}
+
+func TestUserCacheMetrics(t *testing.T) {
+	handler := NewAdminHandler(new(MockUserRepository), mocks.NewSessionRepository(t))
+	rec, c := adminEchoCtx(http.MethodGet, "/admin/metrics/user-cache")
+	assert.NoError(t, handler.UserCacheMetrics(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	userRepo := mocks.NewUserRepository(t)
+	userRepo.On("FindProfileByID", 1).Return(&models.User{ID: 1}, nil).Once()
+	handler.UserCache = repository.NewCachedUserRepository(userRepo, 10, time.Minute, nil)
+	for i := 0; i < 2; i++ {
+		_, err := handler.UserCache.FindProfileByID(1)
+		assert.NoError(t, err)
+	}
+
+	rec, c = adminEchoCtx(http.MethodGet, "/admin/metrics/user-cache")
+
+	err := handler.UserCacheMetrics(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `{"capacity":10,"evictions":0,"hits":1,"misses":1,"shared_hits":0,"size":1}`)
+}
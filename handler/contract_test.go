@@ -0,0 +1,516 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/hashing"
+	"github.com/SawitProRecruitment/UserService/identity"
+	"github.com/SawitProRecruitment/UserService/identity/identitytest"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contract serves the routes of the spec the way main does and checks every
+// request it replays and every response against the spec
+type contract struct {
+	spec     *openapi3.T
+	router   routers.Router
+	echo     *echo.Echo
+	users    repository.UserRepository
+	provider *identitytest.Server
+	// covered are the operations replayed so far, keyed by method and spec path
+	covered map[string]bool
+}
+
+// contractRepositories are the repositories behind the handlers of a contract
+type contractRepositories struct {
+	users         repository.UserRepository
+	audits        repository.AuditRepository
+	sessions      repository.SessionRepository
+	recoveryCodes repository.RecoveryCodeRepository
+	webAuthn      repository.WebAuthnRepository
+	identities    repository.IdentityRepository
+	oauth         repository.OAuthRepository
+	// pools are pinged by the readiness
+	pools []repository.ConnPool
+}
+
+func memoryRepositories() contractRepositories {
+	return contractRepositories{
+		users:         repository.NewMemoryUserRepository(),
+		audits:        &memoryAuditRepository{},
+		sessions:      newMemorySessionRepository(),
+		recoveryCodes: &memoryRecoveryCodeRepository{},
+		webAuthn:      newMemoryWebAuthnRepository(),
+		identities:    newMemoryIdentityRepository(),
+		oauth:         newMemoryOAuthRepository(),
+	}
+}
+
+func newContract(t *testing.T, repos contractRepositories) *contract {
+	spec, err := Spec()
+	require.NoError(t, err)
+	router, err := legacy.NewRouter(spec)
+	require.NoError(t, err)
+
+	provider := identitytest.NewServer(identitytest.User{Subject: "budi", Name: "Budi", PhoneNumber: "+6281299990000", PhoneNumberVerified: true})
+	t.Cleanup(provider.Close)
+	stub, err := identity.NewOIDCProvider(context.Background(), provider.URL, provider.ClientID, provider.ClientSecret, "https://app.sawitpro.com/callback", nil)
+	require.NoError(t, err)
+	webAuthn, err := NewWebAuthn(testRPID, "SawitPro", []string{testOrigin})
+	require.NoError(t, err)
+	signingKey, err := util.GenerateRSAPrivateKey()
+	require.NoError(t, err)
+
+	hasher := hashing.NewPool(4, 5*time.Second)
+	// the flows run through the cache, so a stale user fails them
+	userCache := repository.NewCachedUserRepository(repos.users, 100, time.Minute, nil)
+	repos.users = userCache
+	userHandler := &UserHandler{
+		UserRepo:            repos.users,
+		AuditRepo:           repos.audits,
+		SessionRepo:         repos.sessions,
+		RecoveryCodeRepo:    repos.recoveryCodes,
+		TOTPIssuer:          "SawitPro",
+		WebAuthn:            webAuthn,
+		WebAuthnRepo:        repos.webAuthn,
+		IdentityProviders:   map[string]identity.Provider{"stub": stub},
+		IdentityRepo:        repos.identities,
+		OAuthRepo:           repos.oauth,
+		Hasher:              hasher,
+		DeletionGracePeriod: 24 * time.Hour,
+	}
+	adminHandler := NewAdminHandler(repos.users, repos.sessions)
+	adminHandler.AuditRepo = repos.audits
+	adminHandler.Hasher = hasher
+	adminHandler.DeletionGracePeriod = 24 * time.Hour
+	adminHandler.DatabasePools = repos.pools
+	adminHandler.UserCache = userCache
+	oidcHandler := NewOIDCHandler(repos.users, repos.oauth, "http://localhost:1323", signingKey)
+	oidcHandler.LoginURL = "http://localhost:3000/login"
+
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+	e.HTTPErrorHandler = HTTPErrorHandler
+	jwtConfig := echojwt.Config{
+		NewClaimsFunc: func(c echo.Context) jwt.Claims {
+			return new(JwtCustomClaims)
+		},
+		SigningKey: []byte("secret"),
+	}
+	cookieJWTConfig := jwtConfig
+	cookieJWTConfig.TokenLookup = "cookie:" + SessionCookieName
+	generated.RegisterHandlers(&Router{
+		Echo: e,
+		Middleware: SpecMiddleware(spec, map[string][]echo.MiddlewareFunc{
+			"bearerAuth":    {echojwt.WithConfig(jwtConfig), userHandler.ActiveUserMiddleware},
+			"sessionCookie": {oidcHandler.LoginRedirect, echojwt.WithConfig(cookieJWTConfig), userHandler.ActiveUserMiddleware},
+		}, nil),
+	}, &Server{UserHandler: userHandler, AdminHandler: adminHandler, OIDCHandler: oidcHandler, HealthHandler: NewHealthHandler(repos.pools...)})
+
+	return &contract{spec: spec, router: router, echo: e, users: repos.users, provider: provider, covered: map[string]bool{}}
+}
+
+// do replays a documented request, body is sent as a form when it is
+// url.Values and as json otherwise. The request and the response must both
+// match the operation in the spec, an undocumented status fails the test
+func (ct *contract) do(t *testing.T, method, target, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var req *http.Request
+	switch body := body.(type) {
+	case nil:
+		req = httptest.NewRequest(method, target, nil)
+	case url.Values:
+		req = httptest.NewRequest(method, target, strings.NewReader(body.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	default:
+		raw, err := json.Marshal(body)
+		require.NoError(t, err)
+		req = httptest.NewRequest(method, target, bytes.NewReader(raw))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	return ct.serve(t, req)
+}
+
+// browse replays a documented navigation of a browser sending the cookies
+func (ct *contract) browse(t *testing.T, target string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	return ct.serve(t, req)
+}
+
+// serve validates the request and the response against the spec
+func (ct *contract) serve(t *testing.T, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	route, pathParams, err := ct.router.FindRoute(req)
+	require.NoError(t, err, "%s %s is not documented", req.Method, req.URL)
+	operation := route.Method + " " + route.Path
+	ct.covered[operation] = true
+
+	requestInput := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+	}
+	require.NoError(t, openapi3filter.ValidateRequest(context.Background(), requestInput), "request of %s", operation)
+
+	rec := httptest.NewRecorder()
+	ct.echo.ServeHTTP(rec, req)
+
+	err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: requestInput,
+		Status:                 rec.Code,
+		Header:                 rec.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+		Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+	})
+	assert.NoError(t, err, "response of %s: %d %s", operation, rec.Code, rec.Body.String())
+	return rec
+}
+
+// expect replays a request and checks its status, the response is decoded into v when given
+func (ct *contract) expect(t *testing.T, status int, method, target, token string, body interface{}, v interface{}) {
+	t.Helper()
+	rec := ct.do(t, method, target, token, body)
+	require.Equal(t, status, rec.Code, "%s %s: %s", method, target, rec.Body.String())
+	if v != nil {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), v))
+	}
+}
+
+// login logs a user in with a password and returns the access token
+func (ct *contract) login(t *testing.T, phone, password string) generated.LoginResponse {
+	t.Helper()
+	var response generated.LoginResponse
+	ct.expect(t, http.StatusOK, http.MethodPost, "/login", "", generated.LoginRequest{Phone: phone, Password: password}, &response)
+	return response
+}
+
+// register registers a user and returns its id
+func (ct *contract) register(t *testing.T, phone, fullname, password string) int {
+	t.Helper()
+	var response generated.RegisterResponse
+	ct.expect(t, http.StatusCreated, http.MethodPost, "/register", "",
+		generated.RegisterRequest{Phone: phone, Fullname: fullname, Password: password}, &response)
+	return response.Id
+}
+
+// uncovered lists the operations of the spec that were never replayed
+func (ct *contract) uncovered() []string {
+	var operations []string
+	for path, pathItem := range ct.spec.Paths {
+		for method := range pathItem.Operations() {
+			if !ct.covered[method+" "+path] {
+				operations = append(operations, method+" "+path)
+			}
+		}
+	}
+	sort.Strings(operations)
+	return operations
+}
+
+func TestContract(t *testing.T) {
+	database := &stubConnPool{name: "memory"}
+	repos := memoryRepositories()
+	repos.pools = []repository.ConnPool{database}
+	ct := newContract(t, repos)
+	const (
+		phone    = "+6281234567890"
+		password = "Kebun#Sawit77"
+	)
+
+	t.Run("Discovery", func(t *testing.T) {
+		ct.expect(t, http.StatusOK, http.MethodGet, "/.well-known/openid-configuration", "", nil, nil)
+		ct.expect(t, http.StatusOK, http.MethodGet, "/.well-known/jwks.json", "", nil, nil)
+	})
+
+	t.Run("Readiness", func(t *testing.T) {
+		ct.expect(t, http.StatusOK, http.MethodGet, "/ready", "", nil, nil)
+		database.err = errors.New("connection refused")
+		ct.expect(t, http.StatusServiceUnavailable, http.MethodGet, "/ready", "", nil, nil)
+		database.err = nil
+	})
+
+	ct.register(t, phone, "Budi Santoso", password)
+	var token string
+
+	t.Run("Login", func(t *testing.T) {
+		ct.expect(t, http.StatusConflict, http.MethodPost, "/register", "",
+			generated.RegisterRequest{Phone: phone, Fullname: "Budi Santoso", Password: password}, nil)
+		ct.expect(t, http.StatusUnauthorized, http.MethodPost, "/login", "",
+			generated.LoginRequest{Phone: phone, Password: "Wrong#Sawit77"}, nil)
+		token = ct.login(t, phone, password).Token
+	})
+
+	t.Run("Profile", func(t *testing.T) {
+		ct.expect(t, http.StatusUnauthorized, http.MethodGet, "/profile", "", nil, nil)
+		ct.expect(t, http.StatusOK, http.MethodGet, "/profile", token, nil, nil)
+
+		var updated generated.UpdateProfileResponse
+		ct.expect(t, http.StatusOK, http.MethodPatch, "/profile", token,
+			generated.UpdateProfileRequest{Phone: phone, Fullname: "Budi S"}, &updated)
+		assert.Equal(t, "Budi S", updated.Fullname)
+		ct.register(t, "+6281200000003", "Dewi", password)
+		ct.expect(t, http.StatusConflict, http.MethodPatch, "/profile", token,
+			generated.UpdateProfileRequest{Phone: "+6281200000003"}, nil)
+
+		ct.expect(t, http.StatusOK, http.MethodGet, "/profile/export", token, nil, nil)
+		ct.expect(t, http.StatusNoContent, http.MethodPut, "/profile/password", token,
+			generated.ChangePasswordRequest{CurrentPassword: password, NewPassword: "Kebun#Sawit88"}, nil)
+		// changing the password signs every session out
+		token = ct.login(t, phone, "Kebun#Sawit88").Token
+		ct.expect(t, http.StatusNoContent, http.MethodPut, "/profile/password", token,
+			generated.ChangePasswordRequest{CurrentPassword: "Kebun#Sawit88", NewPassword: password}, nil)
+		token = ct.login(t, phone, password).Token
+	})
+
+	t.Run("Sessions", func(t *testing.T) {
+		other := ct.login(t, phone, password)
+		var sessions generated.SessionListResponse
+		ct.expect(t, http.StatusOK, http.MethodGet, "/profile/sessions", token, nil, &sessions)
+		require.Len(t, sessions.Sessions, 2)
+		for _, session := range sessions.Sessions {
+			if !session.Current {
+				ct.expect(t, http.StatusNoContent, http.MethodDelete, "/profile/sessions/"+session.Id, token, nil, nil)
+			}
+		}
+		ct.expect(t, http.StatusNotFound, http.MethodDelete, "/profile/sessions/unknown", token, nil, nil)
+		ct.expect(t, http.StatusUnauthorized, http.MethodGet, "/profile", other.Token, nil, nil)
+	})
+
+	t.Run("Two Factor", func(t *testing.T) {
+		var setup generated.TwoFactorSetupResponse
+		ct.expect(t, http.StatusOK, http.MethodPost, "/profile/2fa/setup", token, generated.TwoFactorSetupRequest{Password: stringPtr(password)}, &setup)
+		code, err := util.TOTPCode(setup.Secret, time.Now())
+		require.NoError(t, err)
+		var confirmed generated.ConfirmTwoFactorResponse
+		ct.expect(t, http.StatusOK, http.MethodPost, "/profile/2fa/confirm", token, generated.ConfirmTwoFactorRequest{Code: code}, &confirmed)
+		require.Len(t, confirmed.RecoveryCodes, 10)
+
+		var challenge generated.MfaChallengeResponse
+		ct.expect(t, http.StatusAccepted, http.MethodPost, "/login", "", generated.LoginRequest{Phone: phone, Password: password}, &challenge)
+		ct.expect(t, http.StatusUnauthorized, http.MethodPost, "/login/2fa", "",
+			generated.LoginTwoFactorRequest{MfaToken: challenge.MfaToken, Code: "000000"}, nil)
+		ct.expect(t, http.StatusOK, http.MethodPost, "/login/2fa", "",
+			generated.LoginTwoFactorRequest{MfaToken: challenge.MfaToken, Code: confirmed.RecoveryCodes[0]}, nil)
+
+		ct.expect(t, http.StatusNoContent, http.MethodDelete, "/profile/2fa", token,
+			generated.DisableTwoFactorRequest{Password: stringPtr(password), Code: confirmed.RecoveryCodes[1]}, nil)
+	})
+
+	t.Run("Passkeys", func(t *testing.T) {
+		authenticator := newSoftAuthenticator(t, testOrigin)
+		var ceremony generated.PasskeyCeremonyResponse
+		ct.expect(t, http.StatusOK, http.MethodPost, "/profile/passkeys/register/begin", token, nil, &ceremony)
+		var passkey generated.Passkey
+		ct.expect(t, http.StatusCreated, http.MethodPost, "/profile/passkeys/register/finish", token, map[string]interface{}{
+			"ceremony_id": ceremony.CeremonyId,
+			"name":        "Budi's phone",
+			"credential":  authenticator.register(t, ceremony.Options),
+		}, &passkey)
+		ct.expect(t, http.StatusOK, http.MethodGet, "/profile/passkeys", token, nil, nil)
+
+		ct.expect(t, http.StatusOK, http.MethodPost, "/login/passkey/begin", "", nil, &ceremony)
+		ct.expect(t, http.StatusOK, http.MethodPost, "/login/passkey/finish", "", map[string]interface{}{
+			"ceremony_id": ceremony.CeremonyId,
+			"credential":  authenticator.login(t, ceremony.Options),
+		}, nil)
+
+		ct.expect(t, http.StatusNoContent, http.MethodDelete, fmt.Sprintf("/profile/passkeys/%d", passkey.Id), token, nil, nil)
+		ct.expect(t, http.StatusNotFound, http.MethodDelete, fmt.Sprintf("/profile/passkeys/%d", passkey.Id), token, nil, nil)
+	})
+
+	t.Run("Identities", func(t *testing.T) {
+		signIn := func(begin, token string) (string, string) {
+			var response generated.SocialLoginBeginResponse
+			ct.expect(t, http.StatusOK, http.MethodPost, begin, token, nil, &response)
+			code, state, err := ct.provider.Authorize(response.AuthorizationUrl)
+			require.NoError(t, err)
+			return state, code
+		}
+
+		state, code := signIn("/profile/identities/stub/begin", token)
+		ct.expect(t, http.StatusCreated, http.MethodPost, "/profile/identities/stub/finish", token,
+			generated.LinkIdentityRequest{State: state, Code: code}, nil)
+		ct.expect(t, http.StatusOK, http.MethodGet, "/profile/identities", token, nil, nil)
+
+		state, code = signIn("/login/social/stub/begin", "")
+		ct.expect(t, http.StatusOK, http.MethodPost, "/login/social/stub/finish", "",
+			generated.SocialLoginFinishRequest{State: state, Code: code}, nil)
+		ct.expect(t, http.StatusNotFound, http.MethodPost, "/login/social/unknown/begin", "", nil, nil)
+
+		ct.expect(t, http.StatusNoContent, http.MethodDelete, "/profile/identities/stub", token, nil, nil)
+		ct.expect(t, http.StatusNotFound, http.MethodDelete, "/profile/identities/stub", token, nil, nil)
+	})
+
+	adminID := ct.register(t, "+6281200000001", "Siti Admin", password)
+	admin, err := ct.users.FindByID(adminID)
+	require.NoError(t, err)
+	admin.Role = models.RoleAdmin
+	require.NoError(t, ct.users.Update(admin, repository.ColumnRole))
+	adminToken := ct.login(t, admin.PhoneNumber, password).Token
+
+	t.Run("OpenID Connect", func(t *testing.T) {
+		var created generated.CreateOAuthClientResponse
+		ct.expect(t, http.StatusCreated, http.MethodPost, "/admin/oauth/clients", adminToken, generated.CreateOAuthClientRequest{
+			Name:         "Kebun",
+			RedirectUris: []string{testRedirectURI},
+			Confidential: boolPtr(true),
+		}, &created)
+		ct.expect(t, http.StatusOK, http.MethodGet, "/admin/oauth/clients", adminToken, nil, nil)
+		clientID := created.Client.Id
+
+		ct.expect(t, http.StatusNoContent, http.MethodPost, "/profile/oauth/consents", token,
+			generated.GrantConsentRequest{ClientId: clientID, Scope: "openid profile"}, nil)
+
+		query := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {clientID},
+			"redirect_uri":          {testRedirectURI},
+			"scope":                 {"openid profile"},
+			"state":                 {"af0ifjsldkj"},
+			"nonce":                 {"n-0S6_WzA2Mj"},
+			"code_challenge":        {codeChallenge(testCodeVerifier)},
+			"code_challenge_method": {"S256"},
+		}
+		// a browser without a session is sent to the login page, which starts one and comes back
+		authorizeURL := "/oauth/authorize?" + query.Encode()
+		rec := ct.browse(t, authorizeURL, nil)
+		require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+		location, err := url.Parse(rec.Header().Get(echo.HeaderLocation))
+		require.NoError(t, err)
+		assert.Equal(t, "http://localhost:1323"+authorizeURL, location.Query().Get("return_to"))
+		rec = ct.do(t, http.MethodPost, "/oauth/session", token, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		cookies := rec.Result().Cookies()
+
+		rec = ct.browse(t, authorizeURL, cookies)
+		require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+		location, err = url.Parse(rec.Header().Get(echo.HeaderLocation))
+		require.NoError(t, err)
+		code := location.Query().Get("code")
+		require.NotEmpty(t, code, location.String())
+
+		var tokens generated.TokenResponse
+		ct.expect(t, http.StatusOK, http.MethodPost, "/oauth/token", "", url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code},
+			"redirect_uri":  {testRedirectURI},
+			"code_verifier": {testCodeVerifier},
+			"client_id":     {clientID},
+			"client_secret": {*created.ClientSecret},
+		}, &tokens)
+		ct.expect(t, http.StatusOK, http.MethodGet, "/userinfo", tokens.AccessToken, nil, nil)
+		ct.expect(t, http.StatusUnauthorized, http.MethodGet, "/userinfo", "", nil, nil)
+
+		ct.expect(t, http.StatusNoContent, http.MethodDelete, "/profile/oauth/consents/"+clientID, token, nil, nil)
+		ct.expect(t, http.StatusNoContent, http.MethodDelete, "/admin/oauth/clients/"+clientID, adminToken, nil, nil)
+		ct.expect(t, http.StatusNotFound, http.MethodDelete, "/admin/oauth/clients/"+clientID, adminToken, nil, nil)
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		userID := ct.register(t, "+6281200000002", "Agus", password)
+		user := fmt.Sprintf("/admin/users/%d", userID)
+
+		ct.expect(t, http.StatusForbidden, http.MethodGet, "/admin/users", token, nil, nil)
+		ct.expect(t, http.StatusOK, http.MethodGet, "/admin/users?status=active&limit=2", adminToken, nil, nil)
+		ct.expect(t, http.StatusOK, http.MethodGet, user, adminToken, nil, nil)
+		ct.expect(t, http.StatusNotFound, http.MethodGet, "/admin/users/999", adminToken, nil, nil)
+		ct.expect(t, http.StatusOK, http.MethodPost, user+"/disable", adminToken, nil, nil)
+		ct.expect(t, http.StatusOK, http.MethodPost, user+"/enable", adminToken, nil, nil)
+		ct.expect(t, http.StatusOK, http.MethodPost, user+"/password-reset", adminToken, nil, nil)
+		ct.expect(t, http.StatusOK, http.MethodGet, "/admin/metrics/password-hashing", adminToken, nil, nil)
+		ct.expect(t, http.StatusOK, http.MethodGet, "/admin/metrics/database", adminToken, nil, nil)
+		ct.expect(t, http.StatusOK, http.MethodGet, "/admin/metrics/user-cache", adminToken, nil, nil)
+		ct.expect(t, http.StatusAccepted, http.MethodDelete, user, adminToken, nil, nil)
+	})
+
+	t.Run("Disabled Account", func(t *testing.T) {
+		userID := ct.register(t, "+6281200000005", "Joko", password)
+		user := fmt.Sprintf("/admin/users/%d", userID)
+		login := generated.LoginRequest{Phone: "+6281200000005", Password: password}
+
+		ct.expect(t, http.StatusOK, http.MethodPost, user+"/disable", adminToken, nil, nil)
+		ct.expect(t, http.StatusForbidden, http.MethodPost, "/login", "", login, nil)
+		ct.expect(t, http.StatusOK, http.MethodPost, user+"/enable", adminToken, nil, nil)
+
+		// the second factor is asked for before the account is checked
+		userToken := ct.login(t, login.Phone, password).Token
+		var setup generated.TwoFactorSetupResponse
+		ct.expect(t, http.StatusOK, http.MethodPost, "/profile/2fa/setup", userToken, generated.TwoFactorSetupRequest{Password: stringPtr(password)}, &setup)
+		code, err := util.TOTPCode(setup.Secret, time.Now())
+		require.NoError(t, err)
+		var confirmed generated.ConfirmTwoFactorResponse
+		ct.expect(t, http.StatusOK, http.MethodPost, "/profile/2fa/confirm", userToken, generated.ConfirmTwoFactorRequest{Code: code}, &confirmed)
+		var challenge generated.MfaChallengeResponse
+		ct.expect(t, http.StatusAccepted, http.MethodPost, "/login", "", login, &challenge)
+		ct.expect(t, http.StatusOK, http.MethodPost, user+"/disable", adminToken, nil, nil)
+		ct.expect(t, http.StatusForbidden, http.MethodPost, "/login/2fa", "",
+			generated.LoginTwoFactorRequest{MfaToken: challenge.MfaToken, Code: confirmed.RecoveryCodes[0]}, nil)
+	})
+
+	t.Run("Forced Password Reset", func(t *testing.T) {
+		userID := ct.register(t, "+6281200000004", "Rina", password)
+		user, err := ct.users.FindByID(userID)
+		require.NoError(t, err)
+		user.Role = models.RoleAdmin
+		require.NoError(t, ct.users.Update(user, repository.ColumnRole))
+		ct.expect(t, http.StatusOK, http.MethodPost, fmt.Sprintf("/admin/users/%d/password-reset", userID), adminToken, nil, nil)
+
+		login := ct.login(t, user.PhoneNumber, password)
+		assert.True(t, login.PasswordResetRequired)
+		// the token only changes the password
+		ct.expect(t, http.StatusUnauthorized, http.MethodGet, "/profile", login.Token, nil, nil)
+		ct.expect(t, http.StatusUnauthorized, http.MethodGet, "/admin/users", login.Token, nil, nil)
+		ct.expect(t, http.StatusNoContent, http.MethodPut, "/profile/password", login.Token,
+			generated.ChangePasswordRequest{CurrentPassword: password, NewPassword: "Kebun#Sawit88"}, nil)
+
+		login = ct.login(t, user.PhoneNumber, "Kebun#Sawit88")
+		assert.False(t, login.PasswordResetRequired)
+		ct.expect(t, http.StatusOK, http.MethodGet, "/profile", login.Token, nil, nil)
+		ct.expect(t, http.StatusOK, http.MethodGet, "/admin/users", login.Token, nil, nil)
+	})
+
+	t.Run("Deletion", func(t *testing.T) {
+		ct.expect(t, http.StatusAccepted, http.MethodDelete, "/profile", token, generated.DeleteProfileRequest{Password: stringPtr(password)}, nil)
+		ct.expect(t, http.StatusConflict, http.MethodPost, "/login", "", generated.LoginRequest{Phone: phone, Password: password}, nil)
+		ct.expect(t, http.StatusOK, http.MethodPost, "/login", "", generated.LoginRequest{Phone: phone, Password: password, Restore: boolPtr(true)}, nil)
+	})
+
+	assert.Empty(t, ct.uncovered(), "operations of the spec without a contract test")
+}
+
+func boolPtr(value bool) *bool {
+	return &value
+}
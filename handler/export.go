@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// exportBatchSize is how many records are read and flushed at a time while streaming an export
+const exportBatchSize = 500
+
+// ExportProfile handler for exporting everything stored about the user, the
+// archive is streamed section by section so large exports are never buffered
+func (h *UserHandler) ExportProfile(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	user, err := h.UserRepo.FindByID(claims.ID)
+	if err != nil {
+		return err
+	}
+	h.recordEvent(c, user.ID, models.EventDataExported)
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="profile-export-%d.json"`, user.ID))
+	res.WriteHeader(http.StatusOK)
+
+	// the status is already sent, an error from here on leaves a truncated archive behind
+	enc := json.NewEncoder(res)
+	if _, err := io.WriteString(res, `{"exported_at":`); err != nil {
+		return err
+	}
+	if err := enc.Encode(time.Now().UTC()); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(res, `,"profile":`); err != nil {
+		return err
+	}
+	// every column but the password, its salt and the authenticator secret
+	err = enc.Encode(generated.ExportedProfile{
+		Id:                    user.ID,
+		Phone:                 user.PhoneNumber,
+		Fullname:              user.Fullname,
+		Role:                  user.Role,
+		Status:                user.Status,
+		TotpEnabled:           user.TOTPEnabled,
+		PasswordResetRequired: user.PasswordResetRequired,
+		PasswordChangedAt:     user.PasswordChangedAt,
+		TokensRevokedAt:       user.TokensRevokedAt,
+		CreatedAt:             user.CreatedAt,
+		UpdatedAt:             user.UpdatedAt,
+		DeletedAt:             user.DeletedAt,
+		PurgeAt:               user.PurgeAt,
+	})
+	if err != nil {
+		return err
+	}
+	if err := h.exportSessions(res, enc, user.ID); err != nil {
+		return err
+	}
+	if err := h.exportPasskeys(res, enc, user.ID); err != nil {
+		return err
+	}
+	if err := h.exportConsents(res, enc, user.ID); err != nil {
+		return err
+	}
+	if err := h.exportIdentities(res, enc, user.ID); err != nil {
+		return err
+	}
+	if err := h.exportAuditEvents(res, enc, "login_history", user.ID, models.LoginEventTypes); err != nil {
+		return err
+	}
+	if err := h.exportAuditEvents(res, enc, "audit_events", user.ID, models.AccountEventTypes); err != nil {
+		return err
+	}
+	_, err = io.WriteString(res, "}")
+	return err
+}
+
+// exportSessions streams every session of the user, including revoked and expired ones, as a json array field
+func (h *UserHandler) exportSessions(res *echo.Response, enc *json.Encoder, userID int) error {
+	if _, err := io.WriteString(res, `,"sessions":[`); err != nil {
+		return err
+	}
+
+	afterID, first := "", true
+	for {
+		sessions, err := h.SessionRepo.ListByUser(userID, afterID, exportBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, session := range sessions {
+			if !first {
+				if _, err := io.WriteString(res, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			err := enc.Encode(generated.ExportedSession{
+				Id:          session.ID,
+				DeviceLabel: session.DeviceLabel,
+				UserAgent:   session.UserAgent,
+				IpAddress:   session.IPAddress,
+				CreatedAt:   session.CreatedAt,
+				LastSeenAt:  session.LastSeenAt,
+				ExpiresAt:   session.ExpiresAt,
+				RevokedAt:   session.RevokedAt,
+			})
+			if err != nil {
+				return err
+			}
+			afterID = session.ID
+		}
+		res.Flush()
+		if len(sessions) < exportBatchSize {
+			break
+		}
+	}
+
+	_, err := io.WriteString(res, "]")
+	return err
+}
+
+// exportPasskeys writes the passkeys of the user as a json array field, the
+// credential ids and public keys stay out as they only matter to the authenticator
+func (h *UserHandler) exportPasskeys(res *echo.Response, enc *json.Encoder, userID int) error {
+	credentials, err := h.WebAuthnRepo.ListCredentialsByUser(userID)
+	if err != nil {
+		return err
+	}
+
+	passkeys := make([]generated.Passkey, 0, len(credentials))
+	for i := range credentials {
+		passkeys = append(passkeys, toPasskey(&credentials[i]))
+	}
+	if _, err := io.WriteString(res, `,"passkeys":`); err != nil {
+		return err
+	}
+	return enc.Encode(passkeys)
+}
+
+// exportConsents writes the scopes the user allowed OpenID Connect clients to access as a json array field
+func (h *UserHandler) exportConsents(res *echo.Response, enc *json.Encoder, userID int) error {
+	consents, err := h.OAuthRepo.ListConsentsByUser(userID)
+	if err != nil {
+		return err
+	}
+
+	exported := make([]generated.ExportedConsent, 0, len(consents))
+	for _, consent := range consents {
+		exported = append(exported, generated.ExportedConsent{
+			ClientId:  consent.ClientID,
+			Scope:     consent.Scope,
+			CreatedAt: consent.CreatedAt,
+			UpdatedAt: consent.UpdatedAt,
+		})
+	}
+	if _, err := io.WriteString(res, `,"oauth_consents":`); err != nil {
+		return err
+	}
+	return enc.Encode(exported)
+}
+
+// exportIdentities writes the accounts at external identity providers linked to the user as a json array field
+func (h *UserHandler) exportIdentities(res *echo.Response, enc *json.Encoder, userID int) error {
+	identities, err := h.IdentityRepo.ListByUser(userID)
+	if err != nil {
+		return err
+	}
+
+	exported := make([]generated.ExportedIdentity, 0, len(identities))
+	for _, link := range identities {
+		exported = append(exported, generated.ExportedIdentity{
+			Provider:  link.Provider,
+			Subject:   link.Subject,
+			CreatedAt: link.CreatedAt,
+		})
+	}
+	if _, err := io.WriteString(res, `,"linked_identities":`); err != nil {
+		return err
+	}
+	return enc.Encode(exported)
+}
+
+// exportAuditEvents streams the user's audit events of the given types as a json array field
+func (h *UserHandler) exportAuditEvents(res *echo.Response, enc *json.Encoder, field string, userID int, eventTypes []string) error {
+	if _, err := fmt.Fprintf(res, `,%q:[`, field); err != nil {
+		return err
+	}
+
+	if h.AuditRepo != nil {
+		afterID, first := 0, true
+		for {
+			events, err := h.AuditRepo.ListByUser(userID, eventTypes, afterID, exportBatchSize)
+			if err != nil {
+				return err
+			}
+			for _, event := range events {
+				if !first {
+					if _, err := io.WriteString(res, ","); err != nil {
+						return err
+					}
+				}
+				first = false
+				err := enc.Encode(generated.AuditEvent{
+					Id:        event.ID,
+					EventType: event.EventType,
+					IpAddress: event.IPAddress,
+					UserAgent: event.UserAgent,
+					CreatedAt: event.CreatedAt,
+				})
+				if err != nil {
+					return err
+				}
+				afterID = event.ID
+			}
+			res.Flush()
+			if len(events) < exportBatchSize {
+				break
+			}
+		}
+	}
+
+	_, err := io.WriteString(res, "]")
+	return err
+}
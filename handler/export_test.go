@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestExportProfile(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	auditRepo := mocks.NewAuditRepository(t)
+	sessionRepo := mocks.NewSessionRepository(t)
+	webAuthnRepo := mocks.NewWebAuthnRepository(t)
+	oauthRepo := mocks.NewOAuthRepository(t)
+	identityRepo := mocks.NewIdentityRepository(t)
+	handler := &UserHandler{
+		UserRepo:     mockRepo,
+		AuditRepo:    auditRepo,
+		SessionRepo:  sessionRepo,
+		WebAuthnRepo: webAuthnRepo,
+		OAuthRepo:    oauthRepo,
+		IdentityRepo: identityRepo,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{ID: 123})
+	req := httptest.NewRequest(http.MethodGet, "/profile/export", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user", token)
+
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	changedAt := createdAt.Add(time.Minute)
+	mockRepo.On("FindByID", 123).Return(&models.User{
+		ID:                    123,
+		PhoneNumber:           "+62812345678909",
+		Password:              "hashedPassword",
+		Fullname:              "The Inspirator",
+		SaltToken:             "salt",
+		Role:                  models.RoleUser,
+		Status:                models.StatusActive,
+		TOTPSecret:            "JBSWY3DPEHPK3PXP",
+		TOTPEnabled:           true,
+		PasswordResetRequired: true,
+		PasswordChangedAt:     &changedAt,
+		CreatedAt:             createdAt,
+		UpdatedAt:             createdAt,
+	}, nil)
+	auditRepo.On("Create", mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.UserID == 123 && event.EventType == models.EventDataExported
+	})).Return(nil)
+	revokedAt := createdAt.Add(time.Hour)
+	// a full first batch makes the export ask for the next one
+	batch := make([]models.Session, exportBatchSize)
+	for i := range batch {
+		batch[i] = models.Session{ID: fmt.Sprintf("a%04d", i), UserID: 123, CreatedAt: createdAt, LastSeenAt: createdAt, ExpiresAt: createdAt}
+	}
+	sessionRepo.On("ListByUser", 123, "", exportBatchSize).Return(batch, nil)
+	sessionRepo.On("ListByUser", 123, batch[exportBatchSize-1].ID, exportBatchSize).Return([]models.Session{
+		{ID: "b1", UserID: 123, DeviceLabel: "Chrome on Android", UserAgent: "curl", IPAddress: "10.0.0.1", CreatedAt: createdAt, LastSeenAt: createdAt, ExpiresAt: createdAt, RevokedAt: &revokedAt},
+	}, nil)
+	webAuthnRepo.On("ListCredentialsByUser", 123).Return([]models.WebAuthnCredential{
+		{ID: 7, UserID: 123, CredentialID: []byte("credential"), PublicKey: []byte("public-key"), Name: "Chrome on Android", CreatedAt: createdAt, LastUsedAt: &changedAt},
+	}, nil)
+	oauthRepo.On("ListConsentsByUser", 123).Return([]models.OAuthConsent{
+		{UserID: 123, ClientID: "kebun", Scope: "openid phone", CreatedAt: createdAt, UpdatedAt: changedAt},
+	}, nil)
+	identityRepo.On("ListByUser", 123).Return([]models.UserIdentity{
+		{ID: 4, UserID: 123, Provider: "google", Subject: "1234567890", CreatedAt: createdAt},
+	}, nil)
+	auditRepo.On("ListByUser", 123, models.LoginEventTypes, 0, exportBatchSize).Return([]models.AuditEvent{
+		{ID: 1, UserID: 123, EventType: models.EventLogin, IPAddress: "10.0.0.1", UserAgent: "curl", CreatedAt: createdAt},
+		{ID: 3, UserID: 123, EventType: models.EventLoginFailed, IPAddress: "10.0.0.2", UserAgent: "curl", CreatedAt: createdAt},
+	}, nil)
+	auditRepo.On("ListByUser", 123, models.AccountEventTypes, 0, exportBatchSize).Return([]models.AuditEvent{
+		{ID: 2, UserID: 123, EventType: models.EventProfileUpdated, IPAddress: "10.0.0.1", UserAgent: "curl", CreatedAt: createdAt},
+	}, nil)
+
+	err := handler.ExportProfile(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `attachment; filename="profile-export-123.json"`, rec.Header().Get(echo.HeaderContentDisposition))
+
+	var export generated.ProfileExport
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &export))
+	assert.Equal(t, generated.ExportedProfile{
+		Id:                    123,
+		Phone:                 "+62812345678909",
+		Fullname:              "The Inspirator",
+		Role:                  models.RoleUser,
+		Status:                models.StatusActive,
+		TotpEnabled:           true,
+		PasswordResetRequired: true,
+		PasswordChangedAt:     &changedAt,
+		CreatedAt:             createdAt,
+		UpdatedAt:             createdAt,
+	}, export.Profile)
+	assert.Len(t, export.Sessions, exportBatchSize+1)
+	assert.Equal(t, "b1", export.Sessions[exportBatchSize].Id)
+	assert.Equal(t, &revokedAt, export.Sessions[exportBatchSize].RevokedAt)
+	assert.Equal(t, []generated.Passkey{
+		{Id: 7, Name: "Chrome on Android", CreatedAt: createdAt, LastUsedAt: &changedAt},
+	}, export.Passkeys)
+	assert.Equal(t, []generated.ExportedConsent{
+		{ClientId: "kebun", Scope: "openid phone", CreatedAt: createdAt, UpdatedAt: changedAt},
+	}, export.OauthConsents)
+	assert.Equal(t, []generated.ExportedIdentity{
+		{Provider: "google", Subject: "1234567890", CreatedAt: createdAt},
+	}, export.LinkedIdentities)
+	assert.Len(t, export.LoginHistory, 2)
+	assert.Equal(t, models.EventLoginFailed, export.LoginHistory[1].EventType)
+	assert.Len(t, export.AuditEvents, 1)
+
+	// credentials never leave the service
+	assert.NotContains(t, rec.Body.String(), "hashedPassword")
+	assert.NotContains(t, rec.Body.String(), "salt")
+	assert.NotContains(t, rec.Body.String(), "JBSWY3DPEHPK3PXP")
+
+	mockRepo.AssertExpectations(t)
+}
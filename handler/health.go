@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// defaultPingTimeout is how long readiness waits for a pool to answer
+const defaultPingTimeout = 2 * time.Second
+
+// HealthHandler reports whether the service can take traffic
+type HealthHandler struct {
+	// Pools are the database pools pinged by Readiness
+	Pools []repository.ConnPool
+	// PingTimeout is how long a pool has to answer, defaultPingTimeout when zero
+	PingTimeout time.Duration
+}
+
+// NewHealthHandler create new health handler
+func NewHealthHandler(pools ...repository.ConnPool) *HealthHandler {
+	return &HealthHandler{Pools: pools}
+}
+
+// Readiness handler pinging every database pool, it answers 503 when one of
+// them fails so the replica gets no traffic until the database is back. The
+// response only carries the status, why a pool failed is logged
+func (h *HealthHandler) Readiness(c echo.Context) error {
+	timeout := h.PingTimeout
+	if timeout <= 0 {
+		timeout = defaultPingTimeout
+	}
+
+	status, response := http.StatusOK, generated.Readiness{Status: generated.Ready}
+	for _, pool := range h.Pools {
+		ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+		err := pool.Ping(ctx)
+		cancel()
+
+		if err != nil {
+			c.Logger().Errorf("database pool %s is not ready: %v", pool.Name(), err)
+			status, response.Status = http.StatusServiceUnavailable, generated.Unavailable
+		}
+	}
+	return c.JSON(status, response)
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubConnPool is a ConnPool whose ping fails with err
+type stubConnPool struct {
+	name  string
+	err   error
+	stats repository.PoolStats
+}
+
+func (p *stubConnPool) Name() string                   { return p.name }
+func (p *stubConnPool) Ping(ctx context.Context) error { return p.err }
+func (p *stubConnPool) Stats() repository.PoolStats    { return p.stats }
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		pools      []repository.ConnPool
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Ready",
+			pools:      []repository.ConnPool{&stubConnPool{name: "gorm"}},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"status":"ready"}`,
+		},
+		{
+			// the ping error is only logged
+			name: "Pool Down",
+			pools: []repository.ConnPool{
+				&stubConnPool{name: "gorm"},
+				&stubConnPool{name: "pgx", err: errors.New("dial tcp 10.0.0.5:5432: connection refused")},
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"status":"unavailable"}`,
+		},
+		{
+			name:       "No Pools",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"status":"ready"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, c := adminEchoCtx(http.MethodGet, "/ready")
+
+			err := NewHealthHandler(tt.pools...).Readiness(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
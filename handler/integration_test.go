@@ -0,0 +1,125 @@
+//go:build integration
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/pgtest"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	pgtest.Main(m, repository.Migrate)
+}
+
+func pgRepositories(db *gorm.DB) contractRepositories {
+	return contractRepositories{
+		users:         repository.NewPgUserRepository(db),
+		audits:        repository.NewPgAuditRepository(db),
+		sessions:      repository.NewPgSessionRepository(db),
+		recoveryCodes: repository.NewPgRecoveryCodeRepository(db),
+		webAuthn:      repository.NewPgWebAuthnRepository(db),
+		identities:    repository.NewPgIdentityRepository(db),
+		oauth:         repository.NewPgOAuthRepository(db),
+		pools:         []repository.ConnPool{repository.NewSQLConnPool("gorm", db.DB())},
+	}
+}
+
+// TestIntegration replays the flows of a user against Postgres, the requests
+// and responses are checked against the spec like in TestContract
+func TestIntegration(t *testing.T) {
+	ct := newContract(t, pgRepositories(pgtest.DB(t)))
+	const (
+		phone    = "+6281234567890"
+		newPhone = "+6281234567899"
+		password = "Kebun#Sawit77"
+	)
+	var token string
+
+	t.Run("Readiness", func(t *testing.T) {
+		var readiness generated.Readiness
+		ct.expect(t, http.StatusOK, http.MethodGet, "/ready", "", nil, &readiness)
+		assert.Equal(t, generated.Ready, readiness.Status)
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		id := ct.register(t, phone, "Budi Santoso", password)
+		assert.NotZero(t, id)
+		ct.expect(t, http.StatusConflict, http.MethodPost, "/register", "",
+			generated.RegisterRequest{Phone: phone, Fullname: "Budi Santoso", Password: password}, nil)
+		ct.expect(t, http.StatusBadRequest, http.MethodPost, "/register", "",
+			generated.RegisterRequest{Phone: "+6581234567890", Fullname: "Budi Santoso", Password: password}, nil)
+	})
+
+	t.Run("Login", func(t *testing.T) {
+		ct.expect(t, http.StatusUnauthorized, http.MethodPost, "/login", "",
+			generated.LoginRequest{Phone: phone, Password: "Wrong#Sawit77"}, nil)
+		token = ct.login(t, phone, password).Token
+	})
+
+	t.Run("Profile", func(t *testing.T) {
+		var profile generated.ProfileResponse
+		ct.expect(t, http.StatusOK, http.MethodGet, "/profile", token, nil, &profile)
+		assert.Equal(t, generated.ProfileResponse{Phone: phone, Fullname: "Budi Santoso"}, profile)
+		ct.expect(t, http.StatusUnauthorized, http.MethodGet, "/profile", "", nil, nil)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		var updated generated.UpdateProfileResponse
+		ct.expect(t, http.StatusOK, http.MethodPatch, "/profile", token,
+			generated.UpdateProfileRequest{Phone: newPhone, Fullname: "Budi S"}, &updated)
+		assert.Equal(t, generated.UpdateProfileResponse{Phone: newPhone, Fullname: "Budi S"}, updated)
+
+		var profile generated.ProfileResponse
+		ct.expect(t, http.StatusOK, http.MethodGet, "/profile", token, nil, &profile)
+		assert.Equal(t, newPhone, profile.Phone)
+		ct.expect(t, http.StatusUnauthorized, http.MethodPost, "/login", "",
+			generated.LoginRequest{Phone: phone, Password: password}, nil)
+		ct.login(t, newPhone, password)
+
+		// the old phone number is free again
+		ct.register(t, phone, "Siti", password)
+		ct.expect(t, http.StatusConflict, http.MethodPatch, "/profile", token,
+			generated.UpdateProfileRequest{Phone: phone}, nil)
+	})
+
+	t.Run("Concurrent Registrations", func(t *testing.T) {
+		body, err := json.Marshal(generated.RegisterRequest{Phone: "+6281200000001", Fullname: "Agus", Password: password})
+		require.NoError(t, err)
+		codes := make([]int, 8)
+		var wg sync.WaitGroup
+		for i := range codes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(body))
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				rec := httptest.NewRecorder()
+				ct.echo.ServeHTTP(rec, req)
+				codes[i] = rec.Code
+			}(i)
+		}
+		wg.Wait()
+
+		created := 0
+		for _, code := range codes {
+			if code == http.StatusCreated {
+				created++
+				continue
+			}
+			assert.Equal(t, http.StatusConflict, code)
+		}
+		assert.Equal(t, 1, created)
+	})
+}
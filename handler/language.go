@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"github.com/SawitProRecruitment/UserService/i18n"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// translatorKey is the context key of the translator of the request
+	translatorKey = "translator"
+
+	headerAcceptLanguage  = "Accept-Language"
+	headerContentLanguage = "Content-Language"
+)
+
+// LanguageMiddleware picks the language of the messages from the
+// Accept-Language header of the request
+func LanguageMiddleware(catalog *i18n.Catalog) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			trans := catalog.Match(c.Request().Header.Get(headerAcceptLanguage))
+			c.Set(translatorKey, trans)
+			c.Response().Header().Set(headerContentLanguage, trans.Locale())
+			c.Response().Header().Add(echo.HeaderVary, headerAcceptLanguage)
+			return next(c)
+		}
+	}
+}
+
+// translator is the translator of the request, messages are served in
+// English without one
+func translator(c echo.Context) ut.Translator {
+	trans, _ := c.Get(translatorKey).(ut.Translator)
+	return trans
+}
+
+// translate translates a message to the language of the request
+func translate(c echo.Context, message string, params ...string) string {
+	return i18n.T(translator(c), message, params...)
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanguageMiddleware(t *testing.T) {
+	catalog, err := i18n.New(i18n.English)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		expectedLang   string
+		expectedJSON   string
+	}{
+		{
+			name:           "English",
+			acceptLanguage: "",
+			expectedLang:   "en",
+			expectedJSON: `{"type":"urn:sawitpro:problem:validation_failed","title":"Bad Request","status":400,"code":"validation_failed","instance":"/register","detail":"phone number must start with +62",
+				"errors":[{"field":"phone","rule":"prefix","message":"phone number must start with +62"}]}`,
+		},
+		{
+			name:           "Indonesian",
+			acceptLanguage: "id-ID,id;q=0.9,en;q=0.8",
+			expectedLang:   "id",
+			expectedJSON: `{"type":"urn:sawitpro:problem:validation_failed","title":"Permintaan Tidak Valid","status":400,"code":"validation_failed","instance":"/register","detail":"nomor telepon harus diawali dengan +62",
+				"errors":[{"field":"phone","rule":"prefix","message":"nomor telepon harus diawali dengan +62"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/register", nil)
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+
+			err := LanguageMiddleware(catalog)(func(c echo.Context) error {
+				return invalidField(c, "phone", "prefix", "phone number must start with +62")
+			})(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedLang, rec.Header().Get("Content-Language"))
+			assert.Equal(t, "Accept-Language", rec.Header().Get(echo.HeaderVary))
+			assert.JSONEq(t, tt.expectedJSON, rec.Body.String())
+		})
+	}
+}
+
+func TestHTTPErrorHandlerTranslated(t *testing.T) {
+	catalog, err := i18n.New(i18n.English)
+	require.NoError(t, err)
+	validate := validator.New()
+	require.NoError(t, catalog.RegisterValidator(validate))
+	type input struct {
+		Phone string `validate:"required"`
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set(translatorKey, catalog.Translator(i18n.Indonesian))
+
+	HTTPErrorHandler(validate.Struct(input{}), c)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"type":"urn:sawitpro:problem:validation_failed","title":"Permintaan Tidak Valid","status":400,"code":"validation_failed","instance":"/login",
+		"detail":"Phone wajib diisi","errors":[{"field":"Phone","rule":"required","message":"Phone wajib diisi"}]}`, rec.Body.String())
+}
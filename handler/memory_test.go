@@ -0,0 +1,466 @@
+package handler
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/jinzhu/gorm"
+)
+
+// the memory repositories keep their rows in maps and fail like the postgres
+// ones do, they back the contract tests that replay whole flows along with
+// repository.MemoryUserRepository
+
+type memorySessionRepository struct {
+	mu       sync.Mutex
+	sessions map[string]models.Session
+}
+
+func newMemorySessionRepository() *memorySessionRepository {
+	return &memorySessionRepository{sessions: map[string]models.Session{}}
+}
+
+func (r *memorySessionRepository) Create(session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session.CreatedAt = time.Now()
+	r.sessions[session.ID] = *session
+	return nil
+}
+
+func (r *memorySessionRepository) FindByID(id string) (*models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &session, nil
+}
+
+func (r *memorySessionRepository) list(match func(session *models.Session) bool) []models.Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []models.Session
+	for _, session := range r.sessions {
+		if match(&session) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.Before(sessions[j].CreatedAt) })
+	return sessions
+}
+
+func (r *memorySessionRepository) ListByUser(userID int, afterID string, limit int) ([]models.Session, error) {
+	sessions := r.list(func(session *models.Session) bool { return session.UserID == userID && session.ID > afterID })
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	return sessions, nil
+}
+
+func (r *memorySessionRepository) ListActiveByUser(userID int, now time.Time) ([]models.Session, error) {
+	return r.list(func(session *models.Session) bool {
+		return session.UserID == userID && session.RevokedAt == nil && session.ExpiresAt.After(now)
+	}), nil
+}
+
+func (r *memorySessionRepository) Touch(id string, lastSeenAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.sessions[id]; ok {
+		session.LastSeenAt = lastSeenAt
+		r.sessions[id] = session
+	}
+	return nil
+}
+
+func (r *memorySessionRepository) Revoke(id string, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	r.sessions[id] = session
+	return nil
+}
+
+func (r *memorySessionRepository) RevokeAllByUser(userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			r.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+func (r *memorySessionRepository) DeleteByUser(userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, session := range r.sessions {
+		if session.UserID == userID {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
+
+type memoryAuditRepository struct {
+	mu     sync.Mutex
+	events []models.AuditEvent
+}
+
+func (r *memoryAuditRepository) Create(event *models.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event.ID, event.CreatedAt = len(r.events)+1, time.Now()
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func (r *memoryAuditRepository) ListByUser(userID int, eventTypes []string, afterID int, limit int) ([]models.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []models.AuditEvent
+	for _, event := range r.events {
+		if event.UserID == userID && event.ID > afterID && containsString(eventTypes, event.EventType) && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (r *memoryAuditRepository) DeleteByUser(userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events[:0]
+	for _, event := range r.events {
+		if event.UserID != userID {
+			events = append(events, event)
+		}
+	}
+	r.events = events
+	return nil
+}
+
+type memoryRecoveryCodeRepository struct {
+	mu    sync.Mutex
+	codes []models.RecoveryCode
+}
+
+func (r *memoryRecoveryCodeRepository) ReplaceByUser(userID int, codeHashes []string) error {
+	r.DeleteByUser(userID)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, codeHash := range codeHashes {
+		r.codes = append(r.codes, models.RecoveryCode{ID: len(r.codes) + 1, UserID: userID, CodeHash: codeHash, CreatedAt: time.Now()})
+	}
+	return nil
+}
+
+func (r *memoryRecoveryCodeRepository) Use(userID int, codeHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, code := range r.codes {
+		if code.UserID == userID && code.CodeHash == codeHash && code.UsedAt == nil {
+			now := time.Now()
+			r.codes[i].UsedAt = &now
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (r *memoryRecoveryCodeRepository) DeleteByUser(userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	codes := r.codes[:0]
+	for _, code := range r.codes {
+		if code.UserID != userID {
+			codes = append(codes, code)
+		}
+	}
+	r.codes = codes
+	return nil
+}
+
+type memoryWebAuthnRepository struct {
+	mu          sync.Mutex
+	credentials []models.WebAuthnCredential
+	ceremonies  map[string]models.WebAuthnCeremony
+	lastID      int
+}
+
+func newMemoryWebAuthnRepository() *memoryWebAuthnRepository {
+	return &memoryWebAuthnRepository{ceremonies: map[string]models.WebAuthnCeremony{}}
+}
+
+func (r *memoryWebAuthnRepository) CreateCredential(credential *models.WebAuthnCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, registered := range r.credentials {
+		if bytes.Equal(registered.CredentialID, credential.CredentialID) {
+			return repository.ErrCredentialTaken
+		}
+	}
+	r.lastID++
+	credential.ID, credential.CreatedAt = r.lastID, time.Now()
+	r.credentials = append(r.credentials, *credential)
+	return nil
+}
+
+func (r *memoryWebAuthnRepository) ListCredentialsByUser(userID int) ([]models.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var credentials []models.WebAuthnCredential
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *memoryWebAuthnRepository) UpdateCredentialUsage(id int, signCount int64, backupState bool, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.credentials {
+		if r.credentials[i].ID == id {
+			r.credentials[i].SignCount, r.credentials[i].BackupState, r.credentials[i].LastUsedAt = signCount, backupState, &usedAt
+		}
+	}
+	return nil
+}
+
+func (r *memoryWebAuthnRepository) DeleteCredential(id int, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, credential := range r.credentials {
+		if credential.ID == id && credential.UserID == userID {
+			r.credentials = append(r.credentials[:i], r.credentials[i+1:]...)
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (r *memoryWebAuthnRepository) CreateCeremony(ceremony *models.WebAuthnCeremony) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ceremonies[ceremony.ID] = *ceremony
+	return nil
+}
+
+func (r *memoryWebAuthnRepository) TakeCeremony(id string, now time.Time) (*models.WebAuthnCeremony, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ceremony, ok := r.ceremonies[id]
+	if !ok || !ceremony.ExpiresAt.After(now) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(r.ceremonies, id)
+	return &ceremony, nil
+}
+
+type memoryIdentityRepository struct {
+	mu         sync.Mutex
+	identities []models.UserIdentity
+	states     map[string]models.IdentityLoginState
+	lastID     int
+}
+
+func newMemoryIdentityRepository() *memoryIdentityRepository {
+	return &memoryIdentityRepository{states: map[string]models.IdentityLoginState{}}
+}
+
+func (r *memoryIdentityRepository) Create(identity *models.UserIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastID++
+	identity.ID, identity.CreatedAt = r.lastID, time.Now()
+	r.identities = append(r.identities, *identity)
+	return nil
+}
+
+func (r *memoryIdentityRepository) FindByProviderSubject(provider, subject string) (*models.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryIdentityRepository) ListByUser(userID int) ([]models.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var identities []models.UserIdentity
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+func (r *memoryIdentityRepository) Delete(userID int, provider string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, identity := range r.identities {
+		if identity.UserID == userID && identity.Provider == provider {
+			r.identities = append(r.identities[:i], r.identities[i+1:]...)
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (r *memoryIdentityRepository) CreateState(state *models.IdentityLoginState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[state.ID] = *state
+	return nil
+}
+
+func (r *memoryIdentityRepository) TakeState(id string, now time.Time) (*models.IdentityLoginState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.states[id]
+	if !ok || !state.ExpiresAt.After(now) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(r.states, id)
+	return &state, nil
+}
+
+type memoryOAuthRepository struct {
+	mu       sync.Mutex
+	clients  []models.OAuthClient
+	codes    map[string]models.OAuthAuthorizationCode
+	consents map[consentKey]models.OAuthConsent
+}
+
+func newMemoryOAuthRepository() *memoryOAuthRepository {
+	return &memoryOAuthRepository{codes: map[string]models.OAuthAuthorizationCode{}, consents: map[consentKey]models.OAuthConsent{}}
+}
+
+type consentKey struct {
+	userID   int
+	clientID string
+}
+
+func (r *memoryOAuthRepository) CreateClient(client *models.OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client.CreatedAt = time.Now()
+	r.clients = append(r.clients, *client)
+	return nil
+}
+
+func (r *memoryOAuthRepository) FindClient(id string) (*models.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, client := range r.clients {
+		if client.ID == id {
+			return &client, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryOAuthRepository) ListClients() ([]models.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]models.OAuthClient(nil), r.clients...), nil
+}
+
+func (r *memoryOAuthRepository) DeleteClient(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, consent := range r.consents {
+		if consent.ClientID == id {
+			delete(r.consents, key)
+		}
+	}
+	for i, client := range r.clients {
+		if client.ID == id {
+			r.clients = append(r.clients[:i], r.clients[i+1:]...)
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (r *memoryOAuthRepository) CreateCode(code *models.OAuthAuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codes[code.CodeHash] = *code
+	return nil
+}
+
+func (r *memoryOAuthRepository) TakeCode(codeHash string, now time.Time) (*models.OAuthAuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	code, ok := r.codes[codeHash]
+	if !ok || !code.ExpiresAt.After(now) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(r.codes, codeHash)
+	return &code, nil
+}
+
+func (r *memoryOAuthRepository) FindConsent(userID int, clientID string) (*models.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	consent, ok := r.consents[consentKey{userID, clientID}]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &consent, nil
+}
+
+func (r *memoryOAuthRepository) ListConsentsByUser(userID int) ([]models.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var consents []models.OAuthConsent
+	for _, consent := range r.consents {
+		if consent.UserID == userID {
+			consents = append(consents, consent)
+		}
+	}
+	sort.Slice(consents, func(i, j int) bool { return consents[i].CreatedAt.Before(consents[j].CreatedAt) })
+	return consents, nil
+}
+
+func (r *memoryOAuthRepository) SaveConsent(consent *models.OAuthConsent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	consent.UpdatedAt = time.Now()
+	r.consents[consentKey{consent.UserID, consent.ClientID}] = *consent
+	return nil
+}
+
+func (r *memoryOAuthRepository) DeleteConsent(userID int, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := consentKey{userID, clientID}
+	if _, ok := r.consents[key]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.consents, key)
+	return nil
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// sessionTouchInterval is how stale the last seen time of a session may get before it is updated
+const sessionTouchInterval = time.Minute
+
+// activeUserKey is where ActiveUserMiddleware keeps the user of the token in the echo context
+const activeUserKey = "activeUser"
+
+// changePasswordPath is the only route the token of a user who must change the password reaches
+const changePasswordPath = "/profile/password"
+
+// ActiveUserMiddleware rejects tokens of deleted or disabled users, tokens
+// issued before the user's tokens were revoked and tokens of revoked sessions,
+// it must run after the jwt middleware. Users who must change their password,
+// because it was reset or expired, can only change it. The user is read
+// through the user cache while the session never is, disabling a user,
+// forcing a password reset, deleting an account and changing the password all
+// revoke the sessions so they apply on every replica of the service at once
+func (h *UserHandler) ActiveUserMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userToken := c.Get("user").(*jwt.Token)
+		claims := userToken.Claims.(*JwtCustomClaims)
+
+		user, err := h.UserRepo.FindProfileByID(claims.ID)
+		if err != nil {
+			if err.Error() == "record not found" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired jwt")
+			}
+			return err
+		}
+
+		if user.Status == models.StatusDisabled {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired jwt")
+		}
+
+		// issued at only has second precision, so a token issued within the
+		// same second as the revocation is still accepted
+		if user.TokensRevokedAt != nil &&
+			(claims.IssuedAt == nil || claims.IssuedAt.Time.Before(user.TokensRevokedAt.Truncate(time.Second))) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired jwt")
+		}
+
+		session, err := h.SessionRepo.FindByID(claims.SessionID)
+		if err != nil {
+			if err.Error() == "record not found" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired jwt")
+			}
+			return err
+		}
+		if session.UserID != user.ID || session.RevokedAt != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired jwt")
+		}
+
+		// last seen is only a hint for the user, so it is not written on every request
+		now := time.Now()
+		if now.Sub(session.LastSeenAt) > sessionTouchInterval {
+			if err := h.SessionRepo.Touch(session.ID, now); err != nil {
+				c.Logger().Errorf("fail to touch session %s: %v", session.ID, err)
+			}
+		}
+
+		if (user.PasswordResetRequired || h.passwordExpired(user)) && !(c.Request().Method == http.MethodPut && c.Path() == changePasswordPath) {
+			return problem(c, http.StatusUnauthorized, CodePasswordChangeRequired, "password must be changed before using the account")
+		}
+
+		c.Set(activeUserKey, user)
+		return next(c)
+	}
+}
+
+// RequireRole only lets users currently having one of the given roles
+// through, the role of the token is ignored so a demoted user loses access
+// at once. It must run after ActiveUserMiddleware
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, ok := c.Get(activeUserKey).(*models.User)
+			if !ok {
+				return echo.NewHTTPError(http.StatusForbidden, "insufficient role")
+			}
+
+			for _, role := range roles {
+				if user.Role == role {
+					return next(c)
+				}
+			}
+			return echo.NewHTTPError(http.StatusForbidden, "insufficient role")
+		}
+	}
+}
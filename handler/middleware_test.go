@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestActiveUserMiddleware(t *testing.T) {
+	issuedAt := time.Now().Add(-time.Hour)
+	revokedBefore := issuedAt.Add(-time.Hour)
+	revokedAfter := issuedAt.Add(time.Minute)
+	changedLongAgo := time.Now().Add(-91 * 24 * time.Hour)
+	activeSession := &models.Session{ID: "s1", UserID: 123, LastSeenAt: time.Now()}
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		findErr    error
+		session    *models.Session
+		sessionErr error
+		// method and path are the route, GET /profile when empty
+		method    string
+		path      string
+		wantTouch bool
+		wantCode  int
+		wantErr   bool
+	}{
+		{
+			name:     "Active User",
+			user:     &models.User{ID: 123},
+			session:  activeSession,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "Stale Session Is Touched",
+			user:      &models.User{ID: 123},
+			session:   &models.Session{ID: "s1", UserID: 123, LastSeenAt: issuedAt},
+			wantTouch: true,
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:     "Tokens Revoked Before Issued",
+			user:     &models.User{ID: 123, TokensRevokedAt: &revokedBefore},
+			session:  activeSession,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Tokens Revoked After Issued",
+			user:     &models.User{ID: 123, TokensRevokedAt: &revokedAfter},
+			wantCode: http.StatusUnauthorized,
+			wantErr:  true,
+		},
+		{
+			name:     "Disabled User",
+			user:     &models.User{ID: 123, Status: models.StatusDisabled},
+			wantCode: http.StatusUnauthorized,
+			wantErr:  true,
+		},
+		{
+			name:     "Deleted User",
+			findErr:  errors.New("record not found"),
+			wantCode: http.StatusUnauthorized,
+			wantErr:  true,
+		},
+		{
+			name:     "Revoked Session",
+			user:     &models.User{ID: 123},
+			session:  &models.Session{ID: "s1", UserID: 123, RevokedAt: &revokedAfter},
+			wantCode: http.StatusUnauthorized,
+			wantErr:  true,
+		},
+		{
+			name:     "Session Of Another User",
+			user:     &models.User{ID: 123},
+			session:  &models.Session{ID: "s1", UserID: 456},
+			wantCode: http.StatusUnauthorized,
+			wantErr:  true,
+		},
+		{
+			name:     "Password Reset Required",
+			user:     &models.User{ID: 123, PasswordResetRequired: true},
+			session:  activeSession,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Password Reset Required Changes The Password",
+			user:     &models.User{ID: 123, PasswordResetRequired: true},
+			session:  activeSession,
+			method:   http.MethodPut,
+			path:     "/profile/password",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Password Expired",
+			user:     &models.User{ID: 123, Password: "hash", PasswordChangedAt: &changedLongAgo},
+			session:  activeSession,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Password Expired Changes The Password",
+			user:     &models.User{ID: 123, Password: "hash", PasswordChangedAt: &changedLongAgo},
+			session:  activeSession,
+			method:   http.MethodPut,
+			path:     "/profile/password",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Password Expired Without Credentials",
+			user:     &models.User{ID: 123, PasswordSet: true, PasswordChangedAt: &changedLongAgo},
+			session:  activeSession,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Password Never Set Does Not Expire",
+			user:     &models.User{ID: 123, PasswordChangedAt: &changedLongAgo},
+			session:  activeSession,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "Unknown Session",
+			user:       &models.User{ID: 123},
+			sessionErr: errors.New("record not found"),
+			wantCode:   http.StatusUnauthorized,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			sessionRepo := new(mocks.SessionRepository)
+			handler := &UserHandler{
+				UserRepo:       mockRepo,
+				SessionRepo:    sessionRepo,
+				MaxPasswordAge: 90 * 24 * time.Hour,
+			}
+			mockRepo.On("FindProfileByID", 123).Return(tt.user, tt.findErr)
+			sessionRepo.On("FindByID", "s1").Return(tt.session, tt.sessionErr)
+			if tt.wantTouch {
+				sessionRepo.On("Touch", "s1", mock.AnythingOfType("time.Time")).Return(nil)
+			}
+
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{
+				ID:        123,
+				SessionID: "s1",
+				RegisteredClaims: jwt.RegisteredClaims{
+					IssuedAt: jwt.NewNumericDate(issuedAt),
+				},
+			})
+			method, path := http.MethodGet, "/profile"
+			if tt.method != "" {
+				method, path = tt.method, tt.path
+			}
+			req := httptest.NewRequest(method, path, nil)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.SetPath(path)
+			c.Set("user", token)
+
+			err := handler.ActiveUserMiddleware(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})(c)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ActiveUserMiddleware() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				assert.Equal(t, tt.wantCode, err.(*echo.HTTPError).Code)
+				return
+			}
+			assert.Equal(t, tt.wantCode, rec.Code)
+			mockRepo.AssertExpectations(t)
+			sessionRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		tokenRole string
+		user      *models.User
+		wantCode  int
+	}{
+		{
+			name:      "Admin Role",
+			tokenRole: models.RoleAdmin,
+			user:      &models.User{ID: 123, Role: models.RoleAdmin},
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "User Role",
+			tokenRole: models.RoleUser,
+			user:      &models.User{ID: 123, Role: models.RoleUser},
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "Demoted Admin",
+			tokenRole: models.RoleAdmin,
+			user:      &models.User{ID: 123, Role: models.RoleUser},
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "Promoted User",
+			tokenRole: models.RoleUser,
+			user:      &models.User{ID: 123, Role: models.RoleAdmin},
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "Without Active User",
+			tokenRole: models.RoleAdmin,
+			wantCode:  http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{ID: 123, Role: tt.tokenRole})
+			req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.Set("user", token)
+			if tt.user != nil {
+				c.Set(activeUserKey, tt.user)
+			}
+
+			err := RequireRole(models.RoleAdmin)(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})(c)
+			if err != nil {
+				assert.Equal(t, tt.wantCode, err.(*echo.HTTPError).Code)
+				return
+			}
+			assert.Equal(t, tt.wantCode, rec.Code)
+		})
+	}
+}
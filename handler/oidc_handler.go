@@ -0,0 +1,617 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// OpenID Connect lifetimes
+const (
+	oauthCodeLifetime = 5 * time.Minute
+	oidcTokenLifetime = time.Hour
+)
+
+// SessionCookieName is the cookie of the access token read by the authorization endpoint
+const SessionCookieName = "oidc_session"
+
+// oidcScopes are the supported scopes, profile releases the name and phone the phone number
+var oidcScopes = []string{"openid", "profile", "phone"}
+
+// OIDCAccessClaims are the claims of the access tokens issued to clients, they
+// are only accepted by /userinfo and the services trusting this provider
+type OIDCAccessClaims struct {
+	Scope    string `json:"scope"`
+	ClientID string `json:"client_id"`
+	jwt.RegisteredClaims
+}
+
+// OIDCIDClaims are the claims of the id tokens issued to clients
+type OIDCIDClaims struct {
+	Nonce       string `json:"nonce,omitempty"`
+	AuthTime    int64  `json:"auth_time"`
+	Name        string `json:"name,omitempty"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// OIDCHandler implements the OpenID Connect provider, tokens are signed with
+// an RSA key published at the jwks uri so other services can verify them
+// without sharing a secret
+type OIDCHandler struct {
+	UserRepo  repository.UserRepository
+	OAuthRepo repository.OAuthRepository
+	// Issuer is the public base url of this service and the iss of every token
+	Issuer     string
+	SigningKey *rsa.PrivateKey
+	keyID      string
+	// LoginURL is the first party page signing browsers without a session
+	// cookie in, it gets the url to come back to in return_to. The
+	// authorization endpoint answers 401 instead when it is empty
+	LoginURL string
+}
+
+// NewOIDCHandler create new OpenID Connect handler
+func NewOIDCHandler(userRepo repository.UserRepository, oauthRepo repository.OAuthRepository, issuer string, signingKey *rsa.PrivateKey) *OIDCHandler {
+	return &OIDCHandler{
+		UserRepo:   userRepo,
+		OAuthRepo:  oauthRepo,
+		Issuer:     strings.TrimSuffix(issuer, "/"),
+		SigningKey: signingKey,
+		keyID:      util.RSAKeyID(&signingKey.PublicKey),
+	}
+}
+
+// OpenidConfiguration handler for the OpenID Connect discovery document
+func (h *OIDCHandler) OpenidConfiguration(c echo.Context) error {
+	return c.JSON(http.StatusOK, generated.OpenIDConfiguration{
+		Issuer:                            h.Issuer,
+		AuthorizationEndpoint:             h.Issuer + "/oauth/authorize",
+		TokenEndpoint:                     h.Issuer + "/oauth/token",
+		UserinfoEndpoint:                  h.Issuer + "/userinfo",
+		JwksUri:                           h.Issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  []string{jwt.SigningMethodRS256.Alg()},
+		ScopesSupported:                   oidcScopes,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		GrantTypesSupported:               []string{"authorization_code"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "phone_number"},
+	})
+}
+
+// Jwks handler for the public keys verifying the tokens
+func (h *OIDCHandler) Jwks(c echo.Context) error {
+	n, e := util.RSAPublicKeyParams(&h.SigningKey.PublicKey)
+	return c.JSON(http.StatusOK, generated.JSONWebKeySet{
+		Keys: []generated.JSONWebKey{{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			Kid: h.keyID,
+			N:   n,
+			E:   e,
+		}},
+	})
+}
+
+// StartBrowserSession handler for keeping the access token in the session
+// cookie, the browser sends it to the authorization endpoint only. The first
+// party login page calls it once the user signed in, as a browser redirected
+// to the authorization endpoint can not send the token in a header
+func (h *OIDCHandler) StartBrowserSession(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	cookie := &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    userToken.Raw,
+		Path:     h.authorizePath(),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.Issuer, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+	if claims.ExpiresAt != nil {
+		cookie.Expires = claims.ExpiresAt.Time
+	}
+	c.SetCookie(cookie)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// LoginRedirect sends a browser the authorization endpoint rejected, for a
+// missing, expired or revoked session cookie, to the login page which brings
+// it back once the user signed in. It runs before the authentication
+func (h *OIDCHandler) LoginRedirect(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		err := next(c)
+		var httpError *echo.HTTPError
+		if h.LoginURL == "" || !errors.As(err, &httpError) || httpError.Code != http.StatusUnauthorized {
+			return err
+		}
+		returnTo := h.Issuer + "/oauth/authorize?" + c.QueryString()
+		return redirectWith(c, h.LoginURL, url.Values{"return_to": {returnTo}})
+	}
+}
+
+// authorizePath is the path of the authorization endpoint as browsers see it
+func (h *OIDCHandler) authorizePath() string {
+	path := "/oauth/authorize"
+	if issuer, err := url.Parse(h.Issuer); err == nil {
+		path = issuer.Path + path
+	}
+	return path
+}
+
+// Authorize handler for the authorization endpoint, it runs behind the
+// session cookie so the user is the one the login page started the browser
+// session for. Errors about the client or redirect uri are answered directly,
+// every other outcome redirects back to the client
+func (h *OIDCHandler) Authorize(c echo.Context, params generated.AuthorizeParams) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	client, err := h.OAuthRepo.FindClient(params.ClientId)
+	if err != nil {
+		if err.Error() == "record not found" {
+			return c.JSON(http.StatusBadRequest, generated.OAuthErrorResponse{
+				Error:            "invalid_client",
+				ErrorDescription: stringPtr("unknown client"),
+			})
+		}
+		return err
+	}
+	redirectURI := params.RedirectUri
+	if !redirectURIAllowed(client, redirectURI) {
+		return c.JSON(http.StatusBadRequest, generated.OAuthErrorResponse{
+			Error:            "invalid_request",
+			ErrorDescription: stringPtr("redirect_uri is not registered for the client"),
+		})
+	}
+
+	state := stringValue(params.State)
+	if params.ResponseType == nil || *params.ResponseType != generated.Code {
+		return redirectWith(c, redirectURI, url.Values{"error": {"unsupported_response_type"}, "state": {state}})
+	}
+	scopes, ok := parseScope(stringValue(params.Scope))
+	if !ok {
+		return redirectWith(c, redirectURI, url.Values{"error": {"invalid_scope"}, "state": {state}})
+	}
+	// PKCE keeps an intercepted code useless, it is required from every client
+	codeChallenge := stringValue(params.CodeChallenge)
+	if codeChallenge == "" || params.CodeChallengeMethod == nil || *params.CodeChallengeMethod != generated.S256 {
+		return redirectWith(c, redirectURI, url.Values{
+			"error":             {"invalid_request"},
+			"error_description": {"code_challenge with code_challenge_method S256 is required"},
+			"state":             {state},
+		})
+	}
+
+	if !client.FirstParty {
+		consent, err := h.OAuthRepo.FindConsent(claims.ID, client.ID)
+		if err != nil && err.Error() != "record not found" {
+			return err
+		}
+		if consent == nil || !scopeCovers(consent.Scope, scopes) {
+			return redirectWith(c, redirectURI, url.Values{"error": {"consent_required"}, "state": {state}})
+		}
+	}
+
+	code := randomToken()
+	authTime := time.Now()
+	if claims.IssuedAt != nil {
+		authTime = claims.IssuedAt.Time
+	}
+	err = h.OAuthRepo.CreateCode(&models.OAuthAuthorizationCode{
+		CodeHash:      sha256Hex(code),
+		ClientID:      client.ID,
+		UserID:        claims.ID,
+		RedirectURI:   redirectURI,
+		Scope:         strings.Join(scopes, " "),
+		Nonce:         stringValue(params.Nonce),
+		CodeChallenge: codeChallenge,
+		AuthTime:      authTime,
+		ExpiresAt:     time.Now().Add(oauthCodeLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	return redirectWith(c, redirectURI, url.Values{"code": {code}, "state": {state}})
+}
+
+// Token handler for the token endpoint, it exchanges an authorization code
+// for an access token and an id token
+func (h *OIDCHandler) Token(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+
+	if c.FormValue("grant_type") != "authorization_code" {
+		return oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+
+	clientID, clientSecret, basicAuth := c.Request().BasicAuth()
+	if !basicAuth {
+		clientID = c.FormValue("client_id")
+		clientSecret = c.FormValue("client_secret")
+	}
+	client, err := h.OAuthRepo.FindClient(clientID)
+	if err != nil && err.Error() != "record not found" {
+		return err
+	}
+	// public clients have no secret to check, they rely on PKCE alone
+	if client == nil || (client.SecretHash != "" && subtle.ConstantTimeCompare([]byte(sha256Hex(clientSecret)), []byte(client.SecretHash)) != 1) {
+		if basicAuth {
+			c.Response().Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		return oauthError(c, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+	}
+
+	code, err := h.OAuthRepo.TakeCode(sha256Hex(c.FormValue("code")), time.Now())
+	if err != nil {
+		if err.Error() == "record not found" {
+			return oauthError(c, http.StatusBadRequest, "invalid_grant", "code is invalid, expired or already used")
+		}
+		return err
+	}
+	if code.ClientID != client.ID || code.RedirectURI != c.FormValue("redirect_uri") {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "code was issued for another client or redirect_uri")
+	}
+	if subtle.ConstantTimeCompare([]byte(pkceChallenge(c.FormValue("code_verifier"))), []byte(code.CodeChallenge)) != 1 {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "code_verifier does not match the code_challenge")
+	}
+
+	user, err := h.UserRepo.FindByID(code.UserID)
+	if err != nil && err.Error() != "record not found" {
+		return err
+	}
+	if user == nil || user.Status == models.StatusDisabled {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "user is no longer active")
+	}
+
+	accessToken, idToken, err := h.signTokens(client, user, code)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, generated.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(oidcTokenLifetime.Seconds()),
+		IdToken:     idToken,
+		Scope:       code.Scope,
+	})
+}
+
+// Userinfo handler for the userinfo endpoint, it answers with the claims the
+// scope of the access token allows
+func (h *OIDCHandler) Userinfo(c echo.Context) error {
+	claims := &OIDCAccessClaims{}
+	tokenString, _ := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return &h.SigningKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithIssuer(h.Issuer), jwt.WithExpirationRequired())
+	// id tokens are signed with the same key, only access tokens are typed at+jwt
+	if err != nil || token.Header["typ"] != "at+jwt" {
+		return invalidBearerToken(c)
+	}
+
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return invalidBearerToken(c)
+	}
+	user, err := h.UserRepo.FindByID(id)
+	if err != nil {
+		if err.Error() == "record not found" {
+			return invalidBearerToken(c)
+		}
+		return err
+	}
+	if user.Status == models.StatusDisabled ||
+		(user.TokensRevokedAt != nil && (claims.IssuedAt == nil || claims.IssuedAt.Time.Before(user.TokensRevokedAt.Truncate(time.Second)))) {
+		return invalidBearerToken(c)
+	}
+
+	response := generated.UserInfoResponse{Sub: claims.Subject}
+	for _, scope := range strings.Fields(claims.Scope) {
+		switch scope {
+		case "profile":
+			response.Name = stringPtr(user.Fullname)
+		case "phone":
+			response.PhoneNumber = stringPtr(user.PhoneNumber)
+		}
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// GrantConsent handler for allowing a third party client to sign the user in
+func (h *OIDCHandler) GrantConsent(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	var input generated.GrantConsentRequest
+	if err := c.Bind(&input); err != nil {
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+	}
+	if err := c.Validate(input); err != nil {
+		return err
+	}
+
+	scopes, ok := parseScope(input.Scope)
+	if !ok {
+		return problem(c, http.StatusBadRequest, CodeBadRequest, "scope must contain openid and only supported scopes")
+	}
+	client, err := h.OAuthRepo.FindClient(input.ClientId)
+	if err != nil {
+		if err.Error() == "record not found" {
+			return problem(c, http.StatusNotFound, CodeNotFound, "client not found")
+		}
+		return err
+	}
+
+	err = h.OAuthRepo.SaveConsent(&models.OAuthConsent{
+		UserID:   claims.ID,
+		ClientID: client.ID,
+		Scope:    strings.Join(scopes, " "),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeConsent handler for withdrawing the consent given to a client
+func (h *OIDCHandler) RevokeConsent(c echo.Context, clientID string) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	err := h.OAuthRepo.DeleteConsent(claims.ID, clientID)
+	if err != nil {
+		if err.Error() == "record not found" {
+			return problem(c, http.StatusNotFound, CodeNotFound, "consent not found")
+		}
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListOAuthClients handler for listing the registered clients
+func (h *OIDCHandler) ListOAuthClients(c echo.Context) error {
+	clients, err := h.OAuthRepo.ListClients()
+	if err != nil {
+		return err
+	}
+
+	response := generated.OAuthClientListResponse{
+		Clients: make([]generated.OAuthClient, 0, len(clients)),
+	}
+	for i := range clients {
+		response.Clients = append(response.Clients, toOAuthClient(&clients[i]))
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// CreateOAuthClient handler for registering a client, the secret of a
+// confidential client is only returned here
+func (h *OIDCHandler) CreateOAuthClient(c echo.Context) error {
+	var input generated.CreateOAuthClientRequest
+	if err := c.Bind(&input); err != nil {
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+	}
+	if err := c.Validate(input); err != nil {
+		return err
+	}
+
+	client := &models.OAuthClient{
+		ID:           util.GenerateSessionID(),
+		Name:         input.Name,
+		RedirectURIs: strings.Join(input.RedirectUris, " "),
+		FirstParty:   input.FirstParty != nil && *input.FirstParty,
+	}
+	var secret *string
+	if input.Confidential != nil && *input.Confidential {
+		secret = stringPtr(randomToken())
+		client.SecretHash = sha256Hex(*secret)
+	}
+	err := h.OAuthRepo.CreateClient(client)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, generated.CreateOAuthClientResponse{
+		Client:       toOAuthClient(client),
+		ClientSecret: secret,
+	})
+}
+
+// DeleteOAuthClient handler for deleting a client, tokens it already received stay valid until they expire
+func (h *OIDCHandler) DeleteOAuthClient(c echo.Context, id string) error {
+	err := h.OAuthRepo.DeleteClient(id)
+	if err != nil {
+		if err.Error() == "record not found" {
+			return problem(c, http.StatusNotFound, CodeNotFound, "client not found")
+		}
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// signTokens signs the access token and the id token for the exchanged code
+func (h *OIDCHandler) signTokens(client *models.OAuthClient, user *models.User, code *models.OAuthAuthorizationCode) (string, string, error) {
+	now := time.Now()
+	registered := jwt.RegisteredClaims{
+		Issuer:    h.Issuer,
+		Subject:   strconv.Itoa(user.ID),
+		Audience:  jwt.ClaimStrings{client.ID},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(oidcTokenLifetime)),
+	}
+
+	accessToken := jwt.NewWithClaims(jwt.SigningMethodRS256, &OIDCAccessClaims{
+		Scope:            code.Scope,
+		ClientID:         client.ID,
+		RegisteredClaims: registered,
+	})
+	accessToken.Header["kid"] = h.keyID
+	accessToken.Header["typ"] = "at+jwt"
+	signedAccessToken, err := accessToken.SignedString(h.SigningKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	idClaims := &OIDCIDClaims{
+		Nonce:            code.Nonce,
+		AuthTime:         code.AuthTime.Unix(),
+		RegisteredClaims: registered,
+	}
+	for _, scope := range strings.Fields(code.Scope) {
+		switch scope {
+		case "profile":
+			idClaims.Name = user.Fullname
+		case "phone":
+			idClaims.PhoneNumber = user.PhoneNumber
+		}
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, idClaims)
+	idToken.Header["kid"] = h.keyID
+	signedIDToken, err := idToken.SignedString(h.SigningKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	return signedAccessToken, signedIDToken, nil
+}
+
+// parseScope splits a scope parameter and drops duplicates, it fails when
+// openid is missing or a scope is not supported
+func parseScope(scope string) ([]string, bool) {
+	var scopes []string
+	hasOpenID := false
+	for _, requested := range strings.Fields(scope) {
+		if !containsString(oidcScopes, requested) {
+			return nil, false
+		}
+		if containsString(scopes, requested) {
+			continue
+		}
+		hasOpenID = hasOpenID || requested == "openid"
+		scopes = append(scopes, requested)
+	}
+	return scopes, hasOpenID
+}
+
+// scopeCovers reports whether the granted space separated scope contains every requested scope
+func scopeCovers(granted string, requested []string) bool {
+	grantedScopes := strings.Fields(granted)
+	for _, scope := range requested {
+		if !containsString(grantedScopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// redirectURIAllowed reports whether the redirect uri exactly matches one registered for the client
+func redirectURIAllowed(client *models.OAuthClient, redirectURI string) bool {
+	return redirectURI != "" && containsString(strings.Fields(client.RedirectURIs), redirectURI)
+}
+
+// redirectWith redirects to the redirect uri with the params added to its
+// query, empty params are left out
+func redirectWith(c echo.Context, redirectURI string, params url.Values) error {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, generated.OAuthErrorResponse{
+			Error:            "invalid_request",
+			ErrorDescription: stringPtr("redirect_uri is not a valid url"),
+		})
+	}
+	query := target.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	target.RawQuery = query.Encode()
+	return c.Redirect(http.StatusFound, target.String())
+}
+
+// oauthError responds with an RFC 6749 error
+func oauthError(c echo.Context, status int, code, description string) error {
+	response := generated.OAuthErrorResponse{Error: code}
+	if description != "" {
+		response.ErrorDescription = stringPtr(description)
+	}
+	return c.JSON(status, response)
+}
+
+// invalidBearerToken responds with the RFC 6750 invalid token error
+func invalidBearerToken(c echo.Context) error {
+	c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	return oauthError(c, http.StatusUnauthorized, "invalid_token", "")
+}
+
+// toOAuthClient converts a client into its api representation
+func toOAuthClient(client *models.OAuthClient) generated.OAuthClient {
+	return generated.OAuthClient{
+		Id:           client.ID,
+		Name:         client.Name,
+		RedirectUris: strings.Fields(client.RedirectURIs),
+		FirstParty:   client.FirstParty,
+		Confidential: client.SecretHash != "",
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// randomToken generates a random 256 bit base64url token for codes and client secrets
+func randomToken() string {
+	token := make([]byte, 32)
+	rand.Read(token)
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// sha256Hex hashes a random token for storage
+func sha256Hex(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
+
+// stringValue is the value of an optional parameter, empty when it is missing
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
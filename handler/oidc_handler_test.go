@@ -0,0 +1,536 @@
+package handler
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testIssuer       = "https://id.sawitpro.com"
+	testRedirectURI  = "https://app.sawitpro.com/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mJ92K8sSoBj6h8R9Dnyp1LWl1xjvWQ"
+)
+
+var (
+	testSigningKey     *rsa.PrivateKey
+	testSigningKeyOnce sync.Once
+)
+
+// oidcHandler builds a handler with a signing key shared by the tests, rsa key generation is slow
+func oidcHandler(t *testing.T) (*OIDCHandler, *MockUserRepository, *mocks.OAuthRepository) {
+	testSigningKeyOnce.Do(func() {
+		var err error
+		testSigningKey, err = util.GenerateRSAPrivateKey()
+		assert.NoError(t, err)
+	})
+	userRepo := new(MockUserRepository)
+	oauthRepo := mocks.NewOAuthRepository(t)
+	return NewOIDCHandler(userRepo, oauthRepo, testIssuer+"/", testSigningKey), userRepo, oauthRepo
+}
+
+func oidcClient(firstParty bool) *models.OAuthClient {
+	return &models.OAuthClient{
+		ID:           "client",
+		Name:         "SawitPro App",
+		RedirectURIs: testRedirectURI + " https://app.sawitpro.com/other",
+		FirstParty:   firstParty,
+	}
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func authorizeEchoCtx(query url.Values) (*httptest.ResponseRecorder, echo.Context) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{
+		ID: 1,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		},
+	})
+	req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user", token)
+	return rec, c
+}
+
+// authorizeParams binds the query the way the generated server does
+func authorizeParams(query url.Values) generated.AuthorizeParams {
+	optional := func(name string) *string {
+		if !query.Has(name) {
+			return nil
+		}
+		return stringPtr(query.Get(name))
+	}
+	params := generated.AuthorizeParams{
+		ClientId:      query.Get("client_id"),
+		RedirectUri:   query.Get("redirect_uri"),
+		Scope:         optional("scope"),
+		State:         optional("state"),
+		Nonce:         optional("nonce"),
+		CodeChallenge: optional("code_challenge"),
+	}
+	if query.Has("response_type") {
+		responseType := generated.AuthorizeParamsResponseType(query.Get("response_type"))
+		params.ResponseType = &responseType
+	}
+	if query.Has("code_challenge_method") {
+		method := generated.AuthorizeParamsCodeChallengeMethod(query.Get("code_challenge_method"))
+		params.CodeChallengeMethod = &method
+	}
+	return params
+}
+
+func authorizeQuery() url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"client"},
+		"redirect_uri":          {testRedirectURI},
+		"scope":                 {"openid profile phone"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {codeChallenge(testCodeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+func tokenEchoCtx(form url.Values) (*httptest.ResponseRecorder, echo.Context) {
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	return rec, echo.New().NewContext(req, rec)
+}
+
+func tokenForm(code string) url.Values {
+	return url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"client"},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {testCodeVerifier},
+	}
+}
+
+func oauthErrorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	var response generated.OAuthErrorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response.Error
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	handler, userRepo, oauthRepo := oidcHandler(t)
+	user := &models.User{ID: 1, PhoneNumber: "+62812345678912", Fullname: "mr smith"}
+
+	var stored *models.OAuthAuthorizationCode
+	oauthRepo.On("FindClient", "client").Return(oidcClient(true), nil)
+	oauthRepo.On("CreateCode", mock.AnythingOfType("*models.OAuthAuthorizationCode")).
+		Run(func(args mock.Arguments) { stored = args.Get(0).(*models.OAuthAuthorizationCode) }).
+		Return(nil).Once()
+
+	rec, c := authorizeEchoCtx(authorizeQuery())
+	assert.NoError(t, handler.Authorize(c, authorizeParams(authorizeQuery())))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	location, err := url.Parse(rec.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, testRedirectURI, location.Scheme+"://"+location.Host+location.Path)
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+	code := location.Query().Get("code")
+	assert.NotEmpty(t, code)
+	// only the hash of the code is stored
+	assert.Equal(t, sha256Hex(code), stored.CodeHash)
+	assert.Equal(t, "openid profile phone", stored.Scope)
+
+	oauthRepo.On("TakeCode", sha256Hex(code), mock.AnythingOfType("time.Time")).Return(stored, nil).Once()
+	userRepo.On("FindByID", 1).Return(user, nil)
+
+	rec, c = tokenEchoCtx(tokenForm(code))
+	assert.NoError(t, handler.Token(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	var response generated.TokenResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "Bearer", response.TokenType)
+	assert.Equal(t, 3600, response.ExpiresIn)
+
+	idClaims := &OIDCIDClaims{}
+	idToken, err := jwt.ParseWithClaims(response.IdToken, idClaims, func(token *jwt.Token) (interface{}, error) {
+		return &testSigningKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(testIssuer), jwt.WithAudience("client"))
+	assert.NoError(t, err)
+	assert.Equal(t, handler.keyID, idToken.Header["kid"])
+	assert.Equal(t, "1", idClaims.Subject)
+	assert.Equal(t, "n-0S6_WzA2Mj", idClaims.Nonce)
+	assert.Equal(t, "mr smith", idClaims.Name)
+	assert.Equal(t, "+62812345678912", idClaims.PhoneNumber)
+
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+response.AccessToken)
+	rec = httptest.NewRecorder()
+	assert.NoError(t, handler.Userinfo(echo.New().NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var userInfo generated.UserInfoResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &userInfo))
+	assert.Equal(t, "1", userInfo.Sub)
+	assert.Equal(t, "mr smith", *userInfo.Name)
+	assert.Equal(t, "+62812345678912", *userInfo.PhoneNumber)
+
+	// the id token is signed with the same key but is not an access token
+	req = httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+response.IdToken)
+	rec = httptest.NewRecorder()
+	assert.NoError(t, handler.Userinfo(echo.New().NewContext(req, rec)))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "invalid_token")
+
+	// a code can only be exchanged once
+	oauthRepo.On("TakeCode", sha256Hex(code), mock.AnythingOfType("time.Time")).Return(nil, errors.New("record not found")).Once()
+	rec, c = tokenEchoCtx(tokenForm(code))
+	assert.NoError(t, handler.Token(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid_grant", oauthErrorCode(t, rec))
+}
+
+func TestOIDCAuthorize(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      func(url.Values)
+		client     *models.OAuthClient
+		consent    *models.OAuthConsent
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "Fail Authorize, unknown client",
+			query:      func(q url.Values) { q.Set("client_id", "unknown") },
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_client",
+		},
+		{
+			name:       "Fail Authorize, unregistered redirect uri",
+			query:      func(q url.Values) { q.Set("redirect_uri", "https://evil.example.com/callback") },
+			client:     oidcClient(true),
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_request",
+		},
+		{
+			name:       "Fail Authorize, unsupported response type",
+			query:      func(q url.Values) { q.Set("response_type", "token") },
+			client:     oidcClient(true),
+			wantStatus: http.StatusFound,
+			wantError:  "unsupported_response_type",
+		},
+		{
+			name:       "Fail Authorize, missing openid scope",
+			query:      func(q url.Values) { q.Set("scope", "profile") },
+			client:     oidcClient(true),
+			wantStatus: http.StatusFound,
+			wantError:  "invalid_scope",
+		},
+		{
+			name:       "Fail Authorize, missing PKCE",
+			query:      func(q url.Values) { q.Del("code_challenge") },
+			client:     oidcClient(true),
+			wantStatus: http.StatusFound,
+			wantError:  "invalid_request",
+		},
+		{
+			name:       "Fail Authorize, third party client without consent",
+			query:      func(q url.Values) {},
+			client:     oidcClient(false),
+			wantStatus: http.StatusFound,
+			wantError:  "consent_required",
+		},
+		{
+			name:       "Fail Authorize, consent does not cover the scope",
+			query:      func(q url.Values) {},
+			client:     oidcClient(false),
+			consent:    &models.OAuthConsent{UserID: 1, ClientID: "client", Scope: "openid profile"},
+			wantStatus: http.StatusFound,
+			wantError:  "consent_required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, oauthRepo := oidcHandler(t)
+			if tt.client != nil {
+				oauthRepo.On("FindClient", "client").Return(tt.client, nil)
+			} else {
+				oauthRepo.On("FindClient", "unknown").Return(nil, errors.New("record not found"))
+			}
+			if tt.client != nil && !tt.client.FirstParty {
+				if tt.consent != nil {
+					oauthRepo.On("FindConsent", 1, "client").Return(tt.consent, nil)
+				} else {
+					oauthRepo.On("FindConsent", 1, "client").Return(nil, errors.New("record not found"))
+				}
+			}
+
+			query := authorizeQuery()
+			tt.query(query)
+			rec, c := authorizeEchoCtx(query)
+			assert.NoError(t, handler.Authorize(c, authorizeParams(query)))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusFound {
+				location, err := url.Parse(rec.Header().Get("Location"))
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantError, location.Query().Get("error"))
+				assert.Equal(t, "xyz", location.Query().Get("state"))
+			} else {
+				assert.Equal(t, tt.wantError, oauthErrorCode(t, rec))
+			}
+		})
+	}
+}
+
+func TestStartBrowserSession(t *testing.T) {
+	handler, _, _ := oidcHandler(t)
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{
+		ID: 1,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	token.Raw = "header.payload.signature"
+	req := httptest.NewRequest(http.MethodPost, "/oauth/session", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user", token)
+
+	assert.NoError(t, handler.StartBrowserSession(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	cookies := rec.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		cookie := cookies[0]
+		assert.Equal(t, SessionCookieName, cookie.Name)
+		assert.Equal(t, "header.payload.signature", cookie.Value)
+		// only the authorization endpoint gets the cookie, and scripts never do
+		assert.Equal(t, "/oauth/authorize", cookie.Path)
+		assert.True(t, cookie.HttpOnly)
+		assert.True(t, cookie.Secure)
+		assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+		assert.True(t, expiresAt.Equal(cookie.Expires))
+	}
+}
+
+func TestLoginRedirect(t *testing.T) {
+	tests := []struct {
+		name         string
+		loginURL     string
+		err          error
+		wantErr      bool
+		wantLocation string
+	}{
+		{
+			name:         "No Session Goes To The Login Page",
+			loginURL:     "https://app.sawitpro.com/login?app=web",
+			err:          echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed jwt"),
+			wantLocation: "https://app.sawitpro.com/login?app=web&return_to=" + url.QueryEscape(testIssuer+"/oauth/authorize?client_id=client"),
+		},
+		{
+			name:    "No Login Page",
+			err:     echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed jwt"),
+			wantErr: true,
+		},
+		{
+			name:     "Other Errors Are Kept",
+			loginURL: "https://app.sawitpro.com/login",
+			err:      errors.New("connection refused"),
+			wantErr:  true,
+		},
+		{
+			name:     "Signed In",
+			loginURL: "https://app.sawitpro.com/login",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, _ := oidcHandler(t)
+			handler.LoginURL = tt.loginURL
+			req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?client_id=client", nil)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+
+			err := handler.LoginRedirect(func(c echo.Context) error {
+				return tt.err
+			})(c)
+			if tt.wantErr {
+				assert.Equal(t, tt.err, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.wantLocation != "" {
+				assert.Equal(t, http.StatusFound, rec.Code)
+				assert.Equal(t, tt.wantLocation, rec.Header().Get(echo.HeaderLocation))
+			}
+		})
+	}
+}
+
+func TestOIDCToken(t *testing.T) {
+	confidential := oidcClient(true)
+	confidential.SecretHash = sha256Hex("s3cret")
+	code := &models.OAuthAuthorizationCode{
+		CodeHash:      sha256Hex("code"),
+		ClientID:      "client",
+		UserID:        1,
+		RedirectURI:   testRedirectURI,
+		Scope:         "openid",
+		CodeChallenge: codeChallenge(testCodeVerifier),
+		AuthTime:      time.Now(),
+	}
+	tests := []struct {
+		name       string
+		form       func(url.Values)
+		client     *models.OAuthClient
+		user       *models.User
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "Success Token, confidential client",
+			form:       func(f url.Values) { f.Set("client_secret", "s3cret") },
+			client:     confidential,
+			user:       &models.User{ID: 1},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Fail Token, wrong client secret",
+			form:       func(f url.Values) { f.Set("client_secret", "wrong") },
+			client:     confidential,
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "invalid_client",
+		},
+		{
+			name:       "Fail Token, unsupported grant type",
+			form:       func(f url.Values) { f.Set("grant_type", "password") },
+			wantStatus: http.StatusBadRequest,
+			wantError:  "unsupported_grant_type",
+		},
+		{
+			name:       "Fail Token, wrong code verifier",
+			form:       func(f url.Values) { f.Set("code_verifier", "wrong-verifier") },
+			client:     oidcClient(true),
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_grant",
+		},
+		{
+			name:       "Fail Token, different redirect uri",
+			form:       func(f url.Values) { f.Set("redirect_uri", "https://app.sawitpro.com/other") },
+			client:     oidcClient(true),
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_grant",
+		},
+		{
+			name:       "Fail Token, disabled user",
+			form:       func(f url.Values) {},
+			client:     oidcClient(true),
+			user:       &models.User{ID: 1, Status: models.StatusDisabled},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_grant",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, userRepo, oauthRepo := oidcHandler(t)
+			if tt.client != nil {
+				oauthRepo.On("FindClient", "client").Return(tt.client, nil)
+				if tt.wantError != "invalid_client" {
+					oauthRepo.On("TakeCode", code.CodeHash, mock.AnythingOfType("time.Time")).Return(code, nil)
+				}
+			}
+			if tt.user != nil {
+				userRepo.On("FindByID", 1).Return(tt.user, nil)
+			}
+
+			form := tokenForm("code")
+			tt.form(form)
+			rec, c := tokenEchoCtx(form)
+			assert.NoError(t, handler.Token(c))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantError != "" {
+				assert.Equal(t, tt.wantError, oauthErrorCode(t, rec))
+			}
+		})
+	}
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	handler, _, _ := oidcHandler(t)
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil), rec)
+	assert.NoError(t, handler.OpenidConfiguration(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var configuration generated.OpenIDConfiguration
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &configuration))
+	assert.Equal(t, testIssuer, configuration.Issuer)
+	assert.Equal(t, testIssuer+"/.well-known/jwks.json", configuration.JwksUri)
+
+	rec = httptest.NewRecorder()
+	c = echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil), rec)
+	assert.NoError(t, handler.Jwks(c))
+	var keySet generated.JSONWebKeySet
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &keySet))
+	assert.Len(t, keySet.Keys, 1)
+	assert.Equal(t, handler.keyID, keySet.Keys[0].Kid)
+	assert.Equal(t, "AQAB", keySet.Keys[0].E)
+}
+
+func TestGrantConsent(t *testing.T) {
+	handler, _, oauthRepo := oidcHandler(t)
+	oauthRepo.On("FindClient", "client").Return(oidcClient(false), nil)
+	oauthRepo.On("SaveConsent", &models.OAuthConsent{UserID: 1, ClientID: "client", Scope: "openid phone"}).Return(nil)
+
+	rec, c := twoFactorEchoCtx(http.MethodPost, "/profile/oauth/consents", `{"client_id": "client", "scope": "openid phone openid"}`)
+	assert.NoError(t, handler.GrantConsent(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec, c = twoFactorEchoCtx(http.MethodPost, "/profile/oauth/consents", `{"client_id": "client", "scope": "openid email"}`)
+	assert.NoError(t, handler.GrantConsent(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCreateOAuthClient(t *testing.T) {
+	handler, _, oauthRepo := oidcHandler(t)
+	oauthRepo.On("CreateClient", mock.AnythingOfType("*models.OAuthClient")).Return(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/oauth/clients",
+		strings.NewReader(`{"name": "Partner", "redirect_uris": ["https://partner.example.com/cb"], "confidential": true}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+	assert.NoError(t, handler.CreateOAuthClient(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var response generated.CreateOAuthClientResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.True(t, response.Client.Confidential)
+	assert.False(t, response.Client.FirstParty)
+	assert.NotNil(t, response.ClientSecret)
+	created := oauthRepo.Calls[0].Arguments.Get(0).(*models.OAuthClient)
+	assert.Equal(t, sha256Hex(*response.ClientSecret), created.SecretHash)
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/labstack/echo/v4"
+)
+
+// Spec is the api.yml embedded in the generated server
+func Spec() (*openapi3.T, error) {
+	spec, err := generated.GetSwagger()
+	if err != nil {
+		return nil, err
+	}
+	// requests are matched on their route whatever host they are sent to
+	spec.Servers = nil
+	return spec, nil
+}
+
+// specPath turns an echo path such as /admin/users/:id into the path of the
+// spec, /admin/users/{id}
+func specPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Operation is the operation of an echo route in the spec, nil when the route
+// is not documented
+func Operation(spec *openapi3.T, method, path string) *openapi3.Operation {
+	pathItem := spec.Paths.Find(specPath(path))
+	if pathItem == nil {
+		return nil
+	}
+	return pathItem.GetOperation(method)
+}
+
+// SecurityScheme is the name of the security scheme the spec requires on a
+// route such as bearerAuth, empty when the route is public
+func SecurityScheme(spec *openapi3.T, method, path string) string {
+	operation := Operation(spec, method, path)
+	if operation == nil {
+		return ""
+	}
+	security := spec.Security
+	if operation.Security != nil {
+		security = *operation.Security
+	}
+	for _, requirement := range security {
+		for name := range requirement {
+			return name
+		}
+	}
+	return ""
+}
+
+// ValidateRequest rejects the requests of a route whose parameters or body do
+// not match its operation in the spec, every broken rule is reported the way
+// the validation errors of the handlers are. Tokens are checked by the jwt
+// middleware, not here
+func ValidateRequest(spec *openapi3.T, method, path string) echo.MiddlewareFunc {
+	route := &routers.Route{
+		Spec:      spec,
+		Path:      specPath(path),
+		PathItem:  spec.Paths.Find(specPath(path)),
+		Method:    method,
+		Operation: Operation(spec, method, path),
+	}
+	options := &openapi3filter.Options{
+		MultiError:          true,
+		AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+		SkipSettingDefaults: true,
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if route.Operation == nil {
+			return next
+		}
+		return func(c echo.Context) error {
+			pathParams := make(map[string]string, len(c.ParamNames()))
+			for i, name := range c.ParamNames() {
+				pathParams[name] = c.ParamValues()[i]
+			}
+			err := openapi3filter.ValidateRequest(c.Request().Context(), &openapi3filter.RequestValidationInput{
+				Request:    c.Request(),
+				PathParams: pathParams,
+				Route:      route,
+				Options:    options,
+			})
+			if err != nil {
+				return invalidRequest(c, err)
+			}
+			return next(c)
+		}
+	}
+}
+
+// invalidRequest reports the errors of openapi3filter.ValidateRequest
+func invalidRequest(c echo.Context, err error) error {
+	var fieldErrors []generated.FieldError
+	for _, err := range flatten(err) {
+		var requestError *openapi3filter.RequestError
+		if !errors.As(err, &requestError) {
+			return err
+		}
+		if requestError.Parameter != nil {
+			fieldErrors = append(fieldErrors, parameterErrors(c, requestError)...)
+			continue
+		}
+		if requestError.Err == nil {
+			// the body is not sent with a content type of the operation
+			return echo.ErrUnsupportedMediaType
+		}
+		if errors.Is(requestError.Err, openapi3filter.ErrInvalidRequired) {
+			return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+		}
+		schemaErrors := schemaErrors(requestError.Err)
+		if len(schemaErrors) == 0 {
+			return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+		}
+		for _, schemaError := range schemaErrors {
+			fieldErrors = append(fieldErrors, schemaFieldError(c, strings.Join(schemaError.JSONPointer(), "."), schemaError))
+		}
+	}
+	return invalidFields(c, fieldErrors)
+}
+
+// parameterErrors are the errors of a query, path or header parameter
+func parameterErrors(c echo.Context, requestError *openapi3filter.RequestError) []generated.FieldError {
+	name := requestError.Parameter.Name
+	if errors.Is(requestError.Err, openapi3filter.ErrInvalidRequired) {
+		return []generated.FieldError{{Field: name, Rule: "required", Message: translate(c, "{0} is required", name)}}
+	}
+	schemaErrors := schemaErrors(requestError.Err)
+	if len(schemaErrors) == 0 {
+		// the value could not even be parsed, such as a word for a number
+		return []generated.FieldError{{Field: name, Rule: "type", Message: translate(c, invalidFieldMessage, name)}}
+	}
+	fieldErrors := make([]generated.FieldError, len(schemaErrors))
+	for i, schemaError := range schemaErrors {
+		fieldErrors[i] = schemaFieldError(c, name, schemaError)
+	}
+	return fieldErrors
+}
+
+// invalidFieldMessage is the message of the rules without a message of their own
+const invalidFieldMessage = "{0} is not valid"
+
+// schemaFieldError describes the schema rule a value broke
+func schemaFieldError(c echo.Context, field string, schemaError *openapi3.SchemaError) generated.FieldError {
+	schema := schemaError.Schema
+	message := translate(c, invalidFieldMessage, field)
+	switch schemaError.SchemaField {
+	case "required":
+		message = translate(c, "{0} is required", field)
+	case "enum":
+		values := make([]string, len(schema.Enum))
+		for i, value := range schema.Enum {
+			values[i] = fmt.Sprint(value)
+		}
+		message = translate(c, "{0} must be one of {1}", field, strings.Join(values, ", "))
+	case "minimum":
+		if schema.Min != nil {
+			message = translate(c, "{0} must be at least {1}", field, formatNumber(*schema.Min))
+		}
+	case "maximum":
+		if schema.Max != nil {
+			message = translate(c, "{0} must be at most {1}", field, formatNumber(*schema.Max))
+		}
+	case "minLength":
+		message = translate(c, "{0} must be at least {1} characters", field, strconv.FormatUint(schema.MinLength, 10))
+	case "maxLength":
+		if schema.MaxLength != nil {
+			message = translate(c, "{0} must be at most {1} characters", field, strconv.FormatUint(*schema.MaxLength, 10))
+		}
+	}
+	return generated.FieldError{Field: field, Rule: schemaError.SchemaField, Message: message}
+}
+
+func formatNumber(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+// flatten unwraps the openapi3.MultiError of a validation
+func flatten(err error) []error {
+	multiError, ok := err.(openapi3.MultiError)
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, err := range multiError {
+		errs = append(errs, flatten(err)...)
+	}
+	return errs
+}
+
+// schemaErrors are the schema errors behind a validation error, none when the
+// value could not be decoded
+func schemaErrors(err error) []*openapi3.SchemaError {
+	var schemaErrors []*openapi3.SchemaError
+	for _, err := range flatten(err) {
+		var schemaError *openapi3.SchemaError
+		if errors.As(err, &schemaError) {
+			schemaErrors = append(schemaErrors, schemaError)
+		}
+	}
+	return schemaErrors
+}
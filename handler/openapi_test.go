@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityScheme(t *testing.T) {
+	spec, err := Spec()
+	require.NoError(t, err)
+
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{method: http.MethodPost, path: "/login", want: ""},
+		{method: http.MethodGet, path: "/profile", want: "bearerAuth"},
+		{method: http.MethodDelete, path: "/profile/sessions/:id", want: "bearerAuth"},
+		{method: http.MethodGet, path: "/admin/users/:id", want: "bearerAuth"},
+		{method: http.MethodPost, path: "/oauth/session", want: "bearerAuth"},
+		{method: http.MethodGet, path: "/oauth/authorize", want: "sessionCookie"},
+		{method: http.MethodPost, path: "/oauth/token", want: ""},
+		{method: http.MethodGet, path: "/userinfo", want: ""},
+		{method: http.MethodGet, path: "/unknown", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, SecurityScheme(spec, tt.method, tt.path))
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	spec, err := Spec()
+	require.NoError(t, err)
+	catalog, err := i18n.New(i18n.English)
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.Use(LanguageMiddleware(catalog))
+	router := &Router{
+		Echo: e,
+		Middleware: func(method, path string) []echo.MiddlewareFunc {
+			return []echo.MiddlewareFunc{ValidateRequest(spec, method, path)}
+		},
+	}
+	noContent := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	router.GET("/admin/users", noContent)
+	router.GET("/admin/users/:id", noContent)
+	router.POST("/register", func(c echo.Context) error {
+		var request generated.RegisterRequest
+		if err := c.Bind(&request); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, request)
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		contentType    string
+		acceptLanguage string
+		expectedStatus int
+		expectedJSON   string
+	}{
+		{
+			name:           "Valid Query",
+			method:         http.MethodGet,
+			target:         "/admin/users?status=active&limit=100",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Query Out Of Range",
+			method:         http.MethodGet,
+			target:         "/admin/users?limit=1000&status=deleted",
+			expectedStatus: http.StatusBadRequest,
+			expectedJSON: `{"type":"urn:sawitpro:problem:validation_failed","title":"Bad Request","status":400,"code":"validation_failed","instance":"/admin/users",
+				"detail":"status must be one of active, disabled, limit must be at most 100",
+				"errors":[{"field":"status","rule":"enum","message":"status must be one of active, disabled"},{"field":"limit","rule":"maximum","message":"limit must be at most 100"}]}`,
+		},
+		{
+			name:           "Query Out Of Range In Indonesian",
+			method:         http.MethodGet,
+			target:         "/admin/users?limit=0",
+			acceptLanguage: "id",
+			expectedStatus: http.StatusBadRequest,
+			expectedJSON: `{"type":"urn:sawitpro:problem:validation_failed","title":"Permintaan Tidak Valid","status":400,"code":"validation_failed","instance":"/admin/users",
+				"detail":"limit minimal 1","errors":[{"field":"limit","rule":"minimum","message":"limit minimal 1"}]}`,
+		},
+		{
+			name:           "Path Parameter Not A Number",
+			method:         http.MethodGet,
+			target:         "/admin/users/abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedJSON: `{"type":"urn:sawitpro:problem:validation_failed","title":"Bad Request","status":400,"code":"validation_failed","instance":"/admin/users/abc",
+				"detail":"id is not valid","errors":[{"field":"id","rule":"type","message":"id is not valid"}]}`,
+		},
+		{
+			name:           "Valid Body",
+			method:         http.MethodPost,
+			target:         "/register",
+			body:           `{"phone":"+62812345678912","fullname":"mr smith","password":"A1234*"}`,
+			contentType:    echo.MIMEApplicationJSON,
+			expectedStatus: http.StatusOK,
+			expectedJSON:   `{"phone":"+62812345678912","fullname":"mr smith","password":"A1234*"}`,
+		},
+		{
+			name:           "Missing Properties",
+			method:         http.MethodPost,
+			target:         "/register",
+			body:           `{"phone":"+62812345678912"}`,
+			contentType:    echo.MIMEApplicationJSON,
+			expectedStatus: http.StatusBadRequest,
+			expectedJSON: `{"type":"urn:sawitpro:problem:validation_failed","title":"Bad Request","status":400,"code":"validation_failed","instance":"/register",
+				"detail":"fullname is required, password is required",
+				"errors":[{"field":"fullname","rule":"required","message":"fullname is required"},{"field":"password","rule":"required","message":"password is required"}]}`,
+		},
+		{
+			name:           "Malformed Body",
+			method:         http.MethodPost,
+			target:         "/register",
+			body:           `{"phone":`,
+			contentType:    echo.MIMEApplicationJSON,
+			expectedStatus: http.StatusBadRequest,
+			expectedJSON: `{"type":"urn:sawitpro:problem:invalid_body","title":"Bad Request","status":400,"code":"invalid_body","instance":"/register",
+				"detail":"fail to bind input, it might be bad request"}`,
+		},
+		{
+			name:           "Unsupported Content Type",
+			method:         http.MethodPost,
+			target:         "/register",
+			body:           `phone=%2B62812345678912`,
+			contentType:    echo.MIMEApplicationForm,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set(echo.HeaderContentType, tt.contentType)
+			}
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedJSON != "" {
+				assert.JSONEq(t, tt.expectedJSON, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// passkeyCeremonyLifetime is how long the client has to finish a passkey ceremony
+const passkeyCeremonyLifetime = 5 * time.Minute
+
+// NewWebAuthn creates the webauthn relying party. Passkeys must be
+// discoverable and verify the user, a passkey login replaces both the
+// password and the second factor
+func NewWebAuthn(rpID, rpName string, rpOrigins []string) (*webauthn.WebAuthn, error) {
+	requireResidentKey := true
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     rpOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			RequireResidentKey: &requireResidentKey,
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: passkeyCeremonyLifetime},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: passkeyCeremonyLifetime},
+		},
+	})
+}
+
+// BeginPasskeyRegistration handler for starting the registration of a passkey
+// for the logged in user
+func (h *UserHandler) BeginPasskeyRegistration(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	user, err := h.loadWebAuthnUser(claims.ID)
+	if err != nil {
+		return err
+	}
+
+	// the authenticator refuses to register a second passkey for the same account
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+	creation, session, err := h.WebAuthn.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return err
+	}
+
+	return h.startCeremony(c, models.CeremonyRegistration, claims.ID, session, creation)
+}
+
+// FinishPasskeyRegistration handler for verifying the new passkey and storing it
+func (h *UserHandler) FinishPasskeyRegistration(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	var input generated.PasskeyRegistrationRequest
+	if err := c.Bind(&input); err != nil {
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+	}
+	if err := c.Validate(input); err != nil {
+		return err
+	}
+
+	session, err := h.takeCeremony(input.CeremonyId, models.CeremonyRegistration, claims.ID)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return problem(c, http.StatusBadRequest, CodeBadRequest, "passkey ceremony is invalid or expired")
+	}
+
+	user, err := h.loadWebAuthnUser(claims.ID)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(input.Credential)
+	if err != nil {
+		return problem(c, http.StatusBadRequest, CodeBadRequest, "invalid passkey credential")
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(raw))
+	if err != nil {
+		return problem(c, http.StatusBadRequest, CodeBadRequest, "invalid passkey credential")
+	}
+	credential, err := h.WebAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return problem(c, http.StatusBadRequest, CodeBadRequest, "invalid passkey credential")
+	}
+
+	name := util.DeviceLabel(c.Request().UserAgent())
+	if input.Name != nil && *input.Name != "" {
+		name = *input.Name
+	}
+	name = util.Truncate(name, 60)
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	passkey := &models.WebAuthnCredential{
+		UserID:          claims.ID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       int64(credential.Authenticator.SignCount),
+		Transports:      strings.Join(transports, ","),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Name:            name,
+	}
+	err = h.WebAuthnRepo.CreateCredential(passkey)
+	if err == repository.ErrCredentialTaken {
+		return problem(c, http.StatusConflict, CodeConflict, "passkey already registered")
+	}
+	if err != nil {
+		return err
+	}
+	h.recordEvent(c, claims.ID, models.EventPasskeyAdded)
+
+	return c.JSON(http.StatusCreated, toPasskey(passkey))
+}
+
+// ListPasskeys handler for listing the passkeys of the logged in user
+func (h *UserHandler) ListPasskeys(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	credentials, err := h.WebAuthnRepo.ListCredentialsByUser(claims.ID)
+	if err != nil {
+		return err
+	}
+
+	response := generated.PasskeyListResponse{
+		Passkeys: make([]generated.Passkey, 0, len(credentials)),
+	}
+	for i := range credentials {
+		response.Passkeys = append(response.Passkeys, toPasskey(&credentials[i]))
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// DeletePasskey handler for removing a passkey of the logged in user
+func (h *UserHandler) DeletePasskey(c echo.Context, id int) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	err := h.WebAuthnRepo.DeleteCredential(id, claims.ID)
+	if err != nil {
+		if err.Error() == "record not found" {
+			return problem(c, http.StatusNotFound, CodeNotFound, "passkey not found")
+		}
+		return err
+	}
+	h.recordEvent(c, claims.ID, models.EventPasskeyRemoved)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// BeginPasskeyLogin handler for starting a passwordless login, any passkey
+// registered for this service can answer it
+func (h *UserHandler) BeginPasskeyLogin(c echo.Context) error {
+	assertion, session, err := h.WebAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		return err
+	}
+
+	return h.startCeremony(c, models.CeremonyLogin, 0, session, assertion)
+}
+
+// FinishPasskeyLogin handler for verifying the passkey assertion, it issues
+// the same token as Login
+func (h *UserHandler) FinishPasskeyLogin(c echo.Context) error {
+	var input generated.PasskeyLoginRequest
+	if err := c.Bind(&input); err != nil {
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+	}
+	if err := c.Validate(input); err != nil {
+		return err
+	}
+
+	session, err := h.takeCeremony(input.CeremonyId, models.CeremonyLogin, 0)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return problem(c, http.StatusUnauthorized, CodeUnauthorized, "passkey ceremony is invalid or expired")
+	}
+
+	raw, err := json.Marshal(input.Credential)
+	if err != nil {
+		return problem(c, http.StatusBadRequest, CodeBadRequest, "invalid passkey credential")
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(raw))
+	if err != nil {
+		return problem(c, http.StatusBadRequest, CodeBadRequest, "invalid passkey credential")
+	}
+
+	// the passkey names its owner through the user handle
+	var user *webAuthnUser
+	var lookupErr error
+	credential, err := h.WebAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		id, err := strconv.Atoi(string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+		user, lookupErr = h.loadWebAuthnUser(id)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		return user, nil
+	}, *session, parsed)
+	if lookupErr != nil && lookupErr.Error() != "record not found" {
+		return lookupErr
+	}
+	// a signature counter going backwards hints at a cloned authenticator
+	if err != nil || credential.Authenticator.CloneWarning {
+		return problem(c, http.StatusUnauthorized, CodeUnauthorized, "invalid passkey")
+	}
+
+	for _, passkey := range user.credentials {
+		if !bytes.Equal(passkey.CredentialID, credential.ID) {
+			continue
+		}
+		err = h.WebAuthnRepo.UpdateCredentialUsage(passkey.ID, int64(credential.Authenticator.SignCount), credential.Flags.BackupState, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	deviceLabel := ""
+	if input.DeviceLabel != nil {
+		deviceLabel = *input.DeviceLabel
+	}
+	return h.completeLogin(c, user.user, false, deviceLabel)
+}
+
+// startCeremony stores the webauthn session data and responds with the
+// options for the browser
+func (h *UserHandler) startCeremony(c echo.Context, kind string, userID int, session *webauthn.SessionData, options interface{}) error {
+	sessionData, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	ceremony := &models.WebAuthnCeremony{
+		ID:          util.GenerateSessionID(),
+		UserID:      userID,
+		Kind:        kind,
+		SessionData: string(sessionData),
+		ExpiresAt:   time.Now().Add(passkeyCeremonyLifetime),
+	}
+	err = h.WebAuthnRepo.CreateCeremony(ceremony)
+	if err != nil {
+		return err
+	}
+
+	// the generated type holds the options as a plain json object
+	raw, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	var optionsObject map[string]interface{}
+	if err := json.Unmarshal(raw, &optionsObject); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, generated.PasskeyCeremonyResponse{
+		CeremonyId: ceremony.ID,
+		Options:    optionsObject,
+	})
+}
+
+// takeCeremony consumes a ceremony started by startCeremony, it returns nil
+// when the ceremony is unknown, expired, already used or of another kind or user
+func (h *UserHandler) takeCeremony(id, kind string, userID int) (*webauthn.SessionData, error) {
+	ceremony, err := h.WebAuthnRepo.TakeCeremony(id, time.Now())
+	if err != nil {
+		if err.Error() == "record not found" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if ceremony.Kind != kind || ceremony.UserID != userID {
+		return nil, nil
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(ceremony.SessionData), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// loadWebAuthnUser loads an active user along with its passkeys
+func (h *UserHandler) loadWebAuthnUser(userID int) (*webAuthnUser, error) {
+	user, err := h.UserRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := h.WebAuthnRepo.ListCredentialsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+// toPasskey converts a stored credential into its api representation
+func toPasskey(credential *models.WebAuthnCredential) generated.Passkey {
+	return generated.Passkey{
+		Id:         credential.ID,
+		Name:       credential.Name,
+		CreatedAt:  credential.CreatedAt,
+		LastUsedAt: credential.LastUsedAt,
+	}
+}
+
+// webAuthnUser adapts a user and its passkeys to the webauthn library
+type webAuthnUser struct {
+	user        *models.User
+	credentials []models.WebAuthnCredential
+}
+
+// WebAuthnID is the user handle stored on the passkey, the user id is not
+// personal data so it is used as is
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.Itoa(u.user.ID))
+}
+
+// WebAuthnName is the account name shown by the authenticator
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.PhoneNumber
+}
+
+// WebAuthnDisplayName is the user name shown by the authenticator
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Fullname
+}
+
+// WebAuthnIcon is deprecated by the specification and left blank
+func (u *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+// WebAuthnCredentials lists the passkeys of the user
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, passkey := range u.credentials {
+		var transports []protocol.AuthenticatorTransport
+		if passkey.Transports != "" {
+			for _, transport := range strings.Split(passkey.Transports, ",") {
+				transports = append(transports, protocol.AuthenticatorTransport(transport))
+			}
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              passkey.CredentialID,
+			PublicKey:       passkey.PublicKey,
+			AttestationType: passkey.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: passkey.BackupEligible,
+				BackupState:    passkey.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    passkey.AAGUID,
+				SignCount: uint32(passkey.SignCount),
+			},
+		})
+	}
+	return credentials
+}
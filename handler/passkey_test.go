@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:1323"
+)
+
+// authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// softAuthenticator is a software passkey, it answers the options of the
+// registration and login ceremonies like a browser talking to a platform authenticator
+type softAuthenticator struct {
+	origin       string
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T, origin string) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	credentialID := make([]byte, 16)
+	rand.Read(credentialID)
+	return &softAuthenticator{origin: origin, key: key, credentialID: credentialID}
+}
+
+// register answers the options of navigator.credentials.create with a "none" attestation
+func (a *softAuthenticator) register(t *testing.T, options map[string]interface{}) map[string]interface{} {
+	publicKey := options["publicKey"].(map[string]interface{})
+	user := publicKey["user"].(map[string]interface{})
+	userHandle, err := base64.RawURLEncoding.DecodeString(user["id"].(string))
+	assert.NoError(t, err)
+	a.userHandle = userHandle
+
+	clientData := a.clientData(t, "webauthn.create", publicKey["challenge"].(string))
+
+	coseKey, err := cbor.Marshal(map[int]interface{}{
+		1:  2,  // kty EC2
+		3:  -7, // alg ES256
+		-1: 1,  // crv P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	assert.NoError(t, err)
+	attestedData := make([]byte, 16) // zero aaguid
+	attestedData = binary.BigEndian.AppendUint16(attestedData, uint16(len(a.credentialID)))
+	attestedData = append(attestedData, a.credentialID...)
+	attestedData = append(attestedData, coseKey...)
+
+	attestationObject, err := cbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": append(a.authData(flagUserPresent|flagUserVerified|flagAttestedData), attestedData...),
+	})
+	assert.NoError(t, err)
+
+	return map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+		},
+	}
+}
+
+// login answers the options of navigator.credentials.get with a signed assertion
+func (a *softAuthenticator) login(t *testing.T, options map[string]interface{}) map[string]interface{} {
+	publicKey := options["publicKey"].(map[string]interface{})
+	clientData := a.clientData(t, "webauthn.get", publicKey["challenge"].(string))
+
+	a.signCount++
+	authData := a.authData(flagUserPresent | flagUserVerified)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	assert.NoError(t, err)
+
+	return map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			"userHandle":        base64.RawURLEncoding.EncodeToString(a.userHandle),
+		},
+	}
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremonyType, challenge string) []byte {
+	clientData, err := json.Marshal(map[string]interface{}{
+		"type":      ceremonyType,
+		"challenge": challenge,
+		"origin":    a.origin,
+	})
+	assert.NoError(t, err)
+	return clientData
+}
+
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	authData := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(authData, a.signCount)
+}
+
+// passkeyHandler builds a handler whose webauthn repository keeps its state in memory
+func passkeyHandler(t *testing.T) (*UserHandler, *MockUserRepository, *mocks.WebAuthnRepository) {
+	webAuthn, err := NewWebAuthn(testRPID, "SawitPro", []string{testOrigin})
+	assert.NoError(t, err)
+
+	mockRepo := new(MockUserRepository)
+	webAuthnRepo := mocks.NewWebAuthnRepository(t)
+	ceremonies := map[string]*models.WebAuthnCeremony{}
+	var credentials []models.WebAuthnCredential
+
+	webAuthnRepo.On("CreateCeremony", mock.AnythingOfType("*models.WebAuthnCeremony")).Return(func(ceremony *models.WebAuthnCeremony) error {
+		ceremonies[ceremony.ID] = ceremony
+		return nil
+	}).Maybe()
+	webAuthnRepo.On("TakeCeremony", mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(func(id string, now time.Time) (*models.WebAuthnCeremony, error) {
+		ceremony, ok := ceremonies[id]
+		if !ok {
+			return nil, errors.New("record not found")
+		}
+		delete(ceremonies, id)
+		return ceremony, nil
+	}, nil).Maybe()
+	webAuthnRepo.On("CreateCredential", mock.AnythingOfType("*models.WebAuthnCredential")).Return(func(credential *models.WebAuthnCredential) error {
+		for _, registered := range credentials {
+			if bytes.Equal(registered.CredentialID, credential.CredentialID) {
+				return repository.ErrCredentialTaken
+			}
+		}
+		credential.ID = len(credentials) + 1
+		credentials = append(credentials, *credential)
+		return nil
+	}).Maybe()
+	webAuthnRepo.On("ListCredentialsByUser", 1).Return(func(userID int) ([]models.WebAuthnCredential, error) {
+		return credentials, nil
+	}, nil).Maybe()
+
+	handler := &UserHandler{
+		UserRepo:     mockRepo,
+		WebAuthn:     webAuthn,
+		WebAuthnRepo: webAuthnRepo,
+	}
+	return handler, mockRepo, webAuthnRepo
+}
+
+// beginCeremony calls a begin handler and returns its response
+func beginCeremony(t *testing.T, begin func() (int, []byte)) generated.PasskeyCeremonyResponse {
+	code, body := begin()
+	assert.Equal(t, http.StatusOK, code)
+
+	var response generated.PasskeyCeremonyResponse
+	assert.NoError(t, json.Unmarshal(body, &response))
+	return response
+}
+
+func passkeyBody(t *testing.T, body map[string]interface{}) string {
+	raw, err := json.Marshal(body)
+	assert.NoError(t, err)
+	return string(raw)
+}
+
+// finishPasskeyRegistration runs the registration ceremony of the authenticator for user 1
+func finishPasskeyRegistration(t *testing.T, handler *UserHandler, authenticator *softAuthenticator) *httptest.ResponseRecorder {
+	ceremony := beginCeremony(t, func() (int, []byte) {
+		rec, c := twoFactorEchoCtx(http.MethodPost, "/profile/passkeys/register/begin", "")
+		assert.NoError(t, handler.BeginPasskeyRegistration(c))
+		return rec.Code, rec.Body.Bytes()
+	})
+
+	rec, c := twoFactorEchoCtx(http.MethodPost, "/profile/passkeys/register/finish", passkeyBody(t, map[string]interface{}{
+		"ceremony_id": ceremony.CeremonyId,
+		"name":        "Budi's phone",
+		"credential":  authenticator.register(t, ceremony.Options),
+	}))
+	assert.NoError(t, handler.FinishPasskeyRegistration(c))
+	return rec
+}
+
+// registerPasskey registers the passkey of the authenticator for user 1
+func registerPasskey(t *testing.T, handler *UserHandler, authenticator *softAuthenticator) {
+	rec := finishPasskeyRegistration(t, handler, authenticator)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var passkey generated.Passkey
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &passkey))
+	assert.Equal(t, 1, passkey.Id)
+	assert.Equal(t, "Budi's phone", passkey.Name)
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	handler, mockRepo, webAuthnRepo := passkeyHandler(t)
+	sessionRepo := mocks.NewSessionRepository(t)
+	handler.SessionRepo = sessionRepo
+
+	mockRepo.On("FindByID", 1).Return(&models.User{ID: 1, PhoneNumber: "+62812345678912", Fullname: "mr smith"}, nil)
+	webAuthnRepo.On("UpdateCredentialUsage", 1, int64(1), false, mock.AnythingOfType("time.Time")).Return(nil)
+	sessionRepo.On("Create", mock.AnythingOfType("*models.Session")).Return(nil)
+
+	authenticator := newSoftAuthenticator(t, testOrigin)
+	registerPasskey(t, handler, authenticator)
+
+	ceremony := beginCeremony(t, func() (int, []byte) {
+		rec, c := registerEchoCtx("", "/login/passkey/begin")
+		assert.NoError(t, handler.BeginPasskeyLogin(c))
+		return rec.Code, rec.Body.Bytes()
+	})
+	body := passkeyBody(t, map[string]interface{}{
+		"ceremony_id": ceremony.CeremonyId,
+		"credential":  authenticator.login(t, ceremony.Options),
+	})
+
+	rec, c := registerEchoCtx(body, "/login/passkey/finish")
+	assert.NoError(t, handler.FinishPasskeyLogin(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response generated.LoginResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Id)
+	assert.NotEmpty(t, response.Token)
+
+	// the ceremony is consumed, replaying the same assertion is rejected
+	rec, c = registerEchoCtx(body, "/login/passkey/finish")
+	assert.NoError(t, handler.FinishPasskeyLogin(c))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.JSONEq(t, `{"type":"urn:sawitpro:problem:unauthorized","title":"Unauthorized","status":401,"code":"unauthorized","instance":"/login/passkey/finish","detail":"passkey ceremony is invalid or expired"}`, rec.Body.String())
+}
+
+func TestFinishPasskeyLoginWrongOrigin(t *testing.T) {
+	handler, mockRepo, _ := passkeyHandler(t)
+
+	mockRepo.On("FindByID", 1).Return(&models.User{ID: 1, PhoneNumber: "+62812345678912", Fullname: "mr smith"}, nil)
+
+	authenticator := newSoftAuthenticator(t, testOrigin)
+	registerPasskey(t, handler, authenticator)
+
+	ceremony := beginCeremony(t, func() (int, []byte) {
+		rec, c := registerEchoCtx("", "/login/passkey/begin")
+		assert.NoError(t, handler.BeginPasskeyLogin(c))
+		return rec.Code, rec.Body.Bytes()
+	})
+	// a phishing site relaying the challenge signs for its own origin
+	authenticator.origin = "https://sawitpro.example.com"
+
+	rec, c := registerEchoCtx(passkeyBody(t, map[string]interface{}{
+		"ceremony_id": ceremony.CeremonyId,
+		"credential":  authenticator.login(t, ceremony.Options),
+	}), "/login/passkey/finish")
+	assert.NoError(t, handler.FinishPasskeyLogin(c))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.JSONEq(t, `{"type":"urn:sawitpro:problem:unauthorized","title":"Unauthorized","status":401,"code":"unauthorized","instance":"/login/passkey/finish","detail":"invalid passkey"}`, rec.Body.String())
+}
+
+func TestFinishPasskeyRegistrationTaken(t *testing.T) {
+	handler, mockRepo, _ := passkeyHandler(t)
+	mockRepo.On("FindByID", 1).Return(&models.User{ID: 1, PhoneNumber: "+62812345678912", Fullname: "mr smith"}, nil)
+
+	authenticator := newSoftAuthenticator(t, testOrigin)
+	registerPasskey(t, handler, authenticator)
+
+	rec := finishPasskeyRegistration(t, handler, authenticator)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.JSONEq(t, `{"type":"urn:sawitpro:problem:conflict","title":"Conflict","status":409,"code":"conflict","instance":"/profile/passkeys/register/finish","detail":"passkey already registered"}`, rec.Body.String())
+}
+
+func TestFinishPasskeyRegistrationWrongCeremony(t *testing.T) {
+	handler, _, _ := passkeyHandler(t)
+
+	// a login ceremony can not be used to register a passkey
+	ceremony := beginCeremony(t, func() (int, []byte) {
+		rec, c := registerEchoCtx("", "/login/passkey/begin")
+		assert.NoError(t, handler.BeginPasskeyLogin(c))
+		return rec.Code, rec.Body.Bytes()
+	})
+
+	rec, c := twoFactorEchoCtx(http.MethodPost, "/profile/passkeys/register/finish", passkeyBody(t, map[string]interface{}{
+		"ceremony_id": ceremony.CeremonyId,
+		"credential":  map[string]interface{}{"id": "abc"},
+	}))
+	assert.NoError(t, handler.FinishPasskeyRegistration(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"type":"urn:sawitpro:problem:bad_request","title":"Bad Request","status":400,"code":"bad_request","instance":"/profile/passkeys/register/finish","detail":"passkey ceremony is invalid or expired"}`, rec.Body.String())
+}
+
+func TestDeletePasskeyNotFound(t *testing.T) {
+	webAuthnRepo := mocks.NewWebAuthnRepository(t)
+	handler := &UserHandler{
+		WebAuthnRepo: webAuthnRepo,
+	}
+
+	webAuthnRepo.On("DeleteCredential", 7, 1).Return(errors.New("record not found"))
+
+	rec, c := twoFactorEchoCtx(http.MethodDelete, "/profile/passkeys/7", "")
+	assert.NoError(t, handler.DeletePasskey(c, 7))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.JSONEq(t, `{"type":"urn:sawitpro:problem:not_found","title":"Not Found","status":404,"code":"not_found","instance":"/profile/passkeys/7","detail":"passkey not found"}`, rec.Body.String())
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix turns a problem code into the problem type
+const problemTypePrefix = "urn:sawitpro:problem:"
+
+// Problem codes, they are stable so clients branch on them instead of the detail
+const (
+	CodeBadRequest         = "bad_request"
+	CodeInvalidBody        = "invalid_body"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeInvalidCode        = "invalid_code"
+	CodeInvalidMfaToken    = "invalid_mfa_token"
+	CodeForbidden          = "forbidden"
+	CodeAccountDisabled    = "account_disabled"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeConflict           = "conflict"
+	CodePayloadTooLarge    = "payload_too_large"
+	CodePhoneRegistered    = "phone_registered"
+	CodeTooManyRequests    = "too_many_requests"
+	CodeInternal           = "internal_error"
+	CodeUnavailable        = "service_unavailable"
+
+	// CodePasswordChangeRequired rejects the tokens of users who must change
+	// their password on every route but PUT /profile/password
+	CodePasswordChangeRequired = "password_change_required"
+)
+
+// statusCodes are the codes of problems that have nothing more specific to say than their status
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusTooManyRequests:       CodeTooManyRequests,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+}
+
+// NewProblem builds the problem details of the request, the title and the
+// detail are translated to the language of the request
+func NewProblem(c echo.Context, status int, code, detail string) generated.Problem {
+	problem := generated.Problem{
+		Type:   problemTypePrefix + code,
+		Title:  translate(c, http.StatusText(status)),
+		Status: status,
+		Code:   code,
+		Detail: translate(c, detail),
+	}
+	if path := c.Request().URL.Path; path != "" {
+		problem.Instance = &path
+	}
+	if requestID := c.Response().Header().Get(echo.HeaderXRequestID); requestID != "" {
+		problem.RequestId = &requestID
+	}
+	return problem
+}
+
+// problem responds with the problem details
+func problem(c echo.Context, status int, code, detail string) error {
+	return writeProblem(c, NewProblem(c, status, code, detail))
+}
+
+// invalidFields responds with a validation problem listing the rules every
+// field breaks, the messages must be translated already
+func invalidFields(c echo.Context, fieldErrors []generated.FieldError) error {
+	messages := make([]string, len(fieldErrors))
+	for i, fieldError := range fieldErrors {
+		messages[i] = fieldError.Message
+	}
+	problem := NewProblem(c, http.StatusBadRequest, CodeValidationFailed, strings.Join(messages, ", "))
+	problem.Errors = &fieldErrors
+	return writeProblem(c, problem)
+}
+
+// invalidField responds with a validation problem of a single field
+func invalidField(c echo.Context, field, rule, message string) error {
+	return invalidFields(c, []generated.FieldError{{Field: field, Rule: rule, Message: translate(c, message)}})
+}
+
+func writeProblem(c echo.Context, problem generated.Problem) error {
+	c.Response().Header().Set(echo.HeaderContentType, ProblemContentType)
+	return c.JSON(problem.Status, problem)
+}
+
+// HTTPErrorHandler renders the errors returned by handlers and middleware as
+// problem details, validation errors list every invalid field
+func HTTPErrorHandler(err error, c echo.Context) {
+	// the request id is in the problem too, so a report from a client leads to its log line
+	c.Logger().Errorf("request %s: %v", c.Response().Header().Get(echo.HeaderXRequestID), err)
+	// a streamed response such as the data export might have been sent partially already
+	if c.Response().Committed {
+		return
+	}
+
+	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		fieldErrors := make([]generated.FieldError, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			fieldErrors[i] = generated.FieldError{
+				Field:   fieldError.Field(),
+				Rule:    fieldError.Tag(),
+				Message: validationMessage(c, fieldError),
+			}
+		}
+		invalidFields(c, fieldErrors)
+		return
+	}
+
+	// any other error is a failure of the service, its message is only logged
+	status, detail := http.StatusInternalServerError, "internal server error"
+	if httpError, ok := err.(*echo.HTTPError); ok {
+		status, detail = httpError.Code, fmt.Sprint(httpError.Message)
+	}
+	code, ok := statusCodes[status]
+	if !ok {
+		code = CodeBadRequest
+		if status >= http.StatusInternalServerError {
+			code = CodeInternal
+		}
+	}
+	problem(c, status, code, detail)
+}
+
+// validationMessage describes a failed validation tag of a field, the six
+// most common tags are described in English when the request has no translator
+func validationMessage(c echo.Context, fieldError validator.FieldError) string {
+	if trans := translator(c); trans != nil {
+		return i18n.ValidationMessage(trans, fieldError)
+	}
+	switch fieldError.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fieldError.Field())
+	case "email":
+		return fmt.Sprintf("%s is not valid email", fieldError.Field())
+	case "gte":
+		return fmt.Sprintf("%s value must be greater than %s", fieldError.Field(), fieldError.Param())
+	case "lte":
+		return fmt.Sprintf("%s value must be lower than %s", fieldError.Field(), fieldError.Param())
+	case "min":
+		return fmt.Sprintf("%s must have length or number at least %s", fieldError.Field(), fieldError.Param())
+	case "max":
+		return fmt.Sprintf("%s must have length or number with maximum %s", fieldError.Field(), fieldError.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", fieldError.Field(), fieldError.Param())
+	default:
+		return fmt.Sprintf("%s is not valid", fieldError.Field())
+	}
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPErrorHandler(t *testing.T) {
+	type input struct {
+		Phone    string `validate:"required"`
+		FullName string `validate:"min=3"`
+	}
+	validationErr := validator.New().Struct(input{FullName: "Al"})
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedJSON   string
+	}{
+		{
+			name:           "HTTP Error",
+			err:            echo.ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedJSON:   `{"type":"urn:sawitpro:problem:not_found","title":"Not Found","status":404,"code":"not_found","instance":"/unknown","request_id":"req-1","detail":"Not Found"}`,
+		},
+		{
+			name:           "Payload Too Large",
+			err:            echo.NewHTTPError(http.StatusRequestEntityTooLarge, "body is too large"),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedJSON:   `{"type":"urn:sawitpro:problem:payload_too_large","title":"Request Entity Too Large","status":413,"code":"payload_too_large","instance":"/unknown","request_id":"req-1","detail":"body is too large"}`,
+		},
+		{
+			name:           "Status Without Code",
+			err:            echo.NewHTTPError(http.StatusUnsupportedMediaType, "unsupported media type"),
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedJSON:   `{"type":"urn:sawitpro:problem:bad_request","title":"Unsupported Media Type","status":415,"code":"bad_request","instance":"/unknown","request_id":"req-1","detail":"unsupported media type"}`,
+		},
+		{
+			name:           "Plain Error",
+			err:            errors.New("pq: connection refused"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedJSON:   `{"type":"urn:sawitpro:problem:internal_error","title":"Internal Server Error","status":500,"code":"internal_error","instance":"/unknown","request_id":"req-1","detail":"internal server error"}`,
+		},
+		{
+			name:           "Validation Errors",
+			err:            validationErr,
+			expectedStatus: http.StatusBadRequest,
+			expectedJSON: `{"type":"urn:sawitpro:problem:validation_failed","title":"Bad Request","status":400,"code":"validation_failed","instance":"/unknown","request_id":"req-1",
+				"detail":"Phone is required, FullName must have length or number at least 3",
+				"errors":[{"field":"Phone","rule":"required","message":"Phone is required"},
+				{"field":"FullName","rule":"min","message":"FullName must have length or number at least 3"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/unknown", nil)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.Response().Header().Set(echo.HeaderXRequestID, "req-1")
+
+			HTTPErrorHandler(tt.err, c)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, ProblemContentType, rec.Header().Get(echo.HeaderContentType))
+			assert.JSONEq(t, tt.expectedJSON, rec.Body.String())
+		})
+	}
+}
+
+func TestHTTPErrorHandlerCommittedResponse(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/profile/export", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.String(http.StatusOK, "partial")
+
+	HTTPErrorHandler(errors.New("stream broke"), c)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "partial", rec.Body.String())
+}
+
+func TestHTTPErrorHandlerLogsRequestID(t *testing.T) {
+	e := echo.New()
+	var logs bytes.Buffer
+	e.Logger.SetOutput(&logs)
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Response().Header().Set(echo.HeaderXRequestID, "req-1")
+
+	HTTPErrorHandler(errors.New("pq: connection refused"), c)
+
+	assert.Contains(t, logs.String(), "request req-1: pq: connection refused")
+	assert.NotContains(t, rec.Body.String(), "connection refused")
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/ratelimit"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// maxRateLimitBody is how much of the request body is read to find the phone number
+const maxRateLimitBody = 64 << 10
+
+// RateLimitRule is one bucket of a rate limit policy, Key names the bucket of
+// a request and an empty key skips the rule
+type RateLimitRule struct {
+	Name  string
+	Limit ratelimit.Limit
+	Key   func(c echo.Context) string
+}
+
+// RateLimit limits the requests to a route, every rule takes a token from its
+// own bucket and the request is rejected with 429 when any of them is empty.
+// The X-RateLimit headers describe the most restrictive bucket. Requests are
+// let through when the store fails so an outage of it does not lock users out
+func RateLimit(store ratelimit.Store, policy string, rules ...RateLimitRule) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			now := time.Now()
+			var strictest *ratelimit.Result
+			for _, rule := range rules {
+				key := rule.Key(c)
+				if key == "" {
+					continue
+				}
+				result, err := store.Take(policy+":"+rule.Name+":"+key, rule.Limit, now)
+				if err != nil {
+					c.Logger().Errorf("fail to rate limit %s by %s: %v", policy, rule.Name, err)
+					continue
+				}
+				if strictest == nil || stricter(result, *strictest) {
+					strictest = &result
+				}
+			}
+			if strictest == nil {
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set("X-RateLimit-Limit", strconv.Itoa(strictest.Limit))
+			header.Set("X-RateLimit-Remaining", strconv.Itoa(strictest.Remaining))
+			header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(strictest.ResetAfter)))
+			if !strictest.Allowed {
+				header.Set("Retry-After", strconv.Itoa(ceilSeconds(strictest.RetryAfter)))
+				return echo.NewHTTPError(http.StatusTooManyRequests, "too many requests, try again later")
+			}
+			return next(c)
+		}
+	}
+}
+
+// NewIPExtractor finds the client ip address of a request, X-Forwarded-For is
+// only trusted from the proxies so clients can not pick their own address to
+// get around the rate limits. Without proxies the peer is the client
+func NewIPExtractor(proxies []*net.IPNet) echo.IPExtractor {
+	if len(proxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range proxies {
+		options = append(options, echo.TrustIPRange(proxy))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// RateLimitByIP keys the bucket on the client ip address
+func RateLimitByIP(c echo.Context) string {
+	return c.RealIP()
+}
+
+// RateLimitByPhone keys the bucket on the phone number in the json body, the
+// body is put back for the handler
+func RateLimitByPhone(c echo.Context) string {
+	var input struct {
+		Phone string `json:"phone"`
+	}
+	if !peekBody(c, &input) {
+		return ""
+	}
+	return strings.TrimSpace(input.Phone)
+}
+
+// RateLimitByMfaUser keys the bucket on the user the mfa token in the json
+// body was issued to, so guessing codes with fresh tokens shares one bucket
+func RateLimitByMfaUser(c echo.Context) string {
+	claims := peekMfaToken(c)
+	if claims == nil {
+		return ""
+	}
+	return strconv.Itoa(claims.ID)
+}
+
+// RateLimitByMfaToken keys the bucket on the mfa token in the json body, a
+// limit refilling slower than the token lives locks the token after its burst
+func RateLimitByMfaToken(c echo.Context) string {
+	claims := peekMfaToken(c)
+	if claims == nil {
+		return ""
+	}
+	return claims.RegisteredClaims.ID
+}
+
+// peekMfaToken verifies the mfa token in the json body, invalid tokens are
+// rejected by the handler so they are not limited
+func peekMfaToken(c echo.Context) *MfaChallengeClaims {
+	var input struct {
+		MfaToken string `json:"mfa_token"`
+	}
+	if !peekBody(c, &input) {
+		return nil
+	}
+	claims, err := parseMfaToken(input.MfaToken)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
+
+// peekBody decodes the json body into input and puts the body back for the handler
+func peekBody(c echo.Context, input interface{}) bool {
+	req := c.Request()
+	if req.Body == nil {
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxRateLimitBody))
+	req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), req.Body))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(body, input) == nil
+}
+
+// RateLimitByUser keys the bucket on the logged in user, it must run after the jwt middleware
+func RateLimitByUser(c echo.Context) string {
+	userToken, ok := c.Get("user").(*jwt.Token)
+	if !ok {
+		return ""
+	}
+	claims, ok := userToken.Claims.(*JwtCustomClaims)
+	if !ok {
+		return ""
+	}
+	return strconv.Itoa(claims.ID)
+}
+
+// stricter reports whether result a is more restrictive than b, a denied
+// result waits the longest and otherwise the fewest remaining requests win
+func stricter(a, b ratelimit.Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/ratelimit"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// echoPhone is a handler answering with the phone number it was sent
+func echoPhone(c echo.Context) error {
+	var input generated.LoginRequest
+	if err := c.Bind(&input); err != nil {
+		return err
+	}
+	return c.String(http.StatusOK, input.Phone)
+}
+
+func rateLimitRequest(mw echo.MiddlewareFunc, ip, jsonInput string) (*httptest.ResponseRecorder, error) {
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(jsonInput))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.RemoteAddr = ip + ":54321"
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	return rec, mw(echoPhone)(c)
+}
+
+func TestRateLimit(t *testing.T) {
+	mw := RateLimit(ratelimit.NewMemoryStore(), "login",
+		RateLimitRule{Name: "ip", Limit: ratelimit.Limit{Burst: 3, Interval: time.Minute}, Key: RateLimitByIP},
+		RateLimitRule{Name: "phone", Limit: ratelimit.Limit{Burst: 2, Interval: time.Minute}, Key: RateLimitByPhone},
+	)
+
+	tests := []struct {
+		name          string
+		ip            string
+		phone         string
+		wantStatus    int
+		wantRemaining string
+	}{
+		{name: "First Request", ip: "10.0.0.1", phone: "+62812345678912", wantStatus: http.StatusOK, wantRemaining: "1"},
+		{name: "Second Request", ip: "10.0.0.2", phone: "+62812345678912", wantStatus: http.StatusOK, wantRemaining: "0"},
+		{name: "Phone Limited From Another IP", ip: "10.0.0.3", phone: "+62812345678912", wantStatus: http.StatusTooManyRequests, wantRemaining: "0"},
+		{name: "Another Phone", ip: "10.0.0.1", phone: "+62812345678913", wantStatus: http.StatusOK, wantRemaining: "1"},
+		{name: "Last Request Of The IP", ip: "10.0.0.1", phone: "+62812345678914", wantStatus: http.StatusOK, wantRemaining: "0"},
+		{name: "IP Exhausted", ip: "10.0.0.1", phone: "+62812345678915", wantStatus: http.StatusTooManyRequests, wantRemaining: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := rateLimitRequest(mw, tt.ip, `{"phone": "`+tt.phone+`", "password": "A1234*"}`)
+			assert.Equal(t, tt.wantRemaining, rec.Header().Get("X-RateLimit-Remaining"))
+			if tt.wantStatus == http.StatusTooManyRequests {
+				var httpError *echo.HTTPError
+				assert.ErrorAs(t, err, &httpError)
+				assert.Equal(t, http.StatusTooManyRequests, httpError.Code)
+				assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+				return
+			}
+			assert.NoError(t, err)
+			// the handler still reads the body the phone number was taken from
+			assert.Equal(t, tt.phone, rec.Body.String())
+			assert.NotEmpty(t, rec.Header().Get("X-RateLimit-Reset"))
+		})
+	}
+}
+
+func TestRateLimitStoreFailure(t *testing.T) {
+	store := mocks.NewRateLimitRepository(t)
+	store.On("Take", "login:ip:10.0.0.1", mock.AnythingOfType("ratelimit.Limit"), mock.AnythingOfType("time.Time")).
+		Return(ratelimit.Result{}, errors.New("connection refused"))
+	mw := RateLimit(store, "login", RateLimitRule{Name: "ip", Limit: ratelimit.Limit{Burst: 1, Interval: time.Minute}, Key: RateLimitByIP})
+
+	rec, err := rateLimitRequest(mw, "10.0.0.1", `{"phone": "+62812345678912"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-RateLimit-Limit"))
+}
+
+func TestNewIPExtractor(t *testing.T) {
+	_, proxy, _ := net.ParseCIDR("10.0.0.0/8")
+	tests := []struct {
+		name    string
+		proxies []*net.IPNet
+		peer    string
+		want    string
+	}{
+		{
+			name: "Without Proxies The Peer Is The Client",
+			peer: "10.0.0.2",
+			want: "10.0.0.2",
+		},
+		{
+			name:    "Trusted Proxy",
+			proxies: []*net.IPNet{proxy},
+			peer:    "10.0.0.2",
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "Private Peer That Is Not A Proxy",
+			proxies: []*net.IPNet{proxy},
+			peer:    "172.17.0.1",
+			want:    "172.17.0.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/login", nil)
+			req.RemoteAddr = tt.peer + ":54321"
+			req.Header.Set(echo.HeaderXForwardedFor, "203.0.113.7")
+			assert.Equal(t, tt.want, NewIPExtractor(tt.proxies)(req))
+		})
+	}
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	_, c := twoFactorEchoCtx(http.MethodPut, "/profile/password", "")
+	assert.Equal(t, "1", RateLimitByUser(c))
+
+	c.Set("user", nil)
+	assert.Equal(t, "", RateLimitByUser(c))
+
+	c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{}))
+	assert.Equal(t, "", RateLimitByUser(c))
+}
+
+func TestRateLimitByMfaToken(t *testing.T) {
+	mw := RateLimit(ratelimit.NewMemoryStore(), "second-factor",
+		RateLimitRule{Name: "user", Limit: ratelimit.Limit{Burst: 4, Interval: time.Minute}, Key: RateLimitByMfaUser},
+		RateLimitRule{Name: "token", Limit: ratelimit.Limit{Burst: 2, Interval: time.Hour}, Key: RateLimitByMfaToken},
+	)
+	first := loginChallenge(t, twoFactorUser())
+	second := loginChallenge(t, twoFactorUser())
+
+	tests := []struct {
+		name       string
+		mfaToken   string
+		wantStatus int
+	}{
+		{name: "First Code", mfaToken: first, wantStatus: http.StatusOK},
+		{name: "Second Code", mfaToken: first, wantStatus: http.StatusOK},
+		{name: "Token Locked", mfaToken: first, wantStatus: http.StatusTooManyRequests},
+		{name: "Fresh Token Of The User", mfaToken: second, wantStatus: http.StatusOK},
+		{name: "User Exhausted", mfaToken: second, wantStatus: http.StatusTooManyRequests},
+		{name: "Invalid Token Left To The Handler", mfaToken: "bogus", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := rateLimitRequest(mw, "10.0.0.1", `{"mfa_token": "`+tt.mfaToken+`", "code": "123456"}`)
+			if tt.wantStatus == http.StatusTooManyRequests {
+				var httpError *echo.HTTPError
+				assert.ErrorAs(t, err, &httpError)
+				assert.Equal(t, http.StatusTooManyRequests, httpError.Code)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+)
+
+// Server serves every operation of api.yml, the operations are implemented by
+// the user, admin, OpenID Connect and health handlers
+type Server struct {
+	*UserHandler
+	*AdminHandler
+	*OIDCHandler
+	*HealthHandler
+}
+
+var _ generated.ServerInterface = (*Server)(nil)
+
+// RouteMiddleware returns the middleware of a route, method and path are the
+// ones of the echo route such as DELETE /profile/sessions/:id
+type RouteMiddleware func(method, path string) []echo.MiddlewareFunc
+
+// SpecMiddleware is the RouteMiddleware of the operations of the spec, the
+// ones with security run the authenticate middleware of their security scheme,
+// such as bearerAuth, and the admin ones need the admin role.
+// rateLimits are keyed by method and path such as "POST /login". Requests are
+// validated against the spec except on the OAuth endpoints, they answer with
+// the errors of RFC 6749
+func SpecMiddleware(spec *openapi3.T, authenticate map[string][]echo.MiddlewareFunc, rateLimits map[string]echo.MiddlewareFunc) RouteMiddleware {
+	return func(method, path string) []echo.MiddlewareFunc {
+		var middleware []echo.MiddlewareFunc
+		if scheme := SecurityScheme(spec, method, path); scheme != "" {
+			schemeMiddleware, ok := authenticate[scheme]
+			if !ok {
+				panic(fmt.Sprintf("no authentication for the %s security scheme of %s %s", scheme, method, path))
+			}
+			middleware = append(middleware, schemeMiddleware...)
+		}
+		if strings.HasPrefix(path, "/admin/") {
+			middleware = append(middleware, RequireRole(models.RoleAdmin))
+		}
+		if rateLimit, ok := rateLimits[method+" "+path]; ok {
+			middleware = append(middleware, rateLimit)
+		}
+		if !strings.HasPrefix(path, "/oauth/") {
+			middleware = append(middleware, ValidateRequest(spec, method, path))
+		}
+		return middleware
+	}
+}
+
+// Router registers the routes of generated.RegisterHandlers on Echo with the
+// middleware Middleware returns for each of them
+type Router struct {
+	Echo       *echo.Echo
+	Middleware RouteMiddleware
+}
+
+var _ generated.EchoRouter = (*Router)(nil)
+
+func (r *Router) add(method, path string, h echo.HandlerFunc, m []echo.MiddlewareFunc) *echo.Route {
+	if r.Middleware != nil {
+		m = append(m, r.Middleware(method, path)...)
+	}
+	return r.Echo.Add(method, path, h, m...)
+}
+
+func (r *Router) CONNECT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodConnect, path, h, m)
+}
+
+func (r *Router) DELETE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodDelete, path, h, m)
+}
+
+func (r *Router) GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodGet, path, h, m)
+}
+
+func (r *Router) HEAD(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodHead, path, h, m)
+}
+
+func (r *Router) OPTIONS(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodOptions, path, h, m)
+}
+
+func (r *Router) PATCH(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodPatch, path, h, m)
+}
+
+func (r *Router) POST(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodPost, path, h, m)
+}
+
+func (r *Router) PUT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodPut, path, h, m)
+}
+
+func (r *Router) TRACE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodTrace, path, h, m)
+}
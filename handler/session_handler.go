@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// ListSessions handler for listing the devices the user is logged in on
+func (h *UserHandler) ListSessions(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	sessions, err := h.SessionRepo.ListActiveByUser(claims.ID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	response := generated.SessionListResponse{
+		Sessions: make([]generated.Session, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, generated.Session{
+			Id:          session.ID,
+			DeviceLabel: session.DeviceLabel,
+			UserAgent:   session.UserAgent,
+			IpAddress:   session.IPAddress,
+			CreatedAt:   session.CreatedAt,
+			LastSeenAt:  session.LastSeenAt,
+			Current:     session.ID == claims.SessionID,
+		})
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// RevokeSession handler for logging the user out of one device
+func (h *UserHandler) RevokeSession(c echo.Context, id string) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	err := h.SessionRepo.Revoke(id, claims.ID)
+	if err != nil {
+		if err.Error() == "record not found" {
+			return problem(c, http.StatusNotFound, CodeNotFound, "session not found")
+		}
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
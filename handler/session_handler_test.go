@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func sessionEchoCtx(method, target string) (*httptest.ResponseRecorder, echo.Context) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{ID: 123, SessionID: "current"})
+	req := httptest.NewRequest(method, target, nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user", token)
+	return rec, c
+}
+
+func TestListSessions(t *testing.T) {
+	sessionRepo := mocks.NewSessionRepository(t)
+	handler := &UserHandler{
+		SessionRepo: sessionRepo,
+	}
+
+	rec, c := sessionEchoCtx(http.MethodGet, "/profile/sessions")
+
+	seenAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sessionRepo.On("ListActiveByUser", 123, mock.AnythingOfType("time.Time")).Return([]models.Session{
+		{ID: "current", UserID: 123, DeviceLabel: "Chrome on Android", UserAgent: "Mozilla/5.0", IPAddress: "10.0.0.1", CreatedAt: seenAt, LastSeenAt: seenAt},
+		{ID: "other", UserID: 123, DeviceLabel: "Unknown device", UserAgent: "curl/8.4.0", IPAddress: "10.0.0.2", CreatedAt: seenAt, LastSeenAt: seenAt},
+	}, nil)
+
+	err := handler.ListSessions(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	expectedJSON := `{"sessions":[
+		{"id":"current","device_label":"Chrome on Android","user_agent":"Mozilla/5.0","ip_address":"10.0.0.1",
+		"created_at":"2024-01-01T00:00:00Z","last_seen_at":"2024-01-01T00:00:00Z","current":true},
+		{"id":"other","device_label":"Unknown device","user_agent":"curl/8.4.0","ip_address":"10.0.0.2",
+		"created_at":"2024-01-01T00:00:00Z","last_seen_at":"2024-01-01T00:00:00Z","current":false}]}`
+	assert.JSONEq(t, expectedJSON, rec.Body.String())
+}
+
+func TestRevokeSession(t *testing.T) {
+	sessionRepo := mocks.NewSessionRepository(t)
+	handler := &UserHandler{
+		SessionRepo: sessionRepo,
+	}
+
+	rec, c := sessionEchoCtx(http.MethodDelete, "/profile/sessions/other")
+	sessionRepo.On("Revoke", "other", 123).Return(nil)
+
+	err := handler.RevokeSession(c, "other")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestRevokeSessionNotFound(t *testing.T) {
+	sessionRepo := mocks.NewSessionRepository(t)
+	handler := &UserHandler{
+		SessionRepo: sessionRepo,
+	}
+
+	rec, c := sessionEchoCtx(http.MethodDelete, "/profile/sessions/unknown")
+	sessionRepo.On("Revoke", "unknown", 123).Return(errors.New("record not found"))
+
+	err := handler.RevokeSession(c, "unknown")
+	assert.NoError(t, err)
+
+	expectedJSON := `{"type":"urn:sawitpro:problem:not_found","title":"Not Found","status":404,"code":"not_found","instance":"/profile/sessions/unknown","detail":"session not found"}`
+	assert.JSONEq(t, expectedJSON, rec.Body.String())
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
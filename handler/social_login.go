@@ -0,0 +1,344 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/identity"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// identityLoginLifetime is how long the user has to sign in at the identity provider
+const identityLoginLifetime = 10 * time.Minute
+
+// fullname lengths the users table accepts, in characters
+const (
+	minFullnameLength = 3
+	maxFullnameLength = 60
+)
+
+// BeginSocialLogin handler for starting a sign in with an external identity provider
+func (h *UserHandler) BeginSocialLogin(c echo.Context, providerName string) error {
+	return h.startIdentityLogin(c, providerName, 0)
+}
+
+// FinishSocialLogin handler for finishing a sign in with an external identity
+// provider. An identity that is not linked yet signs up a new user when the
+// provider shares a verified phone number, the new user has no password
+func (h *UserHandler) FinishSocialLogin(c echo.Context, providerName string) error {
+	var input generated.SocialLoginFinishRequest
+	if err := c.Bind(&input); err != nil {
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+	}
+	if err := c.Validate(input); err != nil {
+		return err
+	}
+
+	if _, ok := h.IdentityProviders[providerName]; !ok {
+		return problem(c, http.StatusNotFound, CodeNotFound, "identity provider not found")
+	}
+	external, err := h.finishIdentityLogin(c, providerName, input.State, input.Code, 0)
+	if err != nil {
+		return err
+	}
+	if external == nil {
+		return problem(c, http.StatusUnauthorized, CodeUnauthorized, "invalid or expired state, or the identity provider rejected the code")
+	}
+
+	link, err := h.IdentityRepo.FindByProviderSubject(providerName, external.Subject)
+	if err != nil && err.Error() != "record not found" {
+		return err
+	}
+
+	var user *models.User
+	if link != nil {
+		user, err = h.UserRepo.FindByID(link.UserID)
+		if err != nil {
+			// accounts pending deletion are restored with a password login
+			if err.Error() == "record not found" {
+				return problem(c, http.StatusUnauthorized, CodeUnauthorized, "account not found")
+			}
+			return err
+		}
+	} else {
+		if !strings.HasPrefix(external.PhoneNumber, "+62") {
+			return problem(c, http.StatusBadRequest, CodeBadRequest, "the identity provider did not share a verified +62 phone number to sign up with")
+		}
+		existingUser, err := h.findLoginUser(external.PhoneNumber)
+		if err != nil {
+			return err
+		}
+		// linking needs a login to the existing account, a matching phone number is not enough
+		if existingUser != nil {
+			return problem(c, http.StatusConflict, CodePhoneRegistered, "phone number already registered, log in and link the identity provider from the profile")
+		}
+
+		fullname, ok := signUpFullname(external.Name)
+		if !ok {
+			return problem(c, http.StatusBadRequest, CodeBadRequest, "the identity provider did not share a name of at least 3 characters to sign up with")
+		}
+
+		user, err = h.signUpWithIdentity(c, providerName, external, fullname)
+		if err != nil {
+			return err
+		}
+	}
+
+	deviceLabel := ""
+	if input.DeviceLabel != nil {
+		deviceLabel = *input.DeviceLabel
+	}
+	// the provider only replaces the password, two factor authentication still applies
+	if user.TOTPEnabled {
+		return h.mfaChallenge(c, user, false, deviceLabel)
+	}
+	return h.completeLogin(c, user, false, deviceLabel)
+}
+
+// ListIdentities handler for listing the identity providers linked to the logged in user
+func (h *UserHandler) ListIdentities(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	identities, err := h.IdentityRepo.ListByUser(claims.ID)
+	if err != nil {
+		return err
+	}
+
+	response := generated.LinkedIdentityListResponse{
+		Identities: make([]generated.LinkedIdentity, 0, len(identities)),
+	}
+	for i := range identities {
+		response.Identities = append(response.Identities, toLinkedIdentity(&identities[i]))
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// BeginLinkIdentity handler for starting to link an identity provider to the logged in user
+func (h *UserHandler) BeginLinkIdentity(c echo.Context, providerName string) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	return h.startIdentityLogin(c, providerName, claims.ID)
+}
+
+// FinishLinkIdentity handler for linking the identity the user signed in with at the provider
+func (h *UserHandler) FinishLinkIdentity(c echo.Context, providerName string) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	var input generated.LinkIdentityRequest
+	if err := c.Bind(&input); err != nil {
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+	}
+	if err := c.Validate(input); err != nil {
+		return err
+	}
+
+	if _, ok := h.IdentityProviders[providerName]; !ok {
+		return problem(c, http.StatusNotFound, CodeNotFound, "identity provider not found")
+	}
+	external, err := h.finishIdentityLogin(c, providerName, input.State, input.Code, claims.ID)
+	if err != nil {
+		return err
+	}
+	if external == nil {
+		return problem(c, http.StatusBadRequest, CodeBadRequest, "invalid or expired state, or the identity provider rejected the code")
+	}
+
+	link, err := h.IdentityRepo.FindByProviderSubject(providerName, external.Subject)
+	if err != nil && err.Error() != "record not found" {
+		return err
+	}
+	if link != nil {
+		return problem(c, http.StatusConflict, CodeConflict, "the identity is already linked to a user")
+	}
+	identities, err := h.IdentityRepo.ListByUser(claims.ID)
+	if err != nil {
+		return err
+	}
+	for _, existing := range identities {
+		if existing.Provider == providerName {
+			return problem(c, http.StatusConflict, CodeConflict, "the identity provider is already linked, unlink it first")
+		}
+	}
+
+	link = &models.UserIdentity{
+		UserID:   claims.ID,
+		Provider: providerName,
+		Subject:  external.Subject,
+	}
+	err = h.IdentityRepo.Create(link)
+	if err != nil {
+		return err
+	}
+	h.recordEvent(c, claims.ID, models.EventIdentityLinked)
+
+	return c.JSON(http.StatusCreated, toLinkedIdentity(link))
+}
+
+// UnlinkIdentity handler for unlinking an identity provider from the logged
+// in user, a user without a password keeps at least one provider
+func (h *UserHandler) UnlinkIdentity(c echo.Context, providerName string) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	user, err := h.UserRepo.FindByIDForUpdate(claims.ID)
+	if err != nil {
+		return err
+	}
+	identities, err := h.IdentityRepo.ListByUser(claims.ID)
+	if err != nil {
+		return err
+	}
+
+	linked := false
+	for _, existing := range identities {
+		linked = linked || existing.Provider == providerName
+	}
+	if !linked {
+		return problem(c, http.StatusNotFound, CodeNotFound, "identity provider is not linked")
+	}
+	// users who signed up with a provider have no password to fall back to
+	if user.Password == "" && len(identities) == 1 {
+		return problem(c, http.StatusConflict, CodeConflict, "the identity provider is the only way to sign in, link another one first")
+	}
+
+	err = h.IdentityRepo.Delete(claims.ID, providerName)
+	if err != nil {
+		if err.Error() == "record not found" {
+			return problem(c, http.StatusNotFound, CodeNotFound, "identity provider is not linked")
+		}
+		return err
+	}
+	h.recordEvent(c, claims.ID, models.EventIdentityUnlinked)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// startIdentityLogin stores the state of a sign in at the provider and
+// responds with where to send the user, userID is the user linking the
+// provider or 0 for a login
+func (h *UserHandler) startIdentityLogin(c echo.Context, providerName string, userID int) error {
+	provider, ok := h.IdentityProviders[providerName]
+	if !ok {
+		return problem(c, http.StatusNotFound, CodeNotFound, "identity provider not found")
+	}
+
+	state := randomToken()
+	loginState := &models.IdentityLoginState{
+		ID:           sha256Hex(state),
+		Provider:     providerName,
+		UserID:       userID,
+		Nonce:        randomToken(),
+		CodeVerifier: randomToken(),
+		ExpiresAt:    time.Now().Add(identityLoginLifetime),
+	}
+	err := h.IdentityRepo.CreateState(loginState)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, generated.SocialLoginBeginResponse{
+		AuthorizationUrl: provider.AuthCodeURL(state, loginState.Nonce, pkceChallenge(loginState.CodeVerifier)),
+		State:            state,
+	})
+}
+
+// finishIdentityLogin takes the state of the sign in and exchanges the code at
+// the provider, it returns nil when the state is invalid, expired, was started
+// for another provider or user, or the provider rejected the code
+func (h *UserHandler) finishIdentityLogin(c echo.Context, providerName, state, code string, userID int) (*identity.Identity, error) {
+	loginState, err := h.IdentityRepo.TakeState(sha256Hex(state), time.Now())
+	if err != nil {
+		if err.Error() == "record not found" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if loginState.Provider != providerName || loginState.UserID != userID {
+		return nil, nil
+	}
+
+	external, err := h.IdentityProviders[providerName].Exchange(c.Request().Context(), code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		c.Logger().Warnf("identity provider %s: %v", providerName, err)
+		return nil, nil
+	}
+	return external, nil
+}
+
+// signUpWithIdentity creates a user without a password for the external identity
+func (h *UserHandler) signUpWithIdentity(c echo.Context, providerName string, external *identity.Identity, fullname string) (*models.User, error) {
+	user := &models.User{
+		PhoneNumber: external.PhoneNumber,
+		Fullname:    fullname,
+		Role:        models.RoleUser,
+		Status:      models.StatusActive,
+	}
+	err := h.UserRepo.Create(user)
+	if err != nil {
+		return nil, err
+	}
+	user, err = h.UserRepo.FindByPhone(external.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.IdentityRepo.Create(&models.UserIdentity{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  external.Subject,
+	})
+	if err != nil {
+		// the users and the identities live in different repositories, so the
+		// user is erased instead of rolled back, as a user without the identity
+		// could never sign in and would keep the phone number taken
+		h.discardUser(c, user.ID)
+		return nil, err
+	}
+	h.recordEvent(c, user.ID, models.EventRegister)
+	h.recordEvent(c, user.ID, models.EventIdentityLinked)
+	return user, nil
+}
+
+// signUpFullname fits the name shared by the identity provider into the
+// fullname of a user, longer names are cut and shorter ones are refused
+func signUpFullname(name string) (string, bool) {
+	fullname := strings.TrimSpace(util.Truncate(strings.TrimSpace(name), maxFullnameLength))
+	return fullname, utf8.RuneCountInString(fullname) >= minFullnameLength
+}
+
+// discardUser erases a user who was just created, the row is kept anonymized
+// like a purged account so the id is never reused
+func (h *UserHandler) discardUser(c echo.Context, id int) {
+	if err := h.UserRepo.Delete(id); err != nil {
+		c.Logger().Errorf("discard user %d: %v", id, err)
+		return
+	}
+	if err := h.UserRepo.Anonymize(id); err != nil {
+		c.Logger().Errorf("discard user %d: %v", id, err)
+	}
+}
+
+// pkceChallenge is the S256 code challenge of the code verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// toLinkedIdentity converts a linked identity into its api representation
+func toLinkedIdentity(link *models.UserIdentity) generated.LinkedIdentity {
+	return generated.LinkedIdentity{
+		Provider:  link.Provider,
+		CreatedAt: link.CreatedAt,
+	}
+}
@@ -0,0 +1,360 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/identity"
+	"github.com/SawitProRecruitment/UserService/identity/identitytest"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// socialHandler builds a handler signing in at a stub provider named stub,
+// the identity repository keeps its state in memory
+func socialHandler(t *testing.T, user identitytest.User) (*UserHandler, *MockUserRepository, *identitytest.Server) {
+	server := identitytest.NewServer(user)
+	t.Cleanup(server.Close)
+	provider, err := identity.NewOIDCProvider(context.Background(), server.URL, server.ClientID, server.ClientSecret, "https://app.sawitpro.com/callback", nil)
+	assert.NoError(t, err)
+
+	mockRepo := new(MockUserRepository)
+	identityRepo := mocks.NewIdentityRepository(t)
+	states := map[string]*models.IdentityLoginState{}
+	var identities []models.UserIdentity
+
+	identityRepo.On("CreateState", mock.AnythingOfType("*models.IdentityLoginState")).Return(func(state *models.IdentityLoginState) error {
+		states[state.ID] = state
+		return nil
+	}).Maybe()
+	identityRepo.On("TakeState", mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(func(id string, now time.Time) (*models.IdentityLoginState, error) {
+		state, ok := states[id]
+		if !ok {
+			return nil, errors.New("record not found")
+		}
+		delete(states, id)
+		return state, nil
+	}, nil).Maybe()
+	identityRepo.On("Create", mock.AnythingOfType("*models.UserIdentity")).Return(func(link *models.UserIdentity) error {
+		link.ID = len(identities) + 1
+		link.CreatedAt = time.Now()
+		identities = append(identities, *link)
+		return nil
+	}).Maybe()
+	identityRepo.On("FindByProviderSubject", "stub", mock.AnythingOfType("string")).Return(func(provider, subject string) (*models.UserIdentity, error) {
+		for i := range identities {
+			if identities[i].Provider == provider && identities[i].Subject == subject {
+				return &identities[i], nil
+			}
+		}
+		return nil, errors.New("record not found")
+	}, nil).Maybe()
+	identityRepo.On("ListByUser", mock.AnythingOfType("int")).Return(func(userID int) ([]models.UserIdentity, error) {
+		var linked []models.UserIdentity
+		for _, link := range identities {
+			if link.UserID == userID {
+				linked = append(linked, link)
+			}
+		}
+		return linked, nil
+	}, nil).Maybe()
+
+	sessionRepo := mocks.NewSessionRepository(t)
+	sessionRepo.On("Create", mock.AnythingOfType("*models.Session")).Return(nil).Maybe()
+
+	handler := &UserHandler{
+		UserRepo:          mockRepo,
+		SessionRepo:       sessionRepo,
+		IdentityRepo:      identityRepo,
+		IdentityProviders: map[string]identity.Provider{"stub": provider},
+	}
+	return handler, mockRepo, server
+}
+
+// socialEchoCtx builds a request for the stub provider, userID 0 is a request without a login
+func socialEchoCtx(target, jsonInput string, userID int) (*httptest.ResponseRecorder, echo.Context) {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(jsonInput))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+	c := e.NewContext(req, rec)
+	if userID != 0 {
+		c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{ID: userID}))
+	}
+	return rec, c
+}
+
+// signInAtProvider begins a sign in, lets the stub provider sign the user in
+// and returns the state and code to finish with
+func signInAtProvider(t *testing.T, handler *UserHandler, server *identitytest.Server, begin func(echo.Context, string) error, userID int) (string, string) {
+	rec, c := socialEchoCtx("/begin", "", userID)
+	assert.NoError(t, begin(c, "stub"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response generated.SocialLoginBeginResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	code, state, err := server.Authorize(response.AuthorizationUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, response.State, state)
+	return state, code
+}
+
+func finishInput(state, code string) string {
+	return fmt.Sprintf(`{"state": %q, "code": %q}`, state, code)
+}
+
+func TestSocialLoginSignUp(t *testing.T) {
+	handler, mockRepo, server := socialHandler(t, identitytest.User{
+		Subject:             "stub-subject",
+		Name:                "mr smith",
+		PhoneNumber:         "+62812345678912",
+		PhoneNumberVerified: true,
+	})
+	created := &models.User{ID: 1, PhoneNumber: "+62812345678912", Fullname: "mr smith", Role: models.RoleUser}
+
+	mockRepo.On("FindByPhone", "+62812345678912").Return((*models.User)(nil), errors.New("record not found")).Once()
+	mockRepo.On("FindDeletedByPhone", "+62812345678912").Return((*models.User)(nil), errors.New("record not found")).Once()
+	mockRepo.On("Create", &models.User{
+		PhoneNumber: "+62812345678912",
+		Fullname:    "mr smith",
+		Role:        models.RoleUser,
+		Status:      models.StatusActive,
+	}).Return(nil).Once()
+	mockRepo.On("FindByPhone", "+62812345678912").Return(created, nil).Once()
+
+	state, code := signInAtProvider(t, handler, server, handler.BeginSocialLogin, 0)
+	rec, c := socialEchoCtx("/finish", finishInput(state, code), 0)
+	assert.NoError(t, handler.FinishSocialLogin(c, "stub"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response generated.LoginResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Id)
+
+	// the state is consumed by the first finish
+	rec, c = socialEchoCtx("/finish", finishInput(state, code), 0)
+	assert.NoError(t, handler.FinishSocialLogin(c, "stub"))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// the linked identity signs the user in from now on
+	mockRepo.On("FindByID", 1).Return(created, nil).Once()
+	state, code = signInAtProvider(t, handler, server, handler.BeginSocialLogin, 0)
+	rec, c = socialEchoCtx("/finish", finishInput(state, code), 0)
+	assert.NoError(t, handler.FinishSocialLogin(c, "stub"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+// failingIdentityRepository fails to link identities
+type failingIdentityRepository struct {
+	repository.IdentityRepository
+}
+
+func (failingIdentityRepository) Create(*models.UserIdentity) error {
+	return errors.New("connection reset")
+}
+
+func TestSocialLoginSignUpLinkFails(t *testing.T) {
+	handler, mockRepo, server := socialHandler(t, identitytest.User{
+		Subject:             "stub-subject",
+		Name:                "mr smith",
+		PhoneNumber:         "+62812345678912",
+		PhoneNumberVerified: true,
+	})
+	handler.IdentityRepo = failingIdentityRepository{handler.IdentityRepo}
+
+	mockRepo.On("FindByPhone", "+62812345678912").Return((*models.User)(nil), errors.New("record not found")).Once()
+	mockRepo.On("FindDeletedByPhone", "+62812345678912").Return((*models.User)(nil), errors.New("record not found")).Once()
+	mockRepo.On("Create", mock.AnythingOfType("*models.User")).Return(nil).Once()
+	mockRepo.On("FindByPhone", "+62812345678912").Return(&models.User{ID: 1, PhoneNumber: "+62812345678912"}, nil).Once()
+	// the user can not sign in without the identity, so it is erased
+	mockRepo.On("Delete", 1).Return(nil).Once()
+	mockRepo.On("Anonymize", 1).Return(nil).Once()
+
+	state, code := signInAtProvider(t, handler, server, handler.BeginSocialLogin, 0)
+	rec, c := socialEchoCtx("/finish", finishInput(state, code), 0)
+	assert.Error(t, handler.FinishSocialLogin(c, "stub"))
+	assert.Empty(t, rec.Body.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestFinishSocialLogin(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       identitytest.User
+		mock       func(*MockUserRepository)
+		wantStatus int
+	}{
+		{
+			name: "Fail FinishSocialLogin, phone number already registered",
+			user: identitytest.User{Subject: "stub-subject", PhoneNumber: "+62812345678912", PhoneNumberVerified: true},
+			mock: func(mockRepo *MockUserRepository) {
+				mockRepo.On("FindByPhone", "+62812345678912").Return(&models.User{ID: 2, PhoneNumber: "+62812345678912"}, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "Fail FinishSocialLogin, phone number not verified",
+			user:       identitytest.User{Subject: "stub-subject", PhoneNumber: "+62812345678912"},
+			mock:       func(mockRepo *MockUserRepository) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Fail FinishSocialLogin, name too short",
+			user: identitytest.User{Subject: "stub-subject", Name: " Al ", PhoneNumber: "+62812345678912", PhoneNumberVerified: true},
+			mock: func(mockRepo *MockUserRepository) {
+				mockRepo.On("FindByPhone", "+62812345678912").Return((*models.User)(nil), errors.New("record not found"))
+				mockRepo.On("FindDeletedByPhone", "+62812345678912").Return((*models.User)(nil), errors.New("record not found"))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail FinishSocialLogin, phone number outside Indonesia",
+			user:       identitytest.User{Subject: "stub-subject", PhoneNumber: "+6591234567", PhoneNumberVerified: true},
+			mock:       func(mockRepo *MockUserRepository) {},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mockRepo, server := socialHandler(t, tt.user)
+			tt.mock(mockRepo)
+
+			state, code := signInAtProvider(t, handler, server, handler.BeginSocialLogin, 0)
+			rec, c := socialEchoCtx("/finish", finishInput(state, code), 0)
+			assert.NoError(t, handler.FinishSocialLogin(c, "stub"))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestSignUpFullname(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		wantOk bool
+	}{
+		{name: "Name", input: "mr smith", want: "mr smith", wantOk: true},
+		{name: "Surrounding Spaces", input: "  mr smith ", want: "mr smith", wantOk: true},
+		{name: "Long Name", input: strings.Repeat("é", 61), want: strings.Repeat("é", 60), wantOk: true},
+		{name: "Cut Before A Space", input: strings.Repeat("a", 59) + " smith", want: strings.Repeat("a", 59), wantOk: true},
+		{name: "Short Name", input: "Al", want: "Al", wantOk: false},
+		{name: "No Name", input: "", want: "", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := signUpFullname(tt.input)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
+
+func TestSocialLoginTwoFactor(t *testing.T) {
+	handler, mockRepo, server := socialHandler(t, identitytest.User{Subject: "stub-subject"})
+	assert.NoError(t, handler.IdentityRepo.Create(&models.UserIdentity{UserID: 1, Provider: "stub", Subject: "stub-subject"}))
+	user := &models.User{ID: 1, PhoneNumber: "+62812345678912", TOTPSecret: testTOTPSecret, TOTPEnabled: true}
+	mockRepo.On("FindByID", 1).Return(user, nil)
+
+	state, code := signInAtProvider(t, handler, server, handler.BeginSocialLogin, 0)
+	rec, c := socialEchoCtx("/finish", finishInput(state, code), 0)
+	assert.NoError(t, handler.FinishSocialLogin(c, "stub"))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+}
+
+func TestLinkIdentity(t *testing.T) {
+	handler, _, server := socialHandler(t, identitytest.User{Subject: "stub-subject"})
+
+	// a state started for a login can not link
+	state, code := signInAtProvider(t, handler, server, handler.BeginSocialLogin, 0)
+	rec, c := socialEchoCtx("/finish", finishInput(state, code), 1)
+	assert.NoError(t, handler.FinishLinkIdentity(c, "stub"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	state, code = signInAtProvider(t, handler, server, handler.BeginLinkIdentity, 1)
+	rec, c = socialEchoCtx("/finish", finishInput(state, code), 1)
+	assert.NoError(t, handler.FinishLinkIdentity(c, "stub"))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var linked generated.LinkedIdentity
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &linked))
+	assert.Equal(t, "stub", linked.Provider)
+
+	// the same identity can not be linked to another user
+	state, code = signInAtProvider(t, handler, server, handler.BeginLinkIdentity, 2)
+	rec, c = socialEchoCtx("/finish", finishInput(state, code), 2)
+	assert.NoError(t, handler.FinishLinkIdentity(c, "stub"))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec, c = socialEchoCtx("/profile/identities", "", 1)
+	assert.NoError(t, handler.ListIdentities(c))
+	var response generated.LinkedIdentityListResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Len(t, response.Identities, 1)
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       *models.User
+		linked     bool
+		wantStatus int
+	}{
+		{
+			name:       "Success UnlinkIdentity",
+			user:       &models.User{ID: 1, Password: util.HashPassword("A1234*", "salt"), SaltToken: "salt"},
+			linked:     true,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "Fail UnlinkIdentity, provider not linked",
+			user:       &models.User{ID: 1, Password: util.HashPassword("A1234*", "salt"), SaltToken: "salt"},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Fail UnlinkIdentity, only way to sign in",
+			user:       &models.User{ID: 1},
+			linked:     true,
+			wantStatus: http.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mockRepo, _ := socialHandler(t, identitytest.User{})
+			mockRepo.On("FindByIDForUpdate", 1).Return(tt.user, nil)
+			if tt.linked {
+				assert.NoError(t, handler.IdentityRepo.Create(&models.UserIdentity{UserID: 1, Provider: "stub", Subject: "stub-subject"}))
+			}
+			if tt.wantStatus == http.StatusNoContent {
+				handler.IdentityRepo.(*mocks.IdentityRepository).On("Delete", 1, "stub").Return(nil)
+			}
+
+			rec, c := socialEchoCtx("/profile/identities/stub", "", 1)
+			assert.NoError(t, handler.UnlinkIdentity(c, "stub"))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestBeginSocialLoginUnknownProvider(t *testing.T) {
+	handler, _, _ := socialHandler(t, identitytest.User{})
+
+	rec, c := socialEchoCtx("/login/social/unknown/begin", "", 0)
+	assert.NoError(t, handler.BeginSocialLogin(c, "unknown"))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
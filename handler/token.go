@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// tokenLifetime is how long an access token and its session stay valid
+const tokenLifetime = 72 * time.Hour
+
+// mfaTokenLifetime is how long the user has to enter the second factor after the password
+const mfaTokenLifetime = 5 * time.Minute
+
+// recentLoginWindow is how long after logging in a user without a password
+// can confirm deleting the account or turning two factor authentication off
+const recentLoginWindow = 10 * time.Minute
+
+// mfaAudience marks mfa challenge tokens, they carry no session so they are
+// rejected as access tokens and access tokens are rejected as challenges
+const mfaAudience = "mfa"
+
+// MfaChallengeClaims are the claims of the token handed out when the password
+// of a user with two factor authentication was correct, it carries the login
+// options so POST /login/2fa can finish the login
+type MfaChallengeClaims struct {
+	ID          int    `json:"id"`
+	Phone       string `json:"phone"`
+	Restore     bool   `json:"restore,omitempty"`
+	DeviceLabel string `json:"device_label,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// issueToken starts a new session for the user on the requesting device and
+// signs an access token referencing it, the device label is derived from the
+// user agent when the client does not provide one
+func (h *UserHandler) issueToken(c echo.Context, user *models.User, deviceLabel string) (string, error) {
+	userAgent := util.Truncate(c.Request().UserAgent(), 255)
+	if deviceLabel == "" {
+		deviceLabel = util.DeviceLabel(userAgent)
+	}
+	deviceLabel = util.Truncate(deviceLabel, 60)
+
+	now := time.Now()
+	session := &models.Session{
+		ID:          util.GenerateSessionID(),
+		UserID:      user.ID,
+		UserAgent:   userAgent,
+		IPAddress:   c.RealIP(),
+		DeviceLabel: deviceLabel,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(tokenLifetime),
+	}
+	if err := h.SessionRepo.Create(session); err != nil {
+		return "", err
+	}
+
+	claims := &JwtCustomClaims{
+		ID:        user.ID,
+		Role:      user.Role,
+		SessionID: session.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte("secret"))
+}
+
+// mfaChallenge responds with a challenge token to exchange together with a
+// second factor for an access token
+func (h *UserHandler) mfaChallenge(c echo.Context, user *models.User, restore bool, deviceLabel string) error {
+	now := time.Now()
+	claims := &MfaChallengeClaims{
+		ID:          user.ID,
+		Phone:       user.PhoneNumber,
+		Restore:     restore,
+		DeviceLabel: deviceLabel,
+		RegisteredClaims: jwt.RegisteredClaims{
+			// the id tells tokens apart so each one is locked on its own
+			ID:        util.GenerateSessionID(),
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenLifetime)),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, generated.MfaChallengeResponse{
+		MfaToken:  token,
+		ExpiresIn: int(mfaTokenLifetime.Seconds()),
+	})
+}
+
+// parseMfaToken verifies a challenge token made by mfaChallenge
+func parseMfaToken(tokenString string) (*MfaChallengeClaims, error) {
+	claims := &MfaChallengeClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(mfaAudience), jwt.WithIssuedAt())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
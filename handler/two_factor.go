@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// recoveryCodeCount is how many recovery codes are generated when two factor authentication is enabled
+const recoveryCodeCount = 10
+
+// LoginTwoFactor handler for finishing a login with the challenge token from
+// Login and a code from the authenticator app or a recovery code
+func (h *UserHandler) LoginTwoFactor(c echo.Context) error {
+	var input generated.LoginTwoFactorRequest
+	if err := c.Bind(&input); err != nil {
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+	}
+	if err := c.Validate(input); err != nil {
+		return err
+	}
+
+	claims, err := parseMfaToken(input.MfaToken)
+	if err != nil {
+		return problem(c, http.StatusUnauthorized, CodeInvalidMfaToken, "invalid or expired mfa token")
+	}
+
+	user, err := h.findLoginUser(claims.Phone)
+	if err != nil {
+		return err
+	}
+	// the challenge is void once the password changed or two factor authentication was turned off
+	if user == nil || user.ID != claims.ID || !user.TOTPEnabled ||
+		(user.TokensRevokedAt != nil && claims.IssuedAt.Time.Before(user.TokensRevokedAt.Truncate(time.Second))) {
+		return problem(c, http.StatusUnauthorized, CodeInvalidMfaToken, "invalid or expired mfa token")
+	}
+
+	ok, err := h.verifySecondFactor(c, user, input.Code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		h.recordEvent(c, user.ID, models.EventLoginFailed)
+		return problem(c, http.StatusUnauthorized, CodeInvalidCode, "invalid code")
+	}
+
+	return h.completeLogin(c, user, claims.Restore, claims.DeviceLabel)
+}
+
+// SetupTwoFactor handler for generating the TOTP secret to add to an
+// authenticator app, a new secret replaces any unconfirmed one. It asks for
+// the password so a stolen access token can not enrol its own authenticator
+func (h *UserHandler) SetupTwoFactor(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	var input generated.TwoFactorSetupRequest
+	if err := c.Bind(&input); err != nil {
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+	}
+
+	user, err := h.UserRepo.FindByIDForUpdate(claims.ID)
+	if err != nil {
+		return err
+	}
+
+	if user.TOTPEnabled {
+		return problem(c, http.StatusConflict, CodeConflict, "two factor authentication is already enabled")
+	}
+	confirmed, err := h.reauthenticated(c, user, claims.SessionID, input.Password)
+	if err != nil {
+		return h.hashingFailed(c, err)
+	}
+	if !confirmed {
+		return reauthenticationFailed(c, user)
+	}
+
+	user.TOTPSecret = util.GenerateTOTPSecret()
+	err = h.UserRepo.Update(user, repository.ColumnTOTPSecret)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, generated.TwoFactorSetupResponse{
+		Secret:     user.TOTPSecret,
+		OtpauthUri: util.TOTPURI(h.TOTPIssuer, user.PhoneNumber, user.TOTPSecret),
+	})
+}
+
+// ConfirmTwoFactor handler for enabling two factor authentication once the
+// authenticator app shows a valid code, it responds with the recovery codes
+// which are only ever shown here
+func (h *UserHandler) ConfirmTwoFactor(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	var input generated.ConfirmTwoFactorRequest
+	if err := c.Bind(&input); err != nil {
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+	}
+	if err := c.Validate(input); err != nil {
+		return err
+	}
+
+	user, err := h.UserRepo.FindByIDForUpdate(claims.ID)
+	if err != nil {
+		return err
+	}
+
+	if user.TOTPEnabled {
+		return problem(c, http.StatusConflict, CodeConflict, "two factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return problem(c, http.StatusBadRequest, CodeBadRequest, "two factor authentication setup has not been started")
+	}
+
+	step, ok := util.ValidateTOTP(user.TOTPSecret, input.Code, time.Now())
+	if !ok {
+		return problem(c, http.StatusBadRequest, CodeInvalidCode, "invalid code")
+	}
+	err = h.UserRepo.UseTOTPStep(user.ID, step)
+	if err != nil {
+		// the code was already used
+		if err.Error() == "record not found" {
+			return problem(c, http.StatusBadRequest, CodeInvalidCode, "invalid code")
+		}
+		return err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	codeHashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i] = util.GenerateRecoveryCode()
+		codeHashes[i] = util.HashRecoveryCode(codes[i])
+	}
+	err = h.RecoveryCodeRepo.ReplaceByUser(user.ID, codeHashes)
+	if err != nil {
+		return err
+	}
+
+	user.TOTPEnabled = true
+	err = h.UserRepo.Update(user, repository.ColumnTOTPEnabled)
+	if err != nil {
+		return err
+	}
+	h.recordEvent(c, user.ID, models.EventTwoFactorEnabled)
+
+	return c.JSON(http.StatusOK, generated.ConfirmTwoFactorResponse{
+		RecoveryCodes: codes,
+	})
+}
+
+// DisableTwoFactor handler for turning two factor authentication off, it asks
+// for both the password and a second factor, users without a password log in
+// again instead of entering it
+func (h *UserHandler) DisableTwoFactor(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	var input generated.DisableTwoFactorRequest
+	if err := c.Bind(&input); err != nil {
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+	}
+	if err := c.Validate(input); err != nil {
+		return err
+	}
+
+	user, err := h.UserRepo.FindByIDForUpdate(claims.ID)
+	if err != nil {
+		return err
+	}
+
+	if !user.TOTPEnabled {
+		return problem(c, http.StatusBadRequest, CodeBadRequest, "two factor authentication is not enabled")
+	}
+	confirmed, err := h.reauthenticated(c, user, claims.SessionID, input.Password)
+	if err != nil {
+		return h.hashingFailed(c, err)
+	}
+	if !confirmed {
+		return reauthenticationFailed(c, user)
+	}
+
+	ok, err := h.verifySecondFactor(c, user, input.Code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return problem(c, http.StatusUnauthorized, CodeInvalidCode, "invalid code")
+	}
+
+	// the last step is kept, the codes of a new secret come in later steps anyway
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	err = h.UserRepo.Update(user, repository.ColumnTOTPEnabled, repository.ColumnTOTPSecret)
+	if err != nil {
+		return err
+	}
+	err = h.RecoveryCodeRepo.DeleteByUser(user.ID)
+	if err != nil {
+		return err
+	}
+	h.recordEvent(c, user.ID, models.EventTwoFactorDisabled)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// verifySecondFactor checks a code from the authenticator app or an unused
+// recovery code, either is consumed so it can not be used again
+func (h *UserHandler) verifySecondFactor(c echo.Context, user *models.User, code string) (bool, error) {
+	if step, ok := util.ValidateTOTP(user.TOTPSecret, code, time.Now()); ok {
+		err := h.UserRepo.UseTOTPStep(user.ID, step)
+		if err != nil {
+			// the code was already used
+			if err.Error() == "record not found" {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	err := h.RecoveryCodeRepo.Use(user.ID, util.HashRecoveryCode(code))
+	if err != nil {
+		if err.Error() == "record not found" {
+			return false, nil
+		}
+		return false, err
+	}
+	h.recordEvent(c, user.ID, models.EventRecoveryCodeUsed)
+	return true, nil
+}
@@ -0,0 +1,390 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+func twoFactorUser() *models.User {
+	return &models.User{
+		ID:          1,
+		PhoneNumber: "+62812345678912",
+		Password:    util.HashPassword("A1234*", "salt"),
+		Fullname:    "mr smith",
+		SaltToken:   "salt",
+		TOTPSecret:  testTOTPSecret,
+		TOTPEnabled: true,
+	}
+}
+
+func twoFactorEchoCtx(method, target, jsonInput string) (*httptest.ResponseRecorder, echo.Context) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{ID: 1, SessionID: "current"})
+	req := httptest.NewRequest(method, target, strings.NewReader(jsonInput))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+	c := e.NewContext(req, rec)
+	c.Set("user", token)
+	return rec, c
+}
+
+// loginChallenge logs the user in with the password and returns the mfa token
+func loginChallenge(t *testing.T, user *models.User) string {
+	mockRepo := new(MockUserRepository)
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+	}
+	mockRepo.On("FindByPhone", user.PhoneNumber).Return(user, nil)
+
+	rec, c := registerEchoCtx(`{"phone": "+62812345678912", "password": "A1234*"}`, "/login")
+	err := handler.Login(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	var response generated.MfaChallengeResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, 300, response.ExpiresIn)
+	return response.MfaToken
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	user := twoFactorUser()
+	mfaToken := loginChallenge(t, user)
+
+	mockRepo := new(MockUserRepository)
+	sessionRepo := mocks.NewSessionRepository(t)
+	handler := &UserHandler{
+		UserRepo:    mockRepo,
+		SessionRepo: sessionRepo,
+	}
+
+	code, _ := util.TOTPCode(testTOTPSecret, time.Now())
+	mockRepo.On("FindByPhone", user.PhoneNumber).Return(user, nil)
+	mockRepo.On("UseTOTPStep", 1, mock.AnythingOfType("int64")).Return(nil)
+	sessionRepo.On("Create", mock.AnythingOfType("*models.Session")).Return(nil)
+
+	rec, c := registerEchoCtx(`{"mfa_token": "`+mfaToken+`", "code": "`+code+`"}`, "/login/2fa")
+	err := handler.LoginTwoFactor(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLoginTwoFactorReplayedCode(t *testing.T) {
+	user := twoFactorUser()
+	mfaToken := loginChallenge(t, user)
+
+	mockRepo := new(MockUserRepository)
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+	}
+
+	code, _ := util.TOTPCode(testTOTPSecret, time.Now())
+	mockRepo.On("FindByPhone", user.PhoneNumber).Return(user, nil)
+	mockRepo.On("UseTOTPStep", 1, mock.AnythingOfType("int64")).Return(errors.New("record not found"))
+
+	rec, c := registerEchoCtx(`{"mfa_token": "`+mfaToken+`", "code": "`+code+`"}`, "/login/2fa")
+	err := handler.LoginTwoFactor(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.JSONEq(t, `{"type":"urn:sawitpro:problem:invalid_code","title":"Unauthorized","status":401,"code":"invalid_code","instance":"/login/2fa","detail":"invalid code"}`, rec.Body.String())
+}
+
+func TestLoginTwoFactorRecoveryCode(t *testing.T) {
+	user := twoFactorUser()
+	mfaToken := loginChallenge(t, user)
+
+	mockRepo := new(MockUserRepository)
+	sessionRepo := mocks.NewSessionRepository(t)
+	recoveryCodeRepo := mocks.NewRecoveryCodeRepository(t)
+	handler := &UserHandler{
+		UserRepo:         mockRepo,
+		SessionRepo:      sessionRepo,
+		RecoveryCodeRepo: recoveryCodeRepo,
+	}
+
+	mockRepo.On("FindByPhone", user.PhoneNumber).Return(user, nil)
+	recoveryCodeRepo.On("Use", 1, util.HashRecoveryCode("ABCDE-FGHIJ")).Return(nil)
+	sessionRepo.On("Create", mock.AnythingOfType("*models.Session")).Return(nil)
+
+	rec, c := registerEchoCtx(`{"mfa_token": "`+mfaToken+`", "code": "abcde-fghij"}`, "/login/2fa")
+	err := handler.LoginTwoFactor(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestLoginTwoFactorInvalidToken(t *testing.T) {
+	accessToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{
+		ID:               1,
+		SessionID:        "current",
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString([]byte("secret"))
+
+	tests := []struct {
+		name     string
+		mfaToken string
+	}{
+		{name: "Access Token", mfaToken: accessToken},
+		{name: "Garbage Token", mfaToken: "not-a-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &UserHandler{
+				UserRepo: new(MockUserRepository),
+			}
+
+			rec, c := registerEchoCtx(`{"mfa_token": "`+tt.mfaToken+`", "code": "123456"}`, "/login/2fa")
+			err := handler.LoginTwoFactor(c)
+			assert.NoError(t, err)
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.JSONEq(t, `{"type":"urn:sawitpro:problem:invalid_mfa_token","title":"Unauthorized","status":401,"code":"invalid_mfa_token","instance":"/login/2fa","detail":"invalid or expired mfa token"}`, rec.Body.String())
+		})
+	}
+}
+
+func TestSetupTwoFactor(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	handler := &UserHandler{
+		UserRepo:   mockRepo,
+		TOTPIssuer: "SawitPro",
+	}
+
+	user := twoFactorUser()
+	user.TOTPSecret, user.TOTPEnabled = "", false
+	mockRepo.On("FindByIDForUpdate", 1).Return(user, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(user *models.User) bool {
+		return len(user.TOTPSecret) == 32 && !user.TOTPEnabled
+	}), []string{repository.ColumnTOTPSecret}).Return(nil)
+
+	rec, c := twoFactorEchoCtx(http.MethodPost, "/profile/2fa/setup", `{"password": "A1234*"}`)
+	err := handler.SetupTwoFactor(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response generated.TwoFactorSetupResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, util.TOTPURI("SawitPro", "+62812345678912", response.Secret), response.OtpauthUri)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSetupTwoFactorAlreadyEnabled(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+	}
+
+	mockRepo.On("FindByIDForUpdate", 1).Return(twoFactorUser(), nil)
+
+	rec, c := twoFactorEchoCtx(http.MethodPost, "/profile/2fa/setup", `{"password": "A1234*"}`)
+	err := handler.SetupTwoFactor(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestSetupTwoFactorReauthentication(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   bool
+		jsonInput  string
+		loggedInAt time.Time
+		wantDetail string
+	}{
+		{
+			name:       "Missing Password",
+			password:   true,
+			jsonInput:  `{}`,
+			wantDetail: "invalid password",
+		},
+		{
+			name:       "Invalid Password",
+			password:   true,
+			jsonInput:  `{"password": "wrong"}`,
+			wantDetail: "invalid password",
+		},
+		{
+			name:       "Old Login Without Password",
+			jsonInput:  `{}`,
+			loggedInAt: time.Now().Add(-time.Hour),
+			wantDetail: "log in again to confirm, the account has no password",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			sessionRepo := mocks.NewSessionRepository(t)
+			handler := &UserHandler{
+				UserRepo:    mockRepo,
+				SessionRepo: sessionRepo,
+			}
+
+			// a stolen access token can not enrol its own authenticator
+			user := twoFactorUser()
+			user.TOTPSecret, user.TOTPEnabled = "", false
+			if !tt.password {
+				user.Password, user.SaltToken = "", ""
+				sessionRepo.On("FindByID", "current").Return(&models.Session{ID: "current", UserID: 1, CreatedAt: tt.loggedInAt}, nil)
+			}
+			mockRepo.On("FindByIDForUpdate", 1).Return(user, nil)
+
+			rec, c := twoFactorEchoCtx(http.MethodPost, "/profile/2fa/setup", tt.jsonInput)
+			err := handler.SetupTwoFactor(c)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.JSONEq(t, `{"type":"urn:sawitpro:problem:invalid_credentials","title":"Unauthorized","status":401,"code":"invalid_credentials","instance":"/profile/2fa/setup","detail":"`+tt.wantDetail+`"}`, rec.Body.String())
+			mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestConfirmTwoFactor(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	recoveryCodeRepo := mocks.NewRecoveryCodeRepository(t)
+	handler := &UserHandler{
+		UserRepo:         mockRepo,
+		RecoveryCodeRepo: recoveryCodeRepo,
+	}
+
+	mockRepo.On("FindByIDForUpdate", 1).Return(&models.User{ID: 1, TOTPSecret: testTOTPSecret}, nil)
+	recoveryCodeRepo.On("ReplaceByUser", 1, mock.MatchedBy(func(codeHashes []string) bool {
+		return len(codeHashes) == recoveryCodeCount
+	})).Return(nil)
+	mockRepo.On("UseTOTPStep", 1, mock.AnythingOfType("int64")).Return(nil)
+	mockRepo.On("Update", mock.MatchedBy(func(user *models.User) bool {
+		return user.TOTPEnabled
+	}), []string{repository.ColumnTOTPEnabled}).Return(nil)
+
+	code, _ := util.TOTPCode(testTOTPSecret, time.Now())
+	rec, c := twoFactorEchoCtx(http.MethodPost, "/profile/2fa/confirm", `{"code": "`+code+`"}`)
+	err := handler.ConfirmTwoFactor(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response generated.ConfirmTwoFactorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Len(t, response.RecoveryCodes, recoveryCodeCount)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfirmTwoFactorInvalidCode(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+	}
+
+	mockRepo.On("FindByIDForUpdate", 1).Return(&models.User{ID: 1, TOTPSecret: testTOTPSecret}, nil)
+
+	code, _ := util.TOTPCode(testTOTPSecret, time.Now().Add(-time.Hour))
+	rec, c := twoFactorEchoCtx(http.MethodPost, "/profile/2fa/confirm", `{"code": "`+code+`"}`)
+	err := handler.ConfirmTwoFactor(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"type":"urn:sawitpro:problem:invalid_code","title":"Bad Request","status":400,"code":"invalid_code","instance":"/profile/2fa/confirm","detail":"invalid code"}`, rec.Body.String())
+}
+
+func TestConfirmTwoFactorUsedCode(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+	}
+
+	mockRepo.On("FindByIDForUpdate", 1).Return(&models.User{ID: 1, TOTPSecret: testTOTPSecret}, nil)
+	mockRepo.On("UseTOTPStep", 1, mock.AnythingOfType("int64")).Return(errors.New("record not found"))
+
+	code, _ := util.TOTPCode(testTOTPSecret, time.Now())
+	rec, c := twoFactorEchoCtx(http.MethodPost, "/profile/2fa/confirm", `{"code": "`+code+`"}`)
+	err := handler.ConfirmTwoFactor(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	recoveryCodeRepo := mocks.NewRecoveryCodeRepository(t)
+	handler := &UserHandler{
+		UserRepo:         mockRepo,
+		RecoveryCodeRepo: recoveryCodeRepo,
+	}
+
+	mockRepo.On("FindByIDForUpdate", 1).Return(twoFactorUser(), nil)
+	mockRepo.On("UseTOTPStep", 1, mock.AnythingOfType("int64")).Return(nil)
+	mockRepo.On("Update", mock.MatchedBy(func(user *models.User) bool {
+		return !user.TOTPEnabled && user.TOTPSecret == ""
+	}), []string{repository.ColumnTOTPEnabled, repository.ColumnTOTPSecret}).Return(nil)
+	recoveryCodeRepo.On("DeleteByUser", 1).Return(nil)
+
+	code, _ := util.TOTPCode(testTOTPSecret, time.Now())
+	rec, c := twoFactorEchoCtx(http.MethodDelete, "/profile/2fa", `{"password": "A1234*", "code": "`+code+`"}`)
+	err := handler.DisableTwoFactor(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDisableTwoFactorWithoutPassword(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	sessionRepo := mocks.NewSessionRepository(t)
+	recoveryCodeRepo := mocks.NewRecoveryCodeRepository(t)
+	handler := &UserHandler{
+		UserRepo:         mockRepo,
+		SessionRepo:      sessionRepo,
+		RecoveryCodeRepo: recoveryCodeRepo,
+	}
+
+	// users signed up with an identity provider confirm with a recent login
+	user := twoFactorUser()
+	user.Password, user.SaltToken = "", ""
+	mockRepo.On("FindByIDForUpdate", 1).Return(user, nil)
+	sessionRepo.On("FindByID", "current").Return(&models.Session{ID: "current", UserID: 1, CreatedAt: time.Now().Add(-time.Minute)}, nil)
+	mockRepo.On("UseTOTPStep", 1, mock.AnythingOfType("int64")).Return(nil)
+	mockRepo.On("Update", mock.AnythingOfType("*models.User"), []string{repository.ColumnTOTPEnabled, repository.ColumnTOTPSecret}).Return(nil)
+	recoveryCodeRepo.On("DeleteByUser", 1).Return(nil)
+
+	code, _ := util.TOTPCode(testTOTPSecret, time.Now())
+	rec, c := twoFactorEchoCtx(http.MethodDelete, "/profile/2fa", `{"code": "`+code+`"}`)
+	err := handler.DisableTwoFactor(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDisableTwoFactorInvalidPassword(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+	}
+
+	mockRepo.On("FindByIDForUpdate", 1).Return(twoFactorUser(), nil)
+
+	for _, jsonInput := range []string{`{"password": "wrong", "code": "123456"}`, `{"code": "123456"}`} {
+		rec, c := twoFactorEchoCtx(http.MethodDelete, "/profile/2fa", jsonInput)
+		err := handler.DisableTwoFactor(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.JSONEq(t, `{"type":"urn:sawitpro:problem:invalid_credentials","title":"Unauthorized","status":401,"code":"invalid_credentials","instance":"/profile/2fa","detail":"invalid password"}`, rec.Body.String())
+	}
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
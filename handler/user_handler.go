@@ -1,25 +1,64 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/hashing"
+	"github.com/SawitProRecruitment/UserService/identity"
 	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/password"
 	"github.com/SawitProRecruitment/UserService/repository"
 	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
 type JwtCustomClaims struct {
-	ID int `json:"id"`
+	ID   int    `json:"id"`
+	Role string `json:"role"`
+	// SessionID references the session the token was issued for
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
 // UserHandler struct
 type UserHandler struct {
 	UserRepo repository.UserRepository
+	// AuditRepo records audit events, auditing is skipped when it is nil
+	AuditRepo   repository.AuditRepository
+	SessionRepo repository.SessionRepository
+	// RecoveryCodeRepo stores the hashed recovery codes of two factor authentication
+	RecoveryCodeRepo repository.RecoveryCodeRepository
+	// TOTPIssuer names the service in authenticator apps
+	TOTPIssuer string
+	// WebAuthn is the relying party verifying passkeys, see NewWebAuthn
+	WebAuthn     *webauthn.WebAuthn
+	WebAuthnRepo repository.WebAuthnRepository
+	// IdentityProviders are the external identity providers users sign in with, keyed by name
+	IdentityProviders map[string]identity.Provider
+	IdentityRepo      repository.IdentityRepository
+	// OAuthRepo holds the consents the user gave to OpenID Connect clients
+	OAuthRepo repository.OAuthRepository
+	// Hasher bounds how many password hashes run at once, passwords are hashed
+	// right away when it is nil
+	Hasher *hashing.Pool
+	// PasswordChecker screens new passwords for common, breached and guessable
+	// ones, only the character rules apply when it is nil
+	PasswordChecker *password.Checker
+	// PasswordPolicy are the character rules of new passwords, the default policy applies when it is nil
+	PasswordPolicy *util.PasswordPolicy
+	// PasswordHistoryRepo keeps the previous passwords, only the current
+	// password is checked for reuse when it is nil
+	PasswordHistoryRepo repository.PasswordHistoryRepository
+	// MaxPasswordAge is how long a password is valid before login asks for a change, 0 never expires passwords
+	MaxPasswordAge time.Duration
+	// DeletionGracePeriod is how long a deleted account can still be restored before it is purged
+	DeletionGracePeriod time.Duration
 }
 
 // NewUserHandler create new user handler
@@ -31,61 +70,68 @@ func NewUserHandler(userRepo repository.UserRepository) *UserHandler {
 func (h *UserHandler) Register(c echo.Context) error {
 	var input generated.RegisterRequest
 	if err := c.Bind(&input); err != nil {
-		return c.JSON(http.StatusBadRequest, generated.ErrorResponse{
-			Message: "fail to bind input, it might be bad request",
-		})
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
 	}
 	if err := c.Validate(input); err != nil {
 		return err
 	}
 	if input.Phone[:3] != "+62" {
-		return c.JSON(http.StatusBadRequest, generated.ErrorResponse{
-			Message: "phone number must start with +62",
-		})
+		return invalidField(c, "phone", "prefix", "phone number must start with +62")
 	}
 
-	if !util.ValidatePassword(input.Password) {
-		return c.JSON(http.StatusBadRequest, generated.ErrorResponse{
-			Message: "password must contains at least 1 uppercase, 1 number, and 1 special character",
-		})
+	if fieldErrors := h.policyViolations(c, "password", input.Password); len(fieldErrors) > 0 {
+		return invalidFields(c, fieldErrors)
+	}
+	fieldErrors, err := h.screenPassword(c, "password", input.Password, password.User{Name: input.Fullname, Phone: input.Phone})
+	if err != nil {
+		return err
+	}
+	if len(fieldErrors) > 0 {
+		return invalidFields(c, fieldErrors)
 	}
 
 	salt := util.GenerateSalt()
-	hashedPassword := util.HashPassword(input.Password, salt)
+	hashedPassword, err := h.hashPassword(c, input.Password, salt)
+	if err != nil {
+		return h.hashingFailed(c, err)
+	}
 	existingUser, err := h.UserRepo.FindByPhone(input.Phone)
 	if err != nil && err.Error() != "record not found" {
-		return c.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	if existingUser != nil {
-		return c.JSON(http.StatusConflict, generated.ErrorResponse{
-			Message: "phone number already registered",
-		})
+		return problem(c, http.StatusConflict, CodePhoneRegistered, "phone number already registered")
 	}
 
+	now := time.Now()
 	user := &models.User{
-		PhoneNumber: input.Phone,
-		Password:    hashedPassword,
-		Fullname:    input.Fullname,
-		SaltToken:   salt,
+		PhoneNumber:       input.Phone,
+		Password:          hashedPassword,
+		Fullname:          input.Fullname,
+		SaltToken:         salt,
+		Role:              models.RoleUser,
+		Status:            models.StatusActive,
+		PasswordChangedAt: &now,
 	}
 
 	err = h.UserRepo.Create(user)
+	if err == repository.ErrPhoneTaken {
+		// registered by a concurrent request since the check above
+		return problem(c, http.StatusConflict, CodePhoneRegistered, "phone number already registered")
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	user, err = h.UserRepo.FindByPhone(input.Phone)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: err.Error(),
-		})
+		return err
 	}
 
+	h.rememberPassword(c, user)
+	h.recordEvent(c, user.ID, models.EventRegister)
+
 	return c.JSON(http.StatusCreated, generated.RegisterResponse{
 		Id: user.ID,
 	})
@@ -95,62 +141,119 @@ func (h *UserHandler) Register(c echo.Context) error {
 func (h *UserHandler) Login(c echo.Context) error {
 	var input generated.LoginRequest
 	if err := c.Bind(&input); err != nil {
-		return c.JSON(http.StatusBadRequest, generated.ErrorResponse{
-			Message: "fail to bind input, it might be bad request",
-		})
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
 	}
 	if err := c.Validate(input); err != nil {
 		return err
 	}
 	if input.Phone[:3] != "+62" {
-		return c.JSON(http.StatusBadRequest, generated.ErrorResponse{
-			Message: "phone number must start with +62",
-		})
+		return invalidField(c, "phone", "prefix", "phone number must start with +62")
 	}
 
-	user, err := h.UserRepo.FindByPhone(input.Phone)
+	user, err := h.findLoginUser(input.Phone)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: err.Error(),
-		})
+		return err
 	}
 
-	hashedPassword := util.HashPassword(input.Password, user.SaltToken)
-	if user.Password != hashedPassword {
-		return c.JSON(http.StatusUnauthorized, generated.ErrorResponse{
-			Message: "invalid phone or password",
-		})
+	matches := false
+	if user != nil {
+		if matches, err = h.passwordMatches(c, user, input.Password); err != nil {
+			return h.hashingFailed(c, err)
+		}
+	}
+	if !matches {
+		if user != nil {
+			h.recordEvent(c, user.ID, models.EventLoginFailed)
+		}
+		return problem(c, http.StatusUnauthorized, CodeInvalidCredentials, "invalid phone or password")
+	}
+
+	restore := input.Restore != nil && *input.Restore
+	deviceLabel := ""
+	if input.DeviceLabel != nil {
+		deviceLabel = *input.DeviceLabel
+	}
+	if user.TOTPEnabled {
+		return h.mfaChallenge(c, user, restore, deviceLabel)
+	}
+	return h.completeLogin(c, user, restore, deviceLabel)
+}
+
+// findLoginUser finds the user signing in by phone, falling back to an account
+// that is deleted but still in its grace period, it returns nil when there is neither
+func (h *UserHandler) findLoginUser(phone string) (*models.User, error) {
+	user, err := h.UserRepo.FindByPhone(phone)
+	if err != nil && err.Error() != "record not found" {
+		return nil, err
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	user, err = h.UserRepo.FindDeletedByPhone(phone)
+	if err != nil && err.Error() != "record not found" {
+		return nil, err
+	}
+	return user, nil
+}
+
+// completeLogin issues the access token of an authenticated user, accounts
+// scheduled for deletion are only let in when restore is set. Disabled
+// accounts are rejected before anything is restored
+func (h *UserHandler) completeLogin(c echo.Context, user *models.User, restore bool, deviceLabel string) error {
+	if user.Status == models.StatusDisabled {
+		return problem(c, http.StatusForbidden, CodeAccountDisabled, "account is disabled")
 	}
 
-	claims := &JwtCustomClaims{
-		user.ID,
-		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 72)),
-		},
+	// only accounts pending deletion have a purge time
+	if user.PurgeAt != nil {
+		if !restore {
+			return c.JSON(http.StatusConflict, generated.PendingDeletionResponse{
+				Message: translate(c, "account is scheduled for deletion, login with restore set to true to restore it"),
+				PurgeAt: *user.PurgeAt,
+			})
+		}
+
+		err := h.UserRepo.Restore(user.ID)
+		if err == repository.ErrPhoneTaken {
+			// the phone number was registered again during the grace period
+			return problem(c, http.StatusConflict, CodePhoneRegistered, "phone number already registered")
+		}
+		if err != nil {
+			return err
+		}
+		h.recordEvent(c, user.ID, models.EventAccountRestored)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	t, err := token.SignedString([]byte("secret"))
+	t, err := h.issueToken(c, user, deviceLabel)
 	if err != nil {
 		return err
 	}
 
+	h.recordEvent(c, user.ID, models.EventLogin)
+
+	expired := h.passwordExpired(user)
 	return c.JSON(http.StatusOK, generated.LoginResponse{
-		Id:    user.ID,
-		Token: t,
+		Id:                    user.ID,
+		Token:                 t,
+		PasswordResetRequired: user.PasswordResetRequired || expired,
+		PasswordExpired:       expired,
 	})
 }
 
-// Profile handler for user profile
+// Profile handler for user profile, it shows the user ActiveUserMiddleware
+// already found so the profile costs no lookup of its own
 func (h *UserHandler) Profile(c echo.Context) error {
-	userToken := c.Get("user").(*jwt.Token)
-	claims := userToken.Claims.(*JwtCustomClaims)
+	user, ok := c.Get(activeUserKey).(*models.User)
+	if !ok {
+		userToken := c.Get("user").(*jwt.Token)
+		claims := userToken.Claims.(*JwtCustomClaims)
 
-	user, err := h.UserRepo.FindByID(claims.ID)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: err.Error(),
-		})
+		var err error
+		user, err = h.UserRepo.FindProfileByID(claims.ID)
+		if err != nil {
+			return err
+		}
 	}
 
 	return c.JSON(http.StatusOK, generated.ProfileResponse{
@@ -166,37 +269,27 @@ func (h *UserHandler) UpdateProfile(c echo.Context) error {
 
 	var input generated.UpdateProfileRequest
 	if err := c.Bind(&input); err != nil {
-		return c.JSON(http.StatusBadRequest, generated.ErrorResponse{
-			Message: "fail to bind input, it might be bad request",
-		})
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
 	}
 	if err := c.Validate(input); err != nil {
 		return err
 	}
 	if input.Phone[:3] != "+62" {
-		return c.JSON(http.StatusBadRequest, generated.ErrorResponse{
-			Message: "phone number must start with +62",
-		})
+		return invalidField(c, "phone", "prefix", "phone number must start with +62")
 	}
 
-	user, err := h.UserRepo.FindByID(claims.ID)
+	user, err := h.UserRepo.FindByIDForUpdate(claims.ID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	existingUser, err := h.UserRepo.FindByPhone(input.Phone)
 	if err != nil && err.Error() != "record not found" {
-		return c.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	if existingUser != nil && existingUser.ID != user.ID {
-		return c.JSON(http.StatusConflict, generated.ErrorResponse{
-			Message: "phone number already registered",
-		})
+		return problem(c, http.StatusConflict, CodePhoneRegistered, "phone number already registered")
 	}
 
 	user.PhoneNumber = input.Phone
@@ -204,15 +297,312 @@ func (h *UserHandler) UpdateProfile(c echo.Context) error {
 		user.Fullname = input.Fullname
 	}
 
-	err = h.UserRepo.Update(user)
+	err = h.UserRepo.Update(user, repository.ColumnPhoneNumber, repository.ColumnFullname)
+	if err == repository.ErrPhoneTaken {
+		return problem(c, http.StatusConflict, CodePhoneRegistered, "phone number already registered")
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: err.Error(),
-		})
+		return err
 	}
+	h.recordEvent(c, user.ID, models.EventProfileUpdated)
 
-	return c.JSON(http.StatusOK, generated.ProfileResponse{
+	return c.JSON(http.StatusOK, generated.UpdateProfileResponse{
 		Fullname: user.Fullname,
 		Phone:    user.PhoneNumber,
 	})
 }
+
+// DeleteProfile handler for deleting the user account, the account is soft
+// deleted right away and its personal data is purged after the grace period.
+// Users without a password log in again instead of entering it
+func (h *UserHandler) DeleteProfile(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	var input generated.DeleteProfileRequest
+	if err := c.Bind(&input); err != nil {
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+	}
+	if err := c.Validate(input); err != nil {
+		return err
+	}
+
+	user, err := h.UserRepo.FindByIDForUpdate(claims.ID)
+	if err != nil {
+		return err
+	}
+
+	confirmed, err := h.reauthenticated(c, user, claims.SessionID, input.Password)
+	if err != nil {
+		return h.hashingFailed(c, err)
+	}
+	if !confirmed {
+		return reauthenticationFailed(c, user)
+	}
+
+	purgeAt := time.Now().Add(h.DeletionGracePeriod)
+	err = h.UserRepo.ScheduleDeletion(user.ID, purgeAt)
+	if err != nil {
+		return err
+	}
+	err = h.SessionRepo.RevokeAllByUser(user.ID)
+	if err != nil {
+		return err
+	}
+	h.recordEvent(c, user.ID, models.EventAccountDeleted)
+
+	return c.JSON(http.StatusAccepted, generated.DeleteProfileResponse{
+		PurgeAt: purgeAt,
+	})
+}
+
+// ChangePassword handler for changing the user password, every token issued
+// before the change is revoked so the user has to login again
+func (h *UserHandler) ChangePassword(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(*JwtCustomClaims)
+
+	var input generated.ChangePasswordRequest
+	if err := c.Bind(&input); err != nil {
+		return problem(c, http.StatusBadRequest, CodeInvalidBody, "fail to bind input, it might be bad request")
+	}
+	if err := c.Validate(input); err != nil {
+		return err
+	}
+
+	if fieldErrors := h.policyViolations(c, "new_password", input.NewPassword); len(fieldErrors) > 0 {
+		return invalidFields(c, fieldErrors)
+	}
+
+	user, err := h.UserRepo.FindByIDForUpdate(claims.ID)
+	if err != nil {
+		return err
+	}
+
+	matches, err := h.passwordMatches(c, user, input.CurrentPassword)
+	if err != nil {
+		return h.hashingFailed(c, err)
+	}
+	if !matches {
+		return problem(c, http.StatusUnauthorized, CodeInvalidCredentials, "invalid password")
+	}
+	fieldErrors, err := h.screenPassword(c, "new_password", input.NewPassword, password.User{Name: user.Fullname, Phone: user.PhoneNumber})
+	if err != nil {
+		return err
+	}
+	reused, err := h.passwordReused(c, user, input.NewPassword)
+	if err != nil {
+		return h.hashingFailed(c, err)
+	}
+	if reused {
+		fieldErrors = append(fieldErrors, generated.FieldError{
+			Field:   "new_password",
+			Rule:    "history",
+			Message: translate(c, "password must not be one of your last {0} passwords", strconv.Itoa(h.passwordPolicy().History)),
+		})
+	}
+	if len(fieldErrors) > 0 {
+		return invalidFields(c, fieldErrors)
+	}
+
+	now := time.Now()
+	user.SaltToken = util.GenerateSalt()
+	if user.Password, err = h.hashPassword(c, input.NewPassword, user.SaltToken); err != nil {
+		return h.hashingFailed(c, err)
+	}
+	user.PasswordResetRequired = false
+	user.PasswordChangedAt = &now
+	user.TokensRevokedAt = &now
+
+	err = h.UserRepo.Update(user, repository.ColumnPassword, repository.ColumnSaltToken,
+		repository.ColumnPasswordResetRequired, repository.ColumnPasswordChangedAt, repository.ColumnTokensRevokedAt)
+	if err != nil {
+		return err
+	}
+	err = h.SessionRepo.RevokeAllByUser(user.ID)
+	if err != nil {
+		return err
+	}
+	h.rememberPassword(c, user)
+	h.recordEvent(c, user.ID, models.EventPasswordChanged)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// passwordMatches checks the given plain password against the user's hashed password
+func (h *UserHandler) passwordMatches(c echo.Context, user *models.User, password string) (bool, error) {
+	hashedPassword, err := h.hashPassword(c, password, user.SaltToken)
+	if err != nil {
+		return false, err
+	}
+	return hashedPassword == user.Password, nil
+}
+
+// reauthenticated confirms a sensitive change with the password of the user.
+// Users signed up with an identity provider have no password, they confirm it
+// by having logged in within recentLoginWindow instead
+func (h *UserHandler) reauthenticated(c echo.Context, user *models.User, sessionID string, password *string) (bool, error) {
+	if user.Password == "" {
+		session, err := h.SessionRepo.FindByID(sessionID)
+		if err != nil {
+			return false, err
+		}
+		return time.Since(session.CreatedAt) <= recentLoginWindow, nil
+	}
+	if password == nil {
+		return false, nil
+	}
+	return h.passwordMatches(c, user, *password)
+}
+
+// reauthenticationFailed responds to a change that reauthenticated did not confirm
+func reauthenticationFailed(c echo.Context, user *models.User) error {
+	if user.Password == "" {
+		return problem(c, http.StatusUnauthorized, CodeInvalidCredentials, "log in again to confirm, the account has no password")
+	}
+	return problem(c, http.StatusUnauthorized, CodeInvalidCredentials, "invalid password")
+}
+
+// passwordPolicy is the configured password policy or the default one
+func (h *UserHandler) passwordPolicy() util.PasswordPolicy {
+	if h.PasswordPolicy == nil {
+		return util.DefaultPasswordPolicy()
+	}
+	return *h.PasswordPolicy
+}
+
+// policyViolations checks a new password against the password policy, the
+// violations are reported on field
+func (h *UserHandler) policyViolations(c echo.Context, field, newPassword string) []generated.FieldError {
+	var fieldErrors []generated.FieldError
+	for _, violation := range h.passwordPolicy().Validate(newPassword) {
+		fieldErrors = append(fieldErrors, generated.FieldError{Field: field, Rule: violation.Rule, Message: translate(c, violation.Template, violation.Params...)})
+	}
+	return fieldErrors
+}
+
+// screenPassword runs the password checker on a new password, the violations are reported on field
+func (h *UserHandler) screenPassword(c echo.Context, field, newPassword string, user password.User) ([]generated.FieldError, error) {
+	if h.PasswordChecker == nil {
+		return nil, nil
+	}
+	violations, err := h.PasswordChecker.Check(c.Request().Context(), newPassword, user)
+	if err != nil {
+		return nil, err
+	}
+	var fieldErrors []generated.FieldError
+	for _, violation := range violations {
+		fieldErrors = append(fieldErrors, generated.FieldError{Field: field, Rule: violation.Rule, Message: translate(c, violation.Message)})
+	}
+	return fieldErrors, nil
+}
+
+// passwordReused reports whether the new password is the current one or one of
+// the previous passwords the policy keeps
+func (h *UserHandler) passwordReused(c echo.Context, user *models.User, newPassword string) (bool, error) {
+	history := h.passwordPolicy().History
+	if history == 0 {
+		return false, nil
+	}
+	var previous []models.PasswordHistory
+	if user.Password != "" {
+		previous = append(previous, models.PasswordHistory{Password: user.Password, SaltToken: user.SaltToken})
+	}
+	if h.PasswordHistoryRepo != nil {
+		entries, err := h.PasswordHistoryRepo.ListByUser(user.ID, history)
+		if err != nil {
+			return false, err
+		}
+		for _, entry := range entries {
+			// the newest entry is usually the current password
+			if entry.SaltToken != user.SaltToken {
+				previous = append(previous, entry)
+			}
+		}
+	}
+	if len(previous) > history {
+		previous = previous[:history]
+	}
+
+	for _, entry := range previous {
+		hashedPassword, err := h.hashPassword(c, newPassword, entry.SaltToken)
+		if err != nil {
+			return false, err
+		}
+		if hashedPassword == entry.Password {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rememberPassword adds the user's password to the password history, failing
+// to do so is logged but never fails the request
+func (h *UserHandler) rememberPassword(c echo.Context, user *models.User) {
+	history := h.passwordPolicy().History
+	if h.PasswordHistoryRepo == nil || history == 0 {
+		return
+	}
+	err := h.PasswordHistoryRepo.Add(&models.PasswordHistory{
+		UserID:    user.ID,
+		Password:  user.Password,
+		SaltToken: user.SaltToken,
+	}, history)
+	if err != nil {
+		c.Logger().Errorf("fail to add password history of user %d: %v", user.ID, err)
+	}
+}
+
+// passwordExpired reports whether the password is older than the maximum
+// password age, accounts without a password never expire
+func (h *UserHandler) passwordExpired(user *models.User) bool {
+	if h.MaxPasswordAge == 0 || !user.HasPassword() {
+		return false
+	}
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	return time.Since(changedAt) > h.MaxPasswordAge
+}
+
+// hashPassword hashes the password through the hashing pool
+func (h *UserHandler) hashPassword(c echo.Context, password, salt string) (string, error) {
+	if h.Hasher == nil {
+		return util.HashPassword(password, salt), nil
+	}
+	return h.Hasher.Hash(c.Request().Context(), password, salt)
+}
+
+// hashingFailed responds to a failed password hash, a saturated pool is
+// reported as 503 so clients retry after the queue timeout
+func (h *UserHandler) hashingFailed(c echo.Context, err error) error {
+	if errors.Is(err, hashing.ErrSaturated) {
+		c.Response().Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(h.Hasher.QueueTimeout()))))
+		return problem(c, http.StatusServiceUnavailable, CodeUnavailable, err.Error())
+	}
+	return err
+}
+
+// recordEvent stores an audit event for the user
+func (h *UserHandler) recordEvent(c echo.Context, userID int, eventType string) {
+	recordEvent(h.AuditRepo, c, userID, eventType)
+}
+
+// recordEvent stores an audit event for the user, auditing is skipped when the
+// repository is nil and failing to record it is logged but never fails the request
+func recordEvent(auditRepo repository.AuditRepository, c echo.Context, userID int, eventType string) {
+	if auditRepo == nil {
+		return
+	}
+
+	err := auditRepo.Create(&models.AuditEvent{
+		UserID:    userID,
+		EventType: eventType,
+		IPAddress: c.RealIP(),
+		UserAgent: util.Truncate(c.Request().UserAgent(), 255),
+	})
+	if err != nil {
+		c.Logger().Errorf("fail to record %s event for user %d: %v", eventType, userID, err)
+	}
+}
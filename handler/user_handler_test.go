@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -9,7 +10,11 @@ import (
 	"time"
 
 	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/hashing"
 	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/password"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
 	"github.com/SawitProRecruitment/UserService/util"
 	"github.com/go-playground/validator/v10"
 	"github.com/golang-jwt/jwt/v5"
@@ -45,11 +50,61 @@ func (m *MockUserRepository) FindByID(id int) (*models.User, error) {
 	return args[0].(*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) Update(user *models.User) error {
-	args := m.Called(user)
+func (m *MockUserRepository) FindByIDForUpdate(id int) (*models.User, error) {
+	args := m.Called(id)
+	return args[0].(*models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) FindProfileByID(id int) (*models.User, error) {
+	args := m.Called(id)
+	return args[0].(*models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Update(user *models.User, columns ...string) error {
+	args := m.Called(user, columns)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) Delete(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) ScheduleDeletion(id int, purgeAt time.Time) error {
+	args := m.Called(id, purgeAt)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) FindDeletedByPhone(phone string) (*models.User, error) {
+	args := m.Called(phone)
+	return args[0].(*models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Restore(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) FindPurgeable(before time.Time, limit int) ([]models.User, error) {
+	args := m.Called(before, limit)
+	return args[0].([]models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Anonymize(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UseTOTPStep(id int, step int64) error {
+	args := m.Called(id, step)
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) List(params models.UserListParams) (*models.UserPage, error) {
+	args := m.Called(params)
+	return args[0].(*models.UserPage), args.Error(1)
+}
+
 func registerEchoCtx(jsonInput, endpoint string) (*httptest.ResponseRecorder, echo.Context) {
 	req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(jsonInput))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -103,6 +158,62 @@ func TestRegister(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestRegisterPhoneTakenMeanwhile(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+	}
+
+	jsonInput := `{
+		"phone": "+62812345678912",
+		"password": "A1234*",
+		"fullname": "mr smith"
+	}`
+	rec, c := registerEchoCtx(jsonInput, "/register")
+
+	// another request registers the phone number between the check and the insert
+	var existingUser *models.User
+	mockRepo.On("FindByPhone", "+62812345678912").Return(existingUser, errors.New("record not found")).Once()
+	mockRepo.On("Create", mock.AnythingOfType("*models.User")).Return(repository.ErrPhoneTaken)
+
+	err := handler.Register(c)
+	assert.NoError(t, err)
+
+	expectedJSON := `{"type":"urn:sawitpro:problem:phone_registered","title":"Conflict","status":409,"code":"phone_registered","instance":"/register","detail":"phone number already registered"}`
+	assert.JSONEq(t, expectedJSON, rec.Body.String())
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRegisterHashingSaturated(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+
+	hasher := hashing.NewPool(1, 10*time.Millisecond)
+	release, err := hasher.Acquire(context.Background())
+	assert.NoError(t, err)
+	defer release()
+
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+		Hasher:   hasher,
+	}
+
+	jsonInput := `{
+		"phone": "+62812345678912",
+		"password": "A1234*",
+		"fullname": "mr smith"
+	}`
+	rec, c := registerEchoCtx(jsonInput, "/register")
+
+	err = handler.Register(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	mockRepo.AssertExpectations(t)
+}
+
 func TestRegisterFailBindInput(t *testing.T) {
 	mockRepo := new(MockUserRepository)
 
@@ -118,7 +229,7 @@ func TestRegisterFailBindInput(t *testing.T) {
 	err := handler.Register(c)
 	assert.NoError(t, err)
 
-	expectedJSON := `{"message":"fail to bind input, it might be bad request"}`
+	expectedJSON := `{"type":"urn:sawitpro:problem:invalid_body","title":"Bad Request","status":400,"code":"invalid_body","instance":"/register","detail":"fail to bind input, it might be bad request"}`
 	assert.JSONEq(t, expectedJSON, rec.Body.String())
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	mockRepo.AssertExpectations(t)
@@ -166,7 +277,8 @@ func TestRegisterValidatePhone(t *testing.T) {
 	err := handler.Register(c)
 	assert.NoError(t, err)
 
-	expectedJSON := `{"message":"phone number must start with +62"}`
+	expectedJSON := `{"type":"urn:sawitpro:problem:validation_failed","title":"Bad Request","status":400,"code":"validation_failed","instance":"/register","detail":"phone number must start with +62",
+		"errors":[{"field":"phone","rule":"prefix","message":"phone number must start with +62"}]}`
 	assert.JSONEq(t, expectedJSON, rec.Body.String())
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	mockRepo.AssertExpectations(t)
@@ -189,12 +301,85 @@ func TestRegisterValidatePassword(t *testing.T) {
 	err := handler.Register(c)
 	assert.NoError(t, err)
 
-	expectedJSON := `{"message":"phone number must start with +62"}`
+	expectedJSON := `{"type":"urn:sawitpro:problem:validation_failed","title":"Bad Request","status":400,"code":"validation_failed","instance":"/register","detail":"phone number must start with +62",
+		"errors":[{"field":"phone","rule":"prefix","message":"phone number must start with +62"}]}`
 	assert.JSONEq(t, expectedJSON, rec.Body.String())
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	mockRepo.AssertExpectations(t)
 }
 
+func TestRegisterPasswordPolicy(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	handler := &UserHandler{
+		UserRepo:       mockRepo,
+		PasswordPolicy: &util.PasswordPolicy{MinLength: 8, MaxLength: 64, Require: []string{util.PasswordUpper, util.PasswordNumber}, Symbols: "#"},
+	}
+
+	jsonInput := `{
+		"phone": "+62812345678912",
+		"password": "abc#!",
+		"fullname": "mr smith"
+	}`
+	rec, c := registerEchoCtx(jsonInput, "/register")
+
+	err := handler.Register(c)
+	assert.NoError(t, err)
+
+	expectedJSON := `{"type":"urn:sawitpro:problem:validation_failed","title":"Bad Request","status":400,"code":"validation_failed","instance":"/register","detail":"password must be at least 8 characters, password must contain at least 1 uppercase letter, password must contain at least 1 number, password must not contain \"!\", the allowed symbols are #",
+		"errors":[{"field":"password","rule":"min_length","message":"password must be at least 8 characters"},
+		{"field":"password","rule":"upper","message":"password must contain at least 1 uppercase letter"},
+		{"field":"password","rule":"number","message":"password must contain at least 1 number"},
+		{"field":"password","rule":"symbols","message":"password must not contain \"!\", the allowed symbols are #"}]}`
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, expectedJSON, rec.Body.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRegisterScreenPassword(t *testing.T) {
+	tests := []struct {
+		name         string
+		password     string
+		expectedJSON string
+	}{
+		{
+			name:     "Common Password",
+			password: "Password1!",
+			expectedJSON: `{"type":"urn:sawitpro:problem:validation_failed","title":"Bad Request","status":400,"code":"validation_failed","instance":"/register","detail":"password is too common, password is too easy to guess, use a longer password or a few uncommon words",
+				"errors":[{"field":"password","rule":"common","message":"password is too common"},
+				{"field":"password","rule":"strength","message":"password is too easy to guess, use a longer password or a few uncommon words"}]}`,
+		},
+		{
+			name:     "Password Contains Name",
+			password: "Smith&Wesson#1867",
+			expectedJSON: `{"type":"urn:sawitpro:problem:validation_failed","title":"Bad Request","status":400,"code":"validation_failed","instance":"/register","detail":"password must not contain your name",
+				"errors":[{"field":"password","rule":"context","message":"password must not contain your name"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			handler := &UserHandler{
+				UserRepo:        mockRepo,
+				PasswordChecker: password.NewChecker(password.NewCommonRule(), password.NewContextRule(), password.NewStrengthRule(2)),
+			}
+
+			jsonInput := `{
+				"phone": "+62812345678912",
+				"password": "` + tt.password + `",
+				"fullname": "mr smith"
+			}`
+			rec, c := registerEchoCtx(jsonInput, "/register")
+
+			err := handler.Register(c)
+			assert.NoError(t, err)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.JSONEq(t, tt.expectedJSON, rec.Body.String())
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
 func TestRegisterValidateErrorRecordNotFound(t *testing.T) {
 	mockRepo := new(MockUserRepository)
 
@@ -213,9 +398,11 @@ func TestRegisterValidateErrorRecordNotFound(t *testing.T) {
 	mockRepo.On("FindByPhone", "+62812345678912").Return(emptyUser, errors.New("test the error"))
 
 	err := handler.Register(c)
-	assert.NoError(t, err)
+	assert.EqualError(t, err, "test the error")
 
-	expectedJSON := `{"message":"test the error"}`
+	// the error message is only logged, the client gets a generic problem
+	HTTPErrorHandler(err, c)
+	expectedJSON := `{"type":"urn:sawitpro:problem:internal_error","title":"Internal Server Error","status":500,"code":"internal_error","instance":"/register","detail":"internal server error"}`
 	assert.JSONEq(t, expectedJSON, rec.Body.String())
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	mockRepo.AssertExpectations(t)
@@ -247,7 +434,7 @@ func TestRegisterValidatePhoneNumberAlreadyRegistered(t *testing.T) {
 	err := handler.Register(c)
 	assert.NoError(t, err)
 
-	expectedJSON := `{"message":"phone number already registered"}`
+	expectedJSON := `{"type":"urn:sawitpro:problem:phone_registered","title":"Conflict","status":409,"code":"phone_registered","instance":"/register","detail":"phone number already registered"}`
 	assert.JSONEq(t, expectedJSON, rec.Body.String())
 	assert.Equal(t, http.StatusConflict, rec.Code)
 	mockRepo.AssertExpectations(t)
@@ -272,9 +459,11 @@ func TestRegisterCreateUserError(t *testing.T) {
 	mockRepo.On("Create", mock.AnythingOfType("*models.User")).Return(errors.New("create user error"))
 
 	err := handler.Register(c)
-	assert.NoError(t, err)
+	assert.EqualError(t, err, "create user error")
 
-	expectedJSON := `{"message":"create user error"}`
+	// the error message is only logged, the client gets a generic problem
+	HTTPErrorHandler(err, c)
+	expectedJSON := `{"type":"urn:sawitpro:problem:internal_error","title":"Internal Server Error","status":500,"code":"internal_error","instance":"/register","detail":"internal server error"}`
 	assert.JSONEq(t, expectedJSON, rec.Body.String())
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	mockRepo.AssertExpectations(t)
@@ -283,8 +472,10 @@ func TestRegisterCreateUserError(t *testing.T) {
 func TestLogin(t *testing.T) {
 	mockRepo := new(MockUserRepository)
 
+	sessionRepo := mocks.NewSessionRepository(t)
 	handler := &UserHandler{
-		UserRepo: mockRepo,
+		UserRepo:    mockRepo,
+		SessionRepo: sessionRepo,
 	}
 
 	jsonInput := `{
@@ -307,6 +498,10 @@ func TestLogin(t *testing.T) {
 		SaltToken:   "salt",
 	}, nil)
 
+	sessionRepo.On("Create", mock.MatchedBy(func(session *models.Session) bool {
+		return session.UserID == 1 && len(session.ID) == 32
+	})).Return(nil)
+
 	err := handler.Login(c)
 	assert.NoError(t, err)
 
@@ -314,6 +509,43 @@ func TestLogin(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestLoginHashingSaturated(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+
+	// the only hashing slot is taken
+	hasher := hashing.NewPool(1, 10*time.Millisecond)
+	release, err := hasher.Acquire(context.Background())
+	assert.NoError(t, err)
+	defer release()
+
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+		Hasher:   hasher,
+	}
+
+	jsonInput := `{
+		"phone": "+62812345678912",
+		"password": "A1234*"
+	}`
+	rec, c := registerEchoCtx(jsonInput, "/login")
+
+	mockRepo.On("FindByPhone", "+62812345678912").Return(&models.User{
+		ID:          1,
+		PhoneNumber: "+62812345678912",
+		Password:    util.HashPassword("A1234*", "salt"),
+		SaltToken:   "salt",
+	}, nil)
+
+	err = handler.Login(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"type":"urn:sawitpro:problem:service_unavailable","title":"Service Unavailable","status":503,"code":"service_unavailable","instance":"/login","detail":"password hashing is saturated, try again later"}`, rec.Body.String())
+	assert.Equal(t, uint64(1), hasher.Stats().Rejected)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestLoginFailBindInput(t *testing.T) {
 	mockRepo := new(MockUserRepository)
 
@@ -329,7 +561,7 @@ func TestLoginFailBindInput(t *testing.T) {
 	err := handler.Login(c)
 	assert.NoError(t, err)
 
-	expectedJSON := `{"message":"fail to bind input, it might be bad request"}`
+	expectedJSON := `{"type":"urn:sawitpro:problem:invalid_body","title":"Bad Request","status":400,"code":"invalid_body","instance":"/login","detail":"fail to bind input, it might be bad request"}`
 	assert.JSONEq(t, expectedJSON, rec.Body.String())
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	mockRepo.AssertExpectations(t)
@@ -350,7 +582,8 @@ func TestLoginValidatePhone(t *testing.T) {
 	err := handler.Login(c)
 	assert.NoError(t, err)
 
-	expectedJSON := `{"message":"phone number must start with +62"}`
+	expectedJSON := `{"type":"urn:sawitpro:problem:validation_failed","title":"Bad Request","status":400,"code":"validation_failed","instance":"/login","detail":"phone number must start with +62",
+		"errors":[{"field":"phone","rule":"prefix","message":"phone number must start with +62"}]}`
 	assert.JSONEq(t, expectedJSON, rec.Body.String())
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	mockRepo.AssertExpectations(t)
@@ -382,6 +615,207 @@ func TestLoginValidate(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestLoginDisabledUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+	}
+
+	jsonInput := `{
+		"phone": "+62812345678912",
+		"password": "A1234*"
+	}`
+	rec, c := registerEchoCtx(jsonInput, "/login")
+
+	mockRepo.On("FindByPhone", "+62812345678912").Return(&models.User{
+		ID:          1,
+		PhoneNumber: "+62812345678912",
+		Password:    util.HashPassword("A1234*", "salt"),
+		Fullname:    "mr smith",
+		SaltToken:   "salt",
+		Role:        models.RoleUser,
+		Status:      models.StatusDisabled,
+	}, nil)
+
+	err := handler.Login(c)
+	assert.NoError(t, err)
+
+	expectedJSON := `{"type":"urn:sawitpro:problem:account_disabled","title":"Forbidden","status":403,"code":"account_disabled","instance":"/login","detail":"account is disabled"}`
+	assert.JSONEq(t, expectedJSON, rec.Body.String())
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLoginUnknownPhone(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+	}
+
+	jsonInput := `{
+		"phone": "+62812345678912",
+		"password": "A1234*"
+	}`
+	rec, c := registerEchoCtx(jsonInput, "/login")
+
+	var emptyUser *models.User
+	mockRepo.On("FindByPhone", "+62812345678912").Return(emptyUser, errors.New("record not found"))
+	mockRepo.On("FindDeletedByPhone", "+62812345678912").Return(emptyUser, errors.New("record not found"))
+
+	err := handler.Login(c)
+	assert.NoError(t, err)
+
+	expectedJSON := `{"type":"urn:sawitpro:problem:invalid_credentials","title":"Unauthorized","status":401,"code":"invalid_credentials","instance":"/login","detail":"invalid phone or password"}`
+	assert.JSONEq(t, expectedJSON, rec.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLoginPendingDeletion(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+	}
+
+	jsonInput := `{
+		"phone": "+62812345678912",
+		"password": "A1234*"
+	}`
+	rec, c := registerEchoCtx(jsonInput, "/login")
+
+	var emptyUser *models.User
+	purgeAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("FindByPhone", "+62812345678912").Return(emptyUser, errors.New("record not found"))
+	mockRepo.On("FindDeletedByPhone", "+62812345678912").Return(&models.User{
+		ID:          1,
+		PhoneNumber: "+62812345678912",
+		Password:    util.HashPassword("A1234*", "salt"),
+		Fullname:    "mr smith",
+		SaltToken:   "salt",
+		PurgeAt:     &purgeAt,
+	}, nil)
+
+	err := handler.Login(c)
+	assert.NoError(t, err)
+
+	expectedJSON := `{"message":"account is scheduled for deletion, login with restore set to true to restore it","purge_at":"2024-03-01T00:00:00Z"}`
+	assert.JSONEq(t, expectedJSON, rec.Body.String())
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLoginPasswordExpired(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	sessionRepo := mocks.NewSessionRepository(t)
+	handler := &UserHandler{
+		UserRepo:       mockRepo,
+		SessionRepo:    sessionRepo,
+		MaxPasswordAge: 90 * 24 * time.Hour,
+	}
+
+	jsonInput := `{
+		"phone": "+62812345678912",
+		"password": "A1234*"
+	}`
+	rec, c := registerEchoCtx(jsonInput, "/login")
+
+	changedAt := time.Now().Add(-91 * 24 * time.Hour)
+	mockRepo.On("FindByPhone", "+62812345678912").Return(&models.User{
+		ID:                1,
+		PhoneNumber:       "+62812345678912",
+		Password:          util.HashPassword("A1234*", "salt"),
+		SaltToken:         "salt",
+		PasswordChangedAt: &changedAt,
+	}, nil)
+	sessionRepo.On("Create", mock.Anything).Return(nil)
+
+	err := handler.Login(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"password_expired":true,"password_reset_required":true`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLoginRestore(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+
+	sessionRepo := mocks.NewSessionRepository(t)
+	handler := &UserHandler{
+		UserRepo:    mockRepo,
+		SessionRepo: sessionRepo,
+	}
+
+	jsonInput := `{
+		"phone": "+62812345678912",
+		"password": "A1234*",
+		"restore": true
+	}`
+	rec, c := registerEchoCtx(jsonInput, "/login")
+
+	var emptyUser *models.User
+	purgeAt := time.Now().Add(24 * time.Hour)
+	mockRepo.On("FindByPhone", "+62812345678912").Return(emptyUser, errors.New("record not found"))
+	mockRepo.On("FindDeletedByPhone", "+62812345678912").Return(&models.User{
+		ID:          1,
+		PhoneNumber: "+62812345678912",
+		Password:    util.HashPassword("A1234*", "salt"),
+		Fullname:    "mr smith",
+		SaltToken:   "salt",
+		PurgeAt:     &purgeAt,
+	}, nil)
+	mockRepo.On("Restore", 1).Return(nil)
+
+	sessionRepo.On("Create", mock.MatchedBy(func(session *models.Session) bool {
+		return session.UserID == 1 && len(session.ID) == 32
+	})).Return(nil)
+
+	err := handler.Login(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLoginRestoreDisabledUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+	}
+
+	jsonInput := `{
+		"phone": "+62812345678912",
+		"password": "A1234*",
+		"restore": true
+	}`
+	rec, c := registerEchoCtx(jsonInput, "/login")
+
+	var emptyUser *models.User
+	purgeAt := time.Now().Add(24 * time.Hour)
+	mockRepo.On("FindByPhone", "+62812345678912").Return(emptyUser, errors.New("record not found"))
+	mockRepo.On("FindDeletedByPhone", "+62812345678912").Return(&models.User{
+		ID:          1,
+		PhoneNumber: "+62812345678912",
+		Password:    util.HashPassword("A1234*", "salt"),
+		Fullname:    "mr smith",
+		SaltToken:   "salt",
+		Status:      models.StatusDisabled,
+		PurgeAt:     &purgeAt,
+	}, nil)
+
+	err := handler.Login(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	// the account stays scheduled for deletion
+	mockRepo.AssertNotCalled(t, "Restore", 1)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestProfile(t *testing.T) {
 	// Create an instance of the mocked repository and UserHandler
 	mockRepo := new(MockUserRepository)
@@ -418,7 +852,7 @@ func TestProfile(t *testing.T) {
 	c.Set("user", token)
 
 	// Mock the UserRepo method
-	mockRepo.On("FindByID", 123).Return(&models.User{
+	mockRepo.On("FindProfileByID", 123).Return(&models.User{
 		ID:          123,
 		Fullname:    "John Doe",
 		PhoneNumber: "+628123456789",
@@ -439,6 +873,24 @@ func TestProfile(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestProfileOfActiveUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	handler := &UserHandler{
+		UserRepo: mockRepo,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set(activeUserKey, &models.User{ID: 123, Fullname: "John Doe", PhoneNumber: "+628123456789"})
+
+	// the user found by ActiveUserMiddleware is shown without another lookup
+	assert.NoError(t, handler.Profile(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"fullname":"John Doe","phone":"+628123456789"}`, rec.Body.String())
+	mockRepo.AssertNotCalled(t, "FindProfileByID", 123)
+}
+
 func TestUpdateProfile(t *testing.T) {
 	// Create an instance of the mocked repository and UserHandler
 	mockRepo := new(MockUserRepository)
@@ -478,7 +930,7 @@ func TestUpdateProfile(t *testing.T) {
 	c.Set("user", token)
 
 	hashedPassword := util.HashPassword("A1234*", "salt")
-	mockRepo.On("FindByID", 123).Return(&models.User{
+	mockRepo.On("FindByIDForUpdate", 123).Return(&models.User{
 		ID:          123,
 		PhoneNumber: "+62812345678909",
 		Password:    hashedPassword,
@@ -498,7 +950,7 @@ func TestUpdateProfile(t *testing.T) {
 		Password:    hashedPassword,
 		Fullname:    "The Inspirator",
 		SaltToken:   "salt",
-	}).Return(nil)
+	}, []string{repository.ColumnPhoneNumber, repository.ColumnFullname}).Return(nil)
 
 	err = handler.UpdateProfile(c)
 	assert.NoError(t, err)
@@ -519,3 +971,217 @@ func TestNewUserHandler(t *testing.T) {
 		t.Errorf("NewUserHandler() returned nil")
 	}
 }
+
+func deleteProfileEchoCtx(jsonInput string, id int) (*httptest.ResponseRecorder, echo.Context) {
+	claims := &JwtCustomClaims{
+		ID: id,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	req := httptest.NewRequest(http.MethodDelete, "/profile", strings.NewReader(jsonInput))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+	c := e.NewContext(req, rec)
+	c.Set("user", token)
+
+	return rec, c
+}
+
+func TestDeleteProfile(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	sessionRepo := mocks.NewSessionRepository(t)
+	handler := &UserHandler{
+		UserRepo:            mockRepo,
+		SessionRepo:         sessionRepo,
+		DeletionGracePeriod: 30 * 24 * time.Hour,
+	}
+
+	rec, c := deleteProfileEchoCtx(`{"password": "A1234*"}`, 123)
+
+	mockRepo.On("FindByIDForUpdate", 123).Return(&models.User{
+		ID:          123,
+		PhoneNumber: "+62812345678909",
+		Password:    util.HashPassword("A1234*", "salt"),
+		Fullname:    "The Inspirator",
+		SaltToken:   "salt",
+	}, nil)
+	mockRepo.On("ScheduleDeletion", 123, mock.MatchedBy(func(purgeAt time.Time) bool {
+		return purgeAt.After(time.Now().Add(29 * 24 * time.Hour))
+	})).Return(nil)
+
+	sessionRepo.On("RevokeAllByUser", 123).Return(nil)
+
+	err := handler.DeleteProfile(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Contains(t, rec.Body.String(), "purge_at")
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteProfileInvalidPassword(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	handler := &UserHandler{
+		UserRepo:            mockRepo,
+		DeletionGracePeriod: 30 * 24 * time.Hour,
+	}
+
+	rec, c := deleteProfileEchoCtx(`{"password": "wrong"}`, 123)
+
+	mockRepo.On("FindByIDForUpdate", 123).Return(&models.User{
+		ID:          123,
+		PhoneNumber: "+62812345678909",
+		Password:    util.HashPassword("A1234*", "salt"),
+		Fullname:    "The Inspirator",
+		SaltToken:   "salt",
+	}, nil)
+
+	err := handler.DeleteProfile(c)
+	assert.NoError(t, err)
+
+	expectedJSON := `{"type":"urn:sawitpro:problem:invalid_credentials","title":"Unauthorized","status":401,"code":"invalid_credentials","instance":"/profile","detail":"invalid password"}`
+	assert.JSONEq(t, expectedJSON, rec.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteProfileWithoutPassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		loggedInAt time.Time
+		wantStatus int
+	}{
+		{name: "Recent Login", loggedInAt: time.Now().Add(-time.Minute), wantStatus: http.StatusAccepted},
+		{name: "Old Login", loggedInAt: time.Now().Add(-time.Hour), wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			sessionRepo := mocks.NewSessionRepository(t)
+			handler := &UserHandler{
+				UserRepo:            mockRepo,
+				SessionRepo:         sessionRepo,
+				DeletionGracePeriod: 30 * 24 * time.Hour,
+			}
+
+			// users signed up with an identity provider have no password to enter
+			rec, c := deleteProfileEchoCtx(`{}`, 123)
+			mockRepo.On("FindByIDForUpdate", 123).Return(&models.User{ID: 123, PhoneNumber: "+62812345678909", Fullname: "The Inspirator"}, nil)
+			sessionRepo.On("FindByID", "").Return(&models.Session{UserID: 123, CreatedAt: tt.loggedInAt}, nil)
+			mockRepo.On("ScheduleDeletion", 123, mock.AnythingOfType("time.Time")).Return(nil).Maybe()
+			sessionRepo.On("RevokeAllByUser", 123).Return(nil).Maybe()
+
+			err := handler.DeleteProfile(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.Contains(t, rec.Body.String(), "log in again to confirm")
+				mockRepo.AssertNotCalled(t, "ScheduleDeletion", 123, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestChangePasswordReused(t *testing.T) {
+	tests := []struct {
+		name        string
+		newPassword string
+	}{
+		{name: "Current Password", newPassword: "A1234*"},
+		{name: "Previous Password", newPassword: "Old1234*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			historyRepo := &mocks.PasswordHistoryRepository{}
+			handler := &UserHandler{
+				UserRepo:            mockRepo,
+				PasswordHistoryRepo: historyRepo,
+				PasswordPolicy:      &util.PasswordPolicy{MinLength: 6, MaxLength: 64, Symbols: util.DefaultPasswordSymbols, History: 3},
+			}
+
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{ID: 123})
+			jsonInput := `{
+				"current_password": "A1234*",
+				"new_password": "` + tt.newPassword + `"
+			}`
+			req := httptest.NewRequest(http.MethodPut, "/profile/password", strings.NewReader(jsonInput))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			e.Validator = &CustomValidator{validator: validator.New()}
+			c := e.NewContext(req, rec)
+			c.Set("user", token)
+
+			mockRepo.On("FindByIDForUpdate", 123).Return(&models.User{
+				ID:        123,
+				Password:  util.HashPassword("A1234*", "salt"),
+				SaltToken: "salt",
+			}, nil)
+			historyRepo.On("ListByUser", 123, 3).Return([]models.PasswordHistory{
+				{ID: 2, UserID: 123, Password: util.HashPassword("A1234*", "salt"), SaltToken: "salt"},
+				{ID: 1, UserID: 123, Password: util.HashPassword("Old1234*", "oldSalt"), SaltToken: "oldSalt"},
+			}, nil).Maybe()
+
+			err := handler.ChangePassword(c)
+			assert.NoError(t, err)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.JSONEq(t, `{"type":"urn:sawitpro:problem:validation_failed","title":"Bad Request","status":400,"code":"validation_failed","instance":"/profile/password","detail":"password must not be one of your last 3 passwords",
+				"errors":[{"field":"new_password","rule":"history","message":"password must not be one of your last 3 passwords"}]}`, rec.Body.String())
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	sessionRepo := mocks.NewSessionRepository(t)
+	handler := &UserHandler{
+		UserRepo:    mockRepo,
+		SessionRepo: sessionRepo,
+	}
+
+	claims := &JwtCustomClaims{ID: 123}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	jsonInput := `{
+		"current_password": "A1234*",
+		"new_password": "B5678&"
+	}`
+	req := httptest.NewRequest(http.MethodPut, "/profile/password", strings.NewReader(jsonInput))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+	c := e.NewContext(req, rec)
+	c.Set("user", token)
+
+	mockRepo.On("FindByIDForUpdate", 123).Return(&models.User{
+		ID:                    123,
+		PhoneNumber:           "+62812345678909",
+		Password:              util.HashPassword("A1234*", "salt"),
+		Fullname:              "The Inspirator",
+		SaltToken:             "salt",
+		PasswordResetRequired: true,
+	}, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(user *models.User) bool {
+		return user.SaltToken != "salt" &&
+			user.Password == util.HashPassword("B5678&", user.SaltToken) &&
+			!user.PasswordResetRequired &&
+			user.TokensRevokedAt != nil
+	}), []string{repository.ColumnPassword, repository.ColumnSaltToken,
+		repository.ColumnPasswordResetRequired, repository.ColumnPasswordChangedAt, repository.ColumnTokensRevokedAt}).Return(nil)
+
+	sessionRepo.On("RevokeAllByUser", 123).Return(nil)
+
+	err := handler.ChangePassword(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,146 @@
+package hashing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/util"
+)
+
+// ErrSaturated is returned when no hashing slot frees up within the queue timeout
+var ErrSaturated = errors.New("password hashing is saturated, try again later")
+
+// Pool bounds how many password hashes run at once, every scrypt hash takes
+// about 32MB of memory so a burst of logins must not run them all together
+type Pool struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+	hash         func(password, salt string) string
+
+	queued   atomic.Int64
+	inFlight atomic.Int64
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// Stats are the counters of a pool, Acquired and Rejected count the callers
+// that got a slot or gave up waiting, wait times are measured from the call
+// until a slot is taken or the queue timeout is hit
+type Stats struct {
+	Concurrency int
+	InFlight    int
+	QueueDepth  int
+	Acquired    uint64
+	Rejected    uint64
+	WaitTotal   time.Duration
+	WaitMax     time.Duration
+}
+
+// NewPool creates a pool running at most concurrency hashes, callers wait up
+// to queueTimeout for a free slot
+func NewPool(concurrency int, queueTimeout time.Duration) *Pool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Pool{
+		slots:        make(chan struct{}, concurrency),
+		queueTimeout: queueTimeout,
+		hash:         util.HashPassword,
+	}
+}
+
+// Hash hashes the password with the salt once a slot is free, it returns
+// ErrSaturated when the queue timeout passes first
+func (p *Pool) Hash(ctx context.Context, password, salt string) (string, error) {
+	release, err := p.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return p.hash(password, salt), nil
+}
+
+// QueueTimeout is how long a caller waits for a slot
+func (p *Pool) QueueTimeout() time.Duration {
+	return p.queueTimeout
+}
+
+// Stats returns a snapshot of the pool counters
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	stats := p.stats
+	p.mu.Unlock()
+
+	stats.Concurrency = cap(p.slots)
+	stats.InFlight = int(p.inFlight.Load())
+	stats.QueueDepth = int(p.queued.Load())
+	return stats
+}
+
+// Acquire waits for a free slot like Hash does, the slot is held until release is called
+func (p *Pool) Acquire(ctx context.Context) (release func(), err error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+	return p.release, nil
+}
+
+func (p *Pool) acquire(ctx context.Context) error {
+	start := time.Now()
+	p.queued.Add(1)
+	defer p.queued.Add(-1)
+
+	// take a free slot right away without starting a timer
+	select {
+	case p.slots <- struct{}{}:
+		p.taken(time.Since(start))
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(p.queueTimeout)
+	defer timer.Stop()
+	select {
+	case p.slots <- struct{}{}:
+		p.taken(time.Since(start))
+		return nil
+	case <-timer.C:
+		p.rejected(time.Since(start))
+		return ErrSaturated
+	case <-ctx.Done():
+		p.rejected(time.Since(start))
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) release() {
+	p.inFlight.Add(-1)
+	<-p.slots
+}
+
+func (p *Pool) taken(wait time.Duration) {
+	p.inFlight.Add(1)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.Acquired++
+	p.waited(wait)
+}
+
+func (p *Pool) rejected(wait time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.Rejected++
+	p.waited(wait)
+}
+
+// waited adds a wait time to the stats, p.mu must be held
+func (p *Pool) waited(wait time.Duration) {
+	p.stats.WaitTotal += wait
+	if wait > p.stats.WaitMax {
+		p.stats.WaitMax = wait
+	}
+}
@@ -0,0 +1,103 @@
+package hashing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingPool returns a pool whose hashes wait until release is closed
+func blockingPool(concurrency int, queueTimeout time.Duration) (pool *Pool, started chan struct{}, release chan struct{}) {
+	pool = NewPool(concurrency, queueTimeout)
+	started = make(chan struct{}, concurrency)
+	release = make(chan struct{})
+	pool.hash = func(password, salt string) string {
+		started <- struct{}{}
+		<-release
+		return password + salt
+	}
+	return pool, started, release
+}
+
+func TestPool_Hash(t *testing.T) {
+	pool := NewPool(2, time.Second)
+
+	got, err := pool.Hash(context.Background(), "Password1!", "salt")
+	assert.NoError(t, err)
+	assert.Equal(t, util.HashPassword("Password1!", "salt"), got)
+
+	stats := pool.Stats()
+	assert.Equal(t, 2, stats.Concurrency)
+	assert.Equal(t, uint64(1), stats.Acquired)
+	assert.Equal(t, 0, stats.InFlight)
+	assert.Equal(t, 0, stats.QueueDepth)
+}
+
+func TestPool_Hash_Saturated(t *testing.T) {
+	pool, started, release := blockingPool(1, 20*time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		_, err := pool.Hash(context.Background(), "first", "salt")
+		done <- err
+	}()
+	<-started
+
+	_, err := pool.Hash(context.Background(), "second", "salt")
+	assert.ErrorIs(t, err, ErrSaturated)
+
+	stats := pool.Stats()
+	assert.Equal(t, 1, stats.InFlight)
+	assert.Equal(t, uint64(1), stats.Rejected)
+	assert.GreaterOrEqual(t, stats.WaitMax, 20*time.Millisecond)
+
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, 0, pool.Stats().InFlight)
+}
+
+func TestPool_Hash_Queued(t *testing.T) {
+	pool, started, release := blockingPool(1, time.Second)
+
+	first := make(chan error)
+	go func() {
+		_, err := pool.Hash(context.Background(), "first", "salt")
+		first <- err
+	}()
+	<-started
+
+	second := make(chan string)
+	go func() {
+		hash, _ := pool.Hash(context.Background(), "second", "salt")
+		second <- hash
+	}()
+	assert.Eventually(t, func() bool { return pool.Stats().QueueDepth == 1 }, time.Second, time.Millisecond)
+
+	// the queued hash runs once the first one frees its slot
+	release <- struct{}{}
+	assert.NoError(t, <-first)
+	<-started
+	close(release)
+	assert.Equal(t, "secondsalt", <-second)
+
+	stats := pool.Stats()
+	assert.Equal(t, uint64(2), stats.Acquired)
+	assert.Equal(t, uint64(0), stats.Rejected)
+	assert.Equal(t, 0, stats.QueueDepth)
+}
+
+func TestPool_Hash_Canceled(t *testing.T) {
+	pool, started, release := blockingPool(1, time.Second)
+	defer close(release)
+
+	go pool.Hash(context.Background(), "first", "salt")
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := pool.Hash(ctx, "second", "salt")
+	assert.ErrorIs(t, err, context.Canceled)
+}
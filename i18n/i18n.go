@@ -0,0 +1,123 @@
+// Package i18n translates the messages of the service. Messages are written in
+// English and the English text is the key of every translation, a message
+// without a translation is served in English.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
+)
+
+// Languages messages are served in
+const (
+	English    = "en"
+	Indonesian = "id"
+)
+
+// catalogs are the translations of every language, English needs none
+var catalogs = map[string]map[string]string{
+	Indonesian: indonesian,
+}
+
+// Catalog holds the translators of the supported languages
+type Catalog struct {
+	universal       *ut.UniversalTranslator
+	defaultLanguage string
+}
+
+// New creates a catalog serving defaultLanguage to clients that accept none of
+// the supported languages
+func New(defaultLanguage string) (*Catalog, error) {
+	universal := ut.New(en.New(), en.New(), id.New())
+	if _, ok := universal.GetTranslator(defaultLanguage); !ok {
+		return nil, fmt.Errorf("unsupported language: %s", defaultLanguage)
+	}
+	for language, messages := range catalogs {
+		trans, _ := universal.GetTranslator(language)
+		for message, translation := range messages {
+			if err := trans.Add(message, translation, false); err != nil {
+				return nil, fmt.Errorf("invalid %s translation of %q: %w", language, message, err)
+			}
+		}
+	}
+	return &Catalog{universal: universal, defaultLanguage: defaultLanguage}, nil
+}
+
+// Translator returns the translator of a supported language, or of the
+// default language
+func (c *Catalog) Translator(language string) ut.Translator {
+	if trans, ok := c.universal.GetTranslator(language); ok {
+		return trans
+	}
+	trans, _ := c.universal.GetTranslator(c.defaultLanguage)
+	return trans
+}
+
+// Match picks the translator of the most preferred supported language of an
+// Accept-Language header, such as "id-ID,id;q=0.9,en;q=0.8"
+func (c *Catalog) Match(acceptLanguage string) ut.Translator {
+	for _, language := range preferredLanguages(acceptLanguage) {
+		if trans, ok := c.universal.GetTranslator(language); ok {
+			return trans
+		}
+	}
+	return c.Translator(c.defaultLanguage)
+}
+
+// preferredLanguages lists the base languages of an Accept-Language header,
+// most preferred first
+func preferredLanguages(acceptLanguage string) []string {
+	type preference struct {
+		language string
+		quality  float64
+	}
+	var preferences []preference
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if quality, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if language == "" || quality <= 0 {
+			continue
+		}
+		preferences = append(preferences, preference{language: language, quality: quality})
+	}
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].quality > preferences[j].quality
+	})
+	languages := make([]string, len(preferences))
+	for i, preference := range preferences {
+		languages[i] = preference.language
+	}
+	return languages
+}
+
+// T translates a message, {0}, {1} and so on are replaced with params. A nil
+// translator or a message without translation is served in English.
+func T(trans ut.Translator, message string, params ...string) string {
+	if trans != nil {
+		if translation, err := trans.T(message, params...); err == nil {
+			return translation
+		}
+	}
+	return Format(message, params...)
+}
+
+// Format replaces {0}, {1} and so on in the message with params
+func Format(message string, params ...string) string {
+	for i, param := range params {
+		message = strings.ReplaceAll(message, "{"+strconv.Itoa(i)+"}", param)
+	}
+	return message
+}
@@ -0,0 +1,86 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	_, err := New("fr")
+	assert.Error(t, err)
+}
+
+func TestCatalog_Match(t *testing.T) {
+	catalog, err := New(English)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string
+	}{
+		{name: "No Header", acceptLanguage: "", want: English},
+		{name: "Indonesian", acceptLanguage: "id", want: Indonesian},
+		{name: "Region", acceptLanguage: "id-ID", want: Indonesian},
+		{name: "Quality", acceptLanguage: "en;q=0.5, id;q=0.9", want: Indonesian},
+		{name: "Unsupported First", acceptLanguage: "fr-FR,fr;q=0.9,id;q=0.8", want: Indonesian},
+		{name: "Refused", acceptLanguage: "id;q=0", want: English},
+		{name: "Unsupported", acceptLanguage: "ja", want: English},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, catalog.Match(tt.acceptLanguage).Locale())
+		})
+	}
+}
+
+func TestCatalog_MatchDefaultLanguage(t *testing.T) {
+	catalog, err := New(Indonesian)
+	require.NoError(t, err)
+
+	assert.Equal(t, Indonesian, catalog.Match("ja").Locale())
+	assert.Equal(t, English, catalog.Match("en-US").Locale())
+}
+
+func TestT(t *testing.T) {
+	catalog, err := New(English)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		language string
+		message  string
+		params   []string
+		want     string
+	}{
+		{name: "English", language: English, message: "invalid code", want: "invalid code"},
+		{name: "Indonesian", language: Indonesian, message: "invalid code", want: "kode salah"},
+		{name: "Params", language: Indonesian, message: "password must be at least {0} characters", params: []string{"8"}, want: "kata sandi minimal 8 karakter"},
+		{name: "English Params", language: English, message: "password must be at least {0} characters", params: []string{"8"}, want: "password must be at least 8 characters"},
+		{name: "Not Translated", language: Indonesian, message: "connection refused", want: "connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, T(catalog.Translator(tt.language), tt.message, tt.params...))
+		})
+	}
+	assert.Equal(t, "invalid code", T(nil, "invalid code"))
+}
+
+// every translation needs the parameters of its message
+func TestCatalogParams(t *testing.T) {
+	param := regexp.MustCompile(`\{\d+\}`)
+	for language, messages := range catalogs {
+		for message, translation := range messages {
+			want := param.FindAllString(message, -1)
+			got := param.FindAllString(translation, -1)
+			sort.Strings(want)
+			sort.Strings(got)
+			assert.Equal(t, want, got, "%s translation of %q", language, message)
+		}
+	}
+}
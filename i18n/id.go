@@ -0,0 +1,94 @@
+package i18n
+
+// indonesian translates the messages served to clients, keep the {0}
+// parameters of a message in its translation
+var indonesian = map[string]string{
+	// request and validation errors
+	"fail to bind input, it might be bad request": "gagal membaca input, kemungkinan permintaan tidak valid",
+	"{0} is not valid":                                    "{0} tidak valid",
+	"{0} is required":                                     "{0} wajib diisi",
+	"{0} must be one of {1}":                              "{0} harus salah satu dari {1}",
+	"{0} must be at least {1}":                            "{0} minimal {1}",
+	"{0} must be at most {1}":                             "{0} maksimal {1}",
+	"{0} must be at least {1} characters":                 "panjang minimal {0} adalah {1} karakter",
+	"{0} must be at most {1} characters":                  "panjang maksimal {0} adalah {1} karakter",
+	"phone number must start with +62":                    "nomor telepon harus diawali dengan +62",
+	"invalid cursor":                                      "cursor tidak valid",
+	"internal server error":                               "terjadi kesalahan pada server",
+	"scope must contain openid and only supported scopes": "scope harus berisi openid dan hanya scope yang didukung",
+
+	// authentication
+	"invalid phone or password":    "nomor telepon atau kata sandi salah",
+	"invalid password":             "kata sandi salah",
+	"invalid code":                 "kode salah",
+	"invalid or expired mfa token": "token mfa tidak valid atau sudah kedaluwarsa",
+	"invalid or expired jwt":       "jwt tidak valid atau sudah kedaluwarsa",
+	"missing or malformed jwt":     "jwt tidak ada atau formatnya salah",
+	"insufficient role":            "peran tidak mencukupi",
+	"account is disabled":          "akun dinonaktifkan",
+	"account not found":            "akun tidak ditemukan",
+	"account is scheduled for deletion, login with restore set to true to restore it": "akun dijadwalkan untuk dihapus, login dengan restore bernilai true untuk memulihkannya",
+	"too many requests, try again later":                                              "terlalu banyak permintaan, coba lagi nanti",
+	"password must be changed before using the account":                               "kata sandi harus diganti sebelum menggunakan akun",
+	"password hashing is saturated, try again later":                                  "server sedang sibuk memproses kata sandi, coba lagi nanti",
+	"log in again to confirm, the account has no password":                            "login kembali untuk mengonfirmasi, akun tidak memiliki kata sandi",
+
+	// users
+	"phone number already registered":         "nomor telepon sudah terdaftar",
+	"user not found":                          "pengguna tidak ditemukan",
+	"session not found":                       "sesi tidak ditemukan",
+	"password hashing pool is not configured": "pool hashing kata sandi belum dikonfigurasi",
+	"user cache is disabled":                  "cache pengguna dinonaktifkan",
+
+	// two factor authentication and passkeys
+	"two factor authentication is already enabled":         "autentikasi dua faktor sudah aktif",
+	"two factor authentication is not enabled":             "autentikasi dua faktor belum aktif",
+	"two factor authentication setup has not been started": "pengaturan autentikasi dua faktor belum dimulai",
+	"passkey ceremony is invalid or expired":               "proses passkey tidak valid atau sudah kedaluwarsa",
+	"invalid passkey":                                      "passkey tidak valid",
+	"invalid passkey credential":                           "kredensial passkey tidak valid",
+	"passkey not found":                                    "passkey tidak ditemukan",
+	"passkey already registered":                           "passkey sudah terdaftar",
+
+	// identity providers
+	"identity provider not found":                                                             "penyedia identitas tidak ditemukan",
+	"identity provider is not linked":                                                         "penyedia identitas belum ditautkan",
+	"invalid or expired state, or the identity provider rejected the code":                    "state tidak valid atau sudah kedaluwarsa, atau penyedia identitas menolak kodenya",
+	"the identity provider did not share a verified +62 phone number to sign up with":         "penyedia identitas tidak membagikan nomor telepon +62 yang terverifikasi untuk mendaftar",
+	"the identity provider did not share a name of at least 3 characters to sign up with":     "penyedia identitas tidak membagikan nama minimal 3 karakter untuk mendaftar",
+	"phone number already registered, log in and link the identity provider from the profile": "nomor telepon sudah terdaftar, login lalu tautkan penyedia identitas dari profil",
+	"the identity provider is the only way to sign in, link another one first":                "penyedia identitas ini satu-satunya cara untuk masuk, tautkan penyedia lain terlebih dahulu",
+	"the identity provider is already linked, unlink it first":                                "penyedia identitas sudah ditautkan, lepaskan tautannya terlebih dahulu",
+	"the identity is already linked to a user":                                                "identitas sudah ditautkan ke pengguna lain",
+	"client not found":  "client tidak ditemukan",
+	"consent not found": "persetujuan tidak ditemukan",
+
+	// passwords
+	"password must be at least {0} characters":                                     "kata sandi minimal {0} karakter",
+	"password must be at most {0} characters":                                      "kata sandi maksimal {0} karakter",
+	"password must contain at least 1 uppercase letter":                            "kata sandi harus berisi minimal 1 huruf besar",
+	"password must contain at least 1 lowercase letter":                            "kata sandi harus berisi minimal 1 huruf kecil",
+	"password must contain at least 1 number":                                      "kata sandi harus berisi minimal 1 angka",
+	"password must contain at least 1 symbol":                                      "kata sandi harus berisi minimal 1 simbol",
+	"password must not contain {0}, the allowed symbols are {1}":                   "kata sandi tidak boleh berisi {0}, simbol yang diperbolehkan adalah {1}",
+	"password must not repeat a character more than {0} times in a row":            "kata sandi tidak boleh mengulang karakter lebih dari {0} kali berturut-turut",
+	"password must not be one of your last {0} passwords":                          "kata sandi tidak boleh sama dengan {0} kata sandi terakhir Anda",
+	"password is too common":                                                       "kata sandi terlalu umum",
+	"password appeared in a data breach":                                           "kata sandi pernah bocor dalam pelanggaran data",
+	"password must not contain your name":                                          "kata sandi tidak boleh berisi nama Anda",
+	"password must not contain your phone number":                                  "kata sandi tidak boleh berisi nomor telepon Anda",
+	"password is too easy to guess, use a longer password or a few uncommon words": "kata sandi terlalu mudah ditebak, gunakan kata sandi yang lebih panjang atau beberapa kata yang tidak umum",
+
+	// problem titles and the errors of the http server
+	"Bad Request":              "Permintaan Tidak Valid",
+	"Unauthorized":             "Tidak Terautentikasi",
+	"Forbidden":                "Akses Ditolak",
+	"Not Found":                "Tidak Ditemukan",
+	"Method Not Allowed":       "Metode Tidak Diizinkan",
+	"Conflict":                 "Konflik",
+	"Request Entity Too Large": "Permintaan Terlalu Besar",
+	"Unsupported Media Type":   "Jenis Media Tidak Didukung",
+	"Too Many Requests":        "Terlalu Banyak Permintaan",
+	"Internal Server Error":    "Kesalahan Server Internal",
+	"Service Unavailable":      "Layanan Tidak Tersedia",
+}
@@ -0,0 +1,69 @@
+package i18n
+
+import (
+	"fmt"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	idTranslations "github.com/go-playground/validator/v10/translations/id"
+)
+
+// invalidField is the message of validation tags no translation describes
+const invalidField = "{0} is not valid"
+
+// indonesianValidation describes the tags the Indonesian translations of the
+// validator are missing, {0} is the field and {1} the parameter of the tag
+var indonesianValidation = map[string]string{
+	"boolean":                       "{0} harus berupa nilai boolean yang valid",
+	"cron":                          "{0} harus berupa ekspresi cron yang valid",
+	"cve":                           "{0} harus berupa identitas cve yang valid",
+	"datetime":                      "{0} tidak sesuai dengan format {1}",
+	"e164":                          "{0} harus berupa nomor telepon format E.164 yang valid",
+	"fqdn":                          "{0} harus berupa FQDN yang valid",
+	"json":                          "{0} harus berupa string json yang valid",
+	"jwt":                           "{0} harus berupa string jwt yang valid",
+	"lowercase":                     "{0} harus berupa string huruf kecil",
+	"uppercase":                     "{0} harus berupa string huruf besar",
+	"postcode_iso3166_alpha2":       "{0} tidak sesuai dengan format kode pos negara {1}",
+	"postcode_iso3166_alpha2_field": "{0} tidak sesuai dengan format kode pos negara pada kolom {1}",
+	"required_if":                   "{0} wajib diisi",
+	"unique":                        "{0} harus berisi nilai yang unik",
+}
+
+// RegisterValidator registers the messages of every built in validation tag
+// in all supported languages with the validator
+func (c *Catalog) RegisterValidator(validate *validator.Validate) error {
+	if err := enTranslations.RegisterDefaultTranslations(validate, c.Translator(English)); err != nil {
+		return fmt.Errorf("register english validation messages: %w", err)
+	}
+	trans := c.Translator(Indonesian)
+	if err := idTranslations.RegisterDefaultTranslations(validate, trans); err != nil {
+		return fmt.Errorf("register indonesian validation messages: %w", err)
+	}
+	for tag, message := range indonesianValidation {
+		message := message
+		register := func(trans ut.Translator) error {
+			return trans.Add(tag, message, false)
+		}
+		translate := func(trans ut.Translator, fieldError validator.FieldError) string {
+			return T(trans, fieldError.Tag(), fieldError.Field(), fieldError.Param())
+		}
+		if err := validate.RegisterTranslation(tag, trans, register, translate); err != nil {
+			return fmt.Errorf("register indonesian validation message of %s: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// ValidationMessage describes a failed validation tag of a field, tags without
+// a registered message such as custom ones are described as not valid
+func ValidationMessage(trans ut.Translator, fieldError validator.FieldError) string {
+	if trans != nil {
+		// Translate falls back to the error text when the tag has no message
+		if message := fieldError.Translate(trans); message != fieldError.Error() {
+			return message
+		}
+	}
+	return T(trans, invalidField, fieldError.Field())
+}
@@ -0,0 +1,62 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationMessage(t *testing.T) {
+	catalog, err := New(English)
+	require.NoError(t, err)
+	validate := validator.New()
+	require.NoError(t, validate.RegisterValidation("sawit", func(fl validator.FieldLevel) bool { return false }))
+	require.NoError(t, catalog.RegisterValidator(validate))
+
+	type input struct {
+		Phone    string `validate:"required"`
+		Mobile   string `validate:"e164"`
+		FullName string `validate:"max=3"`
+		Estate   string `validate:"sawit"`
+	}
+	validationErrors := validate.Struct(input{Mobile: "0812", FullName: "Sawit", Estate: "Riau"}).(validator.ValidationErrors)
+
+	tests := []struct {
+		name     string
+		language string
+		want     []string
+	}{
+		{
+			name:     "English",
+			language: English,
+			want: []string{
+				"Phone is a required field",
+				"Mobile must be a valid E.164 formatted phone number",
+				"FullName must be a maximum of 3 characters in length",
+				"Estate is not valid",
+			},
+		},
+		{
+			name:     "Indonesian",
+			language: Indonesian,
+			want: []string{
+				"Phone wajib diisi",
+				"Mobile harus berupa nomor telepon format E.164 yang valid",
+				"panjang maksimal FullName adalah 3 karakter",
+				"Estate tidak valid",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trans := catalog.Translator(tt.language)
+			var got []string
+			for _, fieldError := range validationErrors {
+				got = append(got, ValidationMessage(trans, fieldError))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
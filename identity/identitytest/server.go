@@ -0,0 +1,200 @@
+// Package identitytest provides a stub OpenID Connect provider for tests and
+// local development
+package identitytest
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// User is who signs in at the stub provider
+type User struct {
+	Subject             string
+	Name                string
+	PhoneNumber         string
+	PhoneNumberVerified bool
+}
+
+// Server is a stub OpenID Connect provider, every visit to its authorization
+// endpoint signs User in and redirects back with a code
+type Server struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mu   sync.Mutex
+	user User
+	key  *rsa.PrivateKey
+	// codes holds the pending authorization codes
+	codes map[string]grant
+}
+
+type grant struct {
+	user          User
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+// NewServer starts a stub provider, it is closed with Close
+func NewServer(user User) *Server {
+	key, err := util.GenerateRSAPrivateKey()
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{
+		ClientID:     "stub-client",
+		ClientSecret: "stub-secret",
+		user:         user,
+		key:          key,
+		codes:        map[string]grant{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// SetUser changes who signs in next
+func (s *Server) SetUser(user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = user
+}
+
+// Authorize visits the authorization url like a browser would and returns the
+// code and state of the redirect back to the client
+func (s *Server) Authorize(authCodeURL string) (code, state string, err error) {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	res, err := client.Get(authCodeURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		return "", "", fmt.Errorf("authorize responded %d", res.StatusCode)
+	}
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	return location.Query().Get("code"), location.Query().Get("state"), nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	n, e := util.RSAPublicKeyParams(&s.key.PublicKey)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": util.RSAKeyID(&s.key.PublicKey),
+			"alg": "RS256",
+			"n":   n,
+			"e":   e,
+		}},
+	})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI := query.Get("redirect_uri")
+	if query.Get("client_id") != s.ClientID || redirectURI == "" {
+		http.Error(w, "unknown client", http.StatusBadRequest)
+		return
+	}
+
+	code := util.GenerateSessionID()
+	s.mu.Lock()
+	s.codes[code] = grant{
+		user:          s.user,
+		redirectURI:   redirectURI,
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+	s.mu.Unlock()
+
+	target, _ := url.Parse(redirectURI)
+	params := target.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	target.RawQuery = params.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != s.ClientID || clientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostFormValue("code")
+	s.mu.Lock()
+	grant, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+	verifierHash := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || grant.redirectURI != r.PostFormValue("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(verifierHash[:]) != grant.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                   s.URL,
+		"sub":                   grant.user.Subject,
+		"aud":                   s.ClientID,
+		"iat":                   now.Unix(),
+		"exp":                   now.Add(time.Hour).Unix(),
+		"nonce":                 grant.nonce,
+		"name":                  grant.user.Name,
+		"phone_number":          grant.user.PhoneNumber,
+		"phone_number_verified": grant.user.PhoneNumberVerified,
+	})
+	idToken.Header["kid"] = util.RSAKeyID(&s.key.PublicKey)
+	signed, err := idToken.SignedString(s.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": util.GenerateSessionID(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
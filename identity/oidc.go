@@ -0,0 +1,214 @@
+package identity
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/SawitProRecruitment/UserService/util"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCProvider is a Provider for any OpenID Connect provider, the endpoints
+// are read from its discovery document and id tokens are verified with the
+// keys it publishes
+type OIDCProvider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the client page the provider redirects back to
+	RedirectURL string
+	HTTPClient  *http.Client
+
+	authorizationEndpoint string
+	tokenEndpoint         string
+	jwksURI               string
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PublicKey
+}
+
+// IDTokenClaims are the claims read from the id token of the provider
+type IDTokenClaims struct {
+	Nonce               string `json:"nonce"`
+	Name                string `json:"name"`
+	PhoneNumber         string `json:"phone_number"`
+	PhoneNumberVerified bool   `json:"phone_number_verified"`
+	jwt.RegisteredClaims
+}
+
+// NewOIDCProvider creates a provider from the discovery document of the issuer
+func NewOIDCProvider(ctx context.Context, issuer, clientID, clientSecret, redirectURL string, httpClient *http.Client) (*OIDCProvider, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	p := &OIDCProvider{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		HTTPClient:   httpClient,
+	}
+
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JwksURI               string `json:"jwks_uri"`
+	}
+	if err := p.getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("discover %s: %w", p.Issuer, err)
+	}
+	// a document claiming another issuer would let that issuer sign tokens for this one
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("discover %s: document is for issuer %s", p.Issuer, discovery.Issuer)
+	}
+	p.authorizationEndpoint = discovery.AuthorizationEndpoint
+	p.tokenEndpoint = discovery.TokenEndpoint
+	p.jwksURI = discovery.JwksURI
+	return p, nil
+}
+
+// AuthCodeURL is the authorization endpoint of the provider with the parameters of the flow
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {"openid profile phone"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(p.authorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.authorizationEndpoint + separator + query.Encode()
+}
+
+// Exchange trades the code at the token endpoint and verifies the id token it returns
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := p.doJSON(req, &token); err != nil {
+		return nil, fmt.Errorf("exchange code: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("exchange code: no id token in the response")
+	}
+
+	claims := &IDTokenClaims{}
+	_, err = jwt.ParseWithClaims(token.IDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("verify id token: %w", err)
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("verify id token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("verify id token: no subject")
+	}
+
+	identity := &Identity{
+		Subject: claims.Subject,
+		Name:    claims.Name,
+	}
+	if claims.PhoneNumberVerified {
+		identity.PhoneNumber = claims.PhoneNumber
+	}
+	return identity, nil
+}
+
+// publicKey returns the signing key with the key id, the key set is fetched
+// again for an unknown id so keys rotated by the provider are picked up
+func (p *OIDCProvider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	var keySet struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.jwksURI, &keySet); err != nil {
+		return nil, fmt.Errorf("fetch keys: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(keySet.Keys))
+	for _, key := range keySet.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		publicKey, err := util.ParseRSAPublicKeyParams(key.N, key.E)
+		if err != nil {
+			continue
+		}
+		keys[key.Kid] = publicKey
+	}
+	p.keys = keys
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return p.doJSON(req, v)
+}
+
+func (p *OIDCProvider) doJSON(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	res, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded %d: %s", req.URL.Host, res.StatusCode, body)
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,109 @@
+package identity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/identity/identitytest"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCodeVerifier = "dBjftJeZ4CVP-mJ92K8sSoBj6h8R9Dnyp1LWl1xjvWQ"
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestOIDCProvider_Exchange(t *testing.T) {
+	server := identitytest.NewServer(identitytest.User{
+		Subject:             "stub-subject",
+		Name:                "mr smith",
+		PhoneNumber:         "+62812345678912",
+		PhoneNumberVerified: true,
+	})
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		user         identitytest.User
+		clientSecret string
+		verifier     string
+		nonce        string
+		want         *Identity
+		wantErr      bool
+	}{
+		{
+			name: "Success Exchange",
+			user: identitytest.User{Subject: "stub-subject", Name: "mr smith", PhoneNumber: "+62812345678912", PhoneNumberVerified: true},
+			want: &Identity{Subject: "stub-subject", Name: "mr smith", PhoneNumber: "+62812345678912"},
+		},
+		{
+			name: "Success Exchange, unverified phone number is left out",
+			user: identitytest.User{Subject: "stub-subject", Name: "mr smith", PhoneNumber: "+62812345678912"},
+			want: &Identity{Subject: "stub-subject", Name: "mr smith"},
+		},
+		{
+			name:         "Fail Exchange, wrong client secret",
+			user:         identitytest.User{Subject: "stub-subject"},
+			clientSecret: "wrong",
+			wantErr:      true,
+		},
+		{
+			name:     "Fail Exchange, wrong code verifier",
+			user:     identitytest.User{Subject: "stub-subject"},
+			verifier: "wrong-verifier",
+			wantErr:  true,
+		},
+		{
+			name:    "Fail Exchange, nonce mismatch",
+			user:    identitytest.User{Subject: "stub-subject"},
+			nonce:   "another-nonce",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientSecret := server.ClientSecret
+			if tt.clientSecret != "" {
+				clientSecret = tt.clientSecret
+			}
+			provider, err := NewOIDCProvider(context.Background(), server.URL, server.ClientID, clientSecret, "https://app.sawitpro.com/callback", nil)
+			assert.NoError(t, err)
+
+			server.SetUser(tt.user)
+			code, state, err := server.Authorize(provider.AuthCodeURL("state", "nonce", codeChallenge(testCodeVerifier)))
+			assert.NoError(t, err)
+			assert.Equal(t, "state", state)
+
+			verifier := testCodeVerifier
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			nonce := "nonce"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			got, err := provider.Exchange(context.Background(), code, verifier, nonce)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("OIDCProvider.Exchange() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewOIDCProvider(t *testing.T) {
+	server := identitytest.NewServer(identitytest.User{})
+	defer server.Close()
+
+	_, err := NewOIDCProvider(context.Background(), server.URL+"/", server.ClientID, server.ClientSecret, "", nil)
+	assert.NoError(t, err)
+
+	// the issuer has no discovery document
+	_, err = NewOIDCProvider(context.Background(), server.URL+"/tenant", server.ClientID, server.ClientSecret, "", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,24 @@
+// Package identity signs users in with external identity providers such as
+// Google or Apple
+package identity
+
+import "context"
+
+// Identity is the user as known by an external identity provider
+type Identity struct {
+	// Subject identifies the user at the provider, it never changes
+	Subject string
+	Name    string
+	// PhoneNumber is only set when the provider verified it
+	PhoneNumber string
+}
+
+// Provider is an external identity provider using the authorization code flow
+type Provider interface {
+	// AuthCodeURL is where the user is sent to sign in at the provider, the
+	// provider redirects back to the client with the state and a code
+	AuthCodeURL(state, nonce, codeChallenge string) string
+	// Exchange trades the code for the identity of the user, the nonce must
+	// match the one passed to AuthCodeURL
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error)
+}
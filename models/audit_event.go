@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// audit event types
+const (
+	EventRegister          = "register"
+	EventLogin             = "login"
+	EventLoginFailed       = "login_failed"
+	EventProfileUpdated    = "profile_updated"
+	EventAccountDeleted    = "account_deleted"
+	EventAccountRestored   = "account_restored"
+	EventDataExported      = "data_exported"
+	EventPasswordChanged   = "password_changed"
+	EventTwoFactorEnabled  = "two_factor_enabled"
+	EventTwoFactorDisabled = "two_factor_disabled"
+	EventRecoveryCodeUsed  = "recovery_code_used"
+	EventPasskeyAdded      = "passkey_added"
+	EventPasskeyRemoved    = "passkey_removed"
+	EventIdentityLinked    = "identity_linked"
+	EventIdentityUnlinked  = "identity_unlinked"
+	// events triggered by an admin on the user's account
+	EventAccountDisabled     = "account_disabled"
+	EventAccountEnabled      = "account_enabled"
+	EventPasswordResetForced = "password_reset_forced"
+)
+
+// LoginEventTypes are the audit event types that make up the login history
+var LoginEventTypes = []string{EventLogin, EventLoginFailed}
+
+// AccountEventTypes are the audit event types about changes to the account
+var AccountEventTypes = []string{
+	EventRegister,
+	EventProfileUpdated,
+	EventAccountDeleted,
+	EventAccountRestored,
+	EventDataExported,
+	EventPasswordChanged,
+	EventTwoFactorEnabled,
+	EventTwoFactorDisabled,
+	EventRecoveryCodeUsed,
+	EventPasskeyAdded,
+	EventPasskeyRemoved,
+	EventIdentityLinked,
+	EventIdentityUnlinked,
+	EventAccountDisabled,
+	EventAccountEnabled,
+	EventPasswordResetForced,
+}
+
+// AuditEvent model, records a security relevant action of a user
+type AuditEvent struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id" gorm:"not null"`
+	EventType string    `json:"event_type" gorm:"not null"`
+	IPAddress string    `json:"ip_address" gorm:"not null"`
+	UserAgent string    `json:"user_agent" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// UserIdentity model, links a user to the account at an external identity provider
+type UserIdentity struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id" gorm:"not null"`
+	// Provider is the configured name of the identity provider such as google
+	Provider string `json:"provider" gorm:"not null;unique_index:user_identities_provider_subject_idx"`
+	// Subject identifies the user at the provider
+	Subject   string    `json:"-" gorm:"not null;unique_index:user_identities_provider_subject_idx"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IdentityLoginState model, the server side state of a sign in at an external
+// identity provider between its begin and finish requests, it is consumed by
+// the finish request so a state is never accepted twice
+type IdentityLoginState struct {
+	// ID is the hash of the state passed through the provider
+	ID       string `gorm:"primary_key"`
+	Provider string `gorm:"not null"`
+	// UserID is the user linking the provider, 0 for a login
+	UserID       int       `gorm:"not null"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+}
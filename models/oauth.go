@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// OAuthClient model, an application allowed to sign users in through the
+// OpenID Connect provider
+type OAuthClient struct {
+	ID   string `json:"id" gorm:"primary_key"`
+	Name string `json:"name" gorm:"not null"`
+	// SecretHash is the sha256 of the client secret, empty for public clients such as mobile apps
+	SecretHash string `json:"-" gorm:"not null"`
+	// RedirectURIs is the space separated list of redirect uris, a redirect uri must match one exactly
+	RedirectURIs string `json:"redirect_uris" gorm:"column:redirect_uris;not null"`
+	// FirstParty clients are our own apps, users are not asked for consent
+	FirstParty bool      `json:"first_party" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OAuthAuthorizationCode model, a one-time code handed to the client through
+// the redirect uri and exchanged for tokens. Only the hash of the code is stored
+type OAuthAuthorizationCode struct {
+	CodeHash    string `gorm:"primary_key"`
+	ClientID    string `gorm:"not null"`
+	UserID      int    `gorm:"not null"`
+	RedirectURI string `gorm:"column:redirect_uri;not null"`
+	Scope       string `gorm:"not null"`
+	Nonce       string `gorm:"not null"`
+	// CodeChallenge is the PKCE S256 challenge the code verifier must match
+	CodeChallenge string    `gorm:"not null"`
+	AuthTime      time.Time `gorm:"not null"`
+	ExpiresAt     time.Time `gorm:"not null"`
+}
+
+// OAuthConsent model, the scopes a user allowed a third party client to access
+type OAuthConsent struct {
+	UserID    int       `json:"user_id" gorm:"primary_key;auto_increment:false"`
+	ClientID  string    `json:"client_id" gorm:"primary_key"`
+	Scope     string    `json:"scope" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName overrides the gorm default o_auth_clients
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// TableName overrides the gorm default o_auth_authorization_codes
+func (OAuthAuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
+
+// TableName overrides the gorm default o_auth_consents
+func (OAuthConsent) TableName() string {
+	return "oauth_consents"
+}
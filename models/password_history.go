@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PasswordHistory model, a password the user has set, hashed with its own
+// salt like User.Password so a new password can be compared against it
+type PasswordHistory struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id" gorm:"not null"`
+	Password  string    `json:"-" gorm:"not null"`
+	SaltToken string    `json:"-" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName keeps the table name singular
+func (PasswordHistory) TableName() string {
+	return "password_history"
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RateLimitBucket model, a token bucket of the rate limiter shared by every replica
+type RateLimitBucket struct {
+	// ID is the key of the bucket such as login:ip:10.0.0.1
+	ID     string  `gorm:"primary_key"`
+	Tokens float64 `gorm:"not null"`
+	// RefilledAt is when the tokens were last refilled, zero for a new bucket
+	RefilledAt time.Time `gorm:"not null"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RecoveryCode model, a one-time code that replaces the TOTP code when the
+// authenticator app is lost. Only the hash of the code is stored
+type RecoveryCode struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id" gorm:"not null"`
+	CodeHash  string     `json:"-" gorm:"not null"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
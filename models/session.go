@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Session model, one login of a user on a device. Every access token
+// references its session so revoking the session revokes the token
+type Session struct {
+	ID          string     `json:"id" gorm:"primary_key"`
+	UserID      int        `json:"user_id" gorm:"not null"`
+	UserAgent   string     `json:"user_agent" gorm:"not null"`
+	IPAddress   string     `json:"ip_address" gorm:"not null"`
+	DeviceLabel string     `json:"device_label" gorm:"not null"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at" gorm:"not null"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
@@ -1,10 +1,53 @@
 package models
 
+import "time"
+
+// user roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// user statuses
+const (
+	StatusActive   = "active"
+	StatusDisabled = "disabled"
+)
+
 // User model
 type User struct {
 	ID          int    `json:"id"`
-	PhoneNumber string `json:"phone" gorm:"unique,not null"`
+	PhoneNumber string `json:"phone" gorm:"not null"`
 	Fullname    string `json:"username" gorm:"not null"`
 	Password    string `json:"password" gorm:"not null"`
 	SaltToken   string `json:"salt_token" gorm:"not null"`
+	// PasswordSet keeps whether the user has a password in a copy without
+	// the credentials, it is not a column
+	PasswordSet bool   `json:"password_set" gorm:"-"`
+	Role        string `json:"role" gorm:"not null;default:'user'"`
+	Status      string `json:"status" gorm:"not null;default:'active'"`
+	// PasswordResetRequired asks the user to change the password at next login
+	PasswordResetRequired bool       `json:"password_reset_required" gorm:"not null;default:false"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+	DeletedAt             *time.Time `json:"deleted_at" sql:"index"`
+	// PurgeAt is when a deleted account gets anonymized, nil when no deletion is scheduled
+	PurgeAt *time.Time `json:"purge_at"`
+	// TokensRevokedAt invalidates every token issued before it
+	TokensRevokedAt *time.Time `json:"tokens_revoked_at"`
+	// PasswordChangedAt is when the password was last set, nil for accounts
+	// created before it was tracked
+	PasswordChangedAt *time.Time `json:"password_changed_at"`
+	// TOTPSecret is the base32 secret of the authenticator app, set during
+	// setup and only enforced at login once TOTPEnabled is confirmed
+	TOTPSecret  string `json:"-" gorm:"column:totp_secret;not null;default:''"`
+	TOTPEnabled bool   `json:"totp_enabled" gorm:"column:totp_enabled;not null;default:false"`
+	// TOTPLastStep is the time step of the last accepted code, a code is never accepted twice
+	TOTPLastStep int64 `json:"-" gorm:"column:totp_last_step;not null;default:0"`
+}
+
+// HasPassword reports whether the user can sign in with a password, users
+// signed up through an identity provider have none
+func (u *User) HasPassword() bool {
+	return u.Password != "" || u.PasswordSet
 }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// UserListParams filters and pages the users returned by the user repository List,
+// users are ordered by created_at then id
+type UserListParams struct {
+	NamePrefix  string
+	PhonePrefix string
+	Status      string
+	// CreatedFrom is inclusive and CreatedTo is exclusive, nil leaves the range open
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// Cursor continues after the last user of the previous page, empty for the first page
+	Cursor string
+	Limit  int
+}
+
+// UserPage is a page of users returned by the user repository List
+type UserPage struct {
+	Users []User
+	// NextCursor fetches the following page, empty on the last page
+	NextCursor string
+	Total      int
+	// TotalIsEstimate is set when Total comes from the query planner instead of an exact count
+	TotalIsEstimate bool
+}
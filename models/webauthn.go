@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// webauthn ceremony kinds
+const (
+	CeremonyRegistration = "registration"
+	CeremonyLogin        = "login"
+)
+
+// WebAuthnCredential model, a passkey registered by a user
+type WebAuthnCredential struct {
+	ID              int    `json:"id"`
+	UserID          int    `json:"user_id" gorm:"not null"`
+	CredentialID    []byte `json:"-" gorm:"not null"`
+	PublicKey       []byte `json:"-" gorm:"not null"`
+	AttestationType string `json:"-" gorm:"not null"`
+	AAGUID          []byte `json:"-" gorm:"column:aaguid"`
+	// SignCount is the last signature counter reported by the authenticator, a lower one hints at a cloned authenticator
+	SignCount int64 `json:"-" gorm:"not null"`
+	// Transports is the comma separated list of transports the authenticator supports
+	Transports     string     `json:"-" gorm:"not null"`
+	BackupEligible bool       `json:"-" gorm:"not null"`
+	BackupState    bool       `json:"-" gorm:"not null"`
+	Name           string     `json:"name" gorm:"not null"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+}
+
+// WebAuthnCeremony model, the server side state of a passkey registration or
+// login between its begin and finish requests, it is consumed by the finish
+// request so a challenge is never accepted twice
+type WebAuthnCeremony struct {
+	ID string `gorm:"primary_key"`
+	// UserID is the user registering a passkey, 0 for a login
+	UserID int    `gorm:"not null"`
+	Kind   string `gorm:"not null"`
+	// SessionData is the json encoded session data of the webauthn library
+	SessionData string    `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null"`
+}
+
+// TableName overrides the gorm default web_authn_credentials
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// TableName overrides the gorm default web_authn_ceremonies
+func (WebAuthnCeremony) TableName() string {
+	return "webauthn_ceremonies"
+}
@@ -0,0 +1,141 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// hashPrefixLength is how many hex characters of the SHA-1 hash leave the
+// service, the source answers with every breached hash sharing them so it
+// never learns which password is checked
+const hashPrefixLength = 5
+
+// BreachSource looks up breached passwords by k-anonymity, Range returns the
+// breach count of every hash starting with prefix keyed by the rest of the hash.
+// Hashes are upper case hex SHA-1 like the Pwned Passwords range api
+type BreachSource interface {
+	Range(ctx context.Context, prefix string) (map[string]int, error)
+}
+
+// BreachRule rejects passwords seen in data breaches
+type BreachRule struct {
+	Source BreachSource
+}
+
+// NewBreachRule creates a rule looking up passwords in the source
+func NewBreachRule(source BreachSource) *BreachRule {
+	return &BreachRule{Source: source}
+}
+
+// Check rejects the password when its hash is in the source
+func (r *BreachRule) Check(ctx context.Context, password string, user User) (*Violation, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := r.Source.Range(ctx, hash[:hashPrefixLength])
+	if err != nil {
+		return nil, fmt.Errorf("fail to check breached passwords: %w", err)
+	}
+	if suffixes[hash[hashPrefixLength:]] > 0 {
+		return &Violation{Rule: "breached", Message: "password appeared in a data breach"}, nil
+	}
+	return nil, nil
+}
+
+// FileBreachSource reads breached hashes from a local file of "HASH:COUNT"
+// lines sorted by hash, the format of the Pwned Passwords download. The file
+// is binary searched so it is never loaded into memory
+type FileBreachSource struct {
+	file *os.File
+	size int64
+}
+
+// OpenBreachFile opens the sorted hash file at path
+func OpenBreachFile(path string) (*FileBreachSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &FileBreachSource{file: file, size: info.Size()}, nil
+}
+
+// Close closes the hash file
+func (s *FileBreachSource) Close() error {
+	return s.file.Close()
+}
+
+// Range returns the hashes starting with prefix
+func (s *FileBreachSource) Range(ctx context.Context, prefix string) (map[string]int, error) {
+	prefix = strings.ToUpper(prefix)
+
+	// find the first line at or after the offset whose hash is not below the prefix
+	low, high := int64(0), s.size
+	for low < high {
+		mid := low + (high-low)/2
+		_, line, err := s.lineAt(mid)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF || line >= prefix {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+
+	start, _, err := s.lineAt(low)
+	if err == io.EOF {
+		return map[string]int{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suffixes := map[string]int{}
+	scanner := bufio.NewScanner(io.NewSectionReader(s.file, start, s.size-start))
+	for scanner.Scan() {
+		hash, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.HasPrefix(strings.ToUpper(hash), prefix) {
+			break
+		}
+		suffixes[strings.ToUpper(hash[len(prefix):])], _ = strconv.Atoi(count)
+	}
+	return suffixes, scanner.Err()
+}
+
+// lineAt returns the first line starting at or after offset, io.EOF means there is none
+func (s *FileBreachSource) lineAt(offset int64) (int64, string, error) {
+	start := offset
+	reader := bufio.NewReader(io.NewSectionReader(s.file, offset, s.size-offset))
+	if offset > 0 {
+		// offset is in the middle of a line unless the previous byte ends one
+		previous := make([]byte, 1)
+		if _, err := s.file.ReadAt(previous, offset-1); err != nil {
+			return 0, "", err
+		}
+		if previous[0] != '\n' {
+			skipped, err := reader.ReadString('\n')
+			if err != nil {
+				return 0, "", io.EOF
+			}
+			start += int64(len(skipped))
+		}
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return 0, "", err
+	}
+	return start, strings.ToUpper(strings.TrimSpace(line)), nil
+}
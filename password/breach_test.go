@@ -0,0 +1,67 @@
+package password
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// writeBreachFile writes the hashes of the passwords sorted like the Pwned Passwords download
+func writeBreachFile(t *testing.T, passwords ...string) string {
+	lines := make([]string, len(passwords))
+	for i, password := range passwords {
+		lines[i] = fmt.Sprintf("%s:%d", sha1Hex(password), i+1)
+	}
+	sort.Strings(lines)
+	path := filepath.Join(t.TempDir(), "pwned-passwords.txt")
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600))
+	return path
+}
+
+func TestFileBreachSource_Range(t *testing.T) {
+	passwords := []string{"Password1!", "qwerty", "letmein", "hunter2", "iloveyou", "dragon", "monkey", "sunshine"}
+	source, err := OpenBreachFile(writeBreachFile(t, passwords...))
+	assert.NoError(t, err)
+	defer source.Close()
+
+	for i, password := range passwords {
+		hash := sha1Hex(password)
+		got, err := source.Range(context.Background(), hash[:5])
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{hash[5:]: i + 1}, got, password)
+	}
+
+	for _, prefix := range []string{"00000", "FFFFF", "7C4a8"} {
+		got, err := source.Range(context.Background(), prefix)
+		assert.NoError(t, err)
+		assert.Empty(t, got, prefix)
+	}
+}
+
+func TestBreachRule_Check(t *testing.T) {
+	source, err := OpenBreachFile(writeBreachFile(t, "Password1!", "Kebun-Sawit-Riau-88"))
+	assert.NoError(t, err)
+	defer source.Close()
+	rule := NewBreachRule(source)
+
+	got, err := rule.Check(context.Background(), "Kebun-Sawit-Riau-88", User{})
+	assert.NoError(t, err)
+	assert.Equal(t, &Violation{Rule: "breached", Message: "password appeared in a data breach"}, got)
+
+	got, err = rule.Check(context.Background(), "Jx7!Kp2@Lm9#", User{})
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+}
@@ -0,0 +1,47 @@
+package password
+
+import "context"
+
+// User is what is known about the owner of a password, passwords made of it
+// are easy to guess for anyone who knows the user
+type User struct {
+	Name  string
+	Phone string
+}
+
+// Violation is a rule the password breaks
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// Rule screens a password, it returns nil when the password passes
+type Rule interface {
+	Check(ctx context.Context, password string, user User) (*Violation, error)
+}
+
+// Checker screens new passwords with a list of rules
+type Checker struct {
+	Rules []Rule
+}
+
+// NewChecker creates a checker running the rules in order
+func NewChecker(rules ...Rule) *Checker {
+	return &Checker{Rules: rules}
+}
+
+// Check runs every rule and returns the violations, an error means a rule
+// could not decide such as a breach source that is not reachable
+func (c *Checker) Check(ctx context.Context, password string, user User) ([]Violation, error) {
+	var violations []Violation
+	for _, rule := range c.Rules {
+		violation, err := rule.Check(ctx, password, user)
+		if err != nil {
+			return nil, err
+		}
+		if violation != nil {
+			violations = append(violations, *violation)
+		}
+	}
+	return violations, nil
+}
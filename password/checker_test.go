@@ -0,0 +1,65 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ruleFunc func(password string) (*Violation, error)
+
+func (f ruleFunc) Check(ctx context.Context, password string, user User) (*Violation, error) {
+	return f(password)
+}
+
+func TestChecker_Check(t *testing.T) {
+	tooShort := ruleFunc(func(password string) (*Violation, error) {
+		if len(password) < 8 {
+			return &Violation{Rule: "length", Message: "password is too short"}, nil
+		}
+		return nil, nil
+	})
+	tests := []struct {
+		name     string
+		rules    []Rule
+		password string
+		want     []Violation
+		wantErr  bool
+	}{
+		{
+			name:     "Password Passes",
+			rules:    []Rule{tooShort, NewCommonRule()},
+			password: "Kebun-Sawit-Riau-88",
+			want:     nil,
+		},
+		{
+			name:     "Every Violation Is Reported",
+			rules:    []Rule{tooShort, NewCommonRule()},
+			password: "qwerty",
+			want: []Violation{
+				{Rule: "length", Message: "password is too short"},
+				{Rule: "common", Message: "password is too common"},
+			},
+		},
+		{
+			name: "Rule Fails",
+			rules: []Rule{ruleFunc(func(password string) (*Violation, error) {
+				return nil, errors.New("breach source is down")
+			})},
+			password: "Kebun-Sawit-Riau-88",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewChecker(tt.rules...).Check(context.Background(), tt.password, User{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Checker.Check() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
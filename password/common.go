@@ -0,0 +1,88 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var (
+	commonPasswordsOnce sync.Once
+	commonPasswords     Dictionary
+)
+
+// Dictionary ranks words by how common they are, 1 is the most common
+type Dictionary map[string]int
+
+// CommonPasswords is the bundled list of the most used passwords
+func CommonPasswords() Dictionary {
+	commonPasswordsOnce.Do(func() {
+		commonPasswords = Dictionary{}
+		scanner := bufio.NewScanner(strings.NewReader(commonPasswordsFile))
+		for scanner.Scan() {
+			word := strings.TrimSpace(scanner.Text())
+			if _, ok := commonPasswords[word]; word != "" && !ok {
+				commonPasswords[word] = len(commonPasswords) + 1
+			}
+		}
+	})
+	return commonPasswords
+}
+
+// CommonRule rejects passwords on the common password list, including the
+// ones only decorated with digits and symbols at the ends such as "Password1!"
+type CommonRule struct {
+	Dictionary Dictionary
+}
+
+// NewCommonRule creates a rule checking the bundled common password list
+func NewCommonRule() *CommonRule {
+	return &CommonRule{Dictionary: CommonPasswords()}
+}
+
+// Check rejects the password when it or its base word is common
+func (r *CommonRule) Check(ctx context.Context, password string, user User) (*Violation, error) {
+	words := []string{strings.ToLower(password)}
+	if base := strings.TrimFunc(words[0], isDecoration); len(base) >= 4 && base != words[0] {
+		words = append(words, base)
+	}
+	for _, word := range words {
+		for _, candidate := range []string{word, unleet(word, 'i'), unleet(word, 'l')} {
+			if _, ok := r.Dictionary[candidate]; ok {
+				return &Violation{Rule: "common", Message: "password is too common"}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// isDecoration reports whether r is a digit or symbol people put around a word
+func isDecoration(r rune) bool {
+	return !unicode.IsLetter(r)
+}
+
+// leetSubstitutions maps the characters people swap for letters, one is
+// mapped to the letter passed to unleet because it stands for both i and l
+var leetSubstitutions = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '9': 'g',
+	'0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z', '|': 'l',
+}
+
+// unleet undoes the leet substitutions of a lower case word
+func unleet(word string, one rune) string {
+	return strings.Map(func(r rune) rune {
+		if r == '1' || r == '!' {
+			return one
+		}
+		if letter, ok := leetSubstitutions[r]; ok {
+			return letter
+		}
+		return r
+	}, word)
+}
@@ -0,0 +1,36 @@
+package password
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommonRule_Check(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{name: "Common Password", password: "qwerty", want: true},
+		{name: "Common Password In Upper Case", password: "LetMeIn", want: true},
+		{name: "Common Password Decorated With Digits And Symbols", password: "Password1!", want: true},
+		{name: "Common Password In Leet", password: "P@55w0rd", want: true},
+		{name: "Uncommon Password", password: "Kebun-Sawit-Riau-88", want: false},
+		{name: "Short Base Word Is Not Trimmed", password: "1dog2", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewCommonRule().Check(context.Background(), tt.password, User{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got != nil)
+		})
+	}
+}
+
+func TestCommonPasswords(t *testing.T) {
+	dictionary := CommonPasswords()
+	assert.Equal(t, 1, dictionary["123456"])
+	assert.Equal(t, 2, dictionary["password"])
+}
@@ -0,0 +1,73 @@
+package password
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+const (
+	// minNamePart is the shortest part of the name looked for in the password
+	minNamePart = 3
+	// phoneDigits is how many consecutive digits of the phone number make a password guessable
+	phoneDigits = 6
+)
+
+// ContextRule rejects passwords containing the user's name or phone number
+type ContextRule struct{}
+
+// NewContextRule creates a rule checking the password against the user
+func NewContextRule() *ContextRule {
+	return &ContextRule{}
+}
+
+// Check rejects the password when it contains a part of the name or six
+// consecutive digits of the phone number
+func (r *ContextRule) Check(ctx context.Context, password string, user User) (*Violation, error) {
+	lower := strings.ToLower(password)
+	for _, part := range nameParts(user.Name) {
+		if strings.Contains(lower, part) || strings.Contains(unleet(lower, 'i'), part) || strings.Contains(unleet(lower, 'l'), part) {
+			return &Violation{Rule: "context", Message: "password must not contain your name"}, nil
+		}
+	}
+
+	digits := nationalNumber(user.Phone)
+	for i := 0; i+phoneDigits <= len(digits); i++ {
+		if strings.Contains(password, digits[i:i+phoneDigits]) {
+			return &Violation{Rule: "context", Message: "password must not contain your phone number"}, nil
+		}
+	}
+	return nil, nil
+}
+
+// nameParts splits the name into lower case words long enough to matter
+func nameParts(name string) []string {
+	var parts []string
+	for _, part := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if len(part) >= minNamePart {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// nationalNumber strips the +62 country code from the phone number, leaving
+// the digits that are also typed with a leading 0
+func nationalNumber(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, phone)
+	return strings.TrimPrefix(digits, "62")
+}
+
+// userInputs are the words of the user a guesser tries first
+func userInputs(user User) []string {
+	inputs := nameParts(user.Name)
+	if digits := nationalNumber(user.Phone); digits != "" {
+		inputs = append(inputs, digits, "0"+digits)
+	}
+	return inputs
+}
@@ -0,0 +1,45 @@
+package password
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextRule_Check(t *testing.T) {
+	user := User{Name: "Budi Santoso", Phone: "+62812345678912"}
+	tests := []struct {
+		name     string
+		password string
+		want     *Violation
+	}{
+		{
+			name:     "Password Contains Name",
+			password: "Santoso#2024x",
+			want:     &Violation{Rule: "context", Message: "password must not contain your name"},
+		},
+		{
+			name:     "Password Contains Name In Leet",
+			password: "B0di-5ant0s0",
+			want:     &Violation{Rule: "context", Message: "password must not contain your name"},
+		},
+		{
+			name:     "Password Contains Phone Number",
+			password: "Kebun#345678",
+			want:     &Violation{Rule: "context", Message: "password must not contain your phone number"},
+		},
+		{
+			name:     "Password Unrelated To User",
+			password: "Kebun-Sawit-Riau-88",
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewContextRule().Check(context.Background(), tt.password, user)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package password
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The estimator follows zxcvbn: the password is split into the patterns a
+// guesser tries, each pattern is priced in guesses and the cheapest split is
+// the strength of the password
+const (
+	// maxEstimateLength caps the runes estimated, longer passwords are strong anyway
+	maxEstimateLength = 100
+	// bruteforceCardinality is the guesses per character nothing else explains
+	bruteforceCardinality = 10
+	minGuessesSingleChar  = 10
+	minGuessesMultiChar   = 50
+	// minGuessesPerPattern is what every extra pattern in a split costs at least
+	minGuessesPerPattern = 10000
+	// keyboardStarts is the number of keys a keyboard walk can start on
+	keyboardStarts = 94
+	// keyboardDirections is roughly how many neighbours a key has
+	keyboardDirections = 4
+	minYearSpace       = 20
+)
+
+// Estimate is how hard a password is to guess
+type Estimate struct {
+	// Guesses is the log10 of the guesses an attacker needs
+	Guesses float64
+	// Score rates the guesses from 0 too guessable to 4 very unguessable, like zxcvbn
+	Score int
+}
+
+// StrengthRule rejects passwords scoring below MinScore
+type StrengthRule struct {
+	MinScore int
+}
+
+// NewStrengthRule creates a rule requiring the given score, 0 to 4
+func NewStrengthRule(minScore int) *StrengthRule {
+	return &StrengthRule{MinScore: minScore}
+}
+
+// Check rejects the password when it is too easy to guess, the user's name
+// and phone are among the first guesses
+func (r *StrengthRule) Check(ctx context.Context, password string, user User) (*Violation, error) {
+	if Strength(password, userInputs(user)...).Score < r.MinScore {
+		return &Violation{Rule: "strength", Message: "password is too easy to guess, use a longer password or a few uncommon words"}, nil
+	}
+	return nil, nil
+}
+
+// pattern is a part of the password from start up to end priced in log10 guesses
+type pattern struct {
+	start, end int
+	guesses    float64
+}
+
+// Strength estimates how many guesses the password takes, inputs are words
+// such as the user's name that are tried before any dictionary
+func Strength(password string, inputs ...string) Estimate {
+	runes := []rune(password)
+	if len(runes) > maxEstimateLength {
+		runes = runes[:maxEstimateLength]
+	}
+	n := len(runes)
+	if n == 0 {
+		return Estimate{}
+	}
+
+	userDictionary := Dictionary{}
+	for _, input := range inputs {
+		input = strings.ToLower(input)
+		if _, ok := userDictionary[input]; !ok {
+			userDictionary[input] = len(userDictionary) + 1
+		}
+	}
+	patterns := findPatterns(runes, []Dictionary{CommonPasswords(), userDictionary})
+
+	// best[k][l] is the cheapest split of the first k runes into l patterns
+	best := make([][]float64, n+1)
+	for k := range best {
+		best[k] = make([]float64, n+1)
+		for l := range best[k] {
+			best[k][l] = math.Inf(1)
+		}
+	}
+	best[0][0] = 0
+	byEnd := make([][]pattern, n+1)
+	for _, p := range patterns {
+		byEnd[p.end] = append(byEnd[p.end], p)
+	}
+	for end := 1; end <= n; end++ {
+		for start := 0; start < end; start++ {
+			byEnd[end] = append(byEnd[end], bruteforce(start, end, n))
+		}
+		for _, p := range byEnd[end] {
+			for l := 1; l <= end; l++ {
+				if guesses := best[p.start][l-1] + p.guesses; guesses < best[end][l] {
+					best[end][l] = guesses
+				}
+			}
+		}
+	}
+
+	// the guesser also has to try the patterns in every order
+	guesses := math.Inf(1)
+	for l := 1; l <= n; l++ {
+		if math.IsInf(best[n][l], 1) {
+			continue
+		}
+		factorial, _ := math.Lgamma(float64(l + 1))
+		total := log10Sum(factorial/math.Ln10+best[n][l], float64(l-1)*math.Log10(minGuessesPerPattern))
+		guesses = math.Min(guesses, total)
+	}
+	return Estimate{Guesses: guesses, Score: score(guesses)}
+}
+
+// score maps log10 guesses onto the zxcvbn scale
+func score(guesses float64) int {
+	thresholds := []float64{1e3, 1e6, 1e8, 1e10}
+	for i, threshold := range thresholds {
+		if guesses < math.Log10(threshold+5) {
+			return i
+		}
+	}
+	return len(thresholds)
+}
+
+func findPatterns(runes []rune, dictionaries []Dictionary) []pattern {
+	var patterns []pattern
+	patterns = append(patterns, dictionaryPatterns(runes, dictionaries)...)
+	patterns = append(patterns, sequencePatterns(runes)...)
+	patterns = append(patterns, repeatPatterns(runes)...)
+	patterns = append(patterns, keyboardPatterns(runes)...)
+	patterns = append(patterns, yearPatterns(runes)...)
+	return patterns
+}
+
+// newPattern prices a pattern, patterns shorter than the password cost a minimum
+func newPattern(start, end, length int, guesses float64) pattern {
+	if end-start < length {
+		minimum := float64(minGuessesMultiChar)
+		if end-start == 1 {
+			minimum = minGuessesSingleChar
+		}
+		guesses = math.Max(guesses, minimum)
+	}
+	return pattern{start: start, end: end, guesses: math.Log10(guesses)}
+}
+
+func bruteforce(start, end, length int) pattern {
+	guesses := math.Pow(bruteforceCardinality, float64(end-start))
+	if end-start < length {
+		minimum := float64(minGuessesMultiChar + 1)
+		if end-start == 1 {
+			minimum = minGuessesSingleChar + 1
+		}
+		guesses = math.Max(guesses, minimum)
+	}
+	return pattern{start: start, end: end, guesses: math.Log10(guesses)}
+}
+
+// dictionaryPatterns finds words of the dictionaries, also written backwards
+// or in leet
+func dictionaryPatterns(runes []rune, dictionaries []Dictionary) []pattern {
+	var patterns []pattern
+	lower := []rune(strings.ToLower(string(runes)))
+	for start := 0; start < len(runes); start++ {
+		for end := start + 3; end <= len(runes); end++ {
+			word := string(lower[start:end])
+			variations := upperVariations(runes[start:end])
+			// a reversed or leet word takes a guesser twice as long
+			candidates := []string{word, reverse(word), unleet(word, 'i'), unleet(word, 'l')}
+			for i, candidate := range candidates {
+				factor := 1.0
+				if i > 0 {
+					if candidate == word {
+						continue
+					}
+					factor = 2
+				}
+				for _, dictionary := range dictionaries {
+					if rank, ok := dictionary[candidate]; ok {
+						patterns = append(patterns, newPattern(start, end, len(runes), float64(rank)*variations*factor))
+					}
+				}
+			}
+		}
+	}
+	return patterns
+}
+
+// upperVariations is how many ways the letters of the word could be capitalized
+func upperVariations(word []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	if lower == 0 || upper == 1 && (unicode.IsUpper(word[0]) || unicode.IsUpper(word[len(word)-1])) {
+		return 2
+	}
+	variations := 0.0
+	for k := 1; k <= upper && k <= lower; k++ {
+		variations += binomial(upper+lower, k)
+	}
+	return variations
+}
+
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
+
+// sequencePatterns finds runs such as abcd or 9876
+func sequencePatterns(runes []rune) []pattern {
+	var patterns []pattern
+	for start := 0; start < len(runes)-2; {
+		delta := runes[start+1] - runes[start]
+		end := start + 1
+		for end < len(runes) && runes[end]-runes[end-1] == delta && (delta == 1 || delta == -1) {
+			end++
+		}
+		if end-start < 3 {
+			start++
+			continue
+		}
+		guesses := 26.0
+		switch {
+		case strings.ContainsRune("aAzZ019", runes[start]):
+			guesses = 4
+		case unicode.IsDigit(runes[start]):
+			guesses = 10
+		}
+		if delta < 0 {
+			guesses *= 2
+		}
+		patterns = append(patterns, newPattern(start, end, len(runes), guesses*float64(end-start)))
+		start = end - 1
+	}
+	return patterns
+}
+
+// repeatPatterns finds a character typed three or more times in a row
+func repeatPatterns(runes []rune) []pattern {
+	var patterns []pattern
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && runes[end] == runes[start] {
+			end++
+		}
+		if end-start >= 3 {
+			patterns = append(patterns, newPattern(start, end, len(runes), cardinality(runes[start])*float64(end-start)))
+		}
+		start = end
+	}
+	return patterns
+}
+
+func cardinality(r rune) float64 {
+	switch {
+	case unicode.IsDigit(r):
+		return 10
+	case unicode.IsLetter(r):
+		return 26
+	default:
+		return 33
+	}
+}
+
+// keyboardRows is a qwerty keyboard, the offsets are how far each row is shifted right
+var keyboardRows = []struct {
+	keys, shifted string
+	offset        float64
+}{
+	{"`1234567890-=", "~!@#$%^&*()_+", 0},
+	{"qwertyuiop[]\\", "QWERTYUIOP{}|", 1.5},
+	{"asdfghjkl;'", "ASDFGHJKL:\"", 1.75},
+	{"zxcvbnm,./", "ZXCVBNM<>?", 2.25},
+}
+
+type keyPosition struct {
+	row int
+	x   float64
+}
+
+var keyPositions = func() map[rune]keyPosition {
+	positions := map[rune]keyPosition{}
+	for row, keyboardRow := range keyboardRows {
+		for i, key := range keyboardRow.keys {
+			positions[key] = keyPosition{row: row, x: float64(i) + keyboardRow.offset}
+		}
+		for i, key := range keyboardRow.shifted {
+			positions[key] = keyPosition{row: row, x: float64(i) + keyboardRow.offset}
+		}
+	}
+	return positions
+}()
+
+// keyboardStep is the direction from one key to its neighbour, ok is false
+// when the keys are not next to each other
+func keyboardStep(from, to rune) (step [2]int, ok bool) {
+	a, okA := keyPositions[from]
+	b, okB := keyPositions[to]
+	if !okA || !okB || from == to {
+		return step, false
+	}
+	dx := b.x - a.x
+	switch b.row - a.row {
+	case 0:
+		ok = math.Abs(dx) == 1
+	case 1, -1:
+		ok = math.Abs(dx) <= 0.75
+	}
+	return [2]int{b.row - a.row, int(math.Copysign(math.Ceil(math.Abs(dx)), dx))}, ok
+}
+
+// keyboardPatterns finds walks over neighbouring keys such as qwerty or zaq1
+func keyboardPatterns(runes []rune) []pattern {
+	var patterns []pattern
+	for start := 0; start < len(runes)-2; {
+		end := start + 1
+		turns := 0
+		var direction [2]int
+		for end < len(runes) {
+			step, ok := keyboardStep(runes[end-1], runes[end])
+			if !ok {
+				break
+			}
+			if end == start+1 || step != direction {
+				turns++
+				direction = step
+			}
+			end++
+		}
+		if end-start < 3 {
+			start++
+			continue
+		}
+		guesses := keyboardStarts * math.Pow(keyboardDirections, float64(turns)) * float64(end-start)
+		patterns = append(patterns, newPattern(start, end, len(runes), guesses))
+		start = end - 1
+	}
+	return patterns
+}
+
+// yearPatterns finds years from 1900 to 2099
+func yearPatterns(runes []rune) []pattern {
+	var patterns []pattern
+	now := time.Now().Year()
+	for start := 0; start+4 <= len(runes); start++ {
+		year, err := strconv.Atoi(string(runes[start : start+4]))
+		if err != nil || year < 1900 || year > 2099 {
+			continue
+		}
+		space := math.Max(math.Abs(float64(year-now)), minYearSpace)
+		patterns = append(patterns, newPattern(start, start+4, len(runes), space))
+	}
+	return patterns
+}
+
+func reverse(word string) string {
+	runes := []rune(word)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// log10Sum adds two numbers given and returned as log10
+func log10Sum(a, b float64) float64 {
+	high, low := math.Max(a, b), math.Min(a, b)
+	return high + math.Log10(1+math.Pow(10, low-high))
+}
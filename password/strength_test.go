@@ -0,0 +1,45 @@
+package password
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrength(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		inputs   []string
+		want     int
+	}{
+		{name: "Empty", password: "", want: 0},
+		{name: "Common Password", password: "qwerty", want: 0},
+		{name: "Keyboard Walk", password: "zaq12wsx", want: 0},
+		{name: "Repeated Character", password: "aaaaaaaa", want: 0},
+		{name: "Decorated Common Password", password: "Password1!", want: 1},
+		{name: "Sequence", password: "abcdef123", want: 1},
+		{name: "User Input", password: "budisantoso", inputs: []string{"budi", "santoso"}, want: 1},
+		{name: "Random Characters", password: "Jx7!Kp2@Lm9#", want: 4},
+		{name: "Long Passphrase", password: "Kebun-Sawit-Riau-88", want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Strength(tt.password, tt.inputs...)
+			assert.Equal(t, tt.want, got.Score, "guesses 10^%.2f", got.Guesses)
+		})
+	}
+}
+
+func TestStrengthRule_Check(t *testing.T) {
+	rule := NewStrengthRule(2)
+
+	got, err := rule.Check(context.Background(), "Password1!", User{})
+	assert.NoError(t, err)
+	assert.Equal(t, "strength", got.Rule)
+
+	got, err = rule.Check(context.Background(), "Kebun-Sawit-Riau-88", User{})
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many takes the memory store handles between sweeps of its full buckets
+const sweepEvery = 1024
+
+// MemoryStore keeps the buckets in memory, every replica has its own limits
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	takes   int
+}
+
+type memoryBucket struct {
+	Bucket
+	limit Limit
+}
+
+// NewMemoryStore creates an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*memoryBucket{}}
+}
+
+// Take takes a token from the bucket of the key
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.takes++
+	if s.takes%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{}
+		s.buckets[key] = bucket
+	}
+	bucket.limit = limit
+	return bucket.Take(limit, now), nil
+}
+
+// sweep drops the buckets that refilled completely so idle keys do not pile up
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if bucket.Full(bucket.limit, now) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Package ratelimit implements token bucket rate limiting with pluggable storage
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Limit allows bursts of up to Burst requests, one more request is allowed
+// every Interval after that
+type Limit struct {
+	Burst    int
+	Interval time.Duration
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Remaining is how many requests are allowed right now
+	Remaining int
+	// RetryAfter is how long until the next request is allowed, 0 when allowed
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// Store keeps the buckets, taking a token must be atomic per key
+type Store interface {
+	Take(key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Bucket is the state of a token bucket, a zero bucket is full
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Take refills the bucket for the time passed since its last update and takes
+// a token when one is left, a denied request takes nothing
+func (b *Bucket) Take(limit Limit, now time.Time) Result {
+	burst := float64(limit.Burst)
+	if b.UpdatedAt.IsZero() {
+		b.Tokens = burst
+	} else if elapsed := now.Sub(b.UpdatedAt); elapsed > 0 {
+		b.Tokens = math.Min(burst, b.Tokens+float64(elapsed)/float64(limit.Interval))
+	}
+	b.UpdatedAt = now
+
+	result := Result{Limit: limit.Burst}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.Tokens) * float64(limit.Interval))
+	}
+	result.Remaining = int(b.Tokens)
+	result.ResetAfter = time.Duration((burst - b.Tokens) * float64(limit.Interval))
+	return result
+}
+
+// Full reports whether the bucket refilled completely by now, a full bucket
+// behaves like a missing one so it can be dropped
+func (b *Bucket) Full(limit Limit, now time.Time) bool {
+	return b.UpdatedAt.IsZero() || b.Tokens+float64(now.Sub(b.UpdatedAt))/float64(limit.Interval) >= float64(limit.Burst)
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucket_Take(t *testing.T) {
+	limit := Limit{Burst: 3, Interval: 10 * time.Second}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		at   time.Duration
+		want Result
+	}{
+		{
+			name: "First Request Starts Full",
+			at:   0,
+			want: Result{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: 10 * time.Second},
+		},
+		{
+			name: "Second Request",
+			at:   0,
+			want: Result{Allowed: true, Limit: 3, Remaining: 1, ResetAfter: 20 * time.Second},
+		},
+		{
+			name: "Last Token",
+			at:   0,
+			want: Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 30 * time.Second},
+		},
+		{
+			name: "Empty Bucket",
+			at:   5 * time.Second,
+			want: Result{Allowed: false, Limit: 3, Remaining: 0, RetryAfter: 5 * time.Second, ResetAfter: 25 * time.Second},
+		},
+		{
+			name: "Refilled One Token",
+			at:   10 * time.Second,
+			want: Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 30 * time.Second},
+		},
+		{
+			name: "Refill Stops At Burst",
+			at:   time.Hour,
+			want: Result{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: 10 * time.Second},
+		},
+	}
+
+	bucket := &Bucket{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bucket.Take(limit, start.Add(tt.at))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Burst: 1, Interval: time.Minute}
+	now := time.Now()
+
+	result, err := store.Take("login:ip:10.0.0.1", limit, now)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	result, err = store.Take("login:ip:10.0.0.1", limit, now)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Minute, result.RetryAfter)
+
+	// every key has its own bucket
+	result, err = store.Take("login:ip:10.0.0.2", limit, now)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// full buckets are swept
+	store.sweep(now.Add(time.Minute))
+	assert.Empty(t, store.buckets)
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/jinzhu/gorm"
+)
+
+type PgAuditRepository struct {
+	DB *gorm.DB
+}
+
+// AuditRepository is an interface for audit event repository
+type AuditRepository interface {
+	Create(event *models.AuditEvent) error
+	ListByUser(userID int, eventTypes []string, afterID int, limit int) ([]models.AuditEvent, error)
+	DeleteByUser(userID int) error
+}
+
+// Create creates a new audit event
+func (r *PgAuditRepository) Create(event *models.AuditEvent) error {
+	return r.DB.Create(event).Error
+}
+
+// ListByUser lists at most limit audit events of a user with one of the given
+// types, ordered by id and starting after afterID so callers can page through
+// every event without offsets
+func (r *PgAuditRepository) ListByUser(userID int, eventTypes []string, afterID int, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	err := r.DB.
+		Where("user_id = ? AND id > ? AND event_type IN (?)", userID, afterID, eventTypes).
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// DeleteByUser deletes every audit event of a user
+func (r *PgAuditRepository) DeleteByUser(userID int) error {
+	return r.DB.Where("user_id = ?", userID).Delete(&models.AuditEvent{}).Error
+}
+
+// NewPgAuditRepository creates new postgress audit event repository
+func NewPgAuditRepository(db *gorm.DB) *PgAuditRepository {
+	return &PgAuditRepository{DB: db}
+}
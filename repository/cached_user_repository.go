@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"container/list"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+)
+
+// UserCacheBackend is a cache shared by the replicas of the service, such as
+// Redis, behind the cache of each replica. A user invalidated by one replica
+// is evicted by every other through Subscribe
+type UserCacheBackend interface {
+	// Get returns the cached user, nil when there is none
+	Get(id int) (*models.User, error)
+	Set(id int, user *models.User, ttl time.Duration) error
+	// Invalidate deletes the user and has every subscriber evict it
+	Invalidate(id int) error
+	// Subscribe calls evict with the ids invalidated by any replica
+	Subscribe(evict func(id int))
+}
+
+// CacheStats are the counters of a CachedUserRepository since it was created
+type CacheStats struct {
+	Capacity int
+	Size     int
+	Hits     uint64
+	// SharedHits are the lookups missing the cache of the replica that the
+	// backend answered
+	SharedHits uint64
+	Misses     uint64
+	// Evictions count the users dropped for the capacity, not the invalidations
+	Evictions uint64
+}
+
+// CachedUserRepository caches the users found by FindProfileByID, the most
+// recently used ones up to its capacity and each for its ttl. Users are
+// cached without their credentials. The writes of a user invalidate it, the
+// other methods go to the UserRepository unchanged
+type CachedUserRepository struct {
+	UserRepository
+	backend  UserCacheBackend
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu sync.Mutex
+	// lru holds the *cachedUser, the most recently used first
+	lru   *list.List
+	users map[int]*list.Element
+	// generation counts the invalidations, a user found while one happened
+	// may be stale and is not cached
+	generation uint64
+	stats      CacheStats
+}
+
+type cachedUser struct {
+	id        int
+	user      *models.User
+	expiresAt time.Time
+}
+
+var _ UserRepository = (*CachedUserRepository)(nil)
+
+// NewCachedUserRepository caches the lookups of repo, backend is optional
+func NewCachedUserRepository(repo UserRepository, capacity int, ttl time.Duration, backend UserCacheBackend) *CachedUserRepository {
+	r := &CachedUserRepository{
+		UserRepository: repo,
+		backend:        backend,
+		capacity:       capacity,
+		ttl:            ttl,
+		now:            time.Now,
+		lru:            list.New(),
+		users:          map[int]*list.Element{},
+	}
+	if backend != nil {
+		backend.Subscribe(r.evict)
+	}
+	return r
+}
+
+// FindProfileByID finds a user by id without its credentials, from the cache
+// when it is there
+func (r *CachedUserRepository) FindProfileByID(id int) (*models.User, error) {
+	user, generation := r.get(id)
+	if user != nil {
+		return user, nil
+	}
+
+	if r.backend != nil {
+		shared, err := r.backend.Get(id)
+		if err != nil {
+			log.Printf("fail to get user %d from the cache backend: %v", id, err)
+		}
+		if shared != nil {
+			r.count(func(stats *CacheStats) { stats.SharedHits++ })
+			r.put(id, shared, generation)
+			return withoutCredentials(shared), nil
+		}
+	}
+
+	r.count(func(stats *CacheStats) { stats.Misses++ })
+	user, err := r.UserRepository.FindProfileByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if r.put(id, user, generation) && r.backend != nil {
+		if err := r.backend.Set(id, withoutCredentials(user), r.ttl); err != nil {
+			log.Printf("fail to set user %d in the cache backend: %v", id, err)
+		}
+	}
+	return withoutCredentials(user), nil
+}
+
+// get returns a copy of the cached user, nil when it is missing or expired,
+// and the generation to put the user found instead with
+func (r *CachedUserRepository) get(id int) (*models.User, uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	element, ok := r.users[id]
+	if !ok {
+		return nil, r.generation
+	}
+	cached := element.Value.(*cachedUser)
+	if !r.now().Before(cached.expiresAt) {
+		r.remove(element)
+		return nil, r.generation
+	}
+	r.lru.MoveToFront(element)
+	r.stats.Hits++
+	user := *cached.user
+	return &user, r.generation
+}
+
+// put caches the user unless it was invalidated since generation, it reports
+// whether the user was cached
+func (r *CachedUserRepository) put(id int, user *models.User, generation uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.capacity <= 0 || generation != r.generation {
+		return false
+	}
+	cached := &cachedUser{id: id, user: withoutCredentials(user), expiresAt: r.now().Add(r.ttl)}
+	if element, ok := r.users[id]; ok {
+		element.Value = cached
+		r.lru.MoveToFront(element)
+		return true
+	}
+	r.users[id] = r.lru.PushFront(cached)
+	for r.lru.Len() > r.capacity {
+		r.remove(r.lru.Back())
+		r.stats.Evictions++
+	}
+	return true
+}
+
+func (r *CachedUserRepository) remove(element *list.Element) {
+	r.lru.Remove(element)
+	delete(r.users, element.Value.(*cachedUser).id)
+}
+
+func (r *CachedUserRepository) count(f func(stats *CacheStats)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f(&r.stats)
+}
+
+// evict drops the user from the cache of this replica
+func (r *CachedUserRepository) evict(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	if element, ok := r.users[id]; ok {
+		r.remove(element)
+	}
+}
+
+// invalidate drops the user from the cache of every replica
+func (r *CachedUserRepository) invalidate(id int) {
+	r.evict(id)
+	if r.backend != nil {
+		if err := r.backend.Invalidate(id); err != nil {
+			log.Printf("fail to invalidate user %d in the cache backend: %v", id, err)
+		}
+	}
+}
+
+// Stats returns a snapshot of the cache counters
+func (r *CachedUserRepository) Stats() CacheStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.stats
+	stats.Capacity = r.capacity
+	stats.Size = r.lru.Len()
+	return stats
+}
+
+// Update updates the columns of a user and invalidates it
+func (r *CachedUserRepository) Update(user *models.User, columns ...string) error {
+	defer r.invalidate(user.ID)
+	return r.UserRepository.Update(user, columns...)
+}
+
+// Delete soft deletes a user and invalidates it
+func (r *CachedUserRepository) Delete(id int) error {
+	defer r.invalidate(id)
+	return r.UserRepository.Delete(id)
+}
+
+// ScheduleDeletion schedules the deletion of a user and invalidates it
+func (r *CachedUserRepository) ScheduleDeletion(id int, purgeAt time.Time) error {
+	defer r.invalidate(id)
+	return r.UserRepository.ScheduleDeletion(id, purgeAt)
+}
+
+// Restore cancels a scheduled deletion and invalidates the user
+func (r *CachedUserRepository) Restore(id int) error {
+	defer r.invalidate(id)
+	return r.UserRepository.Restore(id)
+}
+
+// Anonymize anonymizes a deleted user and invalidates it
+func (r *CachedUserRepository) Anonymize(id int) error {
+	defer r.invalidate(id)
+	return r.UserRepository.Anonymize(id)
+}
+
+// UseTOTPStep marks the TOTP time step as used and invalidates the user
+func (r *CachedUserRepository) UseTOTPStep(id int, step int64) error {
+	defer r.invalidate(id)
+	return r.UserRepository.UseTOTPStep(id, step)
+}
@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedUserRepositoryConformance(t *testing.T) {
+	testUserRepository(t, func(t *testing.T) UserRepository {
+		return NewCachedUserRepository(NewMemoryUserRepository(), 100, time.Minute, nil)
+	})
+}
+
+// memoryCacheBackend is a UserCacheBackend shared by the caches of a test
+type memoryCacheBackend struct {
+	mu          sync.Mutex
+	users       map[int]models.User
+	subscribers []func(id int)
+}
+
+func newMemoryCacheBackend() *memoryCacheBackend {
+	return &memoryCacheBackend{users: map[int]models.User{}}
+}
+
+func (b *memoryCacheBackend) Get(id int) (*models.User, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	user, ok := b.users[id]
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+func (b *memoryCacheBackend) Set(id int, user *models.User, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.users[id] = *user
+	return nil
+}
+
+func (b *memoryCacheBackend) Invalidate(id int) error {
+	b.mu.Lock()
+	delete(b.users, id)
+	subscribers := b.subscribers
+	b.mu.Unlock()
+	for _, evict := range subscribers {
+		evict(id)
+	}
+	return nil
+}
+
+func (b *memoryCacheBackend) Subscribe(evict func(id int)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, evict)
+}
+
+func TestCachedUserRepository(t *testing.T) {
+	budi := &models.User{ID: 1, PhoneNumber: "+6281200000001", Fullname: "Budi", Password: "hash", SaltToken: "salt", TOTPSecret: "JBSWY3DPEHPK3PXP"}
+	siti := &models.User{ID: 2, PhoneNumber: "+6281200000002", Fullname: "Siti"}
+
+	t.Run("Hits Skip The Repository", func(t *testing.T) {
+		repo := mocks.NewUserRepository(t)
+		repo.On("FindProfileByID", 1).Return(budi, nil).Once()
+		cache := NewCachedUserRepository(repo, 10, time.Minute, nil)
+
+		for i := 0; i < 3; i++ {
+			user, err := cache.FindProfileByID(1)
+			require.NoError(t, err)
+			assert.Equal(t, "Budi", user.Fullname)
+		}
+		stats := cache.Stats()
+		assert.Equal(t, CacheStats{Capacity: 10, Size: 1, Hits: 2, Misses: 1}, stats)
+	})
+
+	t.Run("Credentials Are Never Cached", func(t *testing.T) {
+		repo := mocks.NewUserRepository(t)
+		repo.On("FindProfileByID", 1).Return(budi, nil).Once()
+		backend := newMemoryCacheBackend()
+		cache := NewCachedUserRepository(repo, 10, time.Minute, backend)
+
+		for i := 0; i < 2; i++ {
+			user, err := cache.FindProfileByID(1)
+			require.NoError(t, err)
+			assert.Empty(t, user.Password)
+			assert.Empty(t, user.SaltToken)
+			assert.Empty(t, user.TOTPSecret)
+			assert.True(t, user.HasPassword())
+		}
+		cached := cache.users[1].Value.(*cachedUser).user
+		assert.Empty(t, cached.Password)
+		assert.Empty(t, cached.SaltToken)
+		assert.Empty(t, backend.users[1].Password)
+		assert.Empty(t, backend.users[1].SaltToken)
+	})
+
+	t.Run("Callers Get Copies", func(t *testing.T) {
+		repo := mocks.NewUserRepository(t)
+		repo.On("FindProfileByID", 1).Return(budi, nil).Once()
+		cache := NewCachedUserRepository(repo, 10, time.Minute, nil)
+
+		user, err := cache.FindProfileByID(1)
+		require.NoError(t, err)
+		user.Fullname = "Changed"
+		user, err = cache.FindProfileByID(1)
+		require.NoError(t, err)
+		assert.Equal(t, "Budi", user.Fullname)
+	})
+
+	t.Run("Entries Expire After The TTL", func(t *testing.T) {
+		repo := mocks.NewUserRepository(t)
+		repo.On("FindProfileByID", 1).Return(budi, nil).Twice()
+		cache := NewCachedUserRepository(repo, 10, time.Minute, nil)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		_, err := cache.FindProfileByID(1)
+		require.NoError(t, err)
+		now = now.Add(time.Minute)
+		_, err = cache.FindProfileByID(1)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), cache.Stats().Misses)
+	})
+
+	t.Run("Least Recently Used Are Evicted", func(t *testing.T) {
+		repo := mocks.NewUserRepository(t)
+		repo.On("FindProfileByID", 1).Return(budi, nil).Once()
+		repo.On("FindProfileByID", 2).Return(siti, nil).Twice()
+		repo.On("FindProfileByID", 3).Return(&models.User{ID: 3}, nil).Once()
+		cache := NewCachedUserRepository(repo, 2, time.Minute, nil)
+
+		for _, id := range []int{1, 2, 1, 3, 1, 2} {
+			_, err := cache.FindProfileByID(id)
+			require.NoError(t, err)
+		}
+		stats := cache.Stats()
+		assert.Equal(t, 2, stats.Size)
+		assert.Equal(t, uint64(2), stats.Evictions)
+	})
+
+	t.Run("Errors Are Not Cached", func(t *testing.T) {
+		repo := mocks.NewUserRepository(t)
+		repo.On("FindProfileByID", 1).Return(nil, ErrNotFound).Twice()
+		cache := NewCachedUserRepository(repo, 10, time.Minute, nil)
+
+		for i := 0; i < 2; i++ {
+			_, err := cache.FindProfileByID(1)
+			assert.Equal(t, ErrNotFound, err)
+		}
+		assert.Equal(t, 0, cache.Stats().Size)
+	})
+
+	t.Run("Writes Invalidate", func(t *testing.T) {
+		memory := NewMemoryUserRepository()
+		cache := NewCachedUserRepository(memory, 10, time.Minute, nil)
+		user := &models.User{PhoneNumber: "+6281200000001", Fullname: "Budi", Password: "hash", SaltToken: "salt"}
+		require.NoError(t, cache.Create(user))
+
+		writes := []struct {
+			name  string
+			write func() error
+			check func(t *testing.T, found *models.User, err error)
+		}{
+			{
+				name: "Update",
+				write: func() error {
+					user.Status = models.StatusDisabled
+					return cache.Update(user, ColumnStatus)
+				},
+				check: func(t *testing.T, found *models.User, err error) {
+					require.NoError(t, err)
+					assert.Equal(t, models.StatusDisabled, found.Status)
+				},
+			},
+			{
+				name:  "Use TOTP Step",
+				write: func() error { return cache.UseTOTPStep(user.ID, 7) },
+				check: func(t *testing.T, found *models.User, err error) {
+					require.NoError(t, err)
+					assert.Equal(t, int64(7), found.TOTPLastStep)
+				},
+			},
+			{
+				name:  "Schedule Deletion",
+				write: func() error { return cache.ScheduleDeletion(user.ID, time.Now().Add(time.Hour)) },
+				check: func(t *testing.T, found *models.User, err error) {
+					assert.Equal(t, ErrNotFound, err)
+				},
+			},
+			{
+				name:  "Restore",
+				write: func() error { return cache.Restore(user.ID) },
+				check: func(t *testing.T, found *models.User, err error) {
+					require.NoError(t, err)
+					assert.Nil(t, found.PurgeAt)
+				},
+			},
+			{
+				name:  "Delete",
+				write: func() error { return cache.Delete(user.ID) },
+				check: func(t *testing.T, found *models.User, err error) {
+					assert.Equal(t, ErrNotFound, err)
+				},
+			},
+		}
+		for _, tt := range writes {
+			t.Run(tt.name, func(t *testing.T) {
+				// cache the user before the write
+				_, _ = cache.FindProfileByID(user.ID)
+				require.NoError(t, tt.write())
+				found, err := cache.FindProfileByID(user.ID)
+				tt.check(t, found, err)
+			})
+		}
+	})
+
+	t.Run("Users Found During An Invalidation Are Not Cached", func(t *testing.T) {
+		repo := mocks.NewUserRepository(t)
+		cache := NewCachedUserRepository(repo, 10, time.Minute, nil)
+		repo.On("FindProfileByID", 1).Return(budi, nil).Run(func(args mock.Arguments) {
+			// an update lands while the old user is read
+			cache.evict(1)
+		}).Twice()
+
+		for i := 0; i < 2; i++ {
+			_, err := cache.FindProfileByID(1)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 0, cache.Stats().Size)
+	})
+
+	t.Run("Shared Backend", func(t *testing.T) {
+		memory := NewMemoryUserRepository()
+		backend := newMemoryCacheBackend()
+		first := NewCachedUserRepository(memory, 10, time.Minute, backend)
+		second := NewCachedUserRepository(memory, 10, time.Minute, backend)
+		user := &models.User{PhoneNumber: "+6281200000001", Fullname: "Budi", Password: "hash", SaltToken: "salt"}
+		require.NoError(t, first.Create(user))
+
+		_, err := first.FindProfileByID(user.ID)
+		require.NoError(t, err)
+		_, err = second.FindProfileByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), second.Stats().SharedHits)
+		assert.Equal(t, uint64(0), second.Stats().Misses)
+
+		// the update of the first replica evicts the user from the second
+		user.Fullname = "Budi Santoso"
+		require.NoError(t, first.Update(user, ColumnFullname))
+		found, err := second.FindProfileByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Budi Santoso", found.Fullname)
+		assert.Equal(t, uint64(1), second.Stats().Misses)
+	})
+}
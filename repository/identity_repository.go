@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/jinzhu/gorm"
+)
+
+type PgIdentityRepository struct {
+	DB *gorm.DB
+}
+
+// IdentityRepository is an interface for the linked external identities and sign in state repository
+type IdentityRepository interface {
+	Create(identity *models.UserIdentity) error
+	FindByProviderSubject(provider, subject string) (*models.UserIdentity, error)
+	ListByUser(userID int) ([]models.UserIdentity, error)
+	Delete(userID int, provider string) error
+	CreateState(state *models.IdentityLoginState) error
+	TakeState(id string, now time.Time) (*models.IdentityLoginState, error)
+}
+
+// Create links an external identity to a user
+func (r *PgIdentityRepository) Create(identity *models.UserIdentity) error {
+	return r.DB.Create(identity).Error
+}
+
+// FindByProviderSubject finds the identity with the subject at the provider
+func (r *PgIdentityRepository) FindByProviderSubject(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := r.DB.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// ListByUser lists the identities linked to a user, oldest first
+func (r *PgIdentityRepository) ListByUser(userID int) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := r.DB.Where("user_id = ?", userID).Order("id").Find(&identities).Error
+	if err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// Delete unlinks the provider from the user, it fails with record not found
+// when the provider is not linked
+func (r *PgIdentityRepository) Delete(userID int, provider string) error {
+	result := r.DB.Where("user_id = ? AND provider = ?", userID, provider).Delete(&models.UserIdentity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CreateState stores the state of a new sign in, abandoned sign ins that
+// already expired are cleaned up along the way
+func (r *PgIdentityRepository) CreateState(state *models.IdentityLoginState) error {
+	if err := r.DB.Where("expires_at < ?", time.Now()).Delete(&models.IdentityLoginState{}).Error; err != nil {
+		return err
+	}
+	return r.DB.Create(state).Error
+}
+
+// TakeState deletes and returns an unexpired sign in state, it fails with
+// record not found when the state does not exist, expired or was already taken
+func (r *PgIdentityRepository) TakeState(id string, now time.Time) (*models.IdentityLoginState, error) {
+	var state models.IdentityLoginState
+	err := r.DB.Where("id = ? AND expires_at > ?", id, now).First(&state).Error
+	if err != nil {
+		return nil, err
+	}
+	// only the request that deletes the row gets to use it
+	result := r.DB.Where("id = ?", id).Delete(&models.IdentityLoginState{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &state, nil
+}
+
+// NewPgIdentityRepository creates new postgress identity repository
+func NewPgIdentityRepository(db *gorm.DB) *PgIdentityRepository {
+	return &PgIdentityRepository{DB: db}
+}
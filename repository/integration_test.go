@@ -0,0 +1,501 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/ratelimit"
+	"github.com/SawitProRecruitment/UserService/repository/pgtest"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	pgtest.Main(m, Migrate)
+}
+
+// createUser creates a user the rows of the other tables can reference
+func createUser(t *testing.T, db *gorm.DB, phone string) *models.User {
+	t.Helper()
+	user := &models.User{PhoneNumber: phone, Fullname: "Budi", Password: "hash", SaltToken: "salt"}
+	require.NoError(t, NewPgUserRepository(db).Create(user))
+	return user
+}
+
+// testAnonymize checks that Anonymize deletes the rows of the other tables
+// that hold personal data of the user
+func testAnonymize(t *testing.T, newRepository func(db *gorm.DB) UserRepository) {
+	db := pgtest.DB(t)
+	repo := newRepository(db)
+	user := createUser(t, db, "+6281200000001")
+	require.NoError(t, NewPgPasswordHistoryRepository(db).Add(&models.PasswordHistory{UserID: user.ID, Password: "hash", SaltToken: "salt"}, 5))
+	require.NoError(t, NewPgIdentityRepository(db).Create(&models.UserIdentity{UserID: user.ID, Provider: "google", Subject: "budi"}))
+	require.NoError(t, NewPgRecoveryCodeRepository(db).ReplaceByUser(user.ID, []string{"hash"}))
+	webAuthnRepo := NewPgWebAuthnRepository(db)
+	require.NoError(t, webAuthnRepo.CreateCredential(&models.WebAuthnCredential{UserID: user.ID, CredentialID: []byte("phone"), PublicKey: []byte("key"), AttestationType: "none", Name: "phone"}))
+	require.NoError(t, webAuthnRepo.CreateCeremony(&models.WebAuthnCeremony{ID: "login", UserID: user.ID, Kind: models.CeremonyRegistration, SessionData: "{}", ExpiresAt: time.Now().Add(time.Minute)}))
+	oauthRepo := NewPgOAuthRepository(db)
+	require.NoError(t, oauthRepo.CreateClient(&models.OAuthClient{ID: "kebun", Name: "Kebun", RedirectURIs: "https://kebun.example/callback"}))
+	require.NoError(t, oauthRepo.SaveConsent(&models.OAuthConsent{UserID: user.ID, ClientID: "kebun", Scope: "openid"}))
+	require.NoError(t, oauthRepo.CreateCode(&models.OAuthAuthorizationCode{CodeHash: "hash", ClientID: "kebun", UserID: user.ID, RedirectURI: "https://kebun.example/callback",
+		Scope: "openid", CodeChallenge: "challenge", AuthTime: time.Now(), ExpiresAt: time.Now().Add(time.Minute)}))
+	require.NoError(t, repo.ScheduleDeletion(user.ID, time.Now()))
+
+	require.NoError(t, repo.Anonymize(user.ID))
+
+	for _, model := range []interface{}{&models.PasswordHistory{}, &models.UserIdentity{}, &models.RecoveryCode{},
+		&models.WebAuthnCredential{}, &models.WebAuthnCeremony{}, &models.OAuthConsent{}, &models.OAuthAuthorizationCode{}} {
+		var count int
+		require.NoError(t, db.Model(model).Where("user_id = ?", user.ID).Count(&count).Error)
+		assert.Zero(t, count, "%T", model)
+	}
+}
+
+// concurrently calls f n times at once and returns how many calls succeeded,
+// the errors of the others are passed to failed
+func concurrently(n int, f func(i int) error, failed func(err error)) int {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = f(i)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		failed(err)
+	}
+	return succeeded
+}
+
+func TestPgUserRepository(t *testing.T) {
+	testUserRepository(t, func(t *testing.T) UserRepository {
+		return NewPgUserRepository(pgtest.DB(t))
+	})
+
+	t.Run("Anonymize Deletes The Rows Of The User", func(t *testing.T) {
+		testAnonymize(t, func(db *gorm.DB) UserRepository { return NewPgUserRepository(db) })
+	})
+
+	t.Run("Count Estimate", func(t *testing.T) {
+		db := pgtest.DB(t)
+		for i := 0; i < 4; i++ {
+			createUser(t, db, fmt.Sprintf("+628120000000%d", i))
+		}
+		require.NoError(t, db.Exec("ANALYZE users").Error)
+
+		page, err := (&PgUserRepository{DB: db, CountLimit: 2}).List(models.UserListParams{Limit: 1})
+		require.NoError(t, err)
+		assert.Len(t, page.Users, 1)
+		assert.True(t, page.TotalIsEstimate)
+		assert.GreaterOrEqual(t, page.Total, 3)
+	})
+
+	// the database is its own replica, the lookups go through the routing
+	t.Run("Replicas", func(t *testing.T) {
+		testUserRepository(t, func(t *testing.T) UserRepository {
+			db := pgtest.DB(t)
+			return &PgUserRepository{DB: db, Replicas: NewReplicas(time.Second, db)}
+		})
+	})
+
+	t.Run("Failing Replica", func(t *testing.T) {
+		db := pgtest.DB(t)
+		replica, err := gorm.Open("postgres", pgtest.DSN())
+		require.NoError(t, err)
+		require.NoError(t, replica.Close())
+		repo := &PgUserRepository{DB: db, Replicas: NewReplicas(0, replica)}
+		user := createUser(t, db, "+6281200000001")
+
+		found, err := repo.FindByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user.PhoneNumber, found.PhoneNumber)
+		_, err = repo.FindByPhone("+6281200000002")
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+	})
+}
+
+// TestPgxUserRepository runs the suite PgUserRepository passes, so the
+// repositories can be swapped
+func TestPgxUserRepository(t *testing.T) {
+	pool, err := NewPgxPool(context.Background(), pgtest.DSN(), PoolConfig{MaxConns: 4})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	testUserRepository(t, func(t *testing.T) UserRepository {
+		pgtest.DB(t)
+		return &PgxUserRepository{Pool: pool, Timeout: 5 * time.Second}
+	})
+
+	t.Run("Anonymize Deletes The Rows Of The User", func(t *testing.T) {
+		testAnonymize(t, func(*gorm.DB) UserRepository { return &PgxUserRepository{Pool: pool, Timeout: 5 * time.Second} })
+	})
+
+	t.Run("Count Estimate", func(t *testing.T) {
+		db := pgtest.DB(t)
+		for i := 0; i < 4; i++ {
+			createUser(t, db, fmt.Sprintf("+628120000000%d", i))
+		}
+		require.NoError(t, db.Exec("ANALYZE users").Error)
+
+		page, err := (&PgxUserRepository{Pool: pool, CountLimit: 2}).List(models.UserListParams{Limit: 1})
+		require.NoError(t, err)
+		assert.Len(t, page.Users, 1)
+		assert.True(t, page.TotalIsEstimate)
+		assert.GreaterOrEqual(t, page.Total, 3)
+	})
+
+	t.Run("Replicas", func(t *testing.T) {
+		replica, err := NewPgxPool(context.Background(), pgtest.DSN(), PoolConfig{MaxConns: 2})
+		require.NoError(t, err)
+		defer replica.Close()
+		testUserRepository(t, func(t *testing.T) UserRepository {
+			pgtest.DB(t)
+			return &PgxUserRepository{Pool: pool, Timeout: 5 * time.Second, Replicas: NewReplicas(time.Second, replica)}
+		})
+	})
+
+	t.Run("Failing Replica", func(t *testing.T) {
+		db := pgtest.DB(t)
+		replica, err := NewPgxPool(context.Background(), pgtest.DSN(), PoolConfig{})
+		require.NoError(t, err)
+		replica.Close()
+		repo := &PgxUserRepository{Pool: pool, Replicas: NewReplicas(0, replica)}
+		user := createUser(t, db, "+6281200000001")
+
+		found, err := repo.FindByPhone(user.PhoneNumber)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, found.ID)
+		_, err = repo.FindByID(user.ID + 1)
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("Reads The Users Of PgUserRepository", func(t *testing.T) {
+		db := pgtest.DB(t)
+		now := time.Now()
+		user := &models.User{PhoneNumber: "+6281200000001", Fullname: "Budi", Password: "hash", SaltToken: "salt",
+			PasswordChangedAt: &now, TOTPSecret: "JBSWY3DPEHPK3PXP", TOTPEnabled: true, TOTPLastStep: 42}
+		require.NoError(t, NewPgUserRepository(db).Create(user))
+
+		found, err := NewPgxUserRepository(pool).FindByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user.PhoneNumber, found.PhoneNumber)
+		assert.Equal(t, user.TOTPSecret, found.TOTPSecret)
+		assert.True(t, found.TOTPEnabled)
+		assert.Equal(t, int64(42), found.TOTPLastStep)
+		require.NotNil(t, found.PasswordChangedAt)
+		assert.WithinDuration(t, user.CreatedAt, found.CreatedAt, time.Millisecond)
+	})
+}
+
+func TestPgSessionRepository(t *testing.T) {
+	db := pgtest.DB(t)
+	repo := NewPgSessionRepository(db)
+	user := createUser(t, db, "+6281200000001")
+	other := createUser(t, db, "+6281200000002")
+	now := time.Now()
+
+	newSession := func(id string, expiresAt time.Time) *models.Session {
+		session := &models.Session{ID: id, UserID: user.ID, UserAgent: "curl", IPAddress: "127.0.0.1", DeviceLabel: "laptop", LastSeenAt: now, ExpiresAt: expiresAt}
+		require.NoError(t, repo.Create(session))
+		return session
+	}
+	newSession("first", now.Add(time.Hour))
+	newSession("expired", now.Add(-time.Hour))
+	newSession("second", now.Add(time.Hour))
+
+	session, err := repo.FindByID("first")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, session.UserID)
+	_, err = repo.FindByID("unknown")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	sessions, err := repo.ListByUser(user.ID, "", 2)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "expired", sessions[0].ID)
+	sessions, err = repo.ListByUser(user.ID, sessions[1].ID, 2)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "second", sessions[0].ID)
+
+	require.NoError(t, repo.Touch("second", now.Add(time.Minute)))
+	sessions, err = repo.ListActiveByUser(user.ID, now)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "second", sessions[0].ID)
+
+	assert.Equal(t, gorm.ErrRecordNotFound, repo.Revoke("first", other.ID))
+	require.NoError(t, repo.Revoke("first", user.ID))
+	assert.Equal(t, gorm.ErrRecordNotFound, repo.Revoke("first", user.ID))
+	sessions, err = repo.ListActiveByUser(user.ID, now)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+
+	require.NoError(t, repo.RevokeAllByUser(user.ID))
+	sessions, err = repo.ListActiveByUser(user.ID, now)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	require.NoError(t, repo.DeleteByUser(user.ID))
+	sessions, err = repo.ListByUser(user.ID, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestPgAuditRepository(t *testing.T) {
+	db := pgtest.DB(t)
+	repo := NewPgAuditRepository(db)
+	user := createUser(t, db, "+6281200000001")
+	for _, eventType := range []string{models.EventRegister, models.EventLogin, models.EventLoginFailed, models.EventLogin} {
+		require.NoError(t, repo.Create(&models.AuditEvent{UserID: user.ID, EventType: eventType, IPAddress: "127.0.0.1", UserAgent: "curl"}))
+	}
+
+	events, err := repo.ListByUser(user.ID, []string{models.EventLogin, models.EventRegister}, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, models.EventRegister, events[0].EventType)
+	assert.Equal(t, models.EventLogin, events[1].EventType)
+
+	events, err = repo.ListByUser(user.ID, []string{models.EventLogin, models.EventRegister}, events[1].ID, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.EventLogin, events[0].EventType)
+
+	require.NoError(t, repo.DeleteByUser(user.ID))
+	events, err = repo.ListByUser(user.ID, []string{models.EventLogin}, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestPgRecoveryCodeRepository(t *testing.T) {
+	db := pgtest.DB(t)
+	repo := NewPgRecoveryCodeRepository(db)
+	user := createUser(t, db, "+6281200000001")
+	other := createUser(t, db, "+6281200000002")
+
+	require.NoError(t, repo.ReplaceByUser(user.ID, []string{"first", "second"}))
+	assert.Equal(t, gorm.ErrRecordNotFound, repo.Use(other.ID, "first"))
+	require.NoError(t, repo.Use(user.ID, "first"))
+	assert.Equal(t, gorm.ErrRecordNotFound, repo.Use(user.ID, "first"))
+
+	require.NoError(t, repo.ReplaceByUser(user.ID, []string{"third"}))
+	assert.Equal(t, gorm.ErrRecordNotFound, repo.Use(user.ID, "second"))
+
+	// a code is only used once however many requests race for it
+	used := concurrently(8, func(int) error { return repo.Use(user.ID, "third") }, func(err error) {
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+	})
+	assert.Equal(t, 1, used)
+
+	require.NoError(t, repo.ReplaceByUser(user.ID, []string{"fourth"}))
+	require.NoError(t, repo.DeleteByUser(user.ID))
+	assert.Equal(t, gorm.ErrRecordNotFound, repo.Use(user.ID, "fourth"))
+}
+
+func TestPgWebAuthnRepository(t *testing.T) {
+	db := pgtest.DB(t)
+	repo := NewPgWebAuthnRepository(db)
+	user := createUser(t, db, "+6281200000001")
+	other := createUser(t, db, "+6281200000002")
+	now := time.Now()
+
+	newCredential := func(credentialID string) *models.WebAuthnCredential {
+		return &models.WebAuthnCredential{UserID: user.ID, CredentialID: []byte(credentialID), PublicKey: []byte("key"), AttestationType: "none", Name: credentialID}
+	}
+	phone := newCredential("phone")
+	require.NoError(t, repo.CreateCredential(phone))
+	require.NoError(t, repo.CreateCredential(newCredential("laptop")))
+	// a credential id is registered once
+	assert.Equal(t, ErrCredentialTaken, repo.CreateCredential(newCredential("phone")))
+
+	require.NoError(t, repo.UpdateCredentialUsage(phone.ID, 7, true, now))
+	credentials, err := repo.ListCredentialsByUser(user.ID)
+	require.NoError(t, err)
+	require.Len(t, credentials, 2)
+	assert.Equal(t, "phone", credentials[0].Name)
+	assert.Equal(t, int64(7), credentials[0].SignCount)
+	assert.True(t, credentials[0].BackupState)
+	assert.NotNil(t, credentials[0].LastUsedAt)
+
+	assert.Equal(t, gorm.ErrRecordNotFound, repo.DeleteCredential(phone.ID, other.ID))
+	require.NoError(t, repo.DeleteCredential(phone.ID, user.ID))
+	assert.Equal(t, gorm.ErrRecordNotFound, repo.DeleteCredential(phone.ID, user.ID))
+
+	require.NoError(t, repo.CreateCeremony(&models.WebAuthnCeremony{ID: "expired", UserID: user.ID, Kind: models.CeremonyLogin, SessionData: "{}", ExpiresAt: now.Add(-time.Minute)}))
+	require.NoError(t, repo.CreateCeremony(&models.WebAuthnCeremony{ID: "current", UserID: user.ID, Kind: models.CeremonyRegistration, SessionData: "{}", ExpiresAt: now.Add(time.Minute)}))
+	_, err = repo.TakeCeremony("expired", now)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	taken := concurrently(8, func(int) error {
+		_, err := repo.TakeCeremony("current", now)
+		return err
+	}, func(err error) {
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+	})
+	assert.Equal(t, 1, taken)
+}
+
+func TestPgOAuthRepository(t *testing.T) {
+	db := pgtest.DB(t)
+	repo := NewPgOAuthRepository(db)
+	user := createUser(t, db, "+6281200000001")
+	now := time.Now()
+
+	require.NoError(t, repo.CreateClient(&models.OAuthClient{ID: "kebun", Name: "Kebun", RedirectURIs: "https://kebun.example/callback"}))
+	require.NoError(t, repo.CreateClient(&models.OAuthClient{ID: "panen", Name: "Panen", RedirectURIs: "https://panen.example/callback"}))
+	client, err := repo.FindClient("kebun")
+	require.NoError(t, err)
+	assert.Equal(t, "Kebun", client.Name)
+	_, err = repo.FindClient("unknown")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	clients, err := repo.ListClients()
+	require.NoError(t, err)
+	assert.Len(t, clients, 2)
+
+	newCode := func(codeHash string, expiresAt time.Time) *models.OAuthAuthorizationCode {
+		return &models.OAuthAuthorizationCode{CodeHash: codeHash, ClientID: "kebun", UserID: user.ID, RedirectURI: "https://kebun.example/callback",
+			Scope: "openid", CodeChallenge: "challenge", AuthTime: now, ExpiresAt: expiresAt}
+	}
+	require.NoError(t, repo.CreateCode(newCode("expired", now.Add(-time.Minute))))
+	require.NoError(t, repo.CreateCode(newCode("current", now.Add(time.Minute))))
+	_, err = repo.TakeCode("expired", now)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	taken := concurrently(8, func(int) error {
+		_, err := repo.TakeCode("current", now)
+		return err
+	}, func(err error) {
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+	})
+	assert.Equal(t, 1, taken)
+
+	_, err = repo.FindConsent(user.ID, "kebun")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	require.NoError(t, repo.SaveConsent(&models.OAuthConsent{UserID: user.ID, ClientID: "kebun", Scope: "openid"}))
+	require.NoError(t, repo.SaveConsent(&models.OAuthConsent{UserID: user.ID, ClientID: "kebun", Scope: "openid profile"}))
+	consent, err := repo.FindConsent(user.ID, "kebun")
+	require.NoError(t, err)
+	assert.Equal(t, "openid profile", consent.Scope)
+	require.NoError(t, repo.SaveConsent(&models.OAuthConsent{UserID: user.ID, ClientID: "panen", Scope: "openid"}))
+	consents, err := repo.ListConsentsByUser(user.ID)
+	require.NoError(t, err)
+	require.Len(t, consents, 2)
+	assert.Equal(t, "kebun", consents[0].ClientID)
+	require.NoError(t, repo.DeleteConsent(user.ID, "panen"))
+	assert.Equal(t, gorm.ErrRecordNotFound, repo.DeleteConsent(user.ID, "panen"))
+
+	// deleting a client takes its codes and consents along
+	require.NoError(t, repo.CreateCode(newCode("pending", now.Add(time.Minute))))
+	require.NoError(t, repo.DeleteClient("kebun"))
+	assert.Equal(t, gorm.ErrRecordNotFound, repo.DeleteClient("kebun"))
+	_, err = repo.FindConsent(user.ID, "kebun")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	_, err = repo.TakeCode("pending", now)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestPgIdentityRepository(t *testing.T) {
+	db := pgtest.DB(t)
+	repo := NewPgIdentityRepository(db)
+	user := createUser(t, db, "+6281200000001")
+	other := createUser(t, db, "+6281200000002")
+	now := time.Now()
+
+	// a provider account links to a single user even when both try at once
+	linked := concurrently(2, func(i int) error {
+		userID := []int{user.ID, other.ID}[i]
+		return repo.Create(&models.UserIdentity{UserID: userID, Provider: "google", Subject: "budi"})
+	}, func(err error) {
+		assert.Error(t, err)
+	})
+	assert.Equal(t, 1, linked)
+	require.NoError(t, repo.Create(&models.UserIdentity{UserID: user.ID, Provider: "github", Subject: "budi"}))
+
+	identity, err := repo.FindByProviderSubject("github", "budi")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, identity.UserID)
+	_, err = repo.FindByProviderSubject("github", "siti")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	identities, err := repo.ListByUser(user.ID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, identities)
+	assert.Equal(t, gorm.ErrRecordNotFound, repo.Delete(user.ID, "facebook"))
+	require.NoError(t, repo.Delete(user.ID, "github"))
+
+	newState := func(id string, expiresAt time.Time) *models.IdentityLoginState {
+		return &models.IdentityLoginState{ID: id, Provider: "google", Nonce: "nonce", CodeVerifier: "verifier", ExpiresAt: expiresAt}
+	}
+	require.NoError(t, repo.CreateState(newState("expired", now.Add(-time.Minute))))
+	require.NoError(t, repo.CreateState(newState("current", now.Add(time.Minute))))
+	_, err = repo.TakeState("expired", now)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	taken := concurrently(8, func(int) error {
+		_, err := repo.TakeState("current", now)
+		return err
+	}, func(err error) {
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+	})
+	assert.Equal(t, 1, taken)
+}
+
+func TestPgRateLimitRepository(t *testing.T) {
+	db := pgtest.DB(t)
+	repo := NewPgRateLimitRepository(db)
+	limit := ratelimit.Limit{Burst: 3, Interval: time.Minute}
+	now := time.Now()
+
+	allowed := concurrently(5, func(int) error {
+		result, err := repo.Take("login:127.0.0.1", limit, now)
+		if err == nil && !result.Allowed {
+			return fmt.Errorf("retry after %s", result.RetryAfter)
+		}
+		return err
+	}, func(err error) {
+		assert.Contains(t, err.Error(), "retry after")
+	})
+	assert.Equal(t, 3, allowed)
+
+	result, err := repo.Take("login:127.0.0.1", limit, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	require.NoError(t, repo.DeleteIdle(now.Add(2*time.Minute)))
+	var buckets int
+	require.NoError(t, db.Model(&models.RateLimitBucket{}).Count(&buckets).Error)
+	assert.Zero(t, buckets)
+}
+
+func TestPgPasswordHistoryRepository(t *testing.T) {
+	db := pgtest.DB(t)
+	repo := NewPgPasswordHistoryRepository(db)
+	user := createUser(t, db, "+6281200000001")
+	for _, password := range []string{"first", "second", "third", "fourth"} {
+		require.NoError(t, repo.Add(&models.PasswordHistory{UserID: user.ID, Password: password, SaltToken: "salt"}, 3))
+	}
+
+	entries, err := repo.ListByUser(user.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "fourth", entries[0].Password)
+	assert.Equal(t, "second", entries[2].Password)
+
+	entries, err = repo.ListByUser(user.ID, 1)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
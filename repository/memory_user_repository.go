@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/jinzhu/gorm"
+)
+
+// MemoryUserRepository keeps the users in memory for tests and local runs, it
+// fails the way PgUserRepository does: unknown users are gorm.ErrRecordNotFound
+// and phone numbers are unique among active users. Anonymize only erases the
+// user, the password history, identities, recovery codes, passkeys and oauth
+// consents live in other repositories
+type MemoryUserRepository struct {
+	mu     sync.Mutex
+	users  map[int]models.User
+	lastID int
+}
+
+var _ UserRepository = (*MemoryUserRepository)(nil)
+
+// Create creates a new user, it fails with ErrPhoneTaken when an active user
+// already has the phone number
+func (r *MemoryUserRepository) Create(user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.phoneTaken(user.PhoneNumber, 0) {
+		return ErrPhoneTaken
+	}
+	if user.ID == 0 {
+		user.ID = r.lastID + 1
+	}
+	if user.ID > r.lastID {
+		r.lastID = user.ID
+	}
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = now
+	}
+	// the defaults of the columns
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	if user.Status == "" {
+		user.Status = models.StatusActive
+	}
+	r.users[user.ID] = *user
+	return nil
+}
+
+// phoneTaken reports whether an active user other than id has the phone number
+func (r *MemoryUserRepository) phoneTaken(phone string, id int) bool {
+	for _, user := range r.users {
+		if user.ID != id && user.DeletedAt == nil && user.PhoneNumber == phone {
+			return true
+		}
+	}
+	return false
+}
+
+// find finds the first user matching the condition in id order
+func (r *MemoryUserRepository) find(match func(user *models.User) bool) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := r.sorted(match)
+	if len(users) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &users[0], nil
+}
+
+// sorted lists the users matching the condition in id order
+func (r *MemoryUserRepository) sorted(match func(user *models.User) bool) []models.User {
+	users := []models.User{}
+	for _, user := range r.users {
+		if match(&user) {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
+
+// FindByPhone finds a user by phone number
+func (r *MemoryUserRepository) FindByPhone(phone string) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return isActive(user) && user.PhoneNumber == phone })
+}
+
+// FindByID finds a user by id
+func (r *MemoryUserRepository) FindByID(id int) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return isActive(user) && user.ID == id })
+}
+
+// FindProfileByID finds an active user by id without its credentials
+func (r *MemoryUserRepository) FindProfileByID(id int) (*models.User, error) {
+	user, err := r.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return withoutCredentials(user), nil
+}
+
+// FindByIDForUpdate finds a user by id, there are no replicas in memory
+func (r *MemoryUserRepository) FindByIDForUpdate(id int) (*models.User, error) {
+	return r.FindByID(id)
+}
+
+// Update updates the columns of an active user, it fails with ErrPhoneTaken
+// when the new phone number belongs to another active user
+func (r *MemoryUserRepository) Update(user *models.User, columns ...string) error {
+	values, err := userUpdates(user, columns)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[user.ID]
+	if !ok || !isActive(&stored) {
+		return gorm.ErrRecordNotFound
+	}
+	if slices.Contains(columns, ColumnPhoneNumber) && r.phoneTaken(user.PhoneNumber, user.ID) {
+		return ErrPhoneTaken
+	}
+	for i, column := range columns {
+		reflect.ValueOf(userFields[column](&stored)).Elem().Set(reflect.ValueOf(values[i]))
+	}
+	stored.UpdatedAt = time.Now()
+	user.UpdatedAt = stored.UpdatedAt
+	r.users[user.ID] = stored
+	return nil
+}
+
+// update changes the user when it matches the condition, it fails with
+// record not found when it does not
+func (r *MemoryUserRepository) update(id int, match func(user *models.User) bool, change func(user *models.User) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || !match(&user) {
+		return gorm.ErrRecordNotFound
+	}
+	if err := change(&user); err != nil {
+		return err
+	}
+	user.UpdatedAt = time.Now()
+	r.users[id] = user
+	return nil
+}
+
+func isActive(user *models.User) bool { return user.DeletedAt == nil }
+
+func isDeleted(user *models.User) bool { return user.DeletedAt != nil }
+
+// isPendingDeletion reports whether the user is deleted and scheduled for purging
+func isPendingDeletion(user *models.User) bool { return user.DeletedAt != nil && user.PurgeAt != nil }
+
+// isRestorable reports whether the user is pending deletion and still in its grace period
+func isRestorable(user *models.User) bool {
+	return isPendingDeletion(user) && user.PurgeAt.After(time.Now())
+}
+
+// Delete soft deletes a user
+func (r *MemoryUserRepository) Delete(id int) error {
+	return r.update(id, isActive, func(user *models.User) error {
+		now := time.Now()
+		user.DeletedAt = &now
+		return nil
+	})
+}
+
+// ScheduleDeletion soft deletes a user, revokes its tokens and schedules the
+// anonymization of its personal data at purgeAt
+func (r *MemoryUserRepository) ScheduleDeletion(id int, purgeAt time.Time) error {
+	return r.update(id, isActive, func(user *models.User) error {
+		now := time.Now()
+		user.DeletedAt, user.PurgeAt, user.TokensRevokedAt = &now, &purgeAt, &now
+		return nil
+	})
+}
+
+// FindDeletedByPhone finds a deleted user by phone number that is still in its grace period
+func (r *MemoryUserRepository) FindDeletedByPhone(phone string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := r.sorted(func(user *models.User) bool { return isRestorable(user) && user.PhoneNumber == phone })
+	if len(users) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	// the latest deletion, like the postgres repository
+	sort.SliceStable(users, func(i, j int) bool { return users[i].DeletedAt.After(*users[j].DeletedAt) })
+	return &users[0], nil
+}
+
+// Restore cancels a scheduled deletion and makes the user visible again, it
+// fails with ErrPhoneTaken when the phone number was registered meanwhile and
+// with record not found once the grace period is over
+func (r *MemoryUserRepository) Restore(id int) error {
+	return r.update(id, isRestorable, func(user *models.User) error {
+		if r.phoneTaken(user.PhoneNumber, user.ID) {
+			return ErrPhoneTaken
+		}
+		user.DeletedAt, user.PurgeAt = nil, nil
+		return nil
+	})
+}
+
+// FindPurgeable finds at most limit deleted users whose grace period ended before the given time
+func (r *MemoryUserRepository) FindPurgeable(before time.Time, limit int) ([]models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := r.sorted(func(user *models.User) bool { return isPendingDeletion(user) && !user.PurgeAt.After(before) })
+	sort.SliceStable(users, func(i, j int) bool { return users[i].PurgeAt.Before(*users[j].PurgeAt) })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+// Anonymize permanently erases the personal data of a deleted user
+func (r *MemoryUserRepository) Anonymize(id int) error {
+	return r.update(id, isDeleted, func(user *models.User) error {
+		user.PhoneNumber, user.Fullname, user.Password, user.SaltToken, user.TOTPSecret = "", "", "", "", ""
+		user.PurgeAt = nil
+		return nil
+	})
+}
+
+// UseTOTPStep marks the TOTP time step as used by the user, it fails with
+// record not found when the step or a later one was already used
+func (r *MemoryUserRepository) UseTOTPStep(id int, step int64) error {
+	return r.update(id, func(user *models.User) bool { return user.TOTPLastStep < step }, func(user *models.User) error {
+		user.TOTPLastStep = step
+		return nil
+	})
+}
+
+// List lists a page of users matching the params, ordered by created_at then
+// id with the cursors of PgUserRepository. The total is always exact
+func (r *MemoryUserRepository) List(params models.UserListParams) (*models.UserPage, error) {
+	if params.Limit <= 0 {
+		params.Limit = defaultListLimit
+	}
+	var after *models.User
+	if params.Cursor != "" {
+		createdAt, id, err := decodeUserCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &models.User{ID: id, CreatedAt: createdAt}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := r.sorted(func(user *models.User) bool { return isActive(user) && matchesUserList(user, params) })
+	sort.SliceStable(users, func(i, j int) bool { return userListBefore(&users[i], &users[j]) })
+
+	page := &models.UserPage{Users: []models.User{}, Total: len(users)}
+	for i := range users {
+		if after != nil && !userListBefore(after, &users[i]) {
+			continue
+		}
+		if len(page.Users) == params.Limit {
+			page.NextCursor = encodeUserCursor(&page.Users[params.Limit-1])
+			break
+		}
+		page.Users = append(page.Users, users[i])
+	}
+	return page, nil
+}
+
+// matchesUserList is the filter of List, the counterpart of userListFilter
+func matchesUserList(user *models.User, params models.UserListParams) bool {
+	return strings.HasPrefix(strings.ToLower(user.Fullname), strings.ToLower(params.NamePrefix)) &&
+		strings.HasPrefix(user.PhoneNumber, params.PhonePrefix) &&
+		(params.Status == "" || user.Status == params.Status) &&
+		(params.CreatedFrom == nil || !user.CreatedAt.Before(*params.CreatedFrom)) &&
+		(params.CreatedTo == nil || user.CreatedAt.Before(*params.CreatedTo))
+}
+
+// userListBefore reports whether a comes before b in the created_at, id ordering
+func userListBefore(a, b *models.User) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// NewMemoryUserRepository creates an empty in-memory user repository
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{users: map[int]models.User{}}
+}
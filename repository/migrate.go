@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/jinzhu/gorm"
+)
+
+// Migrate keeps the schema in line with database.sql for databases created
+// before the audit timestamps and soft delete were introduced
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models.User{}, &models.AuditEvent{}, &models.Session{}, &models.RecoveryCode{}, &models.WebAuthnCredential{}, &models.WebAuthnCeremony{},
+		&models.OAuthClient{}, &models.OAuthAuthorizationCode{}, &models.OAuthConsent{},
+		&models.UserIdentity{}, &models.IdentityLoginState{},
+		&models.RateLimitBucket{}, &models.PasswordHistory{}).Error; err != nil {
+		return err
+	}
+	// phone number is unique among active users only, replace the old unique constraint with a partial index
+	if err := db.Exec("ALTER TABLE users DROP CONSTRAINT IF EXISTS users_phone_number_key").Error; err != nil {
+		return err
+	}
+	statements := []string{
+		"CREATE UNIQUE INDEX IF NOT EXISTS users_phone_number_active_key ON users (phone_number) WHERE deleted_at IS NULL",
+		// indexes backing the keyset pagination and prefix filters of the user listing
+		"CREATE INDEX IF NOT EXISTS users_created_at_id_idx ON users (created_at, id) WHERE deleted_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS users_phone_number_pattern_idx ON users (phone_number varchar_pattern_ops) WHERE deleted_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS users_fullname_lower_pattern_idx ON users (lower(fullname) text_pattern_ops) WHERE deleted_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS users_purge_at_idx ON users (purge_at) WHERE purge_at IS NOT NULL",
+		"CREATE INDEX IF NOT EXISTS audit_events_user_id_id_idx ON audit_events (user_id, id)",
+		"CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id)",
+		"CREATE INDEX IF NOT EXISTS recovery_codes_user_id_idx ON recovery_codes (user_id)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS webauthn_credentials_credential_id_key ON webauthn_credentials (credential_id)",
+		"CREATE INDEX IF NOT EXISTS webauthn_credentials_user_id_idx ON webauthn_credentials (user_id)",
+		"CREATE INDEX IF NOT EXISTS webauthn_ceremonies_expires_at_idx ON webauthn_ceremonies (expires_at)",
+		"CREATE INDEX IF NOT EXISTS oauth_authorization_codes_expires_at_idx ON oauth_authorization_codes (expires_at)",
+		"CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities (user_id)",
+		"CREATE INDEX IF NOT EXISTS identity_login_states_expires_at_idx ON identity_login_states (expires_at)",
+		"CREATE INDEX IF NOT EXISTS password_history_user_id_id_idx ON password_history (user_id, id)",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
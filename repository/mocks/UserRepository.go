@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	time "time"
+
 	models "github.com/SawitProRecruitment/UserService/models"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -12,6 +14,24 @@ type UserRepository struct {
 	mock.Mock
 }
 
+// Anonymize provides a mock function with given fields: id
+func (_m *UserRepository) Anonymize(id int) error {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Anonymize")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Create provides a mock function with given fields: user
 func (_m *UserRepository) Create(user *models.User) error {
 	ret := _m.Called(user)
@@ -30,6 +50,24 @@ func (_m *UserRepository) Create(user *models.User) error {
 	return r0
 }
 
+// Delete provides a mock function with given fields: id
+func (_m *UserRepository) Delete(id int) error {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // FindByID provides a mock function with given fields: id
 func (_m *UserRepository) FindByID(id int) (*models.User, error) {
 	ret := _m.Called(id)
@@ -60,6 +98,36 @@ func (_m *UserRepository) FindByID(id int) (*models.User, error) {
 	return r0, r1
 }
 
+// FindByIDForUpdate provides a mock function with given fields: id
+func (_m *UserRepository) FindByIDForUpdate(id int) (*models.User, error) {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindByIDForUpdate")
+	}
+
+	var r0 *models.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int) (*models.User, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(int) *models.User); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindByPhone provides a mock function with given fields: phone
 func (_m *UserRepository) FindByPhone(phone string) (*models.User, error) {
 	ret := _m.Called(phone)
@@ -90,17 +158,198 @@ func (_m *UserRepository) FindByPhone(phone string) (*models.User, error) {
 	return r0, r1
 }
 
-// Update provides a mock function with given fields: user
-func (_m *UserRepository) Update(user *models.User) error {
-	ret := _m.Called(user)
+// FindDeletedByPhone provides a mock function with given fields: phone
+func (_m *UserRepository) FindDeletedByPhone(phone string) (*models.User, error) {
+	ret := _m.Called(phone)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindDeletedByPhone")
+	}
+
+	var r0 *models.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*models.User, error)); ok {
+		return rf(phone)
+	}
+	if rf, ok := ret.Get(0).(func(string) *models.User); ok {
+		r0 = rf(phone)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(phone)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindProfileByID provides a mock function with given fields: id
+func (_m *UserRepository) FindProfileByID(id int) (*models.User, error) {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindProfileByID")
+	}
+
+	var r0 *models.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int) (*models.User, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(int) *models.User); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindPurgeable provides a mock function with given fields: before, limit
+func (_m *UserRepository) FindPurgeable(before time.Time, limit int) ([]models.User, error) {
+	ret := _m.Called(before, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindPurgeable")
+	}
+
+	var r0 []models.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time, int) ([]models.User, error)); ok {
+		return rf(before, limit)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time, int) []models.User); ok {
+		r0 = rf(before, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time, int) error); ok {
+		r1 = rf(before, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: params
+func (_m *UserRepository) List(params models.UserListParams) (*models.UserPage, error) {
+	ret := _m.Called(params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 *models.UserPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(models.UserListParams) (*models.UserPage, error)); ok {
+		return rf(params)
+	}
+	if rf, ok := ret.Get(0).(func(models.UserListParams) *models.UserPage); ok {
+		r0 = rf(params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.UserPage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(models.UserListParams) error); ok {
+		r1 = rf(params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Restore provides a mock function with given fields: id
+func (_m *UserRepository) Restore(id int) error {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Restore")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ScheduleDeletion provides a mock function with given fields: id, purgeAt
+func (_m *UserRepository) ScheduleDeletion(id int, purgeAt time.Time) error {
+	ret := _m.Called(id, purgeAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ScheduleDeletion")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, time.Time) error); ok {
+		r0 = rf(id, purgeAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Update provides a mock function with given fields: user, columns
+func (_m *UserRepository) Update(user *models.User, columns ...string) error {
+	_va := make([]interface{}, len(columns))
+	for _i := range columns {
+		_va[_i] = columns[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, user)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Update")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*models.User) error); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(*models.User, ...string) error); ok {
+		r0 = rf(user, columns...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UseTOTPStep provides a mock function with given fields: id, step
+func (_m *UserRepository) UseTOTPStep(id int, step int64) error {
+	ret := _m.Called(id, step)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UseTOTPStep")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, int64) error); ok {
+		r0 = rf(id, step)
 	} else {
 		r0 = ret.Error(0)
 	}
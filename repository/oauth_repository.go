@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/jinzhu/gorm"
+)
+
+type PgOAuthRepository struct {
+	DB *gorm.DB
+}
+
+// OAuthRepository is an interface for the OpenID Connect clients, authorization codes and consents repository
+type OAuthRepository interface {
+	CreateClient(client *models.OAuthClient) error
+	FindClient(id string) (*models.OAuthClient, error)
+	ListClients() ([]models.OAuthClient, error)
+	DeleteClient(id string) error
+	CreateCode(code *models.OAuthAuthorizationCode) error
+	TakeCode(codeHash string, now time.Time) (*models.OAuthAuthorizationCode, error)
+	FindConsent(userID int, clientID string) (*models.OAuthConsent, error)
+	ListConsentsByUser(userID int) ([]models.OAuthConsent, error)
+	SaveConsent(consent *models.OAuthConsent) error
+	DeleteConsent(userID int, clientID string) error
+}
+
+// CreateClient registers a new client
+func (r *PgOAuthRepository) CreateClient(client *models.OAuthClient) error {
+	return r.DB.Create(client).Error
+}
+
+// FindClient finds a client by id
+func (r *PgOAuthRepository) FindClient(id string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	err := r.DB.Where("id = ?", id).First(&client).Error
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// ListClients lists every registered client, oldest first
+func (r *PgOAuthRepository) ListClients() ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := r.DB.Order("created_at").Find(&clients).Error
+	if err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// DeleteClient deletes a client along with its pending codes and the
+// consents given to it, it fails with record not found when there is no such client
+func (r *PgOAuthRepository) DeleteClient(id string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("client_id = ?", id).Delete(&models.OAuthAuthorizationCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("client_id = ?", id).Delete(&models.OAuthConsent{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", id).Delete(&models.OAuthClient{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// CreateCode stores a new authorization code, expired codes that were never
+// exchanged are cleaned up along the way
+func (r *PgOAuthRepository) CreateCode(code *models.OAuthAuthorizationCode) error {
+	if err := r.DB.Where("expires_at < ?", time.Now()).Delete(&models.OAuthAuthorizationCode{}).Error; err != nil {
+		return err
+	}
+	return r.DB.Create(code).Error
+}
+
+// TakeCode deletes and returns an unexpired authorization code, it fails with
+// record not found when the code does not exist, expired or was already exchanged
+func (r *PgOAuthRepository) TakeCode(codeHash string, now time.Time) (*models.OAuthAuthorizationCode, error) {
+	var code models.OAuthAuthorizationCode
+	err := r.DB.Where("code_hash = ? AND expires_at > ?", codeHash, now).First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	// only the request that deletes the row gets to exchange the code
+	result := r.DB.Where("code_hash = ?", codeHash).Delete(&models.OAuthAuthorizationCode{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &code, nil
+}
+
+// FindConsent finds the consent a user gave to a client
+func (r *PgOAuthRepository) FindConsent(userID int, clientID string) (*models.OAuthConsent, error) {
+	var consent models.OAuthConsent
+	err := r.DB.Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// ListConsentsByUser lists the consents a user gave, oldest first
+func (r *PgOAuthRepository) ListConsentsByUser(userID int) ([]models.OAuthConsent, error) {
+	var consents []models.OAuthConsent
+	err := r.DB.Where("user_id = ?", userID).Order("created_at").Find(&consents).Error
+	if err != nil {
+		return nil, err
+	}
+	return consents, nil
+}
+
+// SaveConsent creates or replaces the consent a user gave to a client
+func (r *PgOAuthRepository) SaveConsent(consent *models.OAuthConsent) error {
+	return r.DB.Save(consent).Error
+}
+
+// DeleteConsent withdraws the consent a user gave to a client, it fails with
+// record not found when there is no such consent
+func (r *PgOAuthRepository) DeleteConsent(userID int, clientID string) error {
+	result := r.DB.Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&models.OAuthConsent{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// NewPgOAuthRepository creates new postgress oauth repository
+func NewPgOAuthRepository(db *gorm.DB) *PgOAuthRepository {
+	return &PgOAuthRepository{DB: db}
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/jinzhu/gorm"
+)
+
+type PgPasswordHistoryRepository struct {
+	DB *gorm.DB
+}
+
+// PasswordHistoryRepository is an interface for password history repository
+type PasswordHistoryRepository interface {
+	Add(entry *models.PasswordHistory, keep int) error
+	ListByUser(userID int, limit int) ([]models.PasswordHistory, error)
+}
+
+// Add stores a password the user has set and deletes the older ones, only the
+// newest keep passwords are kept
+func (r *PgPasswordHistoryRepository) Add(entry *models.PasswordHistory, keep int) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`DELETE FROM password_history WHERE user_id = ? AND id NOT IN (
+			SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?)`,
+			entry.UserID, entry.UserID, keep).Error
+	})
+}
+
+// ListByUser lists the newest passwords of a user, newest first
+func (r *PgPasswordHistoryRepository) ListByUser(userID int, limit int) ([]models.PasswordHistory, error) {
+	var entries []models.PasswordHistory
+	err := r.DB.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// NewPgPasswordHistoryRepository creates new postgress password history repository
+func NewPgPasswordHistoryRepository(db *gorm.DB) *PgPasswordHistoryRepository {
+	return &PgPasswordHistoryRepository{DB: db}
+}
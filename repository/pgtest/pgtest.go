@@ -0,0 +1,199 @@
+// Package pgtest provides a Postgres database for the integration tests, it
+// uses the database of TEST_DATABASE_URL or starts a throwaway server with the
+// initdb and pg_ctl binaries of the machine
+package pgtest
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"github.com/lib/pq"
+)
+
+// the database of the tests of the package, set by Main
+var (
+	dsn string
+	db  *gorm.DB
+)
+
+// Main runs the tests of a package against Postgres and exits, it is called
+// from TestMain. A database without a users table gets the schema of
+// database.sql and migrate runs on top of it like it does at startup
+func Main(m *testing.M, migrate func(db *gorm.DB) error) {
+	os.Exit(run(m, migrate))
+}
+
+func run(m *testing.M, migrate func(db *gorm.DB) error) int {
+	dsn = os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		server, err := start()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pgtest: %v, set TEST_DATABASE_URL or install postgres\n", err)
+			return 1
+		}
+		defer server.stop()
+		dsn = server.dsn
+	}
+
+	var err error
+	db, err = gorm.Open("postgres", dsn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgtest: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	if err := createSchema(migrate); err != nil {
+		fmt.Fprintf(os.Stderr, "pgtest: %v\n", err)
+		return 1
+	}
+	return m.Run()
+}
+
+// createSchema loads database.sql into an empty database and migrates it
+func createSchema(migrate func(db *gorm.DB) error) error {
+	if !db.HasTable("users") {
+		_, file, _, _ := runtime.Caller(0)
+		schema, err := os.ReadFile(filepath.Join(filepath.Dir(file), "..", "..", "database.sql"))
+		if err != nil {
+			return err
+		}
+		if err := db.Exec(string(schema)).Error; err != nil {
+			return fmt.Errorf("database.sql: %w", err)
+		}
+	}
+	return migrate(db)
+}
+
+// DB empties every table and returns the connection of the tests, so each
+// test starts from an empty database. Tests using it must not run in parallel
+func DB(t testing.TB) *gorm.DB {
+	t.Helper()
+	if db == nil {
+		t.Fatal("pgtest: the tests must be run by pgtest.Main")
+	}
+	rows, err := db.Raw("SELECT tablename FROM pg_tables WHERE schemaname = current_schema()").Rows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, pq.QuoteIdentifier(table))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) > 0 {
+		if err := db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// DSN is the connection string of the database of the tests, for the
+// repositories opening connections of their own. Call DB first to empty it
+func DSN() string {
+	return dsn
+}
+
+// server is a Postgres server in a temporary directory, it only listens on
+// the loopback and trusts every connection
+type server struct {
+	pgCtl string
+	dir   string
+	dsn   string
+}
+
+func start() (*server, error) {
+	initdb, err := lookPath("initdb")
+	if err != nil {
+		return nil, err
+	}
+	pgCtl, err := lookPath("pg_ctl")
+	if err != nil {
+		return nil, err
+	}
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "pgtest")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &server{pgCtl: pgCtl, dir: dir}
+	output, err := exec.Command(initdb, "-D", s.data(), "-U", "postgres", "-A", "trust", "--no-sync").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("initdb: %v: %s", err, output)
+	}
+	// fsync is off, the data is thrown away anyway
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1 -F", port, dir)
+	output, err = exec.Command(pgCtl, "-D", s.data(), "-o", options, "-l", filepath.Join(dir, "postgres.log"), "-w", "start").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("pg_ctl start: %v: %s", err, output)
+	}
+	s.dsn = fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+	return s, nil
+}
+
+func (s *server) data() string {
+	return filepath.Join(s.dir, "data")
+}
+
+func (s *server) stop() {
+	if output, err := exec.Command(s.pgCtl, "-D", s.data(), "-m", "immediate", "-w", "stop").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "pgtest: pg_ctl stop: %v: %s\n", err, output)
+	}
+	os.RemoveAll(s.dir)
+}
+
+// lookPath finds a postgres binary in PG_BIN, on PATH or where Debian
+// installs it, the newest version first
+func lookPath(name string) (string, error) {
+	if dir := os.Getenv("PG_BIN"); dir != "" {
+		return exec.LookPath(filepath.Join(dir, name))
+	}
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+	paths, _ := filepath.Glob(filepath.Join("/usr/lib/postgresql", "*", "bin", name))
+	if len(paths) == 0 {
+		return "", fmt.Errorf("%s not found", name)
+	}
+	sort.Slice(paths, func(i, j int) bool { return majorVersion(paths[i]) > majorVersion(paths[j]) })
+	return paths[0], nil
+}
+
+// majorVersion is the version directory of a binary under /usr/lib/postgresql
+func majorVersion(path string) int {
+	var version int
+	fmt.Sscan(filepath.Base(filepath.Dir(filepath.Dir(path))), &version)
+	return version
+}
+
+// freePort asks the kernel for a port nobody listens on
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
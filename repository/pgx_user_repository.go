@@ -0,0 +1,426 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PgxUserRepository is the UserRepository on a pgx pool, it replaces
+// PgUserRepository and fails the same way: ErrNotFound when no user matches
+// and ErrPhoneTaken when an active user already has the phone number
+type PgxUserRepository struct {
+	Pool *pgxpool.Pool
+	// CountLimit is how many matching users List counts exactly before it estimates the total
+	CountLimit int
+	// Timeout bounds every call, calls are only bounded by the pool when it is zero
+	Timeout time.Duration
+	// Replicas serve FindByID and FindByPhone when set
+	Replicas *Replicas[*pgxpool.Pool]
+}
+
+var _ UserRepository = (*PgxUserRepository)(nil)
+
+// userColumns are the columns scanned by scanUser, in its order
+const userColumns = `id, phone_number, fullname, password, salt_token, role, status, password_reset_required,
+	created_at, updated_at, deleted_at, purge_at, tokens_revoked_at, password_changed_at,
+	totp_secret, totp_enabled, totp_last_step`
+
+const (
+	createUserSQL = `INSERT INTO users (phone_number, fullname, password, salt_token, role, status, password_reset_required,
+	created_at, updated_at, deleted_at, purge_at, tokens_revoked_at, password_changed_at, totp_secret, totp_enabled, totp_last_step)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING id`
+	findUserByPhoneSQL = `SELECT ` + userColumns + ` FROM users
+	WHERE phone_number = $1 AND deleted_at IS NULL ORDER BY id LIMIT 1`
+	findUserByIDSQL = `SELECT ` + userColumns + ` FROM users
+	WHERE id = $1 AND deleted_at IS NULL`
+	deleteUserSQL = `UPDATE users SET deleted_at = $2
+	WHERE id = $1 AND deleted_at IS NULL`
+	scheduleUserDeletionSQL = `UPDATE users SET deleted_at = $2, purge_at = $3, tokens_revoked_at = $2, updated_at = $2
+	WHERE id = $1 AND deleted_at IS NULL`
+	findDeletedUserByPhoneSQL = `SELECT ` + userColumns + ` FROM users
+	WHERE phone_number = $1 AND deleted_at IS NOT NULL AND purge_at > $2 ORDER BY deleted_at DESC LIMIT 1`
+	restoreUserSQL = `UPDATE users SET deleted_at = NULL, purge_at = NULL, updated_at = $2
+	WHERE id = $1 AND deleted_at IS NOT NULL AND purge_at > $2`
+	findPurgeableUsersSQL = `SELECT ` + userColumns + ` FROM users
+	WHERE deleted_at IS NOT NULL AND purge_at IS NOT NULL AND purge_at <= $1 ORDER BY purge_at LIMIT $2`
+	anonymizeUserSQL = `UPDATE users SET phone_number = '', fullname = '', password = '', salt_token = '', totp_secret = '',
+	purge_at = NULL, updated_at = $2
+	WHERE id = $1 AND deleted_at IS NOT NULL`
+	deleteUserPasswordHistorySQL = `DELETE FROM password_history WHERE user_id = $1`
+	deleteUserRecoveryCodesSQL   = `DELETE FROM recovery_codes WHERE user_id = $1`
+	deleteUserPasskeysSQL        = `DELETE FROM webauthn_credentials WHERE user_id = $1`
+	deleteUserCeremoniesSQL      = `DELETE FROM webauthn_ceremonies WHERE user_id = $1`
+	deleteUserConsentsSQL        = `DELETE FROM oauth_consents WHERE user_id = $1`
+	deleteUserCodesSQL           = `DELETE FROM oauth_authorization_codes WHERE user_id = $1`
+	deleteUserIdentitiesSQL      = `DELETE FROM user_identities WHERE user_id = $1`
+	useTOTPStepSQL               = `UPDATE users SET totp_last_step = $2
+	WHERE id = $1 AND totp_last_step < $2`
+)
+
+// context bounds a call by Timeout
+func (r *PgxUserRepository) context() (context.Context, context.CancelFunc) {
+	if r.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), r.Timeout)
+}
+
+// Create creates a new user, the id is assigned by the database. It fails
+// with ErrPhoneTaken when an active user already has the phone number
+func (r *PgxUserRepository) Create(user *models.User) error {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = now
+	}
+	// the defaults of the columns
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	if user.Status == "" {
+		user.Status = models.StatusActive
+	}
+	err := r.Pool.QueryRow(ctx, createUserSQL,
+		user.PhoneNumber, user.Fullname, user.Password, user.SaltToken, user.Role, user.Status, user.PasswordResetRequired,
+		user.CreatedAt, user.UpdatedAt, user.DeletedAt, user.PurgeAt, user.TokensRevokedAt, user.PasswordChangedAt,
+		user.TOTPSecret, user.TOTPEnabled, user.TOTPLastStep,
+	).Scan(&user.ID)
+	if err != nil {
+		return pgxError(err)
+	}
+	r.Replicas.stick(userKey(user.ID), phoneKey(user.PhoneNumber))
+	return nil
+}
+
+// FindByPhone finds a user by phone number
+func (r *PgxUserRepository) FindByPhone(phone string) (*models.User, error) {
+	return r.findReplicatedUser(phoneKey(phone), findUserByPhoneSQL, phone)
+}
+
+// FindByID finds a user by id
+func (r *PgxUserRepository) FindByID(id int) (*models.User, error) {
+	return r.findReplicatedUser(userKey(id), findUserByIDSQL, id)
+}
+
+// FindByIDForUpdate finds a user by id on the primary
+func (r *PgxUserRepository) FindByIDForUpdate(id int) (*models.User, error) {
+	return r.findUser(findUserByIDSQL, id)
+}
+
+// FindProfileByID finds a user by id without its credentials
+func (r *PgxUserRepository) FindProfileByID(id int) (*models.User, error) {
+	user, err := r.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return withoutCredentials(user), nil
+}
+
+// FindDeletedByPhone finds a deleted user by phone number that is still in its grace period
+func (r *PgxUserRepository) FindDeletedByPhone(phone string) (*models.User, error) {
+	return r.findUser(findDeletedUserByPhoneSQL, phone, time.Now())
+}
+
+func (r *PgxUserRepository) findUser(sql string, args ...interface{}) (*models.User, error) {
+	return r.findUserOn(r.Pool, sql, args...)
+}
+
+// findReplicatedUser finds a user on the Replicas, key is the one the writes of the user stick
+func (r *PgxUserRepository) findReplicatedUser(key, sql string, args ...interface{}) (*models.User, error) {
+	var user *models.User
+	err := r.Replicas.read(r.Pool, func(pool *pgxpool.Pool) (err error) {
+		user, err = r.findUserOn(pool, sql, args...)
+		return err
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *PgxUserRepository) findUserOn(pool *pgxpool.Pool, sql string, args ...interface{}) (*models.User, error) {
+	ctx, cancel := r.context()
+	defer cancel()
+	user, err := scanUser(pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		return nil, pgxError(err)
+	}
+	return user, nil
+}
+
+// Update updates the columns of an active user, it fails with ErrPhoneTaken
+// when the new phone number belongs to another active user
+func (r *PgxUserRepository) Update(user *models.User, columns ...string) error {
+	values, err := userUpdates(user, columns)
+	if err != nil {
+		return err
+	}
+	keys := []string{userKey(user.ID)}
+	if slices.Contains(columns, ColumnPhoneNumber) {
+		stored, err := r.FindByIDForUpdate(user.ID)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, phoneKey(stored.PhoneNumber), phoneKey(user.PhoneNumber))
+	}
+
+	updatedAt := time.Now()
+	args := append([]interface{}{user.ID, updatedAt}, values...)
+	set := make([]string, len(columns))
+	for i, column := range columns {
+		set[i] = fmt.Sprintf("%s = $%d", column, i+3)
+	}
+	sql := `UPDATE users SET updated_at = $2, ` + strings.Join(set, ", ") + `
+	WHERE id = $1 AND deleted_at IS NULL`
+	if err := r.exec(sql, args...); err != nil {
+		return err
+	}
+	user.UpdatedAt = updatedAt
+	r.Replicas.stick(keys...)
+	return nil
+}
+
+// Delete soft deletes a user by setting deleted_at
+func (r *PgxUserRepository) Delete(id int) error {
+	defer r.Replicas.stick(userKey(id))
+	return r.exec(deleteUserSQL, id, time.Now())
+}
+
+// ScheduleDeletion soft deletes a user, revokes its tokens and schedules the
+// anonymization of its personal data at purgeAt
+func (r *PgxUserRepository) ScheduleDeletion(id int, purgeAt time.Time) error {
+	defer r.Replicas.stick(userKey(id))
+	return r.exec(scheduleUserDeletionSQL, id, time.Now(), purgeAt)
+}
+
+// Restore cancels a scheduled deletion and makes the user visible again, it
+// fails with ErrPhoneTaken when the phone number was registered meanwhile and
+// with ErrNotFound once the grace period is over
+func (r *PgxUserRepository) Restore(id int) error {
+	defer r.Replicas.stick(userKey(id))
+	return r.exec(restoreUserSQL, id, time.Now())
+}
+
+// UseTOTPStep marks the TOTP time step as used by the user, it fails with
+// ErrNotFound when the step or a later one was already used so the same code
+// can not be replayed
+func (r *PgxUserRepository) UseTOTPStep(id int, step int64) error {
+	defer r.Replicas.stick(userKey(id))
+	return r.exec(useTOTPStepSQL, id, step)
+}
+
+// exec runs a statement changing a single user, it fails with ErrNotFound when
+// no user matched
+func (r *PgxUserRepository) exec(sql string, args ...interface{}) error {
+	ctx, cancel := r.context()
+	defer cancel()
+	tag, err := r.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return pgxError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// FindPurgeable finds at most limit deleted users whose grace period ended before the given time
+func (r *PgxUserRepository) FindPurgeable(before time.Time, limit int) ([]models.User, error) {
+	return r.listUsers(findPurgeableUsersSQL, before, limit)
+}
+
+// Anonymize permanently erases the personal data of a deleted user, the row
+// is kept so the id is never reused
+func (r *PgxUserRepository) Anonymize(id int) error {
+	defer r.Replicas.stick(userKey(id))
+	ctx, cancel := r.context()
+	defer cancel()
+	return pgx.BeginFunc(ctx, r.Pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, anonymizeUserSQL, id, time.Now())
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+		if _, err := tx.Exec(ctx, deleteUserPasswordHistorySQL, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, deleteUserRecoveryCodesSQL, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, deleteUserPasskeysSQL, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, deleteUserCeremoniesSQL, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, deleteUserConsentsSQL, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, deleteUserCodesSQL, id); err != nil {
+			return err
+		}
+		// the identities are released so they can sign up again
+		_, err = tx.Exec(ctx, deleteUserIdentitiesSQL, id)
+		return err
+	})
+}
+
+// List lists a page of users matching the params like PgUserRepository does,
+// with the same cursors
+func (r *PgxUserRepository) List(params models.UserListParams) (*models.UserPage, error) {
+	if params.Limit <= 0 {
+		params.Limit = defaultListLimit
+	}
+
+	where, args := userListFilter(params)
+	pageWhere, pageArgs := where, args
+	if params.Cursor != "" {
+		createdAt, id, err := decodeUserCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		pageWhere += " AND (created_at, id) > (?, ?)"
+		pageArgs = append(append([]interface{}{}, args...), createdAt, id)
+	}
+
+	// one extra user tells whether there is a next page
+	users, err := r.listUsers(numberPlaceholders("SELECT "+userColumns+" FROM users WHERE "+pageWhere+" ORDER BY created_at, id LIMIT ?"),
+		append(pageArgs, params.Limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.UserPage{Users: users}
+	if len(users) > params.Limit {
+		page.Users = users[:params.Limit]
+		page.NextCursor = encodeUserCursor(&page.Users[params.Limit-1])
+	}
+
+	page.Total, page.TotalIsEstimate, err = r.countUsers(where, args)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// countUsers counts the users matching the filter, stopping at CountLimit and
+// falling back to the planner estimate when there are more
+func (r *PgxUserRepository) countUsers(where string, args []interface{}) (int, bool, error) {
+	countLimit := r.CountLimit
+	if countLimit <= 0 {
+		countLimit = defaultCountLimit
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+
+	var total int
+	err := r.Pool.QueryRow(ctx, numberPlaceholders("SELECT count(*) FROM (SELECT 1 FROM users WHERE "+where+" LIMIT ?) AS matched"),
+		append(args, countLimit+1)...).Scan(&total)
+	if err != nil {
+		return 0, false, pgxError(err)
+	}
+	if total <= countLimit {
+		return total, false, nil
+	}
+
+	var plan string
+	err = r.Pool.QueryRow(ctx, numberPlaceholders("EXPLAIN (FORMAT JSON) SELECT 1 FROM users WHERE "+where), args...).Scan(&plan)
+	if err != nil {
+		return 0, false, pgxError(err)
+	}
+	estimate, err := planRows(plan)
+	if err != nil {
+		return 0, false, err
+	}
+	// the planner can underestimate, but we know at least this many users match
+	if estimate <= countLimit {
+		estimate = countLimit + 1
+	}
+	return estimate, true, nil
+}
+
+func (r *PgxUserRepository) listUsers(sql string, args ...interface{}) ([]models.User, error) {
+	ctx, cancel := r.context()
+	defer cancel()
+	rows, err := r.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, pgxError(err)
+	}
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.User, error) {
+		user, err := scanUser(row)
+		if err != nil {
+			return models.User{}, err
+		}
+		return *user, nil
+	})
+	if err != nil {
+		return nil, pgxError(err)
+	}
+	return users, nil
+}
+
+// scanUser scans the userColumns of a row
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.PhoneNumber, &user.Fullname, &user.Password, &user.SaltToken, &user.Role, &user.Status,
+		&user.PasswordResetRequired, &user.CreatedAt, &user.UpdatedAt, &user.DeletedAt, &user.PurgeAt, &user.TokensRevokedAt,
+		&user.PasswordChangedAt, &user.TOTPSecret, &user.TOTPEnabled, &user.TOTPLastStep)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// numberPlaceholders turns the ? placeholders of userListFilter into the $1,
+// $2... of pgx, the values never appear in the query so every ? is one
+func numberPlaceholders(sql string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range sql {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+// pgxError turns the errors of pgx into the errors of the repository
+func pgxError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation && pgErr.ConstraintName == usersPhoneKey {
+		return ErrPhoneTaken
+	}
+	return err
+}
+
+// defaultQueryTimeout bounds the calls of the repositories created by their constructors
+const defaultQueryTimeout = 5 * time.Second
+
+// NewPgxUserRepository creates new pgx user repository
+func NewPgxUserRepository(pool *pgxpool.Pool) *PgxUserRepository {
+	return &PgxUserRepository{Pool: pool, Timeout: defaultQueryTimeout}
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumberPlaceholders(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "No Placeholders",
+			sql:  "deleted_at IS NULL",
+			want: "deleted_at IS NULL",
+		},
+		{
+			name: "Placeholders In Order",
+			sql:  "status = ? AND phone_number LIKE ? AND id > ?",
+			want: "status = $1 AND phone_number LIKE $2 AND id > $3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, numberPlaceholders(tt.sql))
+		})
+	}
+}
+
+func TestPgxError(t *testing.T) {
+	other := errors.New("connection refused")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			name: "No Rows",
+			err:  pgx.ErrNoRows,
+			want: ErrNotFound,
+		},
+		{
+			name: "Phone Taken",
+			err:  &pgconn.PgError{Code: pgerrcode.UniqueViolation, ConstraintName: usersPhoneKey},
+			want: ErrPhoneTaken,
+		},
+		{
+			name: "Other Unique Violation",
+			err:  &pgconn.PgError{Code: pgerrcode.UniqueViolation, ConstraintName: "users_pkey"},
+			want: &pgconn.PgError{Code: pgerrcode.UniqueViolation, ConstraintName: "users_pkey"},
+		},
+		{
+			name: "Other Error",
+			err:  other,
+			want: other,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, pgxError(tt.err))
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+// PoolConfig sizes a connection pool, zero values keep the defaults of the driver
+type PoolConfig struct {
+	MaxConns int32
+	// MinConns is how many connections pgx keeps open when idle
+	MinConns int32
+	// MaxIdleConns is how many idle connections database/sql keeps
+	MaxIdleConns int32
+	// MaxConnLifetime is how long a connection is used before it is replaced
+	MaxConnLifetime time.Duration
+	// ConnectTimeout is how long opening a pool retries connecting before it
+	// gives up, the database is tried once when it is zero
+	ConnectTimeout time.Duration
+}
+
+// the waits between connection attempts, doubling from the first to the longest
+var (
+	connectBackoff    = 100 * time.Millisecond
+	connectMaxBackoff = 5 * time.Second
+)
+
+// retryConnect calls connect until it succeeds or timeout is over, with a
+// back-off between the attempts. The error is the one of the last attempt
+func retryConnect(ctx context.Context, timeout time.Duration, connect func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return connect(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	backoff := connectBackoff
+	for {
+		err := connect(ctx)
+		if err == nil {
+			return nil
+		}
+		deadline, _ := ctx.Deadline()
+		if time.Until(deadline) < backoff {
+			return err
+		}
+		log.Printf("fail to connect to database, retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > connectMaxBackoff {
+			backoff = connectMaxBackoff
+		}
+	}
+}
+
+// OpenGorm connects gorm to Postgres, retrying until the ConnectTimeout of
+// poolConfig is over, and sizes its pool
+func OpenGorm(ctx context.Context, dsn string, poolConfig PoolConfig) (*gorm.DB, error) {
+	// database/sql connects lazily, the pings tell whether the database is up
+	sqlDB, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	err = retryConnect(ctx, poolConfig.ConnectTimeout, func(ctx context.Context) error {
+		return pingContext(ctx, sqlDB)
+	})
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	db, err := gorm.Open("postgres", sqlDB)
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	if poolConfig.MaxConns > 0 {
+		db.DB().SetMaxOpenConns(int(poolConfig.MaxConns))
+	}
+	if poolConfig.MaxIdleConns > 0 {
+		db.DB().SetMaxIdleConns(int(poolConfig.MaxIdleConns))
+	}
+	if poolConfig.MaxConnLifetime > 0 {
+		db.DB().SetConnMaxLifetime(poolConfig.MaxConnLifetime)
+	}
+	return db, nil
+}
+
+// pingContext pings db and gives up when ctx is done, lib/pq only dials with
+// the context and waits for a database that never answers the startup forever
+func pingContext(ctx context.Context, db *sql.DB) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- db.PingContext(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewPgxPool opens a pgx connection pool, retrying until the ConnectTimeout
+// of poolConfig is over. Every connection prepares the statements it runs on
+// first use and keeps them in its statement cache
+func NewPgxPool(ctx context.Context, dsn string, poolConfig PoolConfig) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	config.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	if poolConfig.MaxConns > 0 {
+		config.MaxConns = poolConfig.MaxConns
+	}
+	if poolConfig.MinConns > 0 {
+		config.MinConns = poolConfig.MinConns
+	}
+	if poolConfig.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = poolConfig.MaxConnLifetime
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	// the pool connects lazily, the first ping tells whether the database is up
+	if err := retryConnect(ctx, poolConfig.ConnectTimeout, pool.Ping); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// ConnPool is a database connection pool reported by the metrics and readiness
+type ConnPool interface {
+	Name() string
+	Ping(ctx context.Context) error
+	Stats() PoolStats
+}
+
+// PoolStats is a snapshot of a connection pool
+type PoolStats struct {
+	MaxOpen int
+	Open    int
+	InUse   int
+	Idle    int
+	// WaitCount is how many times a caller waited for a connection and
+	// WaitDuration how long, pgx counts the time of every acquire
+	WaitCount    int64
+	WaitDuration time.Duration
+	// ClosedIdle and ClosedLifetime count the connections closed for being
+	// idle too long or reaching their lifetime
+	ClosedIdle     int64
+	ClosedLifetime int64
+}
+
+type sqlConnPool struct {
+	name string
+	db   *sql.DB
+}
+
+// NewSQLConnPool reports the pool of a database/sql connection, such as the one of gorm
+func NewSQLConnPool(name string, db *sql.DB) ConnPool {
+	return &sqlConnPool{name: name, db: db}
+}
+
+func (p *sqlConnPool) Name() string {
+	return p.name
+}
+
+func (p *sqlConnPool) Ping(ctx context.Context) error {
+	return pingContext(ctx, p.db)
+}
+
+func (p *sqlConnPool) Stats() PoolStats {
+	stats := p.db.Stats()
+	return PoolStats{
+		MaxOpen:        stats.MaxOpenConnections,
+		Open:           stats.OpenConnections,
+		InUse:          stats.InUse,
+		Idle:           stats.Idle,
+		WaitCount:      stats.WaitCount,
+		WaitDuration:   stats.WaitDuration,
+		ClosedIdle:     stats.MaxIdleClosed + stats.MaxIdleTimeClosed,
+		ClosedLifetime: stats.MaxLifetimeClosed,
+	}
+}
+
+type pgxConnPool struct {
+	name string
+	pool *pgxpool.Pool
+}
+
+// NewPgxConnPool reports a pgx connection pool
+func NewPgxConnPool(name string, pool *pgxpool.Pool) ConnPool {
+	return &pgxConnPool{name: name, pool: pool}
+}
+
+func (p *pgxConnPool) Name() string {
+	return p.name
+}
+
+func (p *pgxConnPool) Ping(ctx context.Context) error {
+	return p.pool.Ping(ctx)
+}
+
+func (p *pgxConnPool) Stats() PoolStats {
+	stats := p.pool.Stat()
+	return PoolStats{
+		MaxOpen:        int(stats.MaxConns()),
+		Open:           int(stats.TotalConns()),
+		InUse:          int(stats.AcquiredConns()),
+		Idle:           int(stats.IdleConns()),
+		WaitCount:      stats.EmptyAcquireCount(),
+		WaitDuration:   stats.AcquireDuration(),
+		ClosedIdle:     stats.MaxIdleDestroyCount(),
+		ClosedLifetime: stats.MaxLifetimeDestroyCount(),
+	}
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryConnect(t *testing.T) {
+	refused := errors.New("connection refused")
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		failures     int
+		wantErr      error
+		wantAttempts int
+	}{
+		{
+			name:         "Connected At Once",
+			timeout:      time.Second,
+			wantAttempts: 1,
+		},
+		{
+			name:         "Connected After Retries",
+			timeout:      time.Second,
+			failures:     2,
+			wantAttempts: 3,
+		},
+		{
+			name:         "Gives Up At The Deadline",
+			timeout:      150 * time.Millisecond,
+			failures:     10,
+			wantErr:      refused,
+			wantAttempts: 2,
+		},
+		{
+			name:         "Tried Once Without Timeout",
+			failures:     10,
+			wantErr:      refused,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := retryConnect(context.Background(), tt.timeout, func(ctx context.Context) error {
+				assert.NoError(t, ctx.Err())
+				attempts++
+				if attempts <= tt.failures {
+					return refused
+				}
+				return nil
+			})
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
+
+// silentServer accepts connections but never answers the startup message
+func silentServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	return "postgres://user@" + listener.Addr().String() + "/database?sslmode=disable"
+}
+
+func TestOpenGormTimeout(t *testing.T) {
+	start := time.Now()
+	_, err := OpenGorm(context.Background(), silentServer(t), PoolConfig{ConnectTimeout: 300 * time.Millisecond})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestSQLConnPoolPingTimeout(t *testing.T) {
+	db, err := sql.Open("postgres", silentServer(t))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Equal(t, context.DeadlineExceeded, NewSQLConnPool("gorm", db).Ping(ctx))
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/ratelimit"
+	"github.com/jinzhu/gorm"
+)
+
+// PgRateLimitRepository keeps the rate limit buckets in postgres so the limits
+// hold across replicas, it is a ratelimit.Store
+type PgRateLimitRepository struct {
+	DB *gorm.DB
+}
+
+// RateLimitRepository is an interface for the rate limit bucket repository
+type RateLimitRepository interface {
+	Take(key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error)
+	DeleteIdle(before time.Time) error
+}
+
+// Take takes a token from the bucket of the key, the row is locked so
+// concurrent requests of every replica take their tokens one after another
+func (r *PgRateLimitRepository) Take(key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	var result ratelimit.Result
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		// a new bucket has a zero refill time, which makes it start full
+		err := tx.Exec("INSERT INTO rate_limit_buckets (id, tokens, refilled_at) VALUES (?, 0, ?) ON CONFLICT (id) DO NOTHING",
+			key, time.Time{}).Error
+		if err != nil {
+			return err
+		}
+
+		var row models.RateLimitBucket
+		err = tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", key).First(&row).Error
+		if err != nil {
+			return err
+		}
+
+		bucket := ratelimit.Bucket{Tokens: row.Tokens, UpdatedAt: row.RefilledAt}
+		result = bucket.Take(limit, now)
+		return tx.Model(&row).Updates(map[string]interface{}{
+			"tokens":      bucket.Tokens,
+			"refilled_at": bucket.UpdatedAt,
+		}).Error
+	})
+	return result, err
+}
+
+// DeleteIdle deletes the buckets untouched since before, they are full again
+// as long as no limit takes longer than that to refill
+func (r *PgRateLimitRepository) DeleteIdle(before time.Time) error {
+	return r.DB.Where("refilled_at < ?", before).Delete(&models.RateLimitBucket{}).Error
+}
+
+// NewPgRateLimitRepository creates new postgress rate limit repository
+func NewPgRateLimitRepository(db *gorm.DB) *PgRateLimitRepository {
+	return &PgRateLimitRepository{DB: db}
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/jinzhu/gorm"
+)
+
+type PgRecoveryCodeRepository struct {
+	DB *gorm.DB
+}
+
+// RecoveryCodeRepository is an interface for recovery code repository
+type RecoveryCodeRepository interface {
+	ReplaceByUser(userID int, codeHashes []string) error
+	Use(userID int, codeHash string) error
+	DeleteByUser(userID int) error
+}
+
+// ReplaceByUser replaces every recovery code of a user with the given hashes
+func (r *PgRecoveryCodeRepository) ReplaceByUser(userID int, codeHashes []string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for _, codeHash := range codeHashes {
+			if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: codeHash}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Use marks an unused recovery code of the user as used, it fails with record
+// not found when the user has no such unused code
+func (r *PgRecoveryCodeRepository) Use(userID int, codeHash string) error {
+	result := r.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteByUser deletes every recovery code of a user
+func (r *PgRecoveryCodeRepository) DeleteByUser(userID int) error {
+	return r.DB.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// NewPgRecoveryCodeRepository creates new postgress recovery code repository
+func NewPgRecoveryCodeRepository(db *gorm.DB) *PgRecoveryCodeRepository {
+	return &PgRecoveryCodeRepository{DB: db}
+}
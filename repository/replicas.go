@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultReplicaRetryAfter is how long a failing replica is skipped
+const defaultReplicaRetryAfter = 10 * time.Second
+
+// Replicas routes the lookups of a user repository to read replicas, in turn.
+// Users written within StickyWindow are read from the primary so their writer
+// reads its own writes, only the writes of this process are known. A replica
+// failing a query is skipped for RetryAfter, the primary answers meanwhile
+type Replicas[T any] struct {
+	// StickyWindow should cover the replication lag
+	StickyWindow time.Duration
+	RetryAfter   time.Duration
+
+	conns []T
+	now   func() time.Time
+
+	mu        sync.Mutex
+	next      int
+	downUntil []time.Time
+	// written are the users written lately, keyed by userKey and phoneKey,
+	// with the end of their sticky window
+	written   map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewReplicas creates the replicas of a repository, it returns nil without
+// conns and the repository reads from the primary
+func NewReplicas[T any](stickyWindow time.Duration, conns ...T) *Replicas[T] {
+	if len(conns) == 0 {
+		return nil
+	}
+	return &Replicas[T]{
+		StickyWindow: stickyWindow,
+		RetryAfter:   defaultReplicaRetryAfter,
+		conns:        conns,
+		now:          time.Now,
+		downUntil:    make([]time.Time, len(conns)),
+		written:      map[string]time.Time{},
+	}
+}
+
+func userKey(id int) string {
+	return "id:" + strconv.Itoa(id)
+}
+
+func phoneKey(phone string) string {
+	return "phone:" + phone
+}
+
+// read runs query on a healthy replica, and on primary when one of keys was
+// written lately or no replica answered. A replica not finding the user may
+// lag behind, so ErrNotFound is only trusted from the primary
+func (r *Replicas[T]) read(primary T, query func(conn T) error, keys ...string) error {
+	if r == nil {
+		return query(primary)
+	}
+	for _, i := range r.route(keys) {
+		err := query(r.conns[i])
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrNotFound) {
+			break
+		}
+		r.failed(i, err)
+	}
+	return query(primary)
+}
+
+// route returns the healthy replicas in the order they are tried, none when
+// one of keys is in its sticky window
+func (r *Replicas[T]) route(keys []string) []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for _, key := range keys {
+		if now.Before(r.written[key]) {
+			return nil
+		}
+	}
+
+	var order []int
+	for n := 0; n < len(r.conns); n++ {
+		i := (r.next + n) % len(r.conns)
+		if !now.Before(r.downUntil[i]) {
+			order = append(order, i)
+		}
+	}
+	r.next = (r.next + 1) % len(r.conns)
+	return order
+}
+
+// failed skips replica i for RetryAfter
+func (r *Replicas[T]) failed(i int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	log.Printf("replica %d failed, reading from the primary for %s: %v", i, r.RetryAfter, err)
+	r.downUntil[i] = r.now().Add(r.RetryAfter)
+}
+
+// stick reads keys from the primary for StickyWindow
+func (r *Replicas[T]) stick(keys ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	// forget the windows over, at most once per window
+	if now.Sub(r.lastSweep) > r.StickyWindow {
+		for key, until := range r.written {
+			if !now.Before(until) {
+				delete(r.written, key)
+			}
+		}
+		r.lastSweep = now
+	}
+	for _, key := range keys {
+		r.written[key] = now.Add(r.StickyWindow)
+	}
+}
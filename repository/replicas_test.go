@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// replicaTest routes queries over connections named by strings, failing
+// are the connections whose queries fail and the connection reached is
+// recorded in reads
+type replicaTest struct {
+	replicas *Replicas[string]
+	now      time.Time
+	failing  map[string]error
+	reads    []string
+}
+
+func newReplicaTest(conns ...string) *replicaTest {
+	rt := &replicaTest{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), failing: map[string]error{}}
+	rt.replicas = NewReplicas(stickyWindow, conns...)
+	if rt.replicas != nil {
+		rt.replicas.now = func() time.Time { return rt.now }
+	}
+	return rt
+}
+
+func (rt *replicaTest) read(keys ...string) error {
+	return rt.replicas.read("primary", func(conn string) error {
+		rt.reads = append(rt.reads, conn)
+		return rt.failing[conn]
+	}, keys...)
+}
+
+const stickyWindow = 5 * time.Second
+
+func TestReplicas(t *testing.T) {
+	refused := errors.New("connection refused")
+
+	t.Run("Without Replicas Reads The Primary", func(t *testing.T) {
+		rt := newReplicaTest()
+		assert.Nil(t, rt.replicas)
+		rt.replicas.stick(userKey(1))
+		assert.NoError(t, rt.read(userKey(1)))
+		assert.Equal(t, []string{"primary"}, rt.reads)
+	})
+
+	t.Run("Replicas Take Turns", func(t *testing.T) {
+		rt := newReplicaTest("first", "second")
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, rt.read(userKey(1)))
+		}
+		assert.Equal(t, []string{"first", "second", "first"}, rt.reads)
+	})
+
+	t.Run("Written Users Stick To The Primary", func(t *testing.T) {
+		rt := newReplicaTest("first")
+		rt.replicas.stick(userKey(1), phoneKey("+6281200000001"))
+
+		assert.NoError(t, rt.read(phoneKey("+6281200000001")))
+		assert.NoError(t, rt.read(userKey(1)))
+		assert.NoError(t, rt.read(userKey(2)))
+		assert.Equal(t, []string{"primary", "primary", "first"}, rt.reads)
+
+		rt.now = rt.now.Add(stickyWindow)
+		rt.reads = nil
+		assert.NoError(t, rt.read(userKey(1)))
+		assert.Equal(t, []string{"first"}, rt.reads)
+	})
+
+	t.Run("Failing Replicas Are Skipped", func(t *testing.T) {
+		rt := newReplicaTest("first", "second")
+		rt.failing["first"] = refused
+
+		assert.NoError(t, rt.read(userKey(1)))
+		assert.NoError(t, rt.read(userKey(1)))
+		assert.Equal(t, []string{"first", "second", "second"}, rt.reads)
+
+		rt.failing["second"] = refused
+		rt.reads = nil
+		assert.NoError(t, rt.read(userKey(1)))
+		assert.NoError(t, rt.read(userKey(1)))
+		assert.Equal(t, []string{"second", "primary", "primary"}, rt.reads)
+
+		// the replicas are tried again once RetryAfter is over
+		delete(rt.failing, "first")
+		rt.now = rt.now.Add(defaultReplicaRetryAfter)
+		rt.reads = nil
+		assert.NoError(t, rt.read(userKey(1)))
+		assert.Equal(t, []string{"first"}, rt.reads)
+	})
+
+	t.Run("Not Found Is Checked On The Primary", func(t *testing.T) {
+		rt := newReplicaTest("first", "second")
+		rt.failing["first"] = ErrNotFound
+
+		assert.NoError(t, rt.read(userKey(1)))
+		assert.Equal(t, []string{"first", "primary"}, rt.reads)
+
+		// a lagging replica is not failing
+		rt.reads = nil
+		assert.NoError(t, rt.read(userKey(1)))
+		assert.NoError(t, rt.read(userKey(1)))
+		assert.Equal(t, []string{"second", "first", "primary"}, rt.reads)
+	})
+
+	t.Run("Primary Errors Are Returned", func(t *testing.T) {
+		rt := newReplicaTest("first")
+		rt.failing["first"] = refused
+		rt.failing["primary"] = ErrNotFound
+		assert.Equal(t, ErrNotFound, rt.read(userKey(1)))
+	})
+
+	t.Run("Sticky Windows Over Are Forgotten", func(t *testing.T) {
+		rt := newReplicaTest("first")
+		rt.replicas.stick(userKey(1))
+		rt.now = rt.now.Add(2 * stickyWindow)
+		rt.replicas.stick(userKey(2))
+		assert.Equal(t, map[string]time.Time{userKey(2): rt.now.Add(stickyWindow)}, rt.replicas.written)
+	})
+}
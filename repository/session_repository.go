@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/jinzhu/gorm"
+)
+
+type PgSessionRepository struct {
+	DB *gorm.DB
+}
+
+// SessionRepository is an interface for session repository
+type SessionRepository interface {
+	Create(session *models.Session) error
+	FindByID(id string) (*models.Session, error)
+	ListByUser(userID int, afterID string, limit int) ([]models.Session, error)
+	ListActiveByUser(userID int, now time.Time) ([]models.Session, error)
+	Touch(id string, lastSeenAt time.Time) error
+	Revoke(id string, userID int) error
+	RevokeAllByUser(userID int) error
+	DeleteByUser(userID int) error
+}
+
+// Create creates a new session
+func (r *PgSessionRepository) Create(session *models.Session) error {
+	return r.DB.Create(session).Error
+}
+
+// FindByID finds a session by id
+func (r *PgSessionRepository) FindByID(id string) (*models.Session, error) {
+	var session models.Session
+	err := r.DB.Where("id = ?", id).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListByUser lists at most limit sessions of a user including revoked and
+// expired ones, ordered by id and starting after afterID so callers can page
+// through every session without offsets
+func (r *PgSessionRepository) ListByUser(userID int, afterID string, limit int) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.DB.
+		Where("user_id = ? AND id > ?", userID, afterID).
+		Order("id").
+		Limit(limit).
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// ListActiveByUser lists the sessions of a user that are neither revoked nor expired, most recently seen first
+func (r *PgSessionRepository) ListActiveByUser(userID int, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Touch records when the session was last used
+func (r *PgSessionRepository) Touch(id string, lastSeenAt time.Time) error {
+	return r.DB.Model(&models.Session{}).Where("id = ?", id).Update("last_seen_at", lastSeenAt).Error
+}
+
+// Revoke revokes a session of the user, it fails with record not found when the
+// session does not belong to the user or is already revoked
+func (r *PgSessionRepository) Revoke(id string, userID int) error {
+	result := r.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeAllByUser revokes every session of a user
+func (r *PgSessionRepository) RevokeAllByUser(userID int) error {
+	return r.DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// DeleteByUser deletes every session of a user
+func (r *PgSessionRepository) DeleteByUser(userID int) error {
+	return r.DB.Where("user_id = ?", userID).Delete(&models.Session{}).Error
+}
+
+// NewPgSessionRepository creates new postgress session repository
+func NewPgSessionRepository(db *gorm.DB) *PgSessionRepository {
+	return &PgSessionRepository{DB: db}
+}
@@ -1,12 +1,79 @@
 package repository
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/jackc/pgerrcode"
 	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// defaultListLimit is the page size of List when the params have no limit
+const defaultListLimit = 20
+
+// defaultCountLimit is how many matching users List counts exactly before it estimates the total
+const defaultCountLimit = 10000
+
+// ErrInvalidCursor is returned by List when the cursor was not made by a previous List
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrNotFound is returned when no row matches, it is gorm.ErrRecordNotFound so
+// the callers of the gorm repositories handle it unchanged
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// ErrPhoneTaken is returned when another active user already has the phone number
+var ErrPhoneTaken = errors.New("phone number already registered")
+
+// usersPhoneKey is the unique index of the phone numbers of active users
+const usersPhoneKey = "users_phone_number_active_key"
+
+// The columns of the users table Update writes
+const (
+	ColumnPhoneNumber           = "phone_number"
+	ColumnFullname              = "fullname"
+	ColumnPassword              = "password"
+	ColumnSaltToken             = "salt_token"
+	ColumnRole                  = "role"
+	ColumnStatus                = "status"
+	ColumnPasswordResetRequired = "password_reset_required"
+	ColumnPurgeAt               = "purge_at"
+	ColumnTokensRevokedAt       = "tokens_revoked_at"
+	ColumnPasswordChangedAt     = "password_changed_at"
+	ColumnTOTPSecret            = "totp_secret"
+	ColumnTOTPEnabled           = "totp_enabled"
 )
 
+// userFields point to the field of a user each column of Update is written from
+var userFields = map[string]func(user *models.User) interface{}{
+	ColumnPhoneNumber:           func(user *models.User) interface{} { return &user.PhoneNumber },
+	ColumnFullname:              func(user *models.User) interface{} { return &user.Fullname },
+	ColumnPassword:              func(user *models.User) interface{} { return &user.Password },
+	ColumnSaltToken:             func(user *models.User) interface{} { return &user.SaltToken },
+	ColumnRole:                  func(user *models.User) interface{} { return &user.Role },
+	ColumnStatus:                func(user *models.User) interface{} { return &user.Status },
+	ColumnPasswordResetRequired: func(user *models.User) interface{} { return &user.PasswordResetRequired },
+	ColumnPurgeAt:               func(user *models.User) interface{} { return &user.PurgeAt },
+	ColumnTokensRevokedAt:       func(user *models.User) interface{} { return &user.TokensRevokedAt },
+	ColumnPasswordChangedAt:     func(user *models.User) interface{} { return &user.PasswordChangedAt },
+	ColumnTOTPSecret:            func(user *models.User) interface{} { return &user.TOTPSecret },
+	ColumnTOTPEnabled:           func(user *models.User) interface{} { return &user.TOTPEnabled },
+}
+
 type PgUserRepository struct {
 	DB *gorm.DB
+	// CountLimit is how many matching users List counts exactly before it estimates the total
+	CountLimit int
+	// Replicas serve FindByID and FindByPhone when set
+	Replicas *Replicas[*gorm.DB]
 }
 
 // UserRepository is an interface for user repository
@@ -14,18 +81,45 @@ type UserRepository interface {
 	Create(user *models.User) error
 	FindByPhone(phone string) (*models.User, error)
 	FindByID(id int) (*models.User, error)
-	Update(user *models.User) error
+	// FindByIDForUpdate finds a user by id on the primary, for the reads a
+	// write is decided on, a lagging replica could otherwise undo a recent
+	// change. It takes no lock
+	FindByIDForUpdate(id int) (*models.User, error)
+	// FindProfileByID finds a user by id without its credentials, see
+	// withoutCredentials. It may be cached, a change made through another
+	// replica shows up within USER_CACHE_TTL unless the cache has a shared
+	// backend, so whatever must apply at once has to revoke the sessions too
+	FindProfileByID(id int) (*models.User, error)
+	// Update saves the columns of the user, the other columns keep what
+	// concurrent updates wrote. TOTPLastStep is only changed by UseTOTPStep
+	Update(user *models.User, columns ...string) error
+	Delete(id int) error
+	ScheduleDeletion(id int, purgeAt time.Time) error
+	FindDeletedByPhone(phone string) (*models.User, error)
+	Restore(id int) error
+	FindPurgeable(before time.Time, limit int) ([]models.User, error)
+	Anonymize(id int) error
+	UseTOTPStep(id int, step int64) error
+	List(params models.UserListParams) (*models.UserPage, error)
 }
 
-// Create creates a new user
+// Create creates a new user, it fails with ErrPhoneTaken when an active user
+// already has the phone number
 func (r *PgUserRepository) Create(user *models.User) error {
-	return r.DB.Create(user).Error
+	if err := r.DB.Create(user).Error; err != nil {
+		return phoneTaken(err)
+	}
+	r.Replicas.stick(userKey(user.ID), phoneKey(user.PhoneNumber))
+	return nil
 }
 
 // FindByPhone finds a user by phone number
 func (r *PgUserRepository) FindByPhone(phone string) (*models.User, error) {
 	var user models.User
-	err := r.DB.Where("phone_number = ?", phone).First(&user).Error
+	err := r.Replicas.read(r.DB, func(db *gorm.DB) error {
+		user = models.User{}
+		return db.Where("phone_number = ?", phone).First(&user).Error
+	}, phoneKey(phone))
 	if err != nil {
 		return nil, err
 	}
@@ -35,16 +129,400 @@ func (r *PgUserRepository) FindByPhone(phone string) (*models.User, error) {
 // FindByID finds a user by id
 func (r *PgUserRepository) FindByID(id int) (*models.User, error) {
 	var user models.User
-	err := r.DB.First(&user, id).Error
+	err := r.Replicas.read(r.DB, func(db *gorm.DB) error {
+		user = models.User{}
+		return db.First(&user, id).Error
+	}, userKey(id))
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-// Update updates a user
-func (r *PgUserRepository) Update(user *models.User) error {
-	return r.DB.Save(user).Error
+// FindByIDForUpdate finds a user by id on the primary
+func (r *PgUserRepository) FindByIDForUpdate(id int) (*models.User, error) {
+	var user models.User
+	if err := r.DB.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindProfileByID finds a user by id without its credentials
+func (r *PgUserRepository) FindProfileByID(id int) (*models.User, error) {
+	user, err := r.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return withoutCredentials(user), nil
+}
+
+// Update updates the columns of an active user, it fails with ErrPhoneTaken
+// when the new phone number belongs to another active user
+func (r *PgUserRepository) Update(user *models.User, columns ...string) error {
+	values, err := userUpdates(user, columns)
+	if err != nil {
+		return err
+	}
+	keys, err := r.updatedKeys(user, columns)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{"updated_at": time.Now()}
+	for i, column := range columns {
+		updates[column] = values[i]
+	}
+	result := r.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates)
+	if result.Error != nil {
+		return phoneTaken(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	user.UpdatedAt = updates["updated_at"].(time.Time)
+	r.Replicas.stick(keys...)
+	return nil
+}
+
+// updatedKeys are the keys an update of the columns sticks, a changed phone
+// number sticks the old one too so its lookups do not find the user on a replica
+func (r *PgUserRepository) updatedKeys(user *models.User, columns []string) ([]string, error) {
+	keys := []string{userKey(user.ID)}
+	if !slices.Contains(columns, ColumnPhoneNumber) {
+		return keys, nil
+	}
+	stored, err := r.FindByIDForUpdate(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, phoneKey(stored.PhoneNumber), phoneKey(user.PhoneNumber)), nil
+}
+
+// Delete soft deletes a user by setting deleted_at, soft deleted users are
+// excluded from every Find* query
+func (r *PgUserRepository) Delete(id int) error {
+	defer r.Replicas.stick(userKey(id))
+	result := r.DB.Where("id = ?", id).Delete(&models.User{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ScheduleDeletion soft deletes a user, revokes its tokens and schedules the
+// anonymization of its personal data at purgeAt
+func (r *PgUserRepository) ScheduleDeletion(id int, purgeAt time.Time) error {
+	defer r.Replicas.stick(userKey(id))
+	now := time.Now()
+	result := r.DB.Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"deleted_at":        now,
+		"purge_at":          purgeAt,
+		"tokens_revoked_at": now,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindDeletedByPhone finds a deleted user by phone number that is still in its grace period
+func (r *PgUserRepository) FindDeletedByPhone(phone string) (*models.User, error) {
+	var user models.User
+	err := r.DB.Unscoped().
+		Where("phone_number = ? AND deleted_at IS NOT NULL AND purge_at > ?", phone, time.Now()).
+		Order("deleted_at DESC").
+		First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Restore cancels a scheduled deletion and makes the user visible again, it
+// fails with ErrPhoneTaken when the phone number was registered meanwhile and
+// with record not found once the grace period is over
+func (r *PgUserRepository) Restore(id int) error {
+	defer r.Replicas.stick(userKey(id))
+	result := r.DB.Unscoped().Model(&models.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL AND purge_at > ?", id, time.Now()).
+		Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"purge_at":   nil,
+		})
+	if result.Error != nil {
+		return phoneTaken(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindPurgeable finds at most limit deleted users whose grace period ended before the given time
+func (r *PgUserRepository) FindPurgeable(before time.Time, limit int) ([]models.User, error) {
+	var users []models.User
+	err := r.DB.Unscoped().
+		Where("deleted_at IS NOT NULL AND purge_at IS NOT NULL AND purge_at <= ?", before).
+		Order("purge_at").
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Anonymize permanently erases the personal data of a deleted user, the row
+// is kept so the id is never reused
+func (r *PgUserRepository) Anonymize(id int) error {
+	defer r.Replicas.stick(userKey(id))
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&models.User{}).
+			Where("id = ? AND deleted_at IS NOT NULL", id).
+			Updates(map[string]interface{}{
+				"phone_number": "",
+				"fullname":     "",
+				"password":     "",
+				"salt_token":   "",
+				"totp_secret":  "",
+				"purge_at":     nil,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.WebAuthnCredential{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.WebAuthnCeremony{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.OAuthConsent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.OAuthAuthorizationCode{}).Error; err != nil {
+			return err
+		}
+		// the identities are released so they can sign up again
+		return tx.Where("user_id = ?", id).Delete(&models.UserIdentity{}).Error
+	})
+}
+
+// UseTOTPStep marks the TOTP time step as used by the user, it fails with
+// record not found when the step or a later one was already used so the same
+// code can not be replayed. Accounts pending deletion are included because
+// they sign in before being restored
+func (r *PgUserRepository) UseTOTPStep(id int, step int64) error {
+	defer r.Replicas.stick(userKey(id))
+	result := r.DB.Unscoped().Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		UpdateColumn("totp_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// List lists a page of users matching the params, ordered by created_at then
+// id so pages stay stable while users are created. The total is counted
+// exactly up to CountLimit matching users and estimated by the query planner
+// beyond that, so large tables are never scanned in full
+func (r *PgUserRepository) List(params models.UserListParams) (*models.UserPage, error) {
+	if params.Limit <= 0 {
+		params.Limit = defaultListLimit
+	}
+
+	where, args := userListFilter(params)
+	query := r.DB.Where(where, args...)
+	if params.Cursor != "" {
+		createdAt, id, err := decodeUserCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(created_at, id) > (?, ?)", createdAt, id)
+	}
+
+	// one extra user tells whether there is a next page
+	var users []models.User
+	err := query.Order("created_at, id").Limit(params.Limit + 1).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.UserPage{Users: users}
+	if len(users) > params.Limit {
+		page.Users = users[:params.Limit]
+		page.NextCursor = encodeUserCursor(&page.Users[params.Limit-1])
+	}
+
+	page.Total, page.TotalIsEstimate, err = r.countUsers(where, args)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// countUsers counts the users matching the filter, stopping at CountLimit and
+// falling back to the planner estimate when there are more
+func (r *PgUserRepository) countUsers(where string, args []interface{}) (int, bool, error) {
+	countLimit := r.CountLimit
+	if countLimit <= 0 {
+		countLimit = defaultCountLimit
+	}
+
+	var total int
+	err := r.DB.Raw("SELECT count(*) FROM (SELECT 1 FROM users WHERE "+where+" LIMIT ?) AS matched", append(args, countLimit+1)...).
+		Row().Scan(&total)
+	if err != nil {
+		return 0, false, err
+	}
+	if total <= countLimit {
+		return total, false, nil
+	}
+
+	var plan string
+	err = r.DB.Raw("EXPLAIN (FORMAT JSON) SELECT 1 FROM users WHERE "+where, args...).Row().Scan(&plan)
+	if err != nil {
+		return 0, false, err
+	}
+	estimate, err := planRows(plan)
+	if err != nil {
+		return 0, false, err
+	}
+	// the planner can underestimate, but we know at least this many users match
+	if estimate <= countLimit {
+		estimate = countLimit + 1
+	}
+	return estimate, true, nil
+}
+
+// userListFilter builds the where clause of List, shared by the page and the count queries
+func userListFilter(params models.UserListParams) (string, []interface{}) {
+	conditions := []string{"deleted_at IS NULL"}
+	args := []interface{}{}
+	if params.NamePrefix != "" {
+		conditions = append(conditions, "lower(fullname) LIKE ?")
+		args = append(args, strings.ToLower(escapeLike(params.NamePrefix))+"%")
+	}
+	if params.PhonePrefix != "" {
+		conditions = append(conditions, "phone_number LIKE ?")
+		args = append(args, escapeLike(params.PhonePrefix)+"%")
+	}
+	if params.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, params.Status)
+	}
+	if params.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *params.CreatedFrom)
+	}
+	if params.CreatedTo != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, *params.CreatedTo)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// encodeUserCursor encodes the position of the user in the created_at, id ordering
+func encodeUserCursor(user *models.User) string {
+	raw := user.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + strconv.Itoa(user.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeUserCursor decodes a cursor made by encodeUserCursor
+func decodeUserCursor(cursor string) (time.Time, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ",", 2)
+	if len(parts) != 2 {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return createdAt, id, nil
+}
+
+// planRows reads the estimated row count from the output of EXPLAIN (FORMAT JSON)
+func planRows(plan string) (int, error) {
+	var explain []struct {
+		Plan struct {
+			PlanRows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(plan), &explain); err != nil {
+		return 0, err
+	}
+	if len(explain) == 0 {
+		return 0, errors.New("empty query plan")
+	}
+	return int(explain[0].Plan.PlanRows), nil
+}
+
+// userUpdates are the values of the columns of the user, in their order
+func userUpdates(user *models.User, columns []string) ([]interface{}, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("no columns to update")
+	}
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		field, ok := userFields[column]
+		if !ok {
+			return nil, fmt.Errorf("column %s can not be updated", column)
+		}
+		values[i] = reflect.ValueOf(field(user)).Elem().Interface()
+	}
+	return values, nil
+}
+
+// withoutCredentials copies a user without the Password, SaltToken and
+// TOTPSecret, so they are never kept by a cache
+func withoutCredentials(user *models.User) *models.User {
+	profile := *user
+	profile.PasswordSet = user.HasPassword()
+	profile.Password = ""
+	profile.SaltToken = ""
+	profile.TOTPSecret = ""
+	return &profile
+}
+
+// phoneTaken turns the violation of usersPhoneKey into ErrPhoneTaken
+func phoneTaken(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pgerrcode.UniqueViolation && pqErr.Constraint == usersPhoneKey {
+		return ErrPhoneTaken
+	}
+	return err
+}
+
+// escapeLike escapes the LIKE wildcards so user input is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
 
 // NewPgUserRepository creates new postgress user repository
@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryUserRepository(t *testing.T) {
+	testUserRepository(t, func(t *testing.T) UserRepository {
+		return NewMemoryUserRepository()
+	})
+}
+
+// testUserRepository is the behavior every UserRepository shares,
+// newRepository returns a repository without users
+func testUserRepository(t *testing.T, newRepository func(t *testing.T) UserRepository) {
+	newUser := func(phone, fullname string) *models.User {
+		return &models.User{PhoneNumber: phone, Fullname: fullname, Password: "hash", SaltToken: "salt"}
+	}
+	create := func(t *testing.T, repo UserRepository, phone, fullname string) *models.User {
+		user := newUser(phone, fullname)
+		require.NoError(t, repo.Create(user))
+		return user
+	}
+
+	t.Run("Create Assigns Ids And Defaults", func(t *testing.T) {
+		repo := newRepository(t)
+		first := create(t, repo, "+6281200000001", "Budi")
+		second := create(t, repo, "+6281200000002", "Siti")
+		assert.NotZero(t, first.ID)
+		assert.Greater(t, second.ID, first.ID)
+
+		found, err := repo.FindByID(first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "+6281200000001", found.PhoneNumber)
+		assert.Equal(t, models.RoleUser, found.Role)
+		assert.Equal(t, models.StatusActive, found.Status)
+		assert.False(t, found.CreatedAt.IsZero())
+
+		found, err = repo.FindByPhone("+6281200000002")
+		require.NoError(t, err)
+		assert.Equal(t, second.ID, found.ID)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		repo := newRepository(t)
+		_, err := repo.FindByID(1)
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+		_, err = repo.FindByPhone("+6281200000001")
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+		_, err = repo.FindDeletedByPhone("+6281200000001")
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.Delete(1))
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.ScheduleDeletion(1, time.Now()))
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.Restore(1))
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.Anonymize(1))
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.UseTOTPStep(1, 1))
+	})
+
+	t.Run("Profile Has No Credentials", func(t *testing.T) {
+		repo := newRepository(t)
+		user := newUser("+6281200000001", "Budi")
+		user.TOTPSecret = "JBSWY3DPEHPK3PXP"
+		user.TOTPEnabled = true
+		require.NoError(t, repo.Create(user))
+
+		profile, err := repo.FindProfileByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Budi", profile.Fullname)
+		assert.True(t, profile.TOTPEnabled)
+		assert.Empty(t, profile.Password)
+		assert.Empty(t, profile.SaltToken)
+		assert.Empty(t, profile.TOTPSecret)
+
+		found, err := repo.FindByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "hash", found.Password)
+		assert.Equal(t, "salt", found.SaltToken)
+
+		require.NoError(t, repo.Delete(user.ID))
+		_, err = repo.FindProfileByID(user.ID)
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+	})
+
+	t.Run("Phone Is Unique Among Active Users", func(t *testing.T) {
+		repo := newRepository(t)
+		budi := create(t, repo, "+6281200000001", "Budi")
+		siti := create(t, repo, "+6281200000002", "Siti")
+
+		assert.Equal(t, ErrPhoneTaken, repo.Create(newUser("+6281200000001", "Agus")))
+
+		siti.PhoneNumber = budi.PhoneNumber
+		assert.Equal(t, ErrPhoneTaken, repo.Update(siti, ColumnPhoneNumber))
+
+		require.NoError(t, repo.ScheduleDeletion(budi.ID, time.Now().Add(time.Hour)))
+		agus := create(t, repo, "+6281200000001", "Agus")
+		assert.NotEqual(t, budi.ID, agus.ID)
+		assert.Equal(t, ErrPhoneTaken, repo.Restore(budi.ID))
+	})
+
+	t.Run("Concurrent Creates Of A Phone", func(t *testing.T) {
+		repo := newRepository(t)
+		errs := make([]error, 8)
+		var wg sync.WaitGroup
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = repo.Create(newUser("+6281200000001", "Budi"))
+			}(i)
+		}
+		wg.Wait()
+
+		created := 0
+		for _, err := range errs {
+			if err == nil {
+				created++
+				continue
+			}
+			assert.Equal(t, ErrPhoneTaken, err)
+		}
+		assert.Equal(t, 1, created)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		repo := newRepository(t)
+		user := create(t, repo, "+6281200000001", "Budi")
+		user.Fullname = "Budi Santoso"
+		user.PhoneNumber = "+6281200000009"
+		require.NoError(t, repo.Update(user, ColumnFullname, ColumnPhoneNumber))
+
+		found, err := repo.FindByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Budi Santoso", found.Fullname)
+		assert.Equal(t, "+6281200000009", found.PhoneNumber)
+		_, err = repo.FindByPhone("+6281200000001")
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+	})
+
+	t.Run("Update Writes Only Its Columns", func(t *testing.T) {
+		repo := newRepository(t)
+		user := create(t, repo, "+6281200000001", "Budi")
+		stale, err := repo.FindByIDForUpdate(user.ID)
+		require.NoError(t, err)
+
+		user.Status = models.StatusDisabled
+		require.NoError(t, repo.Update(user, ColumnStatus))
+		// a concurrent update from an older read keeps the status
+		stale.Fullname = "Budi Santoso"
+		require.NoError(t, repo.Update(stale, ColumnFullname))
+
+		found, err := repo.FindByIDForUpdate(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusDisabled, found.Status)
+		assert.Equal(t, "Budi Santoso", found.Fullname)
+
+		assert.Error(t, repo.Update(user))
+		assert.Error(t, repo.Update(user, "deleted_at"))
+		require.NoError(t, repo.Delete(user.ID))
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.Update(user, ColumnFullname))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepository(t)
+		user := create(t, repo, "+6281200000001", "Budi")
+		require.NoError(t, repo.Delete(user.ID))
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.Delete(user.ID))
+
+		_, err := repo.FindByID(user.ID)
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+		// only scheduled deletions can be restored
+		_, err = repo.FindDeletedByPhone(user.PhoneNumber)
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.Restore(user.ID))
+	})
+
+	t.Run("Scheduled Deletion", func(t *testing.T) {
+		repo := newRepository(t)
+		user := create(t, repo, "+6281200000001", "Budi")
+		require.NoError(t, repo.ScheduleDeletion(user.ID, time.Now().Add(time.Hour)))
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.ScheduleDeletion(user.ID, time.Now().Add(time.Hour)))
+
+		_, err := repo.FindByPhone(user.PhoneNumber)
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+		deleted, err := repo.FindDeletedByPhone(user.PhoneNumber)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, deleted.ID)
+		assert.NotNil(t, deleted.PurgeAt)
+		assert.NotNil(t, deleted.TokensRevokedAt)
+
+		require.NoError(t, repo.Restore(user.ID))
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.Restore(user.ID))
+		restored, err := repo.FindByID(user.ID)
+		require.NoError(t, err)
+		assert.Nil(t, restored.PurgeAt)
+	})
+
+	t.Run("Expired Deletion Can Not Be Restored", func(t *testing.T) {
+		repo := newRepository(t)
+		user := create(t, repo, "+6281200000001", "Budi")
+		require.NoError(t, repo.ScheduleDeletion(user.ID, time.Now().Add(-time.Minute)))
+
+		// the purge worker did not run yet
+		_, err := repo.FindDeletedByPhone(user.PhoneNumber)
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.Restore(user.ID))
+		_, err = repo.FindByID(user.ID)
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		repo := newRepository(t)
+		now := time.Now()
+		later := create(t, repo, "+6281200000001", "Budi")
+		sooner := create(t, repo, "+6281200000002", "Siti")
+		pending := create(t, repo, "+6281200000003", "Agus")
+		create(t, repo, "+6281200000004", "Dewi")
+		require.NoError(t, repo.ScheduleDeletion(later.ID, now.Add(-24*time.Hour)))
+		require.NoError(t, repo.ScheduleDeletion(sooner.ID, now.Add(-48*time.Hour)))
+		require.NoError(t, repo.ScheduleDeletion(pending.ID, now.Add(48*time.Hour)))
+
+		purgeable, err := repo.FindPurgeable(now, 10)
+		require.NoError(t, err)
+		require.Len(t, purgeable, 2)
+		assert.Equal(t, sooner.ID, purgeable[0].ID)
+		assert.Equal(t, later.ID, purgeable[1].ID)
+		purgeable, err = repo.FindPurgeable(now, 1)
+		require.NoError(t, err)
+		assert.Len(t, purgeable, 1)
+
+		require.NoError(t, repo.Anonymize(sooner.ID))
+		_, err = repo.FindDeletedByPhone("+6281200000002")
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+		purgeable, err = repo.FindPurgeable(now, 10)
+		require.NoError(t, err)
+		assert.Len(t, purgeable, 1)
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.Anonymize(pending.ID+1))
+	})
+
+	t.Run("TOTP Steps Are Used Once", func(t *testing.T) {
+		repo := newRepository(t)
+		user := create(t, repo, "+6281200000001", "Budi")
+		require.NoError(t, repo.UseTOTPStep(user.ID, 10))
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.UseTOTPStep(user.ID, 10))
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.UseTOTPStep(user.ID, 9))
+		require.NoError(t, repo.UseTOTPStep(user.ID, 11))
+
+		// a user read before the step was used does not lower it
+		user.Fullname = "Budi Santoso"
+		require.NoError(t, repo.Update(user, ColumnFullname))
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.UseTOTPStep(user.ID, 11))
+		found, err := repo.FindByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(11), found.TOTPLastStep)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		repo := newRepository(t)
+		budi := create(t, repo, "+6281200000001", "Budi")
+		bunga := create(t, repo, "+6281300000002", "bunga")
+		siti := create(t, repo, "+6281200000003", "Siti")
+		deleted := create(t, repo, "+6281200000004", "Bambang")
+		require.NoError(t, repo.Delete(deleted.ID))
+		siti.Status = models.StatusDisabled
+		require.NoError(t, repo.Update(siti, ColumnStatus))
+
+		ids := func(page *models.UserPage) []int {
+			ids := []int{}
+			for _, user := range page.Users {
+				ids = append(ids, user.ID)
+			}
+			return ids
+		}
+
+		page, err := repo.List(models.UserListParams{Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []int{budi.ID, bunga.ID}, ids(page))
+		assert.Equal(t, 3, page.Total)
+		assert.False(t, page.TotalIsEstimate)
+		require.NotEmpty(t, page.NextCursor)
+
+		page, err = repo.List(models.UserListParams{Limit: 2, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, []int{siti.ID}, ids(page))
+		assert.Empty(t, page.NextCursor)
+
+		page, err = repo.List(models.UserListParams{NamePrefix: "BU"})
+		require.NoError(t, err)
+		assert.Equal(t, []int{budi.ID, bunga.ID}, ids(page))
+
+		page, err = repo.List(models.UserListParams{PhonePrefix: "+62812", Status: models.StatusActive})
+		require.NoError(t, err)
+		assert.Equal(t, []int{budi.ID}, ids(page))
+		assert.Equal(t, 1, page.Total)
+
+		_, err = repo.List(models.UserListParams{Cursor: "not a cursor"})
+		assert.Equal(t, ErrInvalidCursor, err)
+	})
+}
@@ -1,237 +1,73 @@
 package repository
 
 import (
-	"errors"
 	"testing"
+	"time"
 
 	"github.com/SawitProRecruitment/UserService/models"
-	"github.com/SawitProRecruitment/UserService/repository/mocks"
 	"github.com/stretchr/testify/assert"
 )
 
-type TestingT interface {
-	Errorf(format string, args ...interface{})
-}
-
-func TestPgUserRepository_Create(t *testing.T) {
-	type args struct {
-		user *models.User
+func TestUserCursor(t *testing.T) {
+	user := &models.User{
+		ID:        42,
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.FixedZone("WIB", 7*60*60)),
 	}
-	tests := []struct {
-		name    string
-		args    args
-		want    *models.User
-		wantErr bool
-	}{
-		{
-			name: "Success Create",
-			args: args{
-				&models.User{
-					PhoneNumber: "+6281234567890",
-					Fullname:    "John Doe",
-					Password:    "A1234*",
-				},
-			},
-			wantErr: false,
-		},
-		{
-			name: "Fail Create",
-			args: args{
-				&models.User{},
-			},
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			repo := &mocks.UserRepository{}
-			if !tt.wantErr {
-				repo.On("Create", tt.args.user).Return(nil)
-			} else {
-				repo.On("Create", tt.args.user).Return(errors.New("Fail create user"))
-			}
 
-			err := repo.Create(tt.args.user)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("PgUserRepository.Create() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-		})
-	}
-}
+	createdAt, id, err := decodeUserCursor(encodeUserCursor(user))
+	assert.NoError(t, err)
+	assert.Equal(t, 42, id)
+	assert.True(t, user.CreatedAt.Equal(createdAt))
 
-func TestPgUserRepository_FindByID(t *testing.T) {
-	type args struct {
-		id int
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    *models.User
-		wantErr bool
-	}{
-		{
-			name: "Success FindByID",
-			args: args{
-				1,
-			},
-			want: &models.User{
-				ID:          1,
-				PhoneNumber: "+6281234567890",
-				Fullname:    "John Doe",
-				Password:    "A1234*",
-				SaltToken:   "1234",
-			},
-			wantErr: false,
-		},
-		{
-			name: "Success FindByID",
-			args: args{
-				100,
-			},
-			want:    nil,
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			repo := &mocks.UserRepository{}
-			if !tt.wantErr {
-				repo.On("FindByID", tt.args.id).Return(&models.User{
-					ID:          1,
-					PhoneNumber: "+6281234567890",
-					Fullname:    "John Doe",
-					Password:    "A1234*",
-					SaltToken:   "1234",
-				}, nil)
-			} else {
-				repo.On("FindByID", tt.args.id).Return(nil, errors.New("Record Not Found"))
-			}
-
-			user, err := repo.FindByID(tt.args.id)
-			if !assert.Equal(t, tt.want, user) {
-				t.Errorf("PgUserRepository.FindByID() = %v, want %v", user, tt.want)
-				return
-			}
-			if (err != nil) != tt.wantErr {
-				t.Errorf("PgUserRepository.FindByID() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-		})
+	for _, cursor := range []string{"", "not base64!", "bm8tY29tbWE", "eCwx", "MjAyNC0wMS0wMlQwMzowNDowNVoseA"} {
+		_, _, err := decodeUserCursor(cursor)
+		assert.Equal(t, ErrInvalidCursor, err, cursor)
 	}
 }
 
-func TestPgUserRepository_Update(t *testing.T) {
-	type args struct {
-		user *models.User
-	}
+func TestUserListFilter(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
 	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
+		name      string
+		params    models.UserListParams
+		wantWhere string
+		wantArgs  []interface{}
 	}{
 		{
-			name: "Success Update",
-			args: args{
-				&models.User{
-					ID:          1,
-					PhoneNumber: "+6281234567890",
-					Fullname:    "John Doe Update",
-					Password:    "A1234*",
-					SaltToken:   "1234",
-				},
-			},
-			wantErr: false,
+			name:      "No Filter",
+			params:    models.UserListParams{},
+			wantWhere: "deleted_at IS NULL",
+			wantArgs:  []interface{}{},
 		},
 		{
-			name: "Fail Update",
-			args: args{
-				&models.User{
-					ID:          1,
-					PhoneNumber: "",
-					Fullname:    "John Doe Update",
-					Password:    "A1234*",
-					SaltToken:   "1234",
-				},
+			name: "Every Filter",
+			params: models.UserListParams{
+				NamePrefix:  "John_",
+				PhonePrefix: "+62812",
+				Status:      models.StatusActive,
+				CreatedFrom: &from,
+				CreatedTo:   &to,
 			},
-			wantErr: true,
+			wantWhere: "deleted_at IS NULL AND lower(fullname) LIKE ? AND phone_number LIKE ? AND status = ? AND created_at >= ? AND created_at < ?",
+			wantArgs:  []interface{}{`john\_%`, "+62812%", models.StatusActive, from, to},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := &mocks.UserRepository{}
-			if !tt.wantErr {
-				repo.On("Update", tt.args.user).Return(nil)
-			} else {
-				repo.On("Update", tt.args.user).Return(errors.New("Fail To Update"))
-			}
-
-			err := repo.Update(tt.args.user)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("PgUserRepository.Update() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
+			where, args := userListFilter(tt.params)
+			assert.Equal(t, tt.wantWhere, where)
+			assert.Equal(t, tt.wantArgs, args)
 		})
 	}
 }
 
-func TestPgUserRepository_FindByPhone(t *testing.T) {
-	type args struct {
-		phone string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    *models.User
-		wantErr bool
-	}{
-		{
-			name: "Success FindByPhone",
-			args: args{
-				"+6281234567890",
-			},
-			want: &models.User{
-				ID:          1,
-				PhoneNumber: "+6281234567890",
-				Fullname:    "John Doe",
-				Password:    "A1234*",
-				SaltToken:   "1234",
-			},
-			wantErr: false,
-		},
-		{
-			name: "Success FindByPhone",
-			args: args{
-				"+62812345678111",
-			},
-			want:    nil,
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			repo := &mocks.UserRepository{}
-			if !tt.wantErr {
-				repo.On("FindByPhone", tt.args.phone).Return(&models.User{
-					ID:          1,
-					PhoneNumber: "+6281234567890",
-					Fullname:    "John Doe",
-					Password:    "A1234*",
-					SaltToken:   "1234",
-				}, nil)
-			} else {
-				repo.On("FindByPhone", tt.args.phone).Return(nil, errors.New("Fail Find By Phone"))
-			}
+func TestPlanRows(t *testing.T) {
+	plan := `[{"Plan": {"Node Type": "Seq Scan", "Relation Name": "users", "Plan Rows": 1250000, "Plan Width": 0}}]`
+	rows, err := planRows(plan)
+	assert.NoError(t, err)
+	assert.Equal(t, 1250000, rows)
 
-			user, err := repo.FindByPhone(tt.args.phone)
-			if !assert.Equal(t, tt.want, user) {
-				t.Errorf("PgUserRepository.FindByPhone() = %v, want %v", user, tt.want)
-				return
-			}
-			if (err != nil) != tt.wantErr {
-				t.Errorf("PgUserRepository.FindByPhone() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-		})
-	}
+	_, err = planRows(`[]`)
+	assert.Error(t, err)
 }
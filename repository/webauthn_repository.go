@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/jackc/pgerrcode"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// ErrCredentialTaken is returned when a passkey with the credential id is already registered
+var ErrCredentialTaken = errors.New("passkey already registered")
+
+// webAuthnCredentialKey is the unique index of the passkey credential ids
+const webAuthnCredentialKey = "webauthn_credentials_credential_id_key"
+
+type PgWebAuthnRepository struct {
+	DB *gorm.DB
+}
+
+// WebAuthnRepository is an interface for passkey credentials and ceremony repository
+type WebAuthnRepository interface {
+	CreateCredential(credential *models.WebAuthnCredential) error
+	ListCredentialsByUser(userID int) ([]models.WebAuthnCredential, error)
+	UpdateCredentialUsage(id int, signCount int64, backupState bool, usedAt time.Time) error
+	DeleteCredential(id int, userID int) error
+	CreateCeremony(ceremony *models.WebAuthnCeremony) error
+	TakeCeremony(id string, now time.Time) (*models.WebAuthnCeremony, error)
+}
+
+// CreateCredential stores a new passkey, it fails with ErrCredentialTaken
+// when the credential id is already registered
+func (r *PgWebAuthnRepository) CreateCredential(credential *models.WebAuthnCredential) error {
+	err := r.DB.Create(credential).Error
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pgerrcode.UniqueViolation && pqErr.Constraint == webAuthnCredentialKey {
+		return ErrCredentialTaken
+	}
+	return err
+}
+
+// ListCredentialsByUser lists the passkeys of a user, oldest first
+func (r *PgWebAuthnRepository) ListCredentialsByUser(userID int) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := r.DB.Where("user_id = ?", userID).Order("id").Find(&credentials).Error
+	if err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// UpdateCredentialUsage records a login with the passkey
+func (r *PgWebAuthnRepository) UpdateCredentialUsage(id int, signCount int64, backupState bool, usedAt time.Time) error {
+	return r.DB.Model(&models.WebAuthnCredential{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"sign_count":   signCount,
+		"backup_state": backupState,
+		"last_used_at": usedAt,
+	}).Error
+}
+
+// DeleteCredential deletes a passkey of the user, it fails with record not
+// found when the passkey does not belong to the user
+func (r *PgWebAuthnRepository) DeleteCredential(id int, userID int) error {
+	result := r.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CreateCeremony stores the state of a new ceremony, abandoned ceremonies
+// that already expired are cleaned up along the way
+func (r *PgWebAuthnRepository) CreateCeremony(ceremony *models.WebAuthnCeremony) error {
+	if err := r.DB.Where("expires_at < ?", time.Now()).Delete(&models.WebAuthnCeremony{}).Error; err != nil {
+		return err
+	}
+	return r.DB.Create(ceremony).Error
+}
+
+// TakeCeremony deletes and returns an unexpired ceremony, it fails with record
+// not found when the ceremony does not exist, expired or was already taken
+func (r *PgWebAuthnRepository) TakeCeremony(id string, now time.Time) (*models.WebAuthnCeremony, error) {
+	var ceremony models.WebAuthnCeremony
+	err := r.DB.Where("id = ? AND expires_at > ?", id, now).First(&ceremony).Error
+	if err != nil {
+		return nil, err
+	}
+	// only the request that deletes the row gets to use it
+	result := r.DB.Where("id = ?", id).Delete(&models.WebAuthnCeremony{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &ceremony, nil
+}
+
+// NewPgWebAuthnRepository creates new postgress webauthn repository
+func NewPgWebAuthnRepository(db *gorm.DB) *PgWebAuthnRepository {
+	return &PgWebAuthnRepository{DB: db}
+}
@@ -0,0 +1,73 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+)
+
+// ParseRSAPrivateKey parses a PEM encoded PKCS#1 or PKCS#8 RSA private key
+func ParseRSAPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+	return rsaKey, nil
+}
+
+// GenerateRSAPrivateKey generates a 2048 bit RSA key
+func GenerateRSAPrivateKey() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, 2048)
+}
+
+// RSAPublicKeyParams returns the base64url encoded modulus and exponent of the
+// key as published in a JSON Web Key
+func RSAPublicKeyParams(key *rsa.PublicKey) (n, e string) {
+	n = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+	e = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	return n, e
+}
+
+// RSAKeyID is the RFC 7638 thumbprint of the key, it changes whenever the key does
+func RSAKeyID(key *rsa.PublicKey) string {
+	n, e := RSAPublicKeyParams(key)
+	// the thumbprint covers the required members in lexicographic order without whitespace
+	sum := sha256.Sum256([]byte(`{"e":"` + e + `","kty":"RSA","n":"` + n + `"}`))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ParseRSAPublicKeyParams builds the public key from the base64url encoded
+// modulus and exponent of a JSON Web Key
+func ParseRSAPublicKeyParams(n, e string) (*rsa.PublicKey, error) {
+	modulus, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	exponent, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	if len(modulus) == 0 || len(exponent) == 0 || len(exponent) > 4 {
+		return nil, errors.New("invalid RSA public key")
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(modulus),
+		E: int(new(big.Int).SetBytes(exponent).Int64()),
+	}, nil
+}
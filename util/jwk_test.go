@@ -0,0 +1,87 @@
+package util
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+)
+
+// rfc7638Modulus is the modulus of the example key of RFC 7638 section 3.1
+const rfc7638Modulus = "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"
+
+func TestRSAKeyID(t *testing.T) {
+	modulus, err := base64.RawURLEncoding.DecodeString(rfc7638Modulus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: 65537}
+
+	want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"
+	if got := RSAKeyID(key); got != want {
+		t.Errorf("RSAKeyID() = %v, want %v", got, want)
+	}
+	if n, e := RSAPublicKeyParams(key); n != rfc7638Modulus || e != "AQAB" {
+		t.Errorf("RSAPublicKeyParams() = %v, %v, want the RFC 7638 modulus and AQAB", n, e)
+	}
+}
+
+func TestParseRSAPrivateKey(t *testing.T) {
+	key, err := GenerateRSAPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pem     []byte
+		wantErr bool
+	}{
+		{name: "PKCS1 Key", pem: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})},
+		{name: "PKCS8 Key", pem: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})},
+		{name: "Not PEM", pem: []byte("secret"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRSAPrivateKey(tt.pem)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRSAPrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !got.Equal(key) {
+				t.Errorf("ParseRSAPrivateKey() returned another key")
+			}
+		})
+	}
+}
+
+func TestParseRSAPublicKeyParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       string
+		e       string
+		wantErr bool
+	}{
+		{name: "RFC 7638 Key", n: rfc7638Modulus, e: "AQAB"},
+		{name: "Empty Exponent", n: rfc7638Modulus, e: "", wantErr: true},
+		{name: "Not Base64url", n: "not base64!", e: "AQAB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRSAPublicKeyParams(tt.n, tt.e)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRSAPublicKeyParams() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && RSAKeyID(got) != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+				t.Errorf("ParseRSAPublicKeyParams() returned another key")
+			}
+		})
+	}
+}
@@ -1,28 +1,137 @@
 package util
 
 import (
-	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// DefaultPasswordSymbols are the printable ascii symbols
+const DefaultPasswordSymbols = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// Password character classes a policy can require
+const (
+	PasswordUpper  = "upper"
+	PasswordLower  = "lower"
+	PasswordNumber = "number"
+	PasswordSymbol = "symbol"
+)
+
+// PasswordPolicy are the rules new passwords must follow
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// Require lists the character classes a password needs at least one of
+	Require []string
+	// Symbols are the only non alphanumeric characters allowed
+	Symbols string
+	// MaxRepeated is how often a character may follow itself, 0 allows any run
+	MaxRepeated int
+	// History is how many previous passwords can not be used again, 0 allows reuse
+	History int
+}
+
+// PasswordViolation is a rule of the policy a password breaks
+type PasswordViolation struct {
+	Rule    string
+	Message string
+	// Template is the message before {0}, {1} and so on are replaced with
+	// Params, it is the key translations of the message are found by
+	Template string
+	Params   []string
+}
+
+// newPasswordViolation builds the violation of a rule and its message
+func newPasswordViolation(rule, template string, params ...string) PasswordViolation {
+	message := template
+	for i, param := range params {
+		message = strings.ReplaceAll(message, "{"+strconv.Itoa(i)+"}", param)
+	}
+	return PasswordViolation{Rule: rule, Message: message, Template: template, Params: params}
+}
+
+// DefaultPasswordPolicy is the policy used when none is configured
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength: 6,
+		MaxLength: 64,
+		Require:   []string{PasswordUpper, PasswordNumber, PasswordSymbol},
+		Symbols:   DefaultPasswordSymbols,
+		History:   1,
+	}
+}
+
+// ValidatePassword checks the password against the default policy
 func ValidatePassword(password string) bool {
-	if len(password) < 6 || len(password) > 64 {
-		return false
+	return len(DefaultPasswordPolicy().Validate(password)) == 0
+}
+
+// Validate returns every rule of the policy the password breaks
+func (p PasswordPolicy) Validate(password string) []PasswordViolation {
+	var violations []PasswordViolation
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, newPasswordViolation("min_length", "password must be at least {0} characters", strconv.Itoa(p.MinLength)))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, newPasswordViolation("max_length", "password must be at most {0} characters", strconv.Itoa(p.MaxLength)))
 	}
-	var (
-		upperCase = regexp.MustCompile(`[A-Z]`)
-		number    = regexp.MustCompile(`[0-9]`)
-		special   = regexp.MustCompile(`[@$!%*?&]`)
-	)
 
-	if !upperCase.MatchString(password) {
-		return false
+	var hasUpper, hasLower, hasNumber, hasSymbol bool
+	var disallowed []rune
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasNumber = true
+		case strings.ContainsRune(p.Symbols, r):
+			hasSymbol = true
+		case !unicode.IsLetter(r) && !strings.ContainsRune(string(disallowed), r):
+			disallowed = append(disallowed, r)
+		}
+	}
+	classes := map[string]struct {
+		present bool
+		name    string
+	}{
+		PasswordUpper:  {hasUpper, "uppercase letter"},
+		PasswordLower:  {hasLower, "lowercase letter"},
+		PasswordNumber: {hasNumber, "number"},
+		PasswordSymbol: {hasSymbol, "symbol"},
+	}
+	for _, class := range p.Require {
+		if required, ok := classes[class]; ok && !required.present {
+			violations = append(violations, newPasswordViolation(class, "password must contain at least 1 "+required.name))
+		}
+	}
+	if len(disallowed) > 0 {
+		violations = append(violations, newPasswordViolation("symbols", "password must not contain {0}, the allowed symbols are {1}", strconv.Quote(string(disallowed)), p.Symbols))
 	}
 
-	if !number.MatchString(password) {
-		return false
+	if p.MaxRepeated > 0 && longestRun(password) > p.MaxRepeated {
+		violations = append(violations, newPasswordViolation("repeated", "password must not repeat a character more than {0} times in a row", strconv.Itoa(p.MaxRepeated)))
 	}
-	if !special.MatchString(password) {
-		return false
+	return violations
+}
+
+// longestRun is the length of the longest run of one character
+func longestRun(password string) int {
+	longest, run := 0, 0
+	var previous rune
+	for i, r := range []rune(password) {
+		if i > 0 && r == previous {
+			run++
+		} else {
+			run = 1
+		}
+		previous = r
+		if run > longest {
+			longest = run
+		}
 	}
-	return true
+	return longest
 }
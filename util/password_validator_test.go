@@ -1,6 +1,10 @@
 package util
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func TestValidatePassword(t *testing.T) {
 	type args struct {
@@ -18,6 +22,13 @@ func TestValidatePassword(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "Valid Password, any printable symbol",
+			args: args{
+				password: "Password1#",
+			},
+			want: true,
+		},
 		{
 			name: "Not Valid Password, need at least 1 uppercase, 1 number, and 1 special character",
 			args: args{
@@ -62,3 +73,57 @@ func TestValidatePassword(t *testing.T) {
 		})
 	}
 }
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:   8,
+		MaxLength:   16,
+		Require:     []string{PasswordUpper, PasswordLower, PasswordNumber, PasswordSymbol},
+		Symbols:     "#-",
+		MaxRepeated: 2,
+	}
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{name: "Valid Password", password: "Kebun#Sawit1", want: nil},
+		{name: "Another Allowed Symbol", password: "Kebun-Sawit1", want: nil},
+		{name: "Too Short", password: "Ke#1b", want: []string{"min_length"}},
+		{name: "Too Long", password: "Kebun#Sawit1-Riau88", want: []string{"max_length"}},
+		{name: "Missing Character Classes", password: "kebunsawit", want: []string{PasswordUpper, PasswordNumber, PasswordSymbol}},
+		{name: "Symbol Not Allowed", password: "Kebun#Sawit1!", want: []string{"symbols"}},
+		{name: "Space Not Allowed", password: "Kebun Sawit#1", want: []string{"symbols"}},
+		{name: "Repeated Character", password: "Kebun#Saaawit1", want: []string{"repeated"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, violation := range policy.Validate(tt.password) {
+				got = append(got, violation.Rule)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPasswordPolicy_ValidateMessage(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, Symbols: "#"}
+
+	violations := policy.Validate("Sawit!1")
+
+	assert.Equal(t, []PasswordViolation{
+		{
+			Rule:     "min_length",
+			Message:  "password must be at least 8 characters",
+			Template: "password must be at least {0} characters",
+			Params:   []string{"8"},
+		},
+		{
+			Rule:     "symbols",
+			Message:  `password must not contain "!", the allowed symbols are #`,
+			Template: "password must not contain {0}, the allowed symbols are {1}",
+			Params:   []string{`"!"`, "#"},
+		},
+	}, violations)
+}
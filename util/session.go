@@ -0,0 +1,68 @@
+package util
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+)
+
+// GenerateSessionID generates a random 32 character session id
+func GenerateSessionID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// DeviceLabel derives a human readable device label such as "Chrome on Android" from a user agent
+func DeviceLabel(userAgent string) string {
+	var os, browser string
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		os = "Android"
+	case strings.Contains(userAgent, "iPhone"):
+		os = "iPhone"
+	case strings.Contains(userAgent, "iPad"):
+		os = "iPad"
+	case strings.Contains(userAgent, "Windows"):
+		os = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"):
+		os = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		os = "Linux"
+	}
+
+	// order matters, Edge and Chrome user agents also mention Safari
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	switch {
+	case os != "" && browser != "":
+		return browser + " on " + os
+	case os != "":
+		return os
+	case browser != "":
+		return browser
+	}
+	return "Unknown device"
+}
+
+// Truncate keeps the first n characters of s, it never splits a multi-byte
+// character and replaces invalid UTF-8 so the result can always be stored
+func Truncate(s string, n int) string {
+	var b strings.Builder
+	for i := 0; i < n && s != ""; i++ {
+		r, size := utf8.DecodeRuneInString(s)
+		b.WriteRune(r)
+		s = s[size:]
+	}
+	return b.String()
+}
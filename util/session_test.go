@@ -0,0 +1,101 @@
+package util
+
+import "testing"
+
+func TestGenerateSessionID(t *testing.T) {
+	first := GenerateSessionID()
+	second := GenerateSessionID()
+	if len(first) != 32 {
+		t.Errorf("GenerateSessionID() length = %v, want 32", len(first))
+	}
+	if first == second {
+		t.Errorf("GenerateSessionID() should not return the same id twice")
+	}
+}
+
+func TestDeviceLabel(t *testing.T) {
+	type args struct {
+		userAgent string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "Chrome On Android",
+			args: args{
+				userAgent: "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			},
+			want: "Chrome on Android",
+		},
+		{
+			name: "Safari On iPhone",
+			args: args{
+				userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			},
+			want: "Safari on iPhone",
+		},
+		{
+			name: "Edge On Windows",
+			args: args{
+				userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+			},
+			want: "Edge on Windows",
+		},
+		{
+			name: "Unknown Device",
+			args: args{
+				userAgent: "curl/8.4.0",
+			},
+			want: "Unknown device",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeviceLabel(tt.args.userAgent); got != tt.want {
+				t.Errorf("DeviceLabel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	type args struct {
+		s string
+		n int
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "Shorter",
+			args: args{s: "curl/8.4.0", n: 60},
+			want: "curl/8.4.0",
+		},
+		{
+			name: "Longer",
+			args: args{s: "curl/8.4.0", n: 4},
+			want: "curl",
+		},
+		{
+			name: "Multi-byte Characters",
+			args: args{s: "Ponsel Budi 📱 baru", n: 13},
+			want: "Ponsel Budi 📱",
+		},
+		{
+			name: "Invalid UTF-8",
+			args: args{s: "Budi\xff\xfe", n: 5},
+			want: "Budi\uFFFD",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Truncate(tt.args.s, tt.args.n); got != tt.want {
+				t.Errorf("Truncate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 key of the RFC 6238 test vectors
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	type args struct {
+		unix int64
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		// RFC 6238 appendix B lists 8 digit codes, the 6 digit codes are their last 6 digits
+		{name: "RFC 6238 Vector 59", args: args{59}, want: "287082"},
+		{name: "RFC 6238 Vector 1111111109", args: args{1111111109}, want: "081804"},
+		{name: "RFC 6238 Vector 1111111111", args: args{1111111111}, want: "050471"},
+		{name: "RFC 6238 Vector 1234567890", args: args{1234567890}, want: "005924"},
+		{name: "RFC 6238 Vector 2000000000", args: args{2000000000}, want: "279037"},
+		{name: "RFC 6238 Vector 20000000000", args: args{20000000000}, want: "353130"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TOTPCode(rfc6238Secret, time.Unix(tt.args.unix, 0))
+			if err != nil {
+				t.Errorf("TOTPCode() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("TOTPCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	previous, _ := TOTPCode(rfc6238Secret, now.Add(-30*time.Second))
+	tooOld, _ := TOTPCode(rfc6238Secret, now.Add(-90*time.Second))
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOk   bool
+	}{
+		{name: "Current Code", code: "081804", wantStep: 1111111109 / 30, wantOk: true},
+		{name: "Previous Period Code", code: previous, wantStep: 1111111109/30 - 1, wantOk: true},
+		{name: "Expired Code", code: tooOld, wantOk: false},
+		{name: "Wrong Code", code: "000000", wantOk: false},
+		{name: "Wrong Length", code: "81804", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfc6238Secret, tt.code, now)
+			if ok != tt.wantOk || (ok && step != tt.wantStep) {
+				t.Errorf("ValidateTOTP() = %v, %v, want %v, %v", step, ok, tt.wantStep, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret := GenerateTOTPSecret()
+	if len(secret) != 32 {
+		t.Errorf("GenerateTOTPSecret() length = %v, want 32", len(secret))
+	}
+	if _, err := TOTPCode(secret, time.Now()); err != nil {
+		t.Errorf("GenerateTOTPSecret() is not a valid secret: %v", err)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	got := TOTPURI("SawitPro", "+6281234567890", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/SawitPro:+6281234567890?algorithm=SHA1&digits=6&issuer=SawitPro&period=30&secret=JBSWY3DPEHPK3PXP"
+	if got != want {
+		t.Errorf("TOTPURI() = %v, want %v", got, want)
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	code := GenerateRecoveryCode()
+	if len(code) != 11 || code[5] != '-' {
+		t.Errorf("GenerateRecoveryCode() = %v, want XXXXX-XXXXX", code)
+	}
+	if HashRecoveryCode(code) != HashRecoveryCode(strings.ToLower(strings.ReplaceAll(code, "-", ""))) {
+		t.Errorf("HashRecoveryCode() should ignore dashes and case")
+	}
+	if HashRecoveryCode(code) == HashRecoveryCode(GenerateRecoveryCode()) {
+		t.Errorf("HashRecoveryCode() should differ between codes")
+	}
+}
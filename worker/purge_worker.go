@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// PurgeWorker anonymizes deleted accounts once their grace period is over
+type PurgeWorker struct {
+	UserRepo    repository.UserRepository
+	AuditRepo   repository.AuditRepository
+	SessionRepo repository.SessionRepository
+	Interval    time.Duration
+	BatchSize   int
+}
+
+// NewPurgeWorker creates new purge worker
+func NewPurgeWorker(userRepo repository.UserRepository, auditRepo repository.AuditRepository, sessionRepo repository.SessionRepository, interval time.Duration) *PurgeWorker {
+	return &PurgeWorker{
+		UserRepo:    userRepo,
+		AuditRepo:   auditRepo,
+		SessionRepo: sessionRepo,
+		Interval:    interval,
+		BatchSize:   100,
+	}
+}
+
+// Run purges due accounts every interval until the context is canceled
+func (w *PurgeWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := w.PurgeDue(time.Now())
+		if err != nil {
+			log.Printf("purge worker: %v", err)
+		}
+		if purged > 0 {
+			log.Printf("purge worker: anonymized %d accounts", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeDue anonymizes every account whose purge time is before now and returns
+// how many accounts were anonymized, it stops at the first error
+func (w *PurgeWorker) PurgeDue(now time.Time) (int, error) {
+	purged := 0
+	for {
+		users, err := w.UserRepo.FindPurgeable(now, w.BatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for _, user := range users {
+			// audit events and sessions hold ip addresses and user agents, they go along with the profile
+			if err := w.AuditRepo.DeleteByUser(user.ID); err != nil {
+				return purged, err
+			}
+			if err := w.SessionRepo.DeleteByUser(user.ID); err != nil {
+				return purged, err
+			}
+			if err := w.UserRepo.Anonymize(user.ID); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(users) < w.BatchSize {
+			return purged, nil
+		}
+	}
+}
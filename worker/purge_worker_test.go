@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/models"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeWorker_PurgeDue(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		batches [][]models.User
+		findErr error
+		anonErr error
+		want    int
+		wantErr bool
+	}{
+		{
+			name:    "Nothing To Purge",
+			batches: [][]models.User{{}},
+			want:    0,
+			wantErr: false,
+		},
+		{
+			name:    "Purge Multiple Batches",
+			batches: [][]models.User{{{ID: 1}, {ID: 2}}, {{ID: 3}}},
+			want:    3,
+			wantErr: false,
+		},
+		{
+			name:    "Fail Find Purgeable",
+			batches: [][]models.User{nil},
+			findErr: errors.New("connection refused"),
+			want:    0,
+			wantErr: true,
+		},
+		{
+			name:    "Fail Anonymize",
+			batches: [][]models.User{{{ID: 1}, {ID: 2}}},
+			anonErr: errors.New("connection refused"),
+			want:    0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewUserRepository(t)
+			auditRepo := mocks.NewAuditRepository(t)
+			sessionRepo := mocks.NewSessionRepository(t)
+			for _, batch := range tt.batches {
+				repo.On("FindPurgeable", now, 2).Return(batch, tt.findErr).Once()
+				for _, user := range batch {
+					auditRepo.On("DeleteByUser", user.ID).Return(nil).Once()
+					sessionRepo.On("DeleteByUser", user.ID).Return(nil).Once()
+					repo.On("Anonymize", user.ID).Return(tt.anonErr).Once()
+					if tt.anonErr != nil {
+						break
+					}
+				}
+			}
+
+			w := NewPurgeWorker(repo, auditRepo, sessionRepo, time.Minute)
+			w.BatchSize = 2
+			got, err := w.PurgeDue(now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PurgeWorker.PurgeDue() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}